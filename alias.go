@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// expandUserAlias replaces the first argument with the expansion of the
+// user alias of that name, see [Command.UserAliases]. Expansions starting
+// with another alias are expanded again, commands of the command take
+// precedence over aliases of the same name.
+func (cmd *Command) expandUserAlias(args []string) ([]string, error) {
+	var seen []string
+	for len(args) > 0 {
+		name := args[0]
+		expansion, ok := cmd.UserAliases[name]
+		if !ok || len(expansion) == 0 {
+			break
+		}
+		if subCmd, _ := cmd.lookupCommand(name); subCmd != nil {
+			break
+		}
+
+		for _, s := range seen {
+			if s == name {
+				return nil, fmt.Errorf("alias cycle: %s -> %s", strings.Join(seen, " -> "), name)
+			}
+		}
+		seen = append(seen, name)
+
+		tracef("expanding alias %[1]q to %[2]q (cmd=%[3]q)", name, expansion, cmd.Name)
+		args = append(append([]string{}, expansion...), args[1:]...)
+	}
+
+	return args, nil
+}
+
+// LoadUserAliases reads user aliases from a file with one alias per line in
+// the form "name = command args...", where the arguments are split and
+// quoted like in a response file. Empty lines and lines starting with # are
+// ignored. A missing file results in no aliases.
+func LoadUserAliases(path string) (map[string][]string, error) {
+	aliases := map[string][]string{}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return aliases, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read aliases: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid alias on line %d of %s", lineNum, path)
+		}
+
+		expansion, err := splitResponseFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alias on line %d of %s: %w", lineNum, path, err)
+		}
+		aliases[name] = expansion
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read aliases: %w", err)
+	}
+
+	return aliases, nil
+}
+
+// SaveUserAliases writes user aliases to a file in the format read by
+// [LoadUserAliases]
+func SaveUserAliases(path string, aliases map[string][]string) error {
+	var sb strings.Builder
+	for _, name := range sortedAliasNames(aliases) {
+		fmt.Fprintf(&sb, "%s = %s\n", name, joinAliasArgs(aliases[name]))
+	}
+
+	if err := os.WriteFile(path, []byte(sb.String()), 0o600); err != nil {
+		return fmt.Errorf("could not write aliases: %w", err)
+	}
+
+	return nil
+}
+
+// AliasCommand returns a command for managing the user aliases of the root
+// command, with the subcommands list, set and unset. Changes are saved to
+// the file at path, unless path is empty.
+func AliasCommand(path string) *Command {
+	list := func(_ context.Context, cmd *Command) error {
+		aliases := cmd.Root().UserAliases
+		for _, name := range sortedAliasNames(aliases) {
+			_, _ = fmt.Fprintf(cmd.Root().Writer, "%s = %s\n", name, joinAliasArgs(aliases[name]))
+		}
+		return nil
+	}
+
+	save := func(aliases map[string][]string) error {
+		if path == "" {
+			return nil
+		}
+		return SaveUserAliases(path, aliases)
+	}
+
+	return &Command{
+		Name:   "alias",
+		Usage:  "Manage command aliases",
+		Action: list,
+		Commands: []*Command{
+			{
+				Name:   "list",
+				Usage:  "List all aliases",
+				Action: list,
+			},
+			{
+				Name:            "set",
+				Usage:           "Define an alias",
+				ArgsUsage:       "NAME COMMAND [ARGS...]",
+				SkipFlagParsing: true,
+				Action: func(_ context.Context, cmd *Command) error {
+					if cmd.NArg() < 2 {
+						return Exit("alias set expects a name and a command", 1)
+					}
+
+					root := cmd.Root()
+					name := cmd.Args().First()
+					if subCmd := root.Command(name); subCmd != nil {
+						return Exit(fmt.Sprintf("cannot define alias %q, a command of that name exists", name), 1)
+					}
+
+					if root.UserAliases == nil {
+						root.UserAliases = map[string][]string{}
+					}
+					root.UserAliases[name] = cmd.Args().Tail()
+					if _, err := root.expandUserAlias([]string{name}); err != nil {
+						delete(root.UserAliases, name)
+						return Exit(err.Error(), 1)
+					}
+
+					return save(root.UserAliases)
+				},
+			},
+			{
+				Name:      "unset",
+				Usage:     "Remove an alias",
+				ArgsUsage: "NAME",
+				Action: func(_ context.Context, cmd *Command) error {
+					root := cmd.Root()
+					name := cmd.Args().First()
+					if _, ok := root.UserAliases[name]; !ok {
+						return Exit(fmt.Sprintf("no alias named %q", name), 1)
+					}
+
+					delete(root.UserAliases, name)
+					return save(root.UserAliases)
+				},
+			},
+		},
+	}
+}
+
+func sortedAliasNames(aliases map[string][]string) []string {
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// joinAliasArgs joins arguments so splitResponseFile splits them again
+func joinAliasArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\r\n\"'\\#") {
+			quoted[i] = arg
+			continue
+		}
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+		quoted[i] = `"` + r.Replace(arg) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
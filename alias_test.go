@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_UserAliases(t *testing.T) {
+	var calls []string
+	newCmd := func() *Command {
+		return &Command{
+			Name: "app",
+			UserAliases: map[string][]string{
+				"co":       {"checkout", "--verbose"},
+				"cob":      {"co", "-b"},
+				"loop":     {"loopy"},
+				"loopy":    {"loop"},
+				"checkout": {"bogus"},
+			},
+			Commands: []*Command{
+				{
+					Name: "checkout",
+					Flags: []Flag{
+						&BoolFlag{Name: "verbose"},
+						&BoolFlag{Name: "b"},
+					},
+					Action: func(_ context.Context, cmd *Command) error {
+						calls = append(calls, fmt.Sprintf("verbose=%v b=%v args=%v", cmd.Bool("verbose"), cmd.Bool("b"), cmd.Args().Slice()))
+						return nil
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{name: "alias", args: []string{"app", "co", "main"}, expected: "verbose=true b=false args=[main]"},
+		{name: "nested alias", args: []string{"app", "cob", "topic"}, expected: "verbose=true b=true args=[topic]"},
+		{name: "command shadows alias", args: []string{"app", "checkout", "main"}, expected: "verbose=false b=false args=[main]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls = nil
+			require.NoError(t, newCmd().Run(buildTestContext(t), test.args))
+			assert.Equal(t, []string{test.expected}, calls)
+		})
+	}
+
+	t.Run("cycle", func(t *testing.T) {
+		cmd := newCmd()
+		cmd.ExitErrHandler = func(context.Context, *Command, error) {}
+		err := cmd.Run(buildTestContext(t), []string{"app", "loop"})
+		assert.ErrorContains(t, err, "alias cycle: loop -> loopy -> loop")
+	})
+}
+
+func TestLoadUserAliases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases")
+
+	aliases, err := LoadUserAliases(path)
+	require.NoError(t, err)
+	assert.Empty(t, aliases)
+
+	require.NoError(t, os.WriteFile(path, []byte("# my aliases\n\nco = checkout --verbose\nmsg = commit -m \"quick fix\"\n"), 0o600))
+	aliases, err = LoadUserAliases(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"co":  {"checkout", "--verbose"},
+		"msg": {"commit", "-m", "quick fix"},
+	}, aliases)
+
+	aliases["q"] = []string{"say", `it's "done"`, ""}
+	require.NoError(t, SaveUserAliases(path, aliases))
+	loaded, err := LoadUserAliases(path)
+	require.NoError(t, err)
+	assert.Equal(t, aliases, loaded)
+
+	require.NoError(t, os.WriteFile(path, []byte("co checkout\n"), 0o600))
+	_, err = LoadUserAliases(path)
+	assert.ErrorContains(t, err, "invalid alias on line 1")
+}
+
+func TestAliasCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases")
+	var out bytes.Buffer
+
+	newCmd := func() *Command {
+		aliases, err := LoadUserAliases(path)
+		require.NoError(t, err)
+		return &Command{
+			Name:           "app",
+			Writer:         &out,
+			UserAliases:    aliases,
+			ExitErrHandler: func(context.Context, *Command, error) {},
+			Commands: []*Command{
+				{Name: "checkout"},
+				AliasCommand(path),
+			},
+		}
+	}
+
+	require.NoError(t, newCmd().Run(buildTestContext(t), []string{"app", "alias", "set", "co", "checkout", "--verbose"}))
+	require.NoError(t, newCmd().Run(buildTestContext(t), []string{"app", "alias", "set", "st", "status"}))
+
+	out.Reset()
+	require.NoError(t, newCmd().Run(buildTestContext(t), []string{"app", "alias"}))
+	assert.Equal(t, "co = checkout --verbose\nst = status\n", out.String())
+
+	require.NoError(t, newCmd().Run(buildTestContext(t), []string{"app", "alias", "unset", "st"}))
+	out.Reset()
+	require.NoError(t, newCmd().Run(buildTestContext(t), []string{"app", "alias", "list"}))
+	assert.Equal(t, "co = checkout --verbose\n", out.String())
+
+	err := newCmd().Run(buildTestContext(t), []string{"app", "alias", "set", "checkout", "status"})
+	assert.ErrorContains(t, err, `cannot define alias "checkout"`)
+
+	err = newCmd().Run(buildTestContext(t), []string{"app", "alias", "set", "x", "x"})
+	assert.ErrorContains(t, err, "alias cycle: x -> x")
+
+	err = newCmd().Run(buildTestContext(t), []string{"app", "alias", "unset", "nope"})
+	assert.ErrorContains(t, err, `no alias named "nope"`)
+}
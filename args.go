@@ -73,15 +73,23 @@ var AnyArguments = []Argument{
 	},
 }
 
+// argumentValue is implemented by arguments which can be looked up by name
+// after parsing, see [Command.Arg]
+type argumentValue interface {
+	argName() string
+	argValue() any
+}
+
 type ArgumentBase[T any, C any, VC ValueCreator[T, C]] struct {
-	Name        string `json:"name"`      // the name of this argument
-	Value       T      `json:"value"`     // the default value of this argument
-	Destination *T     `json:"-"`         // the destination point for this argument
-	Values      *[]T   `json:"-"`         // all the values of this argument, only if multiple are supported
-	UsageText   string `json:"usageText"` // the usage text to show
-	Min         int    `json:"minTimes"`  // the min num of occurrences of this argument
-	Max         int    `json:"maxTimes"`  // the max num of occurrences of this argument, set to -1 for unlimited
-	Config      C      `json:"config"`    // config for this argument similar to Flag Config
+	Name        string        `json:"name"`      // the name of this argument
+	Value       T             `json:"value"`     // the default value of this argument
+	Destination *T            `json:"-"`         // the destination point for this argument
+	Values      *[]T          `json:"-"`         // all the values of this argument, only if multiple are supported
+	UsageText   string        `json:"usageText"` // the usage text to show
+	Min         int           `json:"minTimes"`  // the min num of occurrences of this argument
+	Max         int           `json:"maxTimes"`  // the max num of occurrences of this argument, set to -1 for unlimited
+	Config      C             `json:"config"`    // config for this argument similar to Flag Config
+	Validator   func(T) error `json:"-"`         // custom function to validate each value of this argument
 }
 
 func (a *ArgumentBase[T, C, VC]) Usage() string {
@@ -102,6 +110,26 @@ func (a *ArgumentBase[T, C, VC]) Usage() string {
 	return fmt.Sprintf(usageFormat, a.Name)
 }
 
+func (a *ArgumentBase[T, C, VC]) argName() string {
+	return a.Name
+}
+
+func (a *ArgumentBase[T, C, VC]) argValue() any {
+	var values []T
+	if a.Values != nil {
+		values = *a.Values
+	}
+
+	if a.Max == 1 {
+		if len(values) > 0 {
+			return values[0]
+		}
+		return a.Value
+	}
+
+	return values
+}
+
 func (a *ArgumentBase[T, C, VC]) Parse(s []string) ([]string, error) {
 	tracef("calling arg%[1] parse with args %[2]", &a.Name, s)
 	if a.Max == 0 {
@@ -123,7 +151,13 @@ func (a *ArgumentBase[T, C, VC]) Parse(s []string) ([]string, error) {
 		if err := value.Set(arg); err != nil {
 			return s, err
 		}
-		values = append(values, value.Get().(T))
+		v := value.Get().(T)
+		if a.Validator != nil {
+			if err := a.Validator(v); err != nil {
+				return s, fmt.Errorf("invalid value %q for argument %s: %w", arg, a.Name, err)
+			}
+		}
+		values = append(values, v)
 		count++
 		if count >= a.Max && a.Max > -1 {
 			break
@@ -151,10 +185,11 @@ func (a *ArgumentBase[T, C, VC]) Parse(s []string) ([]string, error) {
 }
 
 type (
-	FloatArg     = ArgumentBase[float64, NoConfig, floatValue]
-	IntArg       = ArgumentBase[int64, IntegerConfig, intValue]
+	FloatArg     = ArgumentBase[float64, NoConfig, floatValue[float64]]
+	IntArg       = ArgumentBase[int64, IntegerConfig, intValue[int64]]
+	PathArg      = ArgumentBase[string, PathConfig, pathValue]
 	StringArg    = ArgumentBase[string, StringConfig, stringValue]
 	StringMapArg = ArgumentBase[map[string]string, StringConfig, StringMap]
 	TimestampArg = ArgumentBase[time.Time, TimestampConfig, timestampValue]
-	UintArg      = ArgumentBase[uint64, IntegerConfig, uintValue]
+	UintArg      = ArgumentBase[uint64, IntegerConfig, uintValue[uint64]]
 )
@@ -1,8 +1,11 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -239,3 +242,73 @@ func TestUnboundedArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestCommandArg(t *testing.T) {
+	cmd := buildMinimalTestCommand()
+	cmd.Commands = []*Command{
+		{
+			Name: "copy",
+			Arguments: []Argument{
+				&StringArg{Name: "src", Min: 1, Max: 1},
+				&IntArg{Name: "count", Max: 1, Value: 3},
+				&StringArg{Name: "dst", Max: -1},
+			},
+			Action: func(_ context.Context, cmd *Command) error {
+				require.Equal(t, "a.txt", cmd.Arg("src"))
+				require.Equal(t, int64(3), cmd.Arg("count"))
+				require.Equal(t, []string{}, cmd.Arg("dst"))
+				require.Nil(t, cmd.Arg("missing"))
+				require.Equal(t, "a.txt", Get[string](cmd, "src"))
+				return nil
+			},
+		},
+	}
+
+	require.NoError(t, cmd.Run(context.Background(), []string{"foo", "copy", "a.txt"}))
+
+	var out bytes.Buffer
+	cmd.Writer = &out
+	require.NoError(t, cmd.Run(context.Background(), []string{"foo", "copy", "--help"}))
+	require.Contains(t, out.String(), " src [src ...] [count] [dst ...]\n")
+}
+
+func TestVariadicArgs(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+
+	var files []string
+	var ports []int64
+	cmd := buildMinimalTestCommand()
+	cmd.Arguments = []Argument{
+		&IntArg{
+			Name:   "port",
+			Min:    1,
+			Max:    2,
+			Values: &ports,
+			Validator: func(v int64) error {
+				if v < 1 || v > 65535 {
+					return errors.New("out of range")
+				}
+				return nil
+			},
+		},
+		&PathArg{
+			Name:   "file",
+			Max:    -1,
+			Values: &files,
+			Config: PathConfig{MustExist: true},
+		},
+	}
+
+	require.NoError(t, cmd.Run(context.Background(), []string{"foo", "80", "443", file, file}))
+	require.Equal(t, []int64{80, 443}, ports)
+	require.Equal(t, []string{file, file}, files)
+	require.Equal(t, "port [port ...] [file ...]", cmd.Arguments[0].Usage()+" "+cmd.Arguments[1].Usage())
+
+	err := cmd.Run(context.Background(), []string{"foo", "80", "70000"})
+	require.EqualError(t, err, `invalid value "70000" for argument port: out of range`)
+
+	err = cmd.Run(context.Background(), []string{"foo", "80", "443", file, filepath.Join(dir, "missing.txt")})
+	require.ErrorContains(t, err, "missing.txt")
+}
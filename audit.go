@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// AuditRecord is the record of running a command written to the AuditWriter
+// and AuditFile of the root command
+type AuditRecord struct {
+	// Time the command was started at
+	Time time.Time `json:"time"`
+	// Name of the user running the command from the USER, LOGNAME or
+	// USERNAME env var. It is set by the caller and can be spoofed, so UID
+	// identifies the user.
+	User string `json:"user,omitempty"`
+	// User ID of the process running the command, -1 on Windows
+	UID int `json:"uid"`
+	// Full name of the command, e.g. app deploy
+	Command string `json:"command"`
+	// Values of the flags which were set, the values of sensitive flags are
+	// REDACTED
+	Flags map[string]string `json:"flags,omitempty"`
+	// Exit code of the command
+	ExitCode int `json:"exitCode"`
+	// Error of the command, if any
+	Error string `json:"error,omitempty"`
+	// How long the command ran
+	Duration time.Duration `json:"duration"`
+}
+
+// writeAudit writes the audit record of running the command to the
+// AuditWriter and AuditFile of the root command. Errors writing the record
+// are written to ErrWriter but do not fail the command.
+func (cmd *Command) writeAudit(start time.Time, err error) {
+	root := cmd.Root()
+	if root.AuditWriter == nil && root.AuditFile == "" {
+		return
+	}
+
+	record := AuditRecord{
+		Time:     start.UTC(),
+		User:     currentUser(),
+		UID:      os.Getuid(),
+		Command:  cmd.FullName(),
+		Flags:    cmd.auditFlags(),
+		Duration: cmd.now().Sub(start),
+	}
+	if err != nil {
+		record.ExitCode, record.Error = exitCodeOf(err), err.Error()
+	}
+
+	line, merr := json.Marshal(record)
+	if merr != nil {
+		_, _ = fmt.Fprintf(root.ErrWriter, "could not write audit record: %v\n", merr)
+		return
+	}
+	line = append(line, '\n')
+
+	if root.AuditWriter != nil {
+		if _, werr := root.AuditWriter.Write(line); werr != nil {
+			_, _ = fmt.Fprintf(root.ErrWriter, "could not write audit record: %v\n", werr)
+		}
+	}
+	if root.AuditFile != "" {
+		if werr := appendFile(root.AuditFile, line); werr != nil {
+			_, _ = fmt.Fprintf(root.ErrWriter, "could not write audit record: %v\n", werr)
+		}
+	}
+}
+
+// auditFlags returns the values of the flags of the command and its parents
+// which were set on the command line or from sources which have been read
+// already, with the values of sensitive flags redacted. Sources are not read
+// for the record.
+func (cmd *Command) auditFlags() map[string]string {
+	flags := map[string]string{}
+	for _, pCmd := range cmd.Lineage() {
+		for _, fl := range pCmd.Flags {
+			names := fl.Names()
+			if len(names) == 0 || fl == HelpFlag || fl == VersionFlag || !pCmd.isSetResolved(names[0]) {
+				continue
+			}
+			if _, ok := flags[names[0]]; ok {
+				continue
+			}
+
+			value := "REDACTED"
+			if sf, ok := fl.(SensitiveFlag); !ok || !sf.IsSensitive() {
+				value = fmt.Sprintf("%v", pCmd.resolvedValue(names[0]))
+			}
+			flags[names[0]] = value
+		}
+	}
+	return flags
+}
+
+// currentUser returns the name of the user from the environment. The name is
+// not looked up by the uid to not link os/user.
+func currentUser() string {
+	for _, env := range []string{"USER", "LOGNAME", "USERNAME"} {
+		if name := os.Getenv(env); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// appendFile appends data to the file at path, which is created if it does
+// not exist
+func appendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
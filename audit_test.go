@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Audit(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "audit.log")
+	t.Setenv("APP_ZONE", "b")
+	t.Setenv("USER", "root")
+
+	newCmd := func(w *bytes.Buffer) *Command {
+		return &Command{
+			Name:           "app",
+			AuditWriter:    w,
+			AuditFile:      file,
+			ExitErrHandler: func(context.Context, *Command, error) {},
+			Flags: []Flag{
+				&StringFlag{Name: "token", Sensitive: true},
+				&StringFlag{Name: "region", Value: "eu"},
+				&IntFlag{Name: "retries", Sources: EnvVars("APP_RETRIES")},
+			},
+			Commands: []*Command{
+				{
+					Name: "deploy",
+					Flags: []Flag{
+						&IntFlag{Name: "replicas"},
+						&StringFlag{Name: "zone", Sources: EnvVars("APP_ZONE")},
+					},
+					Action: func(_ context.Context, cmd *Command) error {
+						if cmd.Int("replicas") > 3 {
+							return Exit("too many replicas", 4)
+						}
+						return nil
+					},
+				},
+			},
+		}
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, newCmd(&out).Run(buildTestContext(t), []string{"app", "--token", "secret", "deploy", "--replicas", "2"}))
+	assert.Error(t, newCmd(&out).Run(buildTestContext(t), []string{"app", "deploy", "--replicas", "5"}))
+	// the zone is not read as the command failed on the retries before
+	t.Setenv("APP_RETRIES", "many")
+	assert.Error(t, newCmd(&out).Run(buildTestContext(t), []string{"app", "deploy", "--replicas", "1"}))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, out.String(), string(data))
+	assert.NotContains(t, out.String(), "secret")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+
+	var records []AuditRecord
+	for _, line := range lines {
+		var record AuditRecord
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		assert.WithinDuration(t, time.Now(), record.Time, time.Minute)
+		assert.Equal(t, "root", record.User)
+		assert.Equal(t, os.Getuid(), record.UID)
+		record.Time, record.User, record.UID, record.Duration, record.Error = time.Time{}, "", 0, 0, ""
+		records = append(records, record)
+	}
+
+	assert.Equal(t, []AuditRecord{
+		{
+			Command: "app deploy",
+			Flags:   map[string]string{"token": "REDACTED", "replicas": "2", "zone": "b"},
+		},
+		{
+			Command:  "app deploy",
+			Flags:    map[string]string{"replicas": "5", "zone": "b"},
+			ExitCode: 4,
+		},
+		{
+			Command:  "app deploy",
+			Flags:    map[string]string{"replicas": "1"},
+			ExitCode: 1,
+		},
+	}, records)
+	assert.Contains(t, lines[1], `"error":"too many replicas"`)
+}
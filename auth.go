@@ -0,0 +1,324 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+const (
+	// defaultAuthContext is the context used if none is selected and
+	// AuthConfig has no DefaultContext
+	defaultAuthContext = "default"
+
+	// keys of the current context and the contexts logged in to in the State
+	authContextKey  = "auth.context"
+	authContextsKey = "auth.contexts"
+)
+
+// ErrNotLoggedIn is returned by Command.Auth if there are no credentials for
+// the context
+var ErrNotLoggedIn = errors.New("not logged in")
+
+// ContextFlag selects the auth context of Command.Auth with --context,
+// overriding the context selected with context use. It is not added to
+// commands automatically.
+var ContextFlag Flag = &StringFlag{
+	Name:  "context",
+	Usage: "`NAME` of the auth context to use",
+}
+
+// Auth is the authentication of a context, e.g. an environment like prod or
+// staging, see Command.Auth
+type Auth struct {
+	// Name of the context
+	Context string `json:"-"`
+	// Token of the context
+	Token string `json:"token"`
+	// Time the token expires at, zero if it does not expire
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// Expired returns whether the token has expired
+func (a *Auth) Expired() bool {
+	return a.expiredAt(time.Now())
+}
+
+func (a *Auth) expiredAt(now time.Time) bool {
+	return !a.Expires.IsZero() && !now.Before(a.Expires)
+}
+
+// AuthFunc sets the Token and Expires of the auth of a context
+type AuthFunc func(ctx context.Context, cmd *Command, auth *Auth) error
+
+// AuthConfig configures the auth contexts of a command, see Command.Auth and
+// AuthCommands. The tokens of the contexts are stored in the Credentials of
+// the root command and the current context in its State.
+type AuthConfig struct {
+	// Login sets the token of the context on login, e.g. with an OAuth flow.
+	// If nil the token is read with a hidden prompt or from the --token flag
+	// of the login command.
+	Login AuthFunc
+	// Refresh sets a new token if the token of the context expired. If nil
+	// an expired token requires a new login.
+	Refresh AuthFunc
+	// WhoAmI returns a description of the user the token belongs to, shown
+	// by the whoami command
+	WhoAmI func(ctx context.Context, cmd *Command, auth *Auth) (string, error)
+	// Context used if none is selected, defaults to default
+	DefaultContext string
+}
+
+// Auth returns the auth of the context selected with the ContextFlag of the
+// command or its parents, the <APP>_CONTEXT env var or context use, in that
+// order, or else of the DefaultContext of the AuthConfig. An expired token is
+// refreshed with the Refresh func. It returns ErrNotLoggedIn if there is no
+// token for the context.
+func (cmd *Command) Auth(ctx context.Context) (*Auth, error) {
+	root := cmd.Root()
+	ac := root.AuthConfig
+	if ac == nil {
+		return nil, fmt.Errorf("%s has no auth", root.Name)
+	}
+
+	name, err := cmd.authContext()
+	if err != nil {
+		return nil, err
+	}
+	auth, err := cmd.readAuth(name)
+	if errors.Is(err, ErrCredentialNotFound) {
+		return nil, fmt.Errorf("%w to context %q, run %s login", ErrNotLoggedIn, name, root.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if auth.expiredAt(cmd.now()) {
+		if ac.Refresh == nil {
+			return nil, fmt.Errorf("%w to context %q, the token expired, run %s login", ErrNotLoggedIn, name, root.Name)
+		}
+		tracef("refreshing token of context %[1]q (cmd=%[2]q)", name, cmd.Name)
+		if err := ac.Refresh(ctx, cmd, auth); err != nil {
+			return nil, fmt.Errorf("cannot refresh token of context %q: %w", name, err)
+		}
+		if err := cmd.writeAuth(auth); err != nil {
+			return nil, err
+		}
+	}
+	return auth, nil
+}
+
+// authContext returns the name of the selected context
+func (cmd *Command) authContext() (string, error) {
+	if ContextFlag != nil {
+		for _, name := range ContextFlag.Names() {
+			if cmd.lookupFlag(name) != nil && cmd.IsSet(name) {
+				return cmd.String(name), nil
+			}
+		}
+	}
+	if name := os.Getenv(cmd.appEnvVar("context")); name != "" {
+		return name, nil
+	}
+
+	name, ok, err := cmd.State().Get(authContextKey)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return name, nil
+	}
+	if dc := cmd.Root().AuthConfig.DefaultContext; dc != "" {
+		return dc, nil
+	}
+	return defaultAuthContext, nil
+}
+
+func authCredentialKey(context string) string {
+	return "auth/" + context
+}
+
+func (cmd *Command) readAuth(name string) (*Auth, error) {
+	data, err := cmd.Credentials().Get(authCredentialKey(name))
+	if err != nil {
+		return nil, err
+	}
+	auth := &Auth{Context: name}
+	if err := json.Unmarshal([]byte(data), auth); err != nil {
+		return nil, fmt.Errorf("invalid credentials of context %q: %w", name, err)
+	}
+	return auth, nil
+}
+
+func (cmd *Command) writeAuth(auth *Auth) error {
+	data, err := json.Marshal(auth)
+	if err != nil {
+		return err
+	}
+	if err := cmd.Credentials().Set(authCredentialKey(auth.Context), string(data)); err != nil {
+		return fmt.Errorf("cannot store credentials: %w", err)
+	}
+	return nil
+}
+
+// authContexts returns the names of the contexts logged in to
+func (cmd *Command) authContexts() ([]string, error) {
+	data, ok, err := cmd.State().Get(authContextsKey)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var names []string
+	if err := json.Unmarshal([]byte(data), &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func (cmd *Command) setAuthContexts(names []string) error {
+	slices.Sort(names)
+	data, err := json.Marshal(slices.Compact(names))
+	if err != nil {
+		return err
+	}
+	return cmd.State().Set(authContextsKey, string(data), 0)
+}
+
+// AuthCommands returns the commands login, logout, whoami and context, with
+// the subcommands use, list and current, to manage the auth contexts of the
+// AuthConfig of the root command. login and logout apply to the selected
+// context, see Command.Auth, and are the LoginCommands storing the token of
+// the context.
+func AuthCommands() []*Command {
+	key := func(cmd *Command) (string, string, error) {
+		if cmd.Root().AuthConfig == nil {
+			return "", "", fmt.Errorf("%s has no auth", cmd.Root().Name)
+		}
+		name, err := cmd.authContext()
+		if err != nil {
+			return "", "", err
+		}
+		return authCredentialKey(name), "context " + name, nil
+	}
+
+	login := func(ctx context.Context, cmd *Command) (string, error) {
+		name, err := cmd.authContext()
+		if err != nil {
+			return "", err
+		}
+		auth := &Auth{Context: name}
+		if ac := cmd.Root().AuthConfig; ac.Login != nil {
+			err = ac.Login(ctx, cmd, auth)
+		} else {
+			auth.Token, err = promptToken(ctx, cmd)
+		}
+		if err != nil || auth.Token == "" {
+			return "", err
+		}
+		data, err := json.Marshal(auth)
+		return string(data), err
+	}
+
+	// keep the names of the contexts logged in to for context list
+	after := func(cmd *Command, loggedIn bool) error {
+		name, err := cmd.authContext()
+		if err != nil {
+			return err
+		}
+		names, err := cmd.authContexts()
+		if err != nil {
+			return err
+		}
+		if loggedIn {
+			names = append(names, name)
+		} else {
+			names = slices.DeleteFunc(names, func(n string) bool { return n == name })
+		}
+		return cmd.setAuthContexts(names)
+	}
+
+	commands := loginCommands([]Flag{newTokenFlag()}, key, login, after)
+	commands[0].Usage = "Log in to the current context and store the token"
+	commands[1].Usage = "Delete the token of the current context"
+
+	return append(commands, []*Command{
+		{
+			Name:  "whoami",
+			Usage: "Print who is logged in to the current context",
+			Action: func(ctx context.Context, cmd *Command) error {
+				auth, err := cmd.Auth(ctx)
+				if err != nil {
+					return err
+				}
+				if whoAmI := cmd.Root().AuthConfig.WhoAmI; whoAmI != nil {
+					who, err := whoAmI(ctx, cmd, auth)
+					if err != nil {
+						return err
+					}
+					_, _ = fmt.Fprintf(cmd.Root().Writer, "%s (context %s)\n", who, auth.Context)
+					return nil
+				}
+				_, _ = fmt.Fprintf(cmd.Root().Writer, "logged in to context %s\n", auth.Context)
+				return nil
+			},
+		},
+		{
+			Name:  "context",
+			Usage: "Manage auth contexts",
+			Commands: []*Command{
+				{
+					Name:      "use",
+					Usage:     "Select the context used by default",
+					ArgsUsage: "NAME",
+					ArgsMin:   1,
+					ArgsMax:   1,
+					Action: func(_ context.Context, cmd *Command) error {
+						name := cmd.Args().First()
+						if err := cmd.State().Set(authContextKey, name, 0); err != nil {
+							return err
+						}
+						_, _ = fmt.Fprintf(cmd.Root().Writer, "using context %s\n", name)
+						return nil
+					},
+				},
+				{
+					Name:  "list",
+					Usage: "Print the contexts logged in to, the current one marked with *",
+					Action: func(_ context.Context, cmd *Command) error {
+						current, err := cmd.authContext()
+						if err != nil {
+							return err
+						}
+						names, err := cmd.authContexts()
+						if err != nil {
+							return err
+						}
+						for _, name := range names {
+							mark := " "
+							if name == current {
+								mark = "*"
+							}
+							_, _ = fmt.Fprintf(cmd.Root().Writer, "%s %s\n", mark, name)
+						}
+						return nil
+					},
+				},
+				{
+					Name:  "current",
+					Usage: "Print the current context",
+					Action: func(_ context.Context, cmd *Command) error {
+						name, err := cmd.authContext()
+						if err != nil {
+							return err
+						}
+						_, _ = fmt.Fprintln(cmd.Root().Writer, name)
+						return nil
+					},
+				},
+			},
+		},
+	}...)
+}
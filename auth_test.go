@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthCommands(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APP_DATA_DIR", dir)
+	t.Setenv("APP_CONTEXT", "")
+	store := FileCredentialStore(filepath.Join(dir, "credentials.json"))
+
+	var refreshed int
+	run := func(args ...string) (string, error) {
+		contextFlag := *ContextFlag.(*StringFlag)
+		contextFlag.reset()
+
+		var out bytes.Buffer
+		cmd := &Command{
+			Name:            "app",
+			Writer:          &out,
+			Flags:           []Flag{&contextFlag},
+			CredentialStore: store,
+			AuthConfig: &AuthConfig{
+				Login: func(_ context.Context, cmd *Command, auth *Auth) error {
+					auth.Token = "token-" + auth.Context
+					if auth.Context == "staging" {
+						auth.Expires = time.Now().Add(-time.Minute)
+					}
+					return nil
+				},
+				Refresh: func(_ context.Context, _ *Command, auth *Auth) error {
+					refreshed++
+					auth.Token, auth.Expires = "refreshed", time.Now().Add(time.Hour)
+					return nil
+				},
+				WhoAmI: func(_ context.Context, _ *Command, auth *Auth) (string, error) {
+					return "user with " + auth.Token, nil
+				},
+			},
+			Commands: append(AuthCommands(), &Command{
+				Name: "token",
+				Action: func(ctx context.Context, cmd *Command) error {
+					auth, err := cmd.Auth(ctx)
+					if err != nil {
+						return err
+					}
+					_, _ = out.WriteString(auth.Token + "\n")
+					return nil
+				},
+			}),
+		}
+		err := cmd.Run(buildTestContext(t), append([]string{"app"}, args...))
+		return out.String(), err
+	}
+
+	_, err := run("token")
+	require.ErrorIs(t, err, ErrNotLoggedIn)
+	assert.EqualError(t, err, `not logged in to context "default", run app login`)
+
+	out, err := run("--context", "prod", "login")
+	require.NoError(t, err)
+	assert.Equal(t, "logged in to context prod\n", out)
+	_, err = run("--context", "staging", "login")
+	require.NoError(t, err)
+
+	_, err = run("context", "use")
+	assert.EqualError(t, err, "app context use expects exactly 1 argument but got 0, usage: app context use NAME")
+
+	out, err = run("context", "use", "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "using context prod\n", out)
+
+	out, err = run("context", "list")
+	require.NoError(t, err)
+	assert.Equal(t, "* prod\n  staging\n", out)
+
+	out, err = run("whoami")
+	require.NoError(t, err)
+	assert.Equal(t, "user with token-prod (context prod)\n", out)
+
+	out, err = run("--context", "staging", "token")
+	require.NoError(t, err)
+	assert.Equal(t, "refreshed\n", out)
+	out, err = run("--context", "staging", "token")
+	require.NoError(t, err)
+	assert.Equal(t, "refreshed\n", out)
+	assert.Equal(t, 1, refreshed)
+
+	t.Setenv("APP_CONTEXT", "staging")
+	out, err = run("context", "current")
+	require.NoError(t, err)
+	assert.Equal(t, "staging\n", out)
+	out, err = run("logout")
+	require.NoError(t, err)
+	assert.Equal(t, "logged out of context staging\n", out)
+	out, err = run("logout")
+	require.NoError(t, err)
+	assert.Equal(t, "not logged in to context staging\n", out)
+
+	out, err = run("context", "list")
+	require.NoError(t, err)
+	assert.Equal(t, "  prod\n", out)
+}
@@ -1,12 +1,15 @@
 package cli
 
-import "sort"
+import (
+	"sort"
+	"strings"
+)
 
 // CommandCategories interface allows for category manipulation
 type CommandCategories interface {
 	// AddCommand adds a command to a category, creating a new category if necessary.
 	AddCommand(category string, command *Command)
-	// Categories returns a slice of categories sorted by name
+	// Categories returns a slice of categories sorted by order and name
 	Categories() []CommandCategory
 }
 
@@ -18,6 +21,9 @@ func newCommandCategories() CommandCategories {
 }
 
 func (c *commandCategories) Less(i, j int) bool {
+	if (*c)[i].order != (*c)[j].order {
+		return (*c)[i].order < (*c)[j].order
+	}
 	return lexicographicLess((*c)[i].Name(), (*c)[j].Name())
 }
 
@@ -41,6 +47,18 @@ func (c *commandCategories) AddCommand(category string, command *Command) {
 	*c = newVal
 }
 
+// define sets the description and order of the category with the name of
+// the definition, if there is one
+func (c *commandCategories) define(def CategoryDefinition) {
+	for _, commandCategory := range []*commandCategory(*c) {
+		if commandCategory.name == def.Name {
+			commandCategory.description = def.Description
+			commandCategory.order = def.Order
+			return
+		}
+	}
+}
+
 func (c *commandCategories) Categories() []CommandCategory {
 	ret := make([]CommandCategory, len(*c))
 	for i, cat := range *c {
@@ -53,19 +71,40 @@ func (c *commandCategories) Categories() []CommandCategory {
 type CommandCategory interface {
 	// Name returns the category name string
 	Name() string
+	// Description returns the category description, shown under its name
+	// in help
+	Description() string
 	// VisibleCommands returns a slice of the Commands with Hidden=false
 	VisibleCommands() []*Command
 }
 
+// CategoryDefinition defines the description and order of a category of
+// commands, see Command.Categories
+type CategoryDefinition struct {
+	// Name of the category, as set in the Category of commands
+	Name string `json:"name"`
+	// Description shown under the name of the category in help
+	Description string `json:"description"`
+	// Position of the category relative to the others, categories are
+	// sorted by ascending order and then by name
+	Order int `json:"order"`
+}
+
 type commandCategory struct {
-	name     string
-	commands []*Command
+	name        string
+	description string
+	order       int
+	commands    []*Command
 }
 
 func (c *commandCategory) Name() string {
 	return c.name
 }
 
+func (c *commandCategory) Description() string {
+	return c.description
+}
+
 func (c *commandCategory) VisibleCommands() []*Command {
 	if c.commands == nil {
 		c.commands = []*Command{}
@@ -73,7 +112,7 @@ func (c *commandCategory) VisibleCommands() []*Command {
 
 	var ret []*Command
 	for _, command := range c.commands {
-		if !command.Hidden {
+		if !command.isHidden() {
 			ret = append(ret, command)
 		}
 	}
@@ -89,7 +128,8 @@ type FlagCategories interface {
 }
 
 type defaultFlagCategories struct {
-	m map[string]*defaultVisibleFlagCategory
+	m       map[string]*defaultVisibleFlagCategory
+	grouped bool
 }
 
 func newFlagCategories() FlagCategories {
@@ -133,9 +173,33 @@ func newFlagCategoriesFromFlags(fs []Flag) FlagCategories {
 	return fc
 }
 
+// newGroupedFlagCategoriesFromFlags is like newFlagCategoriesFromFlags but
+// always groups the visible flags, also if none of them has a category, and
+// lists required flags first, see Command.GroupFlags
+func newGroupedFlagCategoriesFromFlags(fs []Flag) FlagCategories {
+	fc := &defaultFlagCategories{
+		m:       map[string]*defaultVisibleFlagCategory{},
+		grouped: true,
+	}
+
+	for _, fl := range fs {
+		vf, ok := fl.(VisibleFlag)
+		if !ok || !vf.IsVisible() {
+			continue
+		}
+		cat := ""
+		if cf, ok := fl.(CategorizableFlag); ok {
+			cat = cf.GetCategory()
+		}
+		fc.AddFlag(cat, fl)
+	}
+
+	return fc
+}
+
 func (f *defaultFlagCategories) AddFlag(category string, fl Flag) {
 	if _, ok := f.m[category]; !ok {
-		f.m[category] = &defaultVisibleFlagCategory{name: category, m: map[string]Flag{}}
+		f.m[category] = &defaultVisibleFlagCategory{name: category, m: map[string]Flag{}, grouped: f.grouped}
 	}
 
 	f.m[category].m[fl.String()] = fl
@@ -166,8 +230,9 @@ type VisibleFlagCategory interface {
 }
 
 type defaultVisibleFlagCategory struct {
-	name string
-	m    map[string]Flag
+	name    string
+	m       map[string]Flag
+	grouped bool
 }
 
 func (fc *defaultVisibleFlagCategory) Name() string {
@@ -185,11 +250,52 @@ func (fc *defaultVisibleFlagCategory) Flags() []Flag {
 	}
 
 	sort.Strings(vfNames)
+	if fc.grouped {
+		sort.SliceStable(vfNames, func(i, j int) bool {
+			return isRequiredFlag(fc.m[vfNames[i]]) && !isRequiredFlag(fc.m[vfNames[j]])
+		})
+	}
 
 	ret := make([]Flag, len(vfNames))
 	for i, flName := range vfNames {
 		ret[i] = fc.m[flName]
+		if fc.grouped {
+			ret[i] = groupedFlag{ret[i]}
+		}
 	}
 
 	return ret
 }
+
+// groupedFlag is a flag listed in help grouped by category, its help text
+// shows the accepted values and whether it is required
+type groupedFlag struct {
+	Flag
+}
+
+func (f groupedFlag) String() string {
+	s := f.Flag.String()
+	if vf, ok := f.Flag.(ValueCompletionFlag); ok {
+		if values := vf.ValueCompletions(); len(values) > 0 {
+			s = appendFlagNote(s, "(one of: "+strings.Join(values, ", ")+")")
+		}
+	}
+	if isRequiredFlag(f.Flag) {
+		s = appendFlagNote(s, "(required)")
+	}
+	return s
+}
+
+// appendFlagNote appends a note to the help text of a flag, in the usage
+// column if the flag has no usage
+func appendFlagNote(s, note string) string {
+	if strings.HasSuffix(s, "\t") {
+		return s + note
+	}
+	return s + " " + note
+}
+
+func isRequiredFlag(fl Flag) bool {
+	rf, ok := fl.(RequiredFlag)
+	return ok && rf.IsRequired()
+}
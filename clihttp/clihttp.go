@@ -0,0 +1,230 @@
+// Package clihttp provides flags configuring HTTP requests and an HTTP
+// client configured by them:
+//
+//	cmd := &cli.Command{
+//		Name:  "api",
+//		Flags: clihttp.Flags(),
+//		Action: func(ctx context.Context, cmd *cli.Command) error {
+//			client, err := clihttp.Client(cmd)
+//			...
+//		},
+//	}
+//
+// It is a separate package so that applications which do not make HTTP
+// requests do not link net/http.
+package clihttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// names of the flags returned by Flags, the timeout flag is prefixed to not
+// clash with the --timeout flag added for cli.Command.Timeout
+const (
+	proxyFlagName    = "proxy"
+	insecureFlagName = "insecure-skip-verify"
+	caCertFlagName   = "ca-cert"
+	timeoutFlagName  = "http-timeout"
+	retriesFlagName  = "retries"
+)
+
+const (
+	// defaultTimeout is the timeout of requests of Client without the
+	// --http-timeout flag
+	defaultTimeout = 30 * time.Second
+	// defaultRetries is the number of retries of Client without the
+	// --retries flag
+	defaultRetries = 3
+	// maxRetryAfter is the longest wait before a retry, also if the
+	// Retry-After header of the response asks for a longer one
+	maxRetryAfter = time.Minute
+)
+
+// retryBackoff is the wait before the first retry of a request, it is
+// doubled for every further retry
+var retryBackoff = 500 * time.Millisecond
+
+// Flags returns the flags configuring the client of Client, to be added to
+// the root command:
+//
+//	--proxy URL              proxy for all requests, instead of HTTPS_PROXY
+//	--insecure-skip-verify   do not verify TLS certificates
+//	--ca-cert FILE           trust the CA certificates in the PEM file
+//	--http-timeout DURATION  timeout of each request, 30s by default
+//	--retries N              retries of failed requests, 3 by default
+//
+// The --timeout flag added for cli.Command.Timeout limits the whole command
+// and can be given together with --http-timeout.
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  proxyFlagName,
+			Usage: "`URL` of the proxy for all requests, by default HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used",
+		},
+		&cli.BoolFlag{
+			Name:  insecureFlagName,
+			Usage: "do not verify TLS certificates, insecure",
+		},
+		&cli.StringFlag{
+			Name:      caCertFlagName,
+			Usage:     "also trust the CA certificates in the PEM `FILE`",
+			TakesFile: true,
+		},
+		&cli.DurationFlag{
+			Name:  timeoutFlagName,
+			Usage: "timeout of each request, including its retries",
+			Value: defaultTimeout,
+		},
+		&cli.IntFlag{
+			Name:  retriesFlagName,
+			Usage: "number of retries of idempotent requests failing with a network error or a 429, 502, 503 or 504 status",
+			Value: defaultRetries,
+		},
+	}
+}
+
+// Client returns an HTTP client configured by the Flags of the command or
+// its parents, or with their defaults if they are not added. Idempotent
+// requests failing with a network error or a 429, 502, 503 or 504 status are
+// retried with an exponential backoff, or after the time given in the
+// Retry-After header, up to a minute. Requests are idempotent if their
+// method is GET, HEAD, OPTIONS, TRACE, PUT or DELETE, or if they have an
+// Idempotency-Key or X-Idempotency-Key header, so POST and PATCH requests
+// are only retried if they opt in with one. Requests with a body are only
+// retried if the body can be read again, see http.Request.GetBody.
+func Client(cmd *cli.Command) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxyURL := cmd.String(proxyFlagName); proxyURL != "" {
+		proxy, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	tlsConfig := &tls.Config{}
+	if cmd.Bool(insecureFlagName) {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if path := cmd.String(caCertFlagName); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", path)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	retries := defaultRetries
+	if v, ok := cmd.Value(retriesFlagName).(int64); ok {
+		retries = int(v)
+	}
+	timeout := defaultTimeout
+	if v, ok := cmd.Value(timeoutFlagName).(time.Duration); ok {
+		timeout = v
+	}
+
+	return &http.Client{
+		Transport: &retryTransport{base: transport, retries: retries},
+		Timeout:   timeout,
+	}, nil
+}
+
+// retryTransport retries requests with a temporary failure
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.retries || !idempotent(req) || !retryable(resp, err) || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+
+		wait := retryWait(resp, attempt)
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryWait returns the wait before retrying a request after the attempt,
+// the time given in the Retry-After header of the response if any, or else
+// an exponential backoff, in both cases at most maxRetryAfter
+func retryWait(resp *http.Response, attempt int) time.Duration {
+	if resp != nil {
+		if after, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && after >= 0 {
+			return time.Duration(min(after, int(maxRetryAfter/time.Second))) * time.Second
+		}
+	}
+
+	wait := retryBackoff
+	for i := 0; i < attempt && wait < maxRetryAfter; i++ {
+		wait *= 2
+	}
+	wait += time.Duration(rand.Int63n(int64(wait)/4 + 1))
+	return min(wait, maxRetryAfter)
+}
+
+// idempotent returns whether the request can be sent again without changing
+// the result, like http.Transport decides whether to retry a request
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, ok := req.Header["Idempotency-Key"]
+	if !ok {
+		_, ok = req.Header["X-Idempotency-Key"]
+	}
+	return ok
+}
+
+// retryable returns whether the request may succeed if it is sent again
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
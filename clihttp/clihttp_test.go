@@ -0,0 +1,205 @@
+package clihttp
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestClient(t *testing.T) {
+	backoff := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = backoff })
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		args       []string
+		method     string
+		header     string
+		wantStatus int
+		wantCalls  int32
+	}{
+		{
+			name:       "retries",
+			args:       []string{"app"},
+			method:     http.MethodPut,
+			wantStatus: http.StatusOK,
+			wantCalls:  3,
+		},
+		{
+			name:       "too few retries",
+			args:       []string{"app", "--retries", "1"},
+			method:     http.MethodPut,
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  2,
+		},
+		{
+			name:       "not idempotent",
+			args:       []string{"app"},
+			method:     http.MethodPost,
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  1,
+		},
+		{
+			name:       "idempotency key",
+			args:       []string{"app"},
+			method:     http.MethodPost,
+			header:     "Idempotency-Key",
+			wantStatus: http.StatusOK,
+			wantCalls:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			cmd := &cli.Command{
+				Name:  "app",
+				Flags: Flags(),
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					client, err := Client(cmd)
+					require.NoError(t, err)
+					assert.Equal(t, defaultTimeout, client.Timeout)
+
+					req, err := http.NewRequestWithContext(ctx, tt.method, server.URL, strings.NewReader("hello"))
+					require.NoError(t, err)
+					if tt.header != "" {
+						req.Header.Set(tt.header, "key")
+					}
+					resp, err := client.Do(req)
+					require.NoError(t, err)
+					defer resp.Body.Close()
+					assert.Equal(t, tt.wantStatus, resp.StatusCode)
+					if resp.StatusCode == http.StatusOK {
+						body, _ := io.ReadAll(resp.Body)
+						assert.Equal(t, "hello", string(body))
+					}
+					return nil
+				},
+			}
+			require.NoError(t, cmd.Run(context.Background(), tt.args))
+			assert.Equal(t, tt.wantCalls, calls.Load())
+		})
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	retryAfter := func(v string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{v}}}
+	}
+
+	assert.Equal(t, 2*time.Second, retryWait(retryAfter("2"), 0))
+	assert.Equal(t, maxRetryAfter, retryWait(retryAfter("86400"), 0))
+	assert.Equal(t, maxRetryAfter, retryWait(retryAfter("99999999999999"), 0))
+
+	wait := retryWait(retryAfter("soon"), 1)
+	assert.GreaterOrEqual(t, wait, 2*retryBackoff)
+	assert.LessOrEqual(t, wait, 2*retryBackoff+retryBackoff/2)
+	assert.Equal(t, maxRetryAfter, retryWait(nil, 100))
+}
+
+func TestClientTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "unknown CA", args: []string{"app", "--retries", "0"}, wantErr: true},
+		{name: "CA cert", args: []string{"app", "--ca-cert", caCert}},
+		{name: "insecure", args: []string{"app", "--insecure-skip-verify"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cli.Command{
+				Name:  "app",
+				Flags: Flags(),
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					client, err := Client(cmd)
+					require.NoError(t, err)
+
+					resp, err := client.Get(server.URL)
+					if tt.wantErr {
+						require.Error(t, err)
+						return nil
+					}
+					require.NoError(t, err)
+					return resp.Body.Close()
+				},
+			}
+			require.NoError(t, cmd.Run(context.Background(), tt.args))
+		})
+	}
+}
+
+func TestClientProxy(t *testing.T) {
+	var proxied atomic.Bool
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(r.URL.Host == "example.invalid")
+	}))
+	defer proxy.Close()
+
+	cmd := &cli.Command{
+		Name:  "app",
+		Flags: Flags(),
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			client, err := Client(cmd)
+			require.NoError(t, err)
+			resp, err := client.Get("http://example.invalid/")
+			require.NoError(t, err)
+			return resp.Body.Close()
+		},
+	}
+	require.NoError(t, cmd.Run(context.Background(), []string{"app", "--proxy", proxy.URL, "--http-timeout", "5s"}))
+	assert.True(t, proxied.Load())
+}
+
+func TestClientTimeout(t *testing.T) {
+	cmd := &cli.Command{
+		Name:    "app",
+		Timeout: time.Minute,
+		Flags:   Flags(),
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			deadline, ok := ctx.Deadline()
+			require.True(t, ok)
+			assert.WithinDuration(t, time.Now().Add(2*time.Second), deadline, time.Second)
+
+			client, err := Client(cmd)
+			require.NoError(t, err)
+			assert.Equal(t, 5*time.Second, client.Timeout)
+			return nil
+		},
+	}
+	require.NoError(t, cmd.Run(context.Background(), []string{"app", "--timeout", "2s", "--http-timeout", "5s"}))
+}
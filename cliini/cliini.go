@@ -0,0 +1,239 @@
+// Package cliini provides the INI format for config files, like the ones of
+// git or the AWS CLI. Importing it registers the format, so config files with
+// the extension .ini are read as INI and config init writes INI with
+// --format ini:
+//
+//	import _ "github.com/urfave/cli/v3/cliini"
+//
+// It is a separate package so that applications which do not use INI do not
+// link an INI parser.
+package cliini
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// Format decodes config files in INI format and writes them for config init,
+// see cli.ConfigFile.Format. Keys before the first section are top level
+// values. Sections like [serve] or [db.migrate] hold the values of the flags
+// of subcommands, or of a profile selected with cli.ConfigFile.ProfileFlag,
+// like [prod] or [prod.serve]. Subsections can also be written git style as
+// [db "migrate"], and AWS style profile sections as [profile prod]. Keys
+// given more than once set slice flags to all values and keys without a
+// value are set to true.
+var Format cli.ConfigFormat = format{}
+
+func init() {
+	cli.RegisterConfigFormat("ini", Format, ".ini")
+}
+
+type format struct{}
+
+func (format) Decode(data []byte) (map[string]any, error) {
+	values := map[string]any{}
+	section := values
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: expected ] after section name", lineNum)
+			}
+			keys, err := iniSectionKeys(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			if section, err = iniSection(values, keys); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			key, value, ok = strings.Cut(line, ":")
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("line %d: invalid line %q", lineNum, line)
+		}
+
+		var v any = true
+		if ok {
+			s, err := iniValue(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			v = s
+		}
+
+		switch existing := section[key].(type) {
+		case nil:
+			section[key] = v
+		case []any:
+			section[key] = append(existing, v)
+		case map[string]any:
+			return nil, fmt.Errorf("line %d: key %q is already a section", lineNum, key)
+		default:
+			section[key] = []any{existing, v}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// iniSection returns the section at the keys below values, creating sections
+// which do not exist yet
+func iniSection(values map[string]any, keys []string) (map[string]any, error) {
+	for i, key := range keys {
+		switch v := values[key].(type) {
+		case nil:
+			sub := map[string]any{}
+			values[key] = sub
+			values = sub
+		case map[string]any:
+			values = v
+		default:
+			return nil, fmt.Errorf("key %q is not a section", strings.Join(keys[:i+1], "."))
+		}
+	}
+	return values, nil
+}
+
+// iniSectionKeys splits a section name into its nested keys
+func iniSectionKeys(name string) ([]string, error) {
+	name = strings.TrimSpace(name)
+	name = strings.TrimPrefix(name, "profile ")
+
+	var keys []string
+	if i := strings.IndexByte(name, '"'); i >= 0 {
+		sub, err := strconv.Unquote(strings.TrimSpace(name[i:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid section name %q", name)
+		}
+		keys = append(strings.Split(strings.TrimSpace(name[:i]), "."), sub)
+	} else {
+		keys = strings.Split(name, ".")
+	}
+
+	for i, key := range keys {
+		keys[i] = strings.TrimSpace(key)
+		if keys[i] == "" {
+			return nil, fmt.Errorf("invalid section name %q", name)
+		}
+	}
+	return keys, nil
+}
+
+// iniValue returns the value with quotes removed, or with a trailing comment
+// removed if not quoted
+func iniValue(value string) (string, error) {
+	if len(value) > 1 && (value[0] == '"' || value[0] == '\'') {
+		end := strings.LastIndexByte(value, value[0])
+		if end == 0 {
+			return "", fmt.Errorf("unterminated quoted value %s", value)
+		}
+		if value[0] == '\'' {
+			return value[1:end], nil
+		}
+		return strconv.Unquote(value[:end+1])
+	}
+
+	for _, comment := range []string{" #", " ;"} {
+		if i := strings.Index(value, comment); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+	}
+	return value, nil
+}
+
+// WriteConfig writes the values of the node as INI, with the usage of the
+// flags as comments
+func (format) WriteConfig(w io.Writer, node *cli.ConfigNode) error {
+	var sb strings.Builder
+	writeConfig(&sb, node, nil)
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeConfig(sb *strings.Builder, node *cli.ConfigNode, keys []string) {
+	if len(keys) > 0 && len(node.Entries) > 0 {
+		fmt.Fprintf(sb, "\n[%s]\n", strings.Join(keys, "."))
+	}
+	for _, e := range node.Entries {
+		if e.Usage != "" {
+			fmt.Fprintf(sb, "; %s\n", e.Usage)
+		}
+
+		// slices and maps are written as one key for each item
+		var items []any
+		switch v := e.Value.(type) {
+		case []any:
+			items = v
+		case map[string]any:
+			names := make([]string, 0, len(v))
+			for name := range v {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				items = append(items, name+"="+scalar(v[name]))
+			}
+		default:
+			items = []any{v}
+		}
+		if len(items) == 0 {
+			fmt.Fprintf(sb, "; %s =\n", e.Name)
+		}
+		for _, item := range items {
+			fmt.Fprintf(sb, "%s = %s\n", e.Name, literal(item))
+		}
+	}
+	for _, child := range node.Children {
+		writeConfig(sb, child, append(keys[:len(keys):len(keys)], child.Name))
+	}
+}
+
+// literal returns the value as INI, quoted if it would not be read back as
+// the same string
+func literal(value any) string {
+	s, ok := value.(string)
+	if !ok {
+		return scalar(value)
+	}
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s, "\"'\n") || strings.Contains(s, " #") || strings.Contains(s, " ;") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// scalar returns the value of a cli.ConfigEntry which is not a slice or map
+// as a string
+func scalar(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
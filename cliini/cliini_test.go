@@ -0,0 +1,95 @@
+package cliini
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestDecode(t *testing.T) {
+	values, err := Format.Decode([]byte(`
+; global settings
+verbose
+name = "quoted # not a comment"
+tag = a
+tag = b
+
+[serve]
+port: 8080 ; comment
+
+[db "migrate"]
+dry-run = true
+
+[profile prod]
+region = eu-west-1
+
+[prod.serve]
+port = 443
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"verbose": true,
+		"name":    "quoted # not a comment",
+		"tag":     []any{"a", "b"},
+		"serve":   map[string]any{"port": "8080"},
+		"db":      map[string]any{"migrate": map[string]any{"dry-run": "true"}},
+		"prod": map[string]any{
+			"region": "eu-west-1",
+			"serve":  map[string]any{"port": "443"},
+		},
+	}, values)
+
+	_, err = Format.Decode([]byte("[serve\nport = 1"))
+	assert.ErrorContains(t, err, "line 1: expected ] after section name")
+}
+
+func TestConfigInit(t *testing.T) {
+	newCmd := func(out *bytes.Buffer, port *int64, tags *[]string) *cli.Command {
+		return &cli.Command{
+			Name:       "app",
+			Writer:     out,
+			ConfigFile: &cli.ConfigFile{Flag: "config"},
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "name", Value: "my app", Usage: "`NAME` of the app"},
+				&cli.IntFlag{Name: "port", Value: 8080},
+				&cli.StringSliceFlag{Name: "tags", Value: []string{"a", "b"}},
+			},
+			Commands: []*cli.Command{
+				cli.ConfigCommands(),
+				{
+					Name:  "db",
+					Flags: []cli.Flag{&cli.StringMapFlag{Name: "labels", Value: map[string]string{"env": "dev"}}},
+					Action: func(_ context.Context, cmd *cli.Command) error {
+						*port, *tags = cmd.Int("port"), cmd.StringSlice("tags")
+						return nil
+					},
+				},
+			},
+		}
+	}
+
+	var out bytes.Buffer
+	var port int64
+	var tags []string
+	require.NoError(t, newCmd(&out, &port, &tags).Run(context.Background(), []string{"app", "config", "init", "--format", "ini"}))
+	assert.Equal(t, `; NAME of the app
+name = my app
+port = 8080
+tags = a
+tags = b
+
+[db]
+labels = env=dev
+`, out.String())
+
+	path := filepath.Join(t.TempDir(), "app.ini")
+	require.NoError(t, newCmd(&out, &port, &tags).Run(context.Background(), []string{"app", "config", "init", "--output", path}))
+	require.NoError(t, newCmd(&out, &port, &tags).Run(context.Background(), []string{"app", "--config", path, "db"}))
+	assert.Equal(t, int64(8080), port)
+	assert.Equal(t, []string{"a", "b"}, tags)
+}
@@ -0,0 +1,23 @@
+// Package clikeychain stores the secrets of cli.Command.Credentials in the
+// keychain of the OS:
+//
+//	cmd := &cli.Command{
+//		Name:            "app",
+//		CredentialStore: clikeychain.Store("app"),
+//	}
+//
+// It is a separate package so that applications which do not store
+// credentials in the keychain do not link os/exec, which is used to run the
+// keychain tools.
+package clikeychain
+
+import "github.com/urfave/cli/v3"
+
+// Store returns a cli.CredentialStore keeping secrets in the keychain of the
+// OS under the service name: the Keychain on macOS, the Credential Manager
+// on Windows and the Secret Service on other systems, through secret-tool.
+// It returns nil if there is no keychain, then a cli.FileCredentialStore can
+// be used instead.
+func Store(service string) cli.CredentialStore {
+	return newKeychainStore(service)
+}
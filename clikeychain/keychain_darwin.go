@@ -0,0 +1,76 @@
+package clikeychain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// securityItemNotFound is the exit code of security for a missing item
+const securityItemNotFound = 44
+
+// keychainStore is a cli.CredentialStore using the Keychain through security
+type keychainStore struct {
+	service string
+}
+
+func newKeychainStore(service string) cli.CredentialStore {
+	if _, err := exec.LookPath("security"); err != nil {
+		return nil
+	}
+	return &keychainStore{service: service}
+}
+
+func (s *keychainStore) Get(key string) (string, error) {
+	out, err := s.security(nil, "find-generic-password", "-s", s.service, "-a", key, "-w")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+func (s *keychainStore) Set(key, secret string) error {
+	// the secret is passed on stdin in interactive mode so it is not shown
+	// in the arguments of the process, where a line break would end the
+	// command and start another one
+	if strings.ContainsAny(s.service+key+secret, "\r\n") {
+		return errors.New("cannot store credentials containing line breaks in the keychain")
+	}
+	cmd := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		shellQuote(s.service), shellQuote(key), shellQuote(secret))
+	_, err := s.security(strings.NewReader(cmd), "-i")
+	return err
+}
+
+func (s *keychainStore) Delete(key string) error {
+	_, err := s.security(nil, "delete-generic-password", "-s", s.service, "-a", key)
+	return err
+}
+
+func (s *keychainStore) security(stdin *strings.Reader, args ...string) (string, error) {
+	cmd := exec.Command("security", args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == securityItemNotFound {
+		return "", cli.ErrCredentialNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("security %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// shellQuote quotes s in single quotes
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
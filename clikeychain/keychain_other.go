@@ -0,0 +1,9 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package clikeychain
+
+import "github.com/urfave/cli/v3"
+
+func newKeychainStore(string) cli.CredentialStore {
+	return nil
+}
@@ -0,0 +1,72 @@
+//go:build dragonfly || freebsd || linux || netbsd || openbsd
+
+package clikeychain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// secretServiceStore is a cli.CredentialStore using the Secret Service, e.g.
+// GNOME Keyring or KWallet, through secret-tool
+type secretServiceStore struct {
+	service string
+}
+
+func newKeychainStore(service string) cli.CredentialStore {
+	// the Secret Service is only reachable in a desktop session
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return nil
+	}
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return nil
+	}
+	return &secretServiceStore{service: service}
+}
+
+func (s *secretServiceStore) Get(key string) (string, error) {
+	out, err := s.secretTool("", "lookup", "service", s.service, "account", key)
+	if err != nil {
+		// lookup fails without output if there is no secret
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) == 0 {
+			return "", cli.ErrCredentialNotFound
+		}
+		return "", err
+	}
+	return out, nil
+}
+
+func (s *secretServiceStore) Set(key, secret string) error {
+	_, err := s.secretTool(secret, "store", "--label", s.service+" "+key, "service", s.service, "account", key)
+	return err
+}
+
+func (s *secretServiceStore) Delete(key string) error {
+	// clear does not fail for a missing secret
+	if _, err := s.Get(key); err != nil {
+		return err
+	}
+	_, err := s.secretTool("", "clear", "service", s.service, "account", key)
+	return err
+}
+
+func (s *secretServiceStore) secretTool(stdin string, args ...string) (string, error) {
+	cmd := exec.Command("secret-tool", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) > 0 {
+			return "", fmt.Errorf("secret-tool %s: %w: %s", args[0], exitErr, bytes.TrimSpace(exitErr.Stderr))
+		}
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,55 @@
+// Package clipager shows long help through a pager like less, see
+// cli.Command.Pager:
+//
+//	cmd := &cli.Command{
+//		Name:  "bigapp",
+//		Pager: clipager.Pager,
+//	}
+//
+// It is a separate package so that applications without a pager do not
+// link os/exec.
+package clipager
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// defaultPager is used when the PAGER env var is not set, like git it lets
+// less exit if the output fits on one screen and keeps colors
+const defaultPager = "less -FRX"
+
+// Pager is a cli.PagerFunc writing the output to out through the pager in
+// the PAGER env var, or less -FRX if it is not set. The output is written to
+// out directly if PAGER is cat. An error is only returned if the pager could
+// not be run, not if it exits with an error, e.g. after being interrupted.
+func Pager(cmd *cli.Command, out io.Writer, output []byte) error {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = defaultPager
+	}
+
+	args := strings.Fields(pager)
+	if len(args) == 0 || args[0] == "cat" {
+		_, err := out.Write(output)
+		return err
+	}
+
+	c := exec.Command(args[0], args[1:]...)
+	c.Stdin = bytes.NewReader(output)
+	c.Stdout = out
+	c.Stderr = cmd.Root().ErrWriter
+	err := c.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil
+	}
+	return err
+}
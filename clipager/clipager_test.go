@@ -0,0 +1,47 @@
+package clipager
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestPager(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "wasip1" {
+		t.Skip("pager test uses sed")
+	}
+
+	cmd := &cli.Command{Name: "app", ErrWriter: new(bytes.Buffer)}
+	output := []byte("one\ntwo\n")
+
+	tests := []struct {
+		name     string
+		pager    string
+		expected string
+		err      bool
+	}{
+		{name: "pager", pager: "sed s/^/>/", expected: ">one\n>two\n"},
+		{name: "cat", pager: "cat", expected: "one\ntwo\n"},
+		{name: "failing pager", pager: "false", expected: ""},
+		{name: "missing pager", pager: "clipager-test-missing", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("PAGER", test.pager)
+
+			var out bytes.Buffer
+			err := Pager(cmd, &out, output)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, out.String())
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Package cliplugin runs executables as subcommands of a command, like git
+// and kubectl plugins, see cli.Command.PluginResolver:
+//
+//	cmd := &cli.Command{
+//		Name:           "myapp",
+//		PluginResolver: cliplugin.PathResolver(pluginDir),
+//	}
+//
+// It is a separate package so that applications without plugins do not
+// link os/exec.
+package cliplugin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	// env vars set for plugins so they can tell they are run as a plugin
+	// and by which command
+	envHost    = "CLI_PLUGIN_HOST"
+	envCommand = "CLI_PLUGIN_COMMAND"
+	envVersion = "CLI_PLUGIN_HOST_VERSION"
+)
+
+// PathResolver returns a cli.PluginResolverFunc looking for an executable
+// named after the full name of the command and the subcommand joined by
+// dashes, e.g. myapp-deploy for "myapp deploy" or myapp-cloud-deploy for
+// "myapp cloud deploy". The given directories are searched first, then the
+// directories in PATH. The executable is run by Executable.
+func PathResolver(dirs ...string) cli.PluginResolverFunc {
+	return func(cmd *cli.Command, name string) (cli.PluginFunc, bool) {
+		if path, ok := lookPath(cmd, name, dirs); ok {
+			return Executable(path, name), true
+		}
+		return nil, false
+	}
+}
+
+// lookPath returns the path of the executable of the subcommand name of cmd
+func lookPath(cmd *cli.Command, name string, dirs []string) (string, bool) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+
+	exe := strings.ReplaceAll(cmd.FullName(), " ", "-") + "-" + name
+
+	for _, dir := range dirs {
+		if path, err := exec.LookPath(filepath.Join(dir, exe)); err == nil {
+			return path, true
+		}
+	}
+
+	if path, err := exec.LookPath(exe); err == nil {
+		return path, true
+	}
+
+	return "", false
+}
+
+// Executable returns a cli.PluginFunc running the executable at path as the
+// subcommand name, connected to the reader and writers of the root command.
+// The env vars CLI_PLUGIN_HOST, CLI_PLUGIN_HOST_VERSION and
+// CLI_PLUGIN_COMMAND are set to the name and version of the root command and
+// the full command name the plugin was invoked as. A non-zero exit status of
+// the plugin is returned as a cli.ExitCoder with the same exit code.
+func Executable(path, name string) cli.PluginFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) error {
+		root := cmd.Root()
+		c := exec.CommandContext(ctx, path, args...)
+		c.Stdin = root.Reader
+		c.Stdout = root.Writer
+		c.Stderr = root.ErrWriter
+		c.Env = append(os.Environ(),
+			envHost+"="+root.Name,
+			envCommand+"="+cmd.FullName()+" "+name,
+			envVersion+"="+root.Version,
+		)
+
+		err := c.Run()
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return cli.Exit("", exitErr.ExitCode())
+		}
+		return err
+	}
+}
@@ -0,0 +1,58 @@
+package cliplugin
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestPathResolver(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "wasip1" {
+		t.Skip("plugin test uses a shell script")
+	}
+
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"$CLI_PLUGIN_HOST|$CLI_PLUGIN_COMMAND|$CLI_PLUGIN_HOST_VERSION|$*\"\nexit $1\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app-cloud-deploy"), []byte(script), 0o755))
+
+	var out bytes.Buffer
+	cmd := &cli.Command{
+		Name:           "app",
+		Version:        "1.2.3",
+		Writer:         &out,
+		ExitErrHandler: func(context.Context, *cli.Command, error) {},
+		PluginResolver: PathResolver(dir),
+		Flags:          []cli.Flag{&cli.BoolFlag{Name: "verbose"}},
+		Commands: []*cli.Command{
+			{
+				Name: "cloud",
+				Commands: []*cli.Command{
+					{Name: "status", Action: func(context.Context, *cli.Command) error { return nil }},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, cmd.Run(context.Background(), []string{"app", "--verbose", "cloud", "deploy", "0", "--force"}))
+	assert.Equal(t, "app|app cloud deploy|1.2.3|0 --force\n", out.String())
+
+	out.Reset()
+	err := cmd.Run(context.Background(), []string{"app", "cloud", "deploy", "3"})
+	var ec cli.ExitCoder
+	require.ErrorAs(t, err, &ec)
+	assert.Equal(t, 3, ec.ExitCode())
+
+	run, ok := PathResolver(dir)(cmd, "missing")
+	assert.False(t, ok)
+	assert.Nil(t, run)
+
+	_, ok = PathResolver(dir)(cmd, "../app-cloud-deploy")
+	assert.False(t, ok)
+}
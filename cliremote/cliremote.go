@@ -0,0 +1,340 @@
+// Package cliremote provides map sources reading flag values from the
+// key-value store of etcd or Consul:
+//
+//	remote := cliremote.EtcdSource("config/myapp/")
+//	cmd := &cli.Command{
+//		Name: "myapp",
+//		Flags: []cli.Flag{
+//			&cli.StringFlag{Name: "region", Sources: remote.Keys("region")},
+//		},
+//	}
+//
+// It is a separate package so that applications which do not read remote
+// sources do not link net/http.
+package cliremote
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// defaultRemoteTimeout is the timeout of reading a RemoteSource if it has
+// no Timeout
+const defaultRemoteTimeout = 5 * time.Second
+
+// RemoteBackend is a key-value store flag values can be read from with a
+// RemoteSource, like etcd or Consul
+type RemoteBackend interface {
+	fmt.Stringer
+
+	// List returns the values of all keys starting with the prefix
+	List(ctx context.Context, prefix string) (map[string]string, error)
+}
+
+// RemoteSource is a cli.MapSource reading flag values from the keys under
+// Prefix in a RemoteBackend, e.g. the value of the flag port from the key
+// config/myapp/port for the prefix config/myapp/. Dots in the lookup key are
+// replaced with slashes, so serve.port is read from config/myapp/serve/port.
+// All keys are read at once on the first lookup, with the context of the
+// run of the command, and read again with Load.
+type RemoteSource struct {
+	// Backend the values are read from
+	Backend RemoteBackend
+	// Prefix of the keys of the values, usually ending with a slash
+	Prefix string
+	// Timeout of reading the values, defaults to 5 seconds
+	Timeout time.Duration
+	// Whether the values are looked up in the other sources of the flags
+	// if the backend cannot be read, so that a command keeps working while
+	// the backend is unreachable. By default it fails the command instead.
+	FailOpen bool
+
+	mu     sync.Mutex
+	loaded bool
+	values map[string]string
+	err    error
+}
+
+// EtcdSource returns a RemoteSource reading from the etcd cluster at the
+// endpoints in ETCDCTL_ENDPOINTS, by default http://127.0.0.1:2379
+func EtcdSource(prefix string) *RemoteSource {
+	return &RemoteSource{Backend: &EtcdBackend{}, Prefix: prefix}
+}
+
+// ConsulSource returns a RemoteSource reading from the Consul agent at
+// CONSUL_HTTP_ADDR, by default http://127.0.0.1:8500
+func ConsulSource(prefix string) *RemoteSource {
+	return &RemoteSource{Backend: &ConsulBackend{}, Prefix: prefix}
+}
+
+// Keys returns a cli.ValueSourceChain looking up the keys in the source in
+// order, e.g. for the Sources of a flag
+func (rs *RemoteSource) Keys(keys ...string) cli.ValueSourceChain {
+	vsc := cli.ValueSourceChain{Chain: make([]cli.ValueSource, 0, len(keys))}
+	for _, key := range keys {
+		vsc.Chain = append(vsc.Chain, cli.NewMapValueSource(key, rs))
+	}
+	return vsc
+}
+
+func (rs *RemoteSource) String() string {
+	return fmt.Sprintf("%[1]s prefix %[2]q", rs.Backend, rs.Prefix)
+}
+
+func (rs *RemoteSource) GoString() string {
+	return fmt.Sprintf("&RemoteSource{Backend:%[1]q, Prefix:%[2]q}", rs.Backend, rs.Prefix)
+}
+
+// Lookup returns the value of the key, or false if the key does not exist
+// or the backend cannot be read
+func (rs *RemoteSource) Lookup(key string) (any, bool) {
+	v, ok, _ := rs.LookupErr(key)
+	return v, ok
+}
+
+// LookupErr is like Lookup but returns the error reading the backend unless
+// FailOpen is set
+func (rs *RemoteSource) LookupErr(key string) (any, bool, error) {
+	return rs.LookupContext(context.Background(), key)
+}
+
+// LookupContext is like LookupErr but reads the backend with the context if
+// it has not been read yet, so that reading it is canceled with the context
+func (rs *RemoteSource) LookupContext(ctx context.Context, key string) (any, bool, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if !rs.loaded {
+		rs.load(ctx)
+	}
+
+	if rs.err != nil {
+		if rs.FailOpen {
+			return nil, false, nil
+		}
+		return nil, false, rs.err
+	}
+
+	v, ok := rs.values[rs.Prefix+strings.ReplaceAll(key, ".", "/")]
+	return v, ok, nil
+}
+
+// Load reads all keys from the backend again, e.g. to pick up changed
+// values before cli.Command.WatchConfig reloads the flags. If the backend
+// cannot be read, the error is returned regardless of FailOpen and the
+// values read before are kept.
+func (rs *RemoteSource) Load(ctx context.Context) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	values, err := rs.list(ctx)
+	if err != nil && rs.loaded && rs.err == nil {
+		return err
+	}
+	rs.values, rs.err, rs.loaded = values, err, true
+	return err
+}
+
+func (rs *RemoteSource) load(ctx context.Context) {
+	rs.values, rs.err = rs.list(ctx)
+	// read again on the next lookup if the read was canceled by the caller
+	rs.loaded = rs.err == nil || ctx.Err() == nil
+}
+
+func (rs *RemoteSource) list(ctx context.Context) (map[string]string, error) {
+	timeout := rs.Timeout
+	if timeout <= 0 {
+		timeout = defaultRemoteTimeout
+	}
+	tctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	values, err := rs.Backend.List(tctx, rs.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", rs, err)
+	}
+	return values, nil
+}
+
+// EtcdBackend reads values from an etcd v3 cluster with its JSON API
+type EtcdBackend struct {
+	// Endpoints of the cluster which are tried in order, defaults to the
+	// comma separated endpoints in ETCDCTL_ENDPOINTS or
+	// http://127.0.0.1:2379
+	Endpoints []string
+	// Client used for requests, defaults to http.DefaultClient
+	Client *http.Client
+}
+
+func (b *EtcdBackend) String() string { return "etcd" }
+
+func (b *EtcdBackend) endpoints() []string {
+	if len(b.Endpoints) > 0 {
+		return b.Endpoints
+	}
+	if env := os.Getenv("ETCDCTL_ENDPOINTS"); env != "" {
+		return strings.Split(env, ",")
+	}
+	return []string{"http://127.0.0.1:2379"}
+}
+
+// List reads all keys with the prefix with a single range request
+func (b *EtcdBackend) List(ctx context.Context, prefix string) (map[string]string, error) {
+	body, err := json.Marshal(map[string]string{
+		"key":       base64.StdEncoding.EncodeToString([]byte(prefix)),
+		"range_end": base64.StdEncoding.EncodeToString(etcdPrefixEnd(prefix)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, endpoint := range b.endpoints() {
+		var resp struct {
+			Kvs []struct {
+				Key   string `json:"key"`
+				Value string `json:"value"`
+			} `json:"kvs"`
+		}
+		if lastErr = remoteRequest(ctx, b.Client, http.MethodPost, withScheme(endpoint)+"/v3/kv/range", body, nil, &resp); lastErr != nil {
+			continue
+		}
+
+		values := make(map[string]string, len(resp.Kvs))
+		for _, kv := range resp.Kvs {
+			key, err := base64.StdEncoding.DecodeString(kv.Key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := base64.StdEncoding.DecodeString(kv.Value)
+			if err != nil {
+				return nil, err
+			}
+			values[string(key)] = string(value)
+		}
+		return values, nil
+	}
+
+	return nil, lastErr
+}
+
+// etcdPrefixEnd returns the end of the range of keys starting with the
+// prefix
+func etcdPrefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// all keys
+	return []byte{0}
+}
+
+// ConsulBackend reads values from the KV store of a Consul agent
+type ConsulBackend struct {
+	// Address of the agent, defaults to CONSUL_HTTP_ADDR or
+	// http://127.0.0.1:8500
+	Address string
+	// ACL token, defaults to CONSUL_HTTP_TOKEN
+	Token string
+	// Client used for requests, defaults to http.DefaultClient
+	Client *http.Client
+}
+
+func (b *ConsulBackend) String() string { return "consul" }
+
+// List reads all keys with the prefix with a single recursive request
+func (b *ConsulBackend) List(ctx context.Context, prefix string) (map[string]string, error) {
+	addr := b.Address
+	if addr == "" {
+		addr = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if addr == "" {
+		addr = "http://127.0.0.1:8500"
+	}
+
+	header := http.Header{}
+	if token := b.Token; token != "" {
+		header.Set("X-Consul-Token", token)
+	} else if token := os.Getenv("CONSUL_HTTP_TOKEN"); token != "" {
+		header.Set("X-Consul-Token", token)
+	}
+
+	var resp []struct {
+		Key   string  `json:"Key"`
+		Value *string `json:"Value"`
+	}
+	u := withScheme(addr) + "/v1/kv/" + (&url.URL{Path: prefix}).EscapedPath() + "?recurse=true"
+	if err := remoteRequest(ctx, b.Client, http.MethodGet, u, nil, header, &resp); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(resp))
+	for _, kv := range resp {
+		if kv.Value == nil {
+			continue
+		}
+		value, err := base64.StdEncoding.DecodeString(*kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		values[kv.Key] = string(value)
+	}
+	return values, nil
+}
+
+func withScheme(addr string) string {
+	addr = strings.TrimSuffix(strings.TrimSpace(addr), "/")
+	if !strings.Contains(addr, "://") {
+		return "http://" + addr
+	}
+	return addr
+}
+
+// remoteRequest sends a request with the body and decodes the JSON response
+// into v, a response with status 404 leaves v unchanged
+func remoteRequest(ctx context.Context, client *http.Client, method, u string, body []byte, header http.Header, v any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return json.NewDecoder(resp.Body).Decode(v)
+	case http.StatusNotFound:
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s %s: %s %s", method, u, resp.Status, strings.TrimSpace(string(msg)))
+}
@@ -0,0 +1,221 @@
+package cliremote
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestRemoteSource(t *testing.T) {
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	etcd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "/v3/kv/range", r.URL.Path)
+		assert.Equal(t, b64("config/myapp/"), req["key"])
+		assert.Equal(t, b64("config/myapp0"), req["range_end"])
+		_, _ = w.Write([]byte(`{"kvs": [
+			{"key": "` + b64("config/myapp/port") + `", "value": "` + b64("8080") + `"},
+			{"key": "` + b64("config/myapp/serve/host") + `", "value": "` + b64("example.com") + `"}
+		]}`))
+	}))
+	defer etcd.Close()
+
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/kv/config/myapp/", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("recurse"))
+		assert.Equal(t, "secret", r.Header.Get("X-Consul-Token"))
+		_, _ = w.Write([]byte(`[
+			{"Key": "config/myapp/", "Value": null},
+			{"Key": "config/myapp/port", "Value": "` + b64("9090") + `"}
+		]`))
+	}))
+	defer consul.Close()
+
+	unreachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer unreachable.Close()
+
+	tests := []struct {
+		name    string
+		src     *RemoteSource
+		port    int64
+		host    string
+		wantErr string
+	}{
+		{
+			name: "etcd",
+			src:  &RemoteSource{Backend: &EtcdBackend{Endpoints: []string{"127.0.0.1:1", etcd.URL}}, Prefix: "config/myapp/"},
+			port: 8080,
+			host: "example.com",
+		},
+		{
+			name: "consul",
+			src:  &RemoteSource{Backend: &ConsulBackend{Address: consul.URL, Token: "secret"}, Prefix: "config/myapp/"},
+			port: 9090,
+			host: "localhost",
+		},
+		{
+			name: "fail open",
+			src:  &RemoteSource{Backend: &ConsulBackend{Address: unreachable.URL}, Prefix: "config/myapp/", Timeout: 10 * time.Millisecond, FailOpen: true},
+			port: 80,
+			host: "localhost",
+		},
+		{
+			name:    "fail closed",
+			src:     &RemoteSource{Backend: &ConsulBackend{Address: unreachable.URL}, Prefix: "config/myapp/", Timeout: 10 * time.Millisecond},
+			wantErr: `could not read value for flag port from key "port" from consul prefix "config/myapp/": could not read consul prefix "config/myapp/"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var port int64
+			var host string
+			cmd := &cli.Command{
+				Name: "app",
+				Flags: []cli.Flag{
+					&cli.IntFlag{Name: "port", Value: 80, Sources: test.src.Keys("port")},
+				},
+				Commands: []*cli.Command{
+					{
+						Name:  "serve",
+						Flags: []cli.Flag{&cli.StringFlag{Name: "host", Value: "localhost", Sources: test.src.Keys("serve.host")}},
+						Action: func(_ context.Context, cmd *cli.Command) error {
+							port, host = cmd.Int("port"), cmd.String("host")
+							return nil
+						},
+					},
+				},
+			}
+
+			err := cmd.Run(context.Background(), []string{"app", "serve"})
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.port, port)
+			assert.Equal(t, test.host, host)
+		})
+	}
+}
+
+// stubBackend is a RemoteBackend returning its values, counting reads
+type stubBackend struct {
+	mu     sync.Mutex
+	values map[string]string
+	reads  int
+}
+
+func (b *stubBackend) String() string { return "stub" }
+
+func (b *stubBackend) List(ctx context.Context, _ string) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reads++
+	return maps.Clone(b.values), nil
+}
+
+func (b *stubBackend) set(key, value string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.values[key] = value
+}
+
+func TestRemoteSource_Context(t *testing.T) {
+	backend := &stubBackend{values: map[string]string{"app/port": "8080"}}
+	src := &RemoteSource{Backend: backend, Prefix: "app/"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := src.LookupContext(ctx, "port")
+	require.ErrorIs(t, err, context.Canceled)
+
+	v, ok, err := src.LookupContext(context.Background(), "port")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "8080", v)
+	assert.Equal(t, 1, backend.reads)
+
+	backend.set("app/port", "9090")
+	v, _, _ = src.LookupErr("port")
+	assert.Equal(t, "8080", v)
+
+	require.NoError(t, src.Load(context.Background()))
+	v, _, _ = src.LookupErr("port")
+	assert.Equal(t, "9090", v)
+
+	require.ErrorIs(t, src.Load(ctx), context.Canceled)
+	v, _, err = src.LookupErr("port")
+	require.NoError(t, err)
+	assert.Equal(t, "9090", v)
+
+	src = &RemoteSource{Backend: backend, Prefix: "app/"}
+	cmd := &cli.Command{
+		Name:   "app",
+		Flags:  []cli.Flag{&cli.IntFlag{Name: "port", Sources: src.Keys("port")}},
+		Action: func(_ context.Context, cmd *cli.Command) error { _ = cmd.Int("port"); return nil },
+	}
+	err = cmd.Run(ctx, []string{"app"})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCommand_WatchConfigRemote(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"host": "a"}`), 0o644))
+
+	backend := &stubBackend{values: map[string]string{"app/port": "8080"}}
+	src := &RemoteSource{Backend: backend, Prefix: "app/"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	values := make(chan []any, 10)
+	cmd := &cli.Command{
+		Name:       "app",
+		ConfigFile: &cli.ConfigFile{Path: path, WatchInterval: 5 * time.Millisecond},
+		Flags: []cli.Flag{
+			&cli.IntFlag{Name: "port", Sources: src.Keys("port")},
+			&cli.StringFlag{Name: "host", Sources: src.Keys("host")},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			return cmd.WatchConfig(ctx, func(changed []string) {
+				values <- []any{changed, cmd.Int("port"), cmd.String("host")}
+			})
+		},
+	}
+	require.NoError(t, cmd.Run(ctx, []string{"app"}))
+	assert.Equal(t, int64(8080), cmd.Int("port"))
+	assert.Equal(t, "a", cmd.String("host"))
+
+	backend.set("app/port", "9090")
+	backend.set("app/host", "b")
+	now := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, now, now))
+
+	select {
+	case vals := <-values:
+		assert.ElementsMatch(t, []string{"port", "host"}, vals[0])
+		assert.Equal(t, []any{int64(9090), "b"}, vals[1:])
+	case <-time.After(5 * time.Second):
+		t.Fatal("no config change received")
+	}
+}
@@ -0,0 +1,186 @@
+// Package clitest runs commands in tests and captures their output and exit
+// code, without swapping os.Stdout or the package-level writers of cli:
+//
+//	res := clitest.Run(cmd, "deploy", "--env=prod")
+//	if res.ExitCode != 0 {
+//		t.Fatalf("deploy failed: %s", res.Stderr)
+//	}
+//
+// A Harness also stubs env vars, stdin, whether the input and output are a
+// terminal, and the time.
+package clitest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// Harness configures how commands are run by Run. The zero value runs them
+// with empty input which is not a terminal. Different commands can be run
+// in parallel unless Env is set, as env vars are global.
+type Harness struct {
+	// Context the command is run with, context.Background() if nil
+	Context context.Context
+	// Env vars set while the command runs, they are restored afterwards
+	Env map[string]string
+	// Input of the command
+	Stdin string
+	// Whether the input and output of the command are a terminal, e.g. to
+	// test prompts or progress output
+	TTY bool
+	// Time returned by the Clock of the command, the current time if zero
+	Now time.Time
+}
+
+// Result is the outcome of running a command
+type Result struct {
+	// Output written to the Writer and ErrWriter of the command
+	Stdout string
+	Stderr string
+	// Exit code the command would exit with, 0 if it succeeded
+	ExitCode int
+	// Error returned by the command
+	Err error
+	// Command which was resolved to run, with its parsed flags and
+	// arguments, or the root command if none was resolved
+	Command *cli.Command
+}
+
+// Run runs the root command cmd with the args, which do not include the
+// program name, with the zero Harness
+func Run(cmd *cli.Command, args ...string) *Result {
+	return (&Harness{}).Run(cmd, args...)
+}
+
+// Run runs the root command cmd with the args, which do not include the
+// program name. The Reader, Writer, ErrWriter, OsExiter, Clock and Observer
+// of cmd are replaced while it runs and restored afterwards.
+func (h *Harness) Run(cmd *cli.Command, args ...string) *Result {
+	ctx := h.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	stdout, stderr := &buffer{tty: h.TTY}, &buffer{tty: h.TTY}
+	res := &Result{Command: cmd, ExitCode: -1}
+
+	defer h.setEnv()()
+	defer replace(&cmd.OsExiter, func(code int) {
+		if res.ExitCode < 0 {
+			res.ExitCode = code
+		}
+	})()
+	defer replace[io.Reader](&cmd.Reader, &reader{Reader: strings.NewReader(h.Stdin), tty: h.TTY})()
+	defer replace[io.Writer](&cmd.Writer, stdout)()
+	defer replace[io.Writer](&cmd.ErrWriter, stderr)()
+
+	clock := cmd.Clock
+	if !h.Now.IsZero() {
+		clock = func() time.Time { return h.Now }
+	}
+	defer replace(&cmd.Clock, clock)()
+
+	observer := cmd.Observer
+	defer replace[cli.Observer](&cmd.Observer, cli.ObserverFunc(func(ctx context.Context, ev cli.Event) {
+		if ev.Type == cli.EventCommandResolved {
+			res.Command = ev.Command
+		}
+		if observer != nil {
+			observer.Observe(ctx, ev)
+		}
+	}))()
+
+	res.Err = cmd.Run(ctx, append([]string{cmd.Name}, args...))
+	if res.ExitCode < 0 {
+		res.ExitCode = exitCode(res.Err)
+	}
+	res.Stdout, res.Stderr = stdout.String(), stderr.String()
+	return res
+}
+
+// setEnv sets the Env and returns a function restoring the env vars
+func (h *Harness) setEnv() func() {
+	type saved struct {
+		value string
+		ok    bool
+	}
+	prev := make(map[string]saved, len(h.Env))
+	for key, value := range h.Env {
+		v, ok := os.LookupEnv(key)
+		prev[key] = saved{v, ok}
+		_ = os.Setenv(key, value)
+	}
+	return func() {
+		for key, s := range prev {
+			if s.ok {
+				_ = os.Setenv(key, s.value)
+			} else {
+				_ = os.Unsetenv(key)
+			}
+		}
+	}
+}
+
+// replace sets *p to v and returns a function setting it back
+func replace[T any](p *T, v T) func() {
+	prev := *p
+	*p = v
+	return func() { *p = prev }
+}
+
+// exitCode returns the exit code of an error which was not passed to the
+// OsExiter of the command, like cli.HandleExitCoder determines it
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr cli.ExitCoder
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// buffer captures output and reports whether it stands for a terminal
+type buffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	tty bool
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *buffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// IsTerminal is checked by cli to decide e.g. whether to color output
+func (b *buffer) IsTerminal() bool {
+	return b.tty
+}
+
+// reader is the input of a command and reports whether it stands for a
+// terminal
+type reader struct {
+	*strings.Reader
+	tty bool
+}
+
+// IsTerminal is checked by cli to decide e.g. whether to prompt
+func (r *reader) IsTerminal() bool {
+	return r.tty
+}
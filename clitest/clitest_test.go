@@ -0,0 +1,98 @@
+package clitest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func newCommand() *cli.Command {
+	return &cli.Command{
+		Name: "app",
+		Commands: []*cli.Command{
+			{
+				Name:  "deploy",
+				Flags: []cli.Flag{&cli.StringFlag{Name: "env", Required: true}},
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					_, _ = fmt.Fprintf(cmd.Root().Writer, "deploying to %s\n", cmd.String("env"))
+					if cmd.String("env") == "broken" {
+						return cli.Exit("deploy failed", 3)
+					}
+					return nil
+				},
+			},
+			{
+				Name: "fail",
+				Action: func(context.Context, *cli.Command) error {
+					return errors.New("plain error")
+				},
+			},
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	res := Run(newCommand(), "deploy", "--env=prod")
+	require.NoError(t, res.Err)
+	assert.Equal(t, "deploying to prod\n", res.Stdout)
+	assert.Equal(t, "", res.Stderr)
+	assert.Equal(t, 0, res.ExitCode)
+	assert.Equal(t, "deploy", res.Command.Name)
+	assert.Equal(t, "prod", res.Command.String("env"))
+
+	res = Run(newCommand(), "deploy", "--env=broken")
+	require.Error(t, res.Err)
+	assert.Equal(t, "deploy failed\n", res.Stderr)
+	assert.Equal(t, 3, res.ExitCode)
+
+	res = Run(newCommand(), "fail")
+	assert.EqualError(t, res.Err, "plain error")
+	assert.Equal(t, 1, res.ExitCode)
+}
+
+func TestHarness(t *testing.T) {
+	t.Setenv("CLITEST_KEPT", "kept")
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	h := &Harness{
+		Env:   map[string]string{"APP_NAME": "world", "CLITEST_KEPT": "changed"},
+		Stdin: "y\n",
+		TTY:   true,
+		Now:   now,
+	}
+
+	var name string
+	var confirmed bool
+	var clock time.Time
+	cmd := &cli.Command{
+		Name:  "app",
+		Flags: []cli.Flag{&cli.StringFlag{Name: "name", Sources: cli.EnvVars("APP_NAME")}},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			name = cmd.String("name")
+			clock = cmd.Clock()
+			var err error
+			confirmed, err = cmd.Confirm("continue?")
+			return err
+		},
+	}
+
+	res := h.Run(cmd)
+	require.NoError(t, res.Err)
+	assert.Equal(t, "world", name)
+	assert.True(t, confirmed)
+	assert.Equal(t, now, clock)
+	assert.Contains(t, res.Stderr, "continue?")
+
+	assert.Equal(t, "kept", os.Getenv("CLITEST_KEPT"))
+	_, ok := os.LookupEnv("APP_NAME")
+	assert.False(t, ok)
+	assert.Nil(t, cmd.Clock)
+	assert.Nil(t, cmd.Writer)
+}
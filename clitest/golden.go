@@ -0,0 +1,126 @@
+package clitest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+)
+
+// update is set with go test -update to write the golden files instead of
+// comparing against them
+var update = flag.Bool("update", false, "update the golden files of clitest")
+
+// DocFormat renders a document of a command for GoldenDocs, e.g. markdown
+// or a man page with a generator like github.com/urfave/cli-docs
+type DocFormat struct {
+	// Extension of the golden file, e.g. md
+	Ext string
+	// Render returns the document of the root command
+	Render func(cmd *cli.Command) (string, error)
+}
+
+// FishCompletion renders the fish completion script of a command
+var FishCompletion = DocFormat{
+	Ext: "fish",
+	Render: func(cmd *cli.Command) (string, error) {
+		return cmd.ToFishCompletion()
+	},
+}
+
+// GoldenHelp compares the output of --help of the root command cmd and each
+// of its subcommands which is not hidden with the golden file in dir named
+// after the full name of the command, e.g. app_deploy.txt. With go test
+// -update the golden files are written instead.
+func GoldenHelp(t testing.TB, cmd *cli.Command, dir string) {
+	t.Helper()
+
+	for _, path := range commandPaths(cmd, nil) {
+		res := Run(cmd, append(path, "--help")...)
+		if res.Err != nil {
+			t.Errorf("%s --help failed: %v", strings.Join(append([]string{cmd.Name}, path...), " "), res.Err)
+			continue
+		}
+		name := strings.Join(append([]string{cmd.Name}, path...), "_") + ".txt"
+		assertGolden(t, filepath.Join(dir, name), res.Stdout)
+	}
+}
+
+// GoldenDocs compares the documents of the root command cmd in the formats
+// with the golden files in dir named after the command with the extension
+// of the format, e.g. app.fish. With go test -update the golden files are
+// written instead.
+func GoldenDocs(t testing.TB, cmd *cli.Command, dir string, formats ...DocFormat) {
+	t.Helper()
+
+	for _, format := range formats {
+		doc, err := format.Render(cmd)
+		if err != nil {
+			t.Errorf("cannot render %s docs: %v", format.Ext, err)
+			continue
+		}
+		assertGolden(t, filepath.Join(dir, cmd.Name+"."+format.Ext), doc)
+	}
+}
+
+// commandPaths returns the names leading from the root to the command and
+// its subcommands which are not hidden
+func commandPaths(cmd *cli.Command, path []string) [][]string {
+	paths := [][]string{path}
+	for _, sub := range cmd.Commands {
+		if sub.Hidden {
+			continue
+		}
+		paths = append(paths, commandPaths(sub, append(path[:len(path):len(path)], sub.Name))...)
+	}
+	return paths
+}
+
+// assertGolden compares got with the golden file, or writes it with -update
+func assertGolden(t testing.TB, path, got string) {
+	t.Helper()
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("cannot read golden file, run the test with -update to write it: %v", err)
+		return
+	}
+	if string(want) != got {
+		t.Errorf("output differs from golden file %s, run the test with -update if the change is intended:\n%s", path, lineDiff(string(want), got))
+	}
+}
+
+// lineDiff returns the lines of want and got which differ, prefixed with -
+// and +
+func lineDiff(want, got string) string {
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	var b strings.Builder
+	for i := 0; i < max(len(wantLines), len(gotLines)); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w == g {
+			continue
+		}
+		fmt.Fprintf(&b, "line %d:\n-%s\n+%s\n", i+1, w, g)
+	}
+	return b.String()
+}
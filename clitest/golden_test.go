@@ -0,0 +1,54 @@
+package clitest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder records the errors of a test instead of failing it
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestGoldenHelp(t *testing.T) {
+	dir := t.TempDir()
+
+	*update = true
+	GoldenHelp(t, newCommand(), dir)
+	GoldenDocs(t, newCommand(), dir, FishCompletion)
+	*update = false
+
+	for _, name := range []string{"app.txt", "app_deploy.txt", "app_fail.txt", "app.fish"} {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+	help, err := os.ReadFile(filepath.Join(dir, "app_deploy.txt"))
+	require.NoError(t, err)
+	assert.Contains(t, string(help), "app deploy")
+
+	GoldenHelp(t, newCommand(), dir)
+	GoldenDocs(t, newCommand(), dir, FishCompletion)
+
+	cmd := newCommand()
+	cmd.Commands[0].Usage = "Deploy the app"
+	r := &recorder{TB: t}
+	GoldenHelp(r, cmd, dir)
+	require.Len(t, r.errors, 2)
+	assert.Contains(t, r.errors[0], "app.txt")
+	assert.Contains(t, r.errors[1], "app_deploy.txt")
+	assert.Contains(t, r.errors[1], "+   app deploy - Deploy the app")
+
+	r = &recorder{TB: t}
+	GoldenHelp(r, newCommand(), t.TempDir())
+	require.Len(t, r.errors, 3)
+	assert.Contains(t, r.errors[0], "run the test with -update to write it")
+}
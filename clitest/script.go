@@ -0,0 +1,310 @@
+package clitest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+)
+
+// RunScripts runs each txtar script matching the glob pattern as a subtest,
+// against a command returned by newCommand for each exec. A script is a
+// txtar archive: its comment holds the commands, one per line, and its
+// files are written to a temporary work dir, which is the current dir while
+// the script runs and is set in $WORK.
+//
+//	env NAME=VALUE    set an env var for the following execs
+//	stdin FILE        use the file as input of the next exec
+//	exec NAME ARGS    run the command, NAME is the name of the root command
+//	stdout REGEXP     check the output of the last exec
+//	stderr REGEXP     check the error output of the last exec
+//	cmp stdout FILE   compare the output of the last exec with the file
+//	exists FILE       check that the file exists
+//
+// A command prefixed with ! must fail, e.g. ! exec app bad-arg or
+// ! stdout error. Arguments are split at spaces, except in single quotes,
+// and $NAME or ${NAME} are replaced with env vars set with env or $WORK.
+// Lines starting with # are comments.
+//
+//	# deploy to prod
+//	env APP_TOKEN=secret
+//	exec app deploy --config config.yaml
+//	stdout 'deployed to prod'
+//	! stderr .
+//
+//	-- config.yaml --
+//	env: prod
+func RunScripts(t *testing.T, newCommand func() *cli.Command, pattern string) {
+	t.Helper()
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no scripts match %s", pattern)
+	}
+
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := &script{t: t, newCommand: newCommand, env: map[string]string{}}
+			s.run(path, string(data))
+		})
+	}
+}
+
+// script is the state of a running script
+type script struct {
+	t          testing.TB
+	newCommand func() *cli.Command
+	work       string
+	env        map[string]string
+	stdin      string
+	last       *Result
+}
+
+func (s *script) run(path, data string) {
+	comment, files := parseTxtar(data)
+
+	s.work = s.t.TempDir()
+	s.env["WORK"] = s.work
+	for name, content := range files {
+		file := filepath.Join(s.work, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			s.t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if err := os.Chdir(s.work); err != nil {
+		s.t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	for i, line := range strings.Split(comment, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := s.exec(line); err != nil {
+			s.t.Fatalf("%s:%d: %s: %v", path, i+1, line, err)
+		}
+	}
+}
+
+// exec runs a line of the script
+func (s *script) exec(line string) error {
+	neg := false
+	if rest, ok := strings.CutPrefix(line, "!"); ok {
+		neg, line = true, strings.TrimSpace(rest)
+	}
+	args, err := s.splitArgs(line)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("missing command")
+	}
+
+	switch name, args := args[0], args[1:]; name {
+	case "env":
+		for _, arg := range args {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				return fmt.Errorf("env needs NAME=VALUE")
+			}
+			s.env[key] = value
+		}
+		return nil
+	case "stdin":
+		if len(args) != 1 {
+			return fmt.Errorf("stdin needs a file")
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		s.stdin = string(data)
+		return nil
+	case "exec":
+		return s.execCommand(neg, args)
+	case "stdout", "stderr":
+		if len(args) != 1 {
+			return fmt.Errorf("%s needs a regexp", name)
+		}
+		out, err := s.output(name)
+		if err != nil {
+			return err
+		}
+		re, err := regexp.Compile("(?m)" + args[0])
+		if err != nil {
+			return err
+		}
+		if matched := re.MatchString(out); matched == neg {
+			if neg {
+				return fmt.Errorf("%s matches %q:\n%s", name, args[0], out)
+			}
+			return fmt.Errorf("%s does not match %q:\n%s", name, args[0], out)
+		}
+		return nil
+	case "cmp":
+		if len(args) != 2 {
+			return fmt.Errorf("cmp needs stdout or stderr and a file")
+		}
+		out, err := s.output(args[0])
+		if err != nil {
+			return err
+		}
+		want, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+		if (out == string(want)) == neg {
+			return fmt.Errorf("%s differs from %s:\n%s", args[0], args[1], lineDiff(string(want), out))
+		}
+		return nil
+	case "exists":
+		for _, arg := range args {
+			if _, err := os.Stat(arg); (err == nil) == neg {
+				if neg {
+					return fmt.Errorf("%s exists", arg)
+				}
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+func (s *script) execCommand(neg bool, args []string) error {
+	cmd := s.newCommand()
+	if len(args) == 0 || args[0] != cmd.Name {
+		return fmt.Errorf("exec can only run %s", cmd.Name)
+	}
+
+	h := &Harness{Env: s.env, Stdin: s.stdin}
+	s.stdin = ""
+	s.last = h.Run(cmd, args[1:]...)
+
+	switch {
+	case neg && s.last.ExitCode == 0:
+		return fmt.Errorf("unexpected success:\n%s", s.last.Stdout)
+	case !neg && s.last.ExitCode != 0:
+		return fmt.Errorf("exit code %d: %v\n%s", s.last.ExitCode, s.last.Err, s.last.Stderr)
+	}
+	return nil
+}
+
+func (s *script) output(name string) (string, error) {
+	if s.last == nil {
+		return "", fmt.Errorf("no command was run")
+	}
+	switch name {
+	case "stdout":
+		return s.last.Stdout, nil
+	case "stderr":
+		return s.last.Stderr, nil
+	}
+	return "", fmt.Errorf("unknown output %q", name)
+}
+
+// splitArgs splits the line at spaces outside of single quotes and expands
+// env vars outside of them
+func (s *script) splitArgs(line string) ([]string, error) {
+	var (
+		args   []string
+		arg    strings.Builder
+		inArg  bool
+		quoted bool
+		plain  strings.Builder
+	)
+	flush := func() {
+		arg.WriteString(os.Expand(plain.String(), s.getenv))
+		plain.Reset()
+	}
+	for _, r := range line {
+		switch {
+		case r == '\'':
+			if quoted {
+				quoted = false
+			} else {
+				flush()
+				quoted, inArg = true, true
+			}
+		case quoted:
+			arg.WriteRune(r)
+		case r == ' ' || r == '\t':
+			if inArg {
+				flush()
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			plain.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inArg {
+		flush()
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+func (s *script) getenv(key string) string {
+	if v, ok := s.env[key]; ok {
+		return v
+	}
+	return os.Getenv(key)
+}
+
+// parseTxtar returns the comment and the files of a txtar archive
+func parseTxtar(data string) (string, map[string]string) {
+	files := make(map[string]string)
+	var (
+		comment strings.Builder
+		name    string
+		content strings.Builder
+		inFile  bool
+	)
+	for _, line := range strings.SplitAfter(data, "\n") {
+		trimmed := strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(trimmed, "-- ") && strings.HasSuffix(trimmed, " --") && len(trimmed) > 6 {
+			if inFile {
+				files[name] = content.String()
+			}
+			name, inFile = strings.TrimSpace(trimmed[3:len(trimmed)-3]), true
+			content.Reset()
+			continue
+		}
+		if inFile {
+			content.WriteString(line)
+		} else {
+			comment.WriteString(line)
+		}
+	}
+	if inFile {
+		files[name] = content.String()
+	}
+	return comment.String(), files
+}
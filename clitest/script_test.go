@@ -0,0 +1,88 @@
+package clitest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func newScriptCommand() *cli.Command {
+	cmd := newCommand()
+	deploy := cmd.Commands[0]
+	deploy.Flags = append(deploy.Flags, &cli.StringFlag{Name: "config"})
+	action := deploy.Action
+	deploy.Action = func(ctx context.Context, cmd *cli.Command) error {
+		if err := action(ctx, cmd); err != nil {
+			return err
+		}
+		if path := cmd.String("config"); path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.Root().Writer, "config: %s", data)
+		}
+		return nil
+	}
+
+	cmd.Commands = append(cmd.Commands,
+		&cli.Command{
+			Name: "echo",
+			Action: func(_ context.Context, cmd *cli.Command) error {
+				_, _ = fmt.Fprintln(cmd.Root().Writer, strings.Join(cmd.Args().Slice(), " "))
+				return nil
+			},
+		},
+		&cli.Command{
+			Name: "cat",
+			Action: func(_ context.Context, cmd *cli.Command) error {
+				_, err := io.Copy(cmd.Root().Writer, cmd.Root().Reader)
+				return err
+			},
+		},
+	)
+	return cmd
+}
+
+func TestRunScripts(t *testing.T) {
+	RunScripts(t, newScriptCommand, "testdata/scripts/*.txtar")
+}
+
+func TestScript_Errors(t *testing.T) {
+	s := &script{t: t, newCommand: newScriptCommand, env: map[string]string{}}
+
+	for _, tt := range []struct {
+		line    string
+		wantErr string
+	}{
+		{"stdout .", "no command was run"},
+		{"unknown", `unknown command "unknown"`},
+		{"exec other", "exec can only run app"},
+		{"exec app 'a", "unterminated quote"},
+		{"exec app fail", "exit code 1: plain error"},
+		{"! exec app echo", "unexpected success"},
+		{"env FOO", "env needs NAME=VALUE"},
+		{"exists missing.file", "missing.file"},
+	} {
+		err := s.exec(tt.line)
+		require.Error(t, err, tt.line)
+		assert.Contains(t, err.Error(), tt.wantErr, tt.line)
+	}
+
+	require.NoError(t, s.exec("exec app echo hello"))
+	assert.EqualError(t, s.exec("stdout bye"), "stdout does not match \"bye\":\nhello\n")
+	assert.EqualError(t, s.exec("! stdout hello"), "stdout matches \"hello\":\nhello\n")
+}
+
+func TestParseTxtar(t *testing.T) {
+	comment, files := parseTxtar("exec app\n\n-- a.txt --\nA\n-- dir/b.txt --\nB\nB\n")
+	assert.Equal(t, "exec app\n\n", comment)
+	assert.Equal(t, map[string]string{"a.txt": "A\n", "dir/b.txt": "B\nB\n"}, files)
+}
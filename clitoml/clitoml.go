@@ -0,0 +1,635 @@
+// Package clitoml provides the TOML format for config files. Importing it
+// registers the format, so config files with the extension .toml are read as
+// TOML and config init writes TOML with --format toml:
+//
+//	import _ "github.com/urfave/cli/v3/clitoml"
+//
+// It is a separate package so that applications which do not use TOML do
+// not link a TOML parser.
+package clitoml
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/urfave/cli/v3"
+)
+
+// Format decodes config files in TOML format and writes them for config
+// init, see cli.ConfigFile.Format. Tables like [serve] or [db.migrate] hold
+// the values of the flags of subcommands.
+var Format cli.ConfigFormat = format{}
+
+func init() {
+	cli.RegisterConfigFormat("toml", Format, ".toml")
+}
+
+type format struct{}
+
+func (format) Decode(data []byte) (map[string]any, error) {
+	p := &tomlParser{src: string(data), line: 1}
+	values, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", p.line, err)
+	}
+	return values, nil
+}
+
+// WriteConfig writes the values of the node as TOML, with the usage of the
+// flags as comments
+func (format) WriteConfig(w io.Writer, node *cli.ConfigNode) error {
+	var sb strings.Builder
+	writeConfig(&sb, node, nil)
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeConfig(sb *strings.Builder, node *cli.ConfigNode, keys []string) {
+	if len(keys) > 0 && len(node.Entries) > 0 {
+		quoted := make([]string, 0, len(keys))
+		for _, k := range keys {
+			quoted = append(quoted, key(k))
+		}
+		fmt.Fprintf(sb, "\n[%s]\n", strings.Join(quoted, "."))
+	}
+	for _, e := range node.Entries {
+		if e.Usage != "" {
+			fmt.Fprintf(sb, "# %s\n", e.Usage)
+		}
+		fmt.Fprintf(sb, "%s = %s\n", key(e.Name), literal(e.Value))
+	}
+	for _, child := range node.Children {
+		writeConfig(sb, child, append(keys[:len(keys):len(keys)], child.Name))
+	}
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// key returns the key quoted if it is not a bare key
+func key(k string) string {
+	if bareKey.MatchString(k) {
+		return k
+	}
+	return strconv.Quote(k)
+}
+
+// literal returns the value as TOML, scalars are written as JSON which is
+// also valid TOML
+func literal(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return `""`
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, literal(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]any:
+		if len(v) == 0 {
+			return "{}"
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, 0, len(v))
+		for _, k := range keys {
+			items = append(items, key(k)+" = "+literal(v[k]))
+		}
+		return "{ " + strings.Join(items, ", ") + " }"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return strconv.Quote(fmt.Sprint(value))
+	}
+	return string(data)
+}
+
+// tomlParser decodes TOML documents into nested maps. Tables are decoded as
+// map[string]any, arrays as []any, integers as int64, floats as float64,
+// offset date-times as time.Time and local dates and times as strings.
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+	// tables defined with a [header], which may not be defined again
+	defined map[string]bool
+}
+
+func (p *tomlParser) parse() (map[string]any, error) {
+	root := map[string]any{}
+	current := root
+	p.defined = map[string]bool{}
+
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return root, nil
+		}
+
+		if p.peek() == '[' {
+			table, err := p.parseTableHeader(root)
+			if err != nil {
+				return nil, err
+			}
+			current = table
+		} else if err := p.parseKeyValue(current); err != nil {
+			return nil, err
+		}
+
+		if err := p.expectLineEnd(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *tomlParser) parseTableHeader(root map[string]any) (map[string]any, error) {
+	p.pos++
+	isArray := p.peek() == '['
+	if isArray {
+		p.pos++
+	}
+
+	p.skipBlank(false)
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank(false)
+
+	closing := "]"
+	if isArray {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return nil, fmt.Errorf("expected %s after table name", closing)
+	}
+	p.pos += len(closing)
+
+	parent, err := tomlTable(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+	name := strings.Join(keys, ".")
+
+	if isArray {
+		var array []any
+		switch v := parent[last].(type) {
+		case nil:
+		case []any:
+			array = v
+		default:
+			return nil, fmt.Errorf("key %q is already defined", name)
+		}
+		table := map[string]any{}
+		parent[last] = append(array, table)
+		return table, nil
+	}
+
+	if p.defined[name] {
+		return nil, fmt.Errorf("table %q is already defined", name)
+	}
+	p.defined[name] = true
+
+	switch v := parent[last].(type) {
+	case nil:
+		table := map[string]any{}
+		parent[last] = table
+		return table, nil
+	case map[string]any:
+		return v, nil
+	}
+	return nil, fmt.Errorf("key %q is already defined", name)
+}
+
+func (p *tomlParser) parseKeyValue(table map[string]any) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+
+	p.skipBlank(false)
+	if p.peek() != '=' {
+		return fmt.Errorf("expected = after key %q", strings.Join(keys, "."))
+	}
+	p.pos++
+	p.skipBlank(false)
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	parent, err := tomlTable(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, ok := parent[last]; ok {
+		return fmt.Errorf("key %q is already defined", strings.Join(keys, "."))
+	}
+	parent[last] = value
+	return nil
+}
+
+// tomlTable returns the table at the dotted keys below table, creating
+// missing tables and using the last table of arrays of tables
+func tomlTable(table map[string]any, keys []string) (map[string]any, error) {
+	for i, key := range keys {
+		switch v := table[key].(type) {
+		case nil:
+			sub := map[string]any{}
+			table[key] = sub
+			table = sub
+		case map[string]any:
+			table = v
+		case []any:
+			var sub map[string]any
+			if len(v) > 0 {
+				sub, _ = v[len(v)-1].(map[string]any)
+			}
+			if sub == nil {
+				return nil, fmt.Errorf("key %q is not a table", strings.Join(keys[:i+1], "."))
+			}
+			table = sub
+		default:
+			return nil, fmt.Errorf("key %q is not a table", strings.Join(keys[:i+1], "."))
+		}
+	}
+	return table, nil
+}
+
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipBlank(false)
+
+		var key string
+		switch p.peek() {
+		case '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		default:
+			start := p.pos
+			for !p.eof() && isTOMLBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, fmt.Errorf("expected key")
+			}
+			key = p.src[start:p.pos]
+		}
+		keys = append(keys, key)
+
+		p.skipBlank(false)
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func isTOMLBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) parseValue() (any, error) {
+	if p.eof() {
+		return nil, fmt.Errorf("expected value")
+	}
+
+	switch c := p.peek(); {
+	case strings.HasPrefix(p.src[p.pos:], `"""`):
+		return p.parseMultilineString(`"""`)
+	case strings.HasPrefix(p.src[p.pos:], `'''`):
+		return p.parseMultilineString(`'''`)
+	case c == '"':
+		return p.parseBasicString()
+	case c == '\'':
+		return p.parseLiteralString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	}
+
+	start := p.pos
+	for !p.eof() && strings.IndexByte("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ_+-.:", p.peek()) >= 0 {
+		p.pos++
+		// the date and time of a date-time may be separated by a space
+		if p.peek() == ' ' && p.pos-start == 10 && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]) {
+			p.pos++
+		}
+	}
+	token := p.src[start:p.pos]
+
+	switch token {
+	case "":
+		return nil, fmt.Errorf("expected value")
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		return strconv.ParseFloat(strings.TrimPrefix(token, "+"), 64)
+	}
+
+	if len(token) >= 8 && (token[2] == ':' || token[4] == '-') {
+		return parseTOMLDateTime(token)
+	}
+
+	if isLeadingZero(token) && !strings.ContainsAny(token, ".eE") {
+		return nil, fmt.Errorf("invalid value %q", token)
+	}
+	if i, err := strconv.ParseInt(token, 0, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(strings.ReplaceAll(token, "_", ""), 64); err == nil && !strings.HasPrefix(token, "0x") {
+		return f, nil
+	}
+
+	return nil, fmt.Errorf("invalid value %q", token)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isLeadingZero reports whether a decimal integer has leading zeros, which
+// TOML does not allow and strconv parses as octal
+func isLeadingZero(token string) bool {
+	token = strings.TrimLeft(token, "+-")
+	return len(token) > 1 && token[0] == '0' && isDigit(token[1])
+}
+
+func parseTOMLDateTime(token string) (any, error) {
+	token = strings.Replace(token, " ", "T", 1)
+	if t, err := time.Parse(time.RFC3339Nano, token); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05.999999999", "2006-01-02", "15:04:05.999999999"} {
+		if _, err := time.Parse(layout, token); err == nil {
+			return token, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid date-time %q", token)
+}
+
+func (p *tomlParser) parseArray() (any, error) {
+	p.pos++
+	values := []any{}
+	for {
+		p.skipBlank(true)
+		if p.peek() == ']' {
+			p.pos++
+			return values, nil
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		p.skipBlank(true)
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return values, nil
+		default:
+			return nil, fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (any, error) {
+	p.pos++
+	table := map[string]any{}
+
+	p.skipBlank(false)
+	if p.peek() == '}' {
+		p.pos++
+		return table, nil
+	}
+
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+
+		p.skipBlank(false)
+		switch p.peek() {
+		case ',':
+			p.pos++
+			p.skipBlank(false)
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, fmt.Errorf("expected , or } in inline table")
+		}
+	}
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", fmt.Errorf("unterminated string")
+		}
+
+		c := p.peek()
+		switch c {
+		case '"':
+			p.pos++
+			return sb.String(), nil
+		case '\\':
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	end := strings.IndexAny(p.src[p.pos:], "'\n")
+	if end < 0 || p.src[p.pos+end] != '\'' {
+		return "", fmt.Errorf("unterminated string")
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+func (p *tomlParser) parseMultilineString(delim string) (string, error) {
+	p.pos += len(delim)
+	// a newline directly after the opening delimiter is trimmed
+	if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.pos += 2
+		p.line++
+	} else if p.peek() == '\n' {
+		p.pos++
+		p.line++
+	}
+
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", fmt.Errorf("unterminated string")
+		}
+
+		if strings.HasPrefix(p.src[p.pos:], delim) {
+			p.pos += len(delim)
+			// up to two quotes may directly precede the closing delimiter
+			for i := 0; i < 2 && !p.eof() && p.peek() == delim[0]; i++ {
+				sb.WriteByte(delim[0])
+				p.pos++
+			}
+			return sb.String(), nil
+		}
+
+		c := p.peek()
+		switch {
+		case c == '\\' && delim == `"""`:
+			// a backslash at the end of a line trims the following whitespace
+			rest := strings.TrimLeft(p.src[p.pos+1:], " \t")
+			if strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+				p.pos = len(p.src) - len(rest)
+				p.skipBlankLines()
+				continue
+			}
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		default:
+			if c == '\n' {
+				p.line++
+			}
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// skipBlankLines skips whitespace including newlines, but not comments
+func (p *tomlParser) skipBlankLines() {
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+		if p.peek() == '\n' {
+			p.line++
+		}
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseEscape(sb *strings.Builder) error {
+	p.pos++
+	if p.eof() {
+		return fmt.Errorf("unterminated string")
+	}
+
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case 'e':
+		sb.WriteByte('\x1b')
+	case '"', '\\':
+		sb.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.src) {
+			return fmt.Errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return fmt.Errorf("invalid unicode escape %q", p.src[p.pos-2:p.pos+n])
+		}
+		sb.WriteRune(rune(code))
+		p.pos += n
+	default:
+		return fmt.Errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
+
+// skipBlank skips whitespace and comments, and newlines if newlines is set
+func (p *tomlParser) skipBlank(newlines bool) {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\r':
+			p.pos++
+		case '\n':
+			if !newlines {
+				return
+			}
+			p.line++
+			p.pos++
+		case '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) expectLineEnd() error {
+	p.skipBlank(false)
+	if p.eof() {
+		return nil
+	}
+	if p.peek() != '\n' {
+		return fmt.Errorf("expected newline, found %q", p.peek())
+	}
+	return nil
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
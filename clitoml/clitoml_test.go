@@ -0,0 +1,181 @@
+package clitoml
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.toml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+# global settings
+verbose = true
+
+[serve]
+port = 8080
+hosts = ["a", "b"]
+
+[db.migrate]
+dry-run = true
+`), 0o644))
+
+	var (
+		verbose, dryRun bool
+		port            int64
+		hosts           []string
+	)
+	cmd := &cli.Command{
+		Name:       "app",
+		ConfigFile: &cli.ConfigFile{Flag: "config"},
+		Flags:      []cli.Flag{&cli.BoolFlag{Name: "verbose"}},
+		Commands: []*cli.Command{
+			{
+				Name: "serve",
+				Flags: []cli.Flag{
+					&cli.IntFlag{Name: "port"},
+					&cli.StringSliceFlag{Name: "hosts"},
+				},
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					verbose, port, hosts = cmd.Bool("verbose"), cmd.Int("port"), cmd.StringSlice("hosts")
+					return nil
+				},
+			},
+			{
+				Name: "db",
+				Commands: []*cli.Command{
+					{
+						Name:  "migrate",
+						Flags: []cli.Flag{&cli.BoolFlag{Name: "dry-run"}},
+						Action: func(_ context.Context, cmd *cli.Command) error {
+							dryRun = cmd.Bool("dry-run")
+							return nil
+						},
+					},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, cmd.Run(context.Background(), []string{"app", "--config", path, "serve"}))
+	assert.True(t, verbose)
+	assert.Equal(t, int64(8080), port)
+	assert.Equal(t, []string{"a", "b"}, hosts)
+
+	cmd = cmd.Clone()
+	require.NoError(t, cmd.Run(context.Background(), []string{"app", "--config", path, "db", "migrate"}))
+	assert.True(t, dryRun)
+}
+
+func TestDecode(t *testing.T) {
+	values, err := Format.Decode([]byte(`
+title = "a \"quoted\" \u00e9" # comment
+path = 'C:\temp'
+count = 1_000
+mask = 0xff
+ratio = 2.5e1
+enabled = false
+released = 1979-05-27T07:32:00Z
+day = 1979-05-27
+list = [
+  1,
+  2, # trailing comma
+]
+point = { x = 1, y.z = "w" }
+text = """
+one \
+  two"""
+a.b = "dotted"
+
+[[plugins]]
+name = "first"
+
+[[plugins]]
+name = "second"
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"title":    `a "quoted" é`,
+		"path":     `C:\temp`,
+		"count":    int64(1000),
+		"mask":     int64(255),
+		"ratio":    25.0,
+		"enabled":  false,
+		"released": time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC),
+		"day":      "1979-05-27",
+		"list":     []any{int64(1), int64(2)},
+		"point":    map[string]any{"x": int64(1), "y": map[string]any{"z": "w"}},
+		"text":     "one two",
+		"a":        map[string]any{"b": "dotted"},
+		"plugins": []any{
+			map[string]any{"name": "first"},
+			map[string]any{"name": "second"},
+		},
+	}, values)
+
+	for src, expected := range map[string]string{
+		"a = ":           "line 1: expected value",
+		"a = 1\na = 2":   `line 2: key "a" is already defined`,
+		"[x]\n[x]":       `line 2: table "x" is already defined`,
+		"a = 1 b":        "line 1: expected newline",
+		"a = \"x":        "line 1: unterminated string",
+		"a = 01":         `line 1: invalid value "01"`,
+		"a = 1\n[a.b]\n": `line 2: key "a" is not a table`,
+		"a = [1 2]":      "line 1: expected , or ] in array",
+	} {
+		_, err := Format.Decode([]byte(src))
+		assert.ErrorContains(t, err, expected, src)
+	}
+}
+
+func TestConfigInit(t *testing.T) {
+	newCmd := func(out *bytes.Buffer, port *int64, tags *[]string) *cli.Command {
+		return &cli.Command{
+			Name:       "app",
+			Writer:     out,
+			ConfigFile: &cli.ConfigFile{Flag: "config"},
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "name", Value: "my app", Usage: "`NAME` of the app"},
+				&cli.IntFlag{Name: "port", Value: 8080},
+				&cli.StringSliceFlag{Name: "tags", Value: []string{"a", "b"}},
+			},
+			Commands: []*cli.Command{
+				cli.ConfigCommands(),
+				{
+					Name:  "db",
+					Flags: []cli.Flag{&cli.StringMapFlag{Name: "labels", Value: map[string]string{"env": "dev"}}},
+					Action: func(_ context.Context, cmd *cli.Command) error {
+						*port, *tags = cmd.Int("port"), cmd.StringSlice("tags")
+						return nil
+					},
+				},
+			},
+		}
+	}
+
+	var out bytes.Buffer
+	var port int64
+	var tags []string
+	require.NoError(t, newCmd(&out, &port, &tags).Run(context.Background(), []string{"app", "config", "init", "--format", "toml"}))
+	assert.Equal(t, `# NAME of the app
+name = "my app"
+port = 8080
+tags = ["a", "b"]
+
+[db]
+labels = { env = "dev" }
+`, out.String())
+
+	path := filepath.Join(t.TempDir(), "app.toml")
+	require.NoError(t, newCmd(&out, &port, &tags).Run(context.Background(), []string{"app", "config", "init", "--output", path}))
+	require.NoError(t, newCmd(&out, &port, &tags).Run(context.Background(), []string{"app", "--config", path, "db"}))
+	assert.Equal(t, int64(8080), port)
+	assert.Equal(t, []string{"a", "b"}, tags)
+}
@@ -0,0 +1,95 @@
+// Package cliyaml provides the YAML format for config files and for the
+// output of cli.Command.Print. Importing it registers the format, so config
+// files with the extension .yaml or .yml are read as YAML, config init
+// writes YAML with --format yaml and --output yaml prints YAML:
+//
+//	import _ "github.com/urfave/cli/v3/cliyaml"
+//
+// It is a separate package so that applications which do not use YAML do
+// not link a YAML parser.
+package cliyaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Format decodes config files in YAML format and writes them for config
+// init, see cli.ConfigFile.Format
+var Format cli.ConfigFormat = format{}
+
+func init() {
+	cli.RegisterConfigFormat("yaml", Format, ".yaml", ".yml")
+	cli.OutputFormatters["yaml"] = FormatOutput
+}
+
+type format struct{}
+
+func (format) Decode(data []byte) (map[string]any, error) {
+	values := map[string]any{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// WriteConfig writes the values of the node as YAML, with the usage of the
+// flags as comments
+func (format) WriteConfig(w io.Writer, node *cli.ConfigNode) error {
+	var sb strings.Builder
+	writeConfig(&sb, node, "")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeConfig(sb *strings.Builder, node *cli.ConfigNode, indent string) {
+	for _, e := range node.Entries {
+		if e.Usage != "" {
+			fmt.Fprintf(sb, "%s# %s\n", indent, e.Usage)
+		}
+		fmt.Fprintf(sb, "%s%s: %s\n", indent, key(e.Name), literal(e.Value))
+	}
+	for _, child := range node.Children {
+		fmt.Fprintf(sb, "%s%s:\n", indent, key(child.Name))
+		writeConfig(sb, child, indent+"  ")
+	}
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// key returns the key quoted if it is not a bare key
+func key(k string) string {
+	if bareKey.MatchString(k) {
+		return k
+	}
+	return strconv.Quote(k)
+}
+
+// literal returns the value as JSON, which is also valid YAML
+func literal(value any) string {
+	if value == nil {
+		return `""`
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return strconv.Quote(fmt.Sprint(value))
+	}
+	return string(data)
+}
+
+// FormatOutput writes v as YAML, see cli.OutputFormatters
+func FormatOutput(w io.Writer, v any) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
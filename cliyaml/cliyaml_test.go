@@ -0,0 +1,104 @@
+package cliyaml
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yml")
+	require.NoError(t, os.WriteFile(path, []byte("port: 8080\nserve:\n  host: example.com\n"), 0o644))
+
+	var port int64
+	var host string
+	cmd := &cli.Command{
+		Name:       "app",
+		ConfigFile: &cli.ConfigFile{Path: path},
+		Flags:      []cli.Flag{&cli.IntFlag{Name: "port"}},
+		Commands: []*cli.Command{
+			{
+				Name:  "serve",
+				Flags: []cli.Flag{&cli.StringFlag{Name: "host"}},
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					port, host = cmd.Int("port"), cmd.String("host")
+					return nil
+				},
+			},
+		},
+	}
+
+	require.NoError(t, cmd.Run(context.Background(), []string{"app", "serve"}))
+	assert.Equal(t, int64(8080), port)
+	assert.Equal(t, "example.com", host)
+
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	require.NoError(t, os.WriteFile(bad, []byte("port: [\n"), 0o644))
+	cmd = cmd.Clone()
+	cmd.ConfigFile = &cli.ConfigFile{Path: bad}
+	err := cmd.Run(context.Background(), []string{"app", "serve"})
+	assert.ErrorContains(t, err, `invalid config file "`+bad+`"`)
+}
+
+func TestConfigInit(t *testing.T) {
+	newCmd := func(out *bytes.Buffer, port *int64) *cli.Command {
+		return &cli.Command{
+			Name:       "app",
+			Writer:     out,
+			ConfigFile: &cli.ConfigFile{Flag: "config"},
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "name", Value: "my app", Usage: "`NAME` of the app"},
+				&cli.IntFlag{Name: "port", Value: 8080},
+				&cli.StringSliceFlag{Name: "tags", Value: []string{"a", "b"}},
+			},
+			Commands: []*cli.Command{
+				cli.ConfigCommands(),
+				{
+					Name:  "db",
+					Flags: []cli.Flag{&cli.StringMapFlag{Name: "labels", Value: map[string]string{"env": "dev"}}},
+					Action: func(_ context.Context, cmd *cli.Command) error {
+						*port = cmd.Int("port")
+						return nil
+					},
+				},
+			},
+		}
+	}
+
+	var out bytes.Buffer
+	var port int64
+	require.NoError(t, newCmd(&out, &port).Run(context.Background(), []string{"app", "config", "init", "--format", "yaml"}))
+	assert.Equal(t, `# NAME of the app
+name: "my app"
+port: 8080
+tags: ["a","b"]
+db:
+  labels: {"env":"dev"}
+`, out.String())
+
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	require.NoError(t, newCmd(&out, &port).Run(context.Background(), []string{"app", "config", "init", "--output", path}))
+	require.NoError(t, newCmd(&out, &port).Run(context.Background(), []string{"app", "--config", path, "db"}))
+	assert.Equal(t, int64(8080), port)
+}
+
+func TestFormatOutput(t *testing.T) {
+	var out bytes.Buffer
+	cmd := &cli.Command{
+		Name:   "app",
+		Writer: &out,
+		Flags:  []cli.Flag{cli.OutputFlag},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			return cmd.Print(map[string]any{"name": "dev", "nodes": []int{1, 2}})
+		},
+	}
+
+	require.NoError(t, cmd.Run(context.Background(), []string{"app", "-o", "yaml"}))
+	assert.Equal(t, "name: dev\nnodes:\n  - 1\n  - 2\n", out.String())
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"maps"
+	"sort"
+)
+
+// clonableFlag is implemented by flags which can be copied by Command.Clone
+type clonableFlag interface {
+	clone() Flag
+}
+
+// Clone returns a copy of the command and its subcommands which can be run
+// independently of the command, e.g. concurrently with it or with other
+// clones. The flags are copied without the values of previous runs and the
+// Metadata is copied. Funcs, writers, Destination pointers, Arguments and
+// the flags of other packages are shared with the command.
+func (cmd *Command) Clone() *Command {
+	return cmd.clone(nil, map[Flag]Flag{})
+}
+
+// clone copies the command, using the copies in flags for the flags that
+// were copied already, e.g. persistent flags of the parent
+func (cmd *Command) clone(parent *Command, flags map[Flag]Flag) *Command {
+	c := *cmd
+	c.parent = parent
+	c.runState = runState{}
+	c.Metadata = maps.Clone(cmd.Metadata)
+
+	c.Flags = cloneFlags(cmd.Flags, flags)
+
+	c.MutuallyExclusiveFlags = nil
+	for _, grp := range cmd.MutuallyExclusiveFlags {
+		grpFlags := grp.Flags
+		grp.Flags = nil
+		for _, grpf := range grpFlags {
+			grp.Flags = append(grp.Flags, cloneFlags(grpf, flags))
+		}
+		c.MutuallyExclusiveFlags = append(c.MutuallyExclusiveFlags, grp)
+	}
+
+	if cmd.timeoutFlag != nil {
+		c.timeoutFlag = cloneFlag(cmd.timeoutFlag, flags).(*DurationFlag)
+	}
+	if cmd.forceFlag != nil {
+		c.forceFlag = cloneFlag(cmd.forceFlag, flags).(*BoolFlag)
+	}
+	if cmd.helpAllFlag != nil {
+		c.helpAllFlag = cloneFlag(cmd.helpAllFlag, flags).(*BoolFlag)
+	}
+
+	c.Commands = nil
+	for _, subCmd := range cmd.Commands {
+		c.Commands = append(c.Commands, subCmd.clone(&c, flags))
+	}
+
+	if cmd.categories != nil {
+		c.categories = newCommandCategories()
+		for _, subCmd := range c.Commands {
+			c.categories.AddCommand(subCmd.Category, subCmd)
+		}
+		c.defineCategories()
+		sort.Sort(c.categories.(*commandCategories))
+	}
+
+	return &c
+}
+
+// cloneFlags returns the copies of the flags, see cloneFlag
+func cloneFlags(fls []Flag, flags map[Flag]Flag) []Flag {
+	if fls == nil {
+		return nil
+	}
+	c := make([]Flag, len(fls))
+	for i, fl := range fls {
+		c[i] = cloneFlag(fl, flags)
+	}
+	return c
+}
+
+// cloneFlag returns the copy of the flag in flags, copying it first if
+// needed. The help and version flags are kept as they are applied as copies
+// anyway and are looked up by identity.
+func cloneFlag(fl Flag, flags map[Flag]Flag) Flag {
+	if fl == HelpFlag || fl == VersionFlag {
+		return fl
+	}
+	if c, ok := flags[fl]; ok {
+		return c
+	}
+
+	c := fl
+	if cf, ok := fl.(clonableFlag); ok {
+		c = cf.clone()
+	}
+	flags[fl] = c
+	return c
+}
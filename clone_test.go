@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCloneTestCommand() *Command {
+	return &Command{
+		Name: "app",
+		Flags: []Flag{
+			&StringFlag{Name: "region", Value: "eu"},
+			&BoolWithInverseFlag{BoolFlag: &BoolFlag{Name: "color"}},
+		},
+		MutuallyExclusiveFlags: []MutuallyExclusiveFlags{
+			{Flags: [][]Flag{{&BoolFlag{Name: "json"}}, {&BoolFlag{Name: "yaml"}}}},
+		},
+		Commands: []*Command{
+			{
+				Name: "deploy",
+				Flags: []Flag{
+					&IntFlag{Name: "replicas", Value: 1},
+				},
+				Action: func(_ context.Context, cmd *Command) error {
+					fmt.Fprintf(cmd.Root().Writer, "%s %d %v %v\n",
+						cmd.String("region"), cmd.Int("replicas"), cmd.Bool("color"), cmd.Bool("json"))
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func TestCommand_Clone(t *testing.T) {
+	cmd := newCloneTestCommand()
+
+	run := func(c *Command, args ...string) string {
+		var out bytes.Buffer
+		c.Writer = &out
+		require.NoError(t, c.Run(buildTestContext(t), append([]string{"app"}, args...)))
+		return out.String()
+	}
+
+	assert.Equal(t, "us 3 true true\n", run(cmd, "--region", "us", "--color", "--json", "deploy", "--replicas", "3"))
+	assert.Equal(t, "eu 1 false false\n", run(cmd.Clone(), "deploy"))
+	assert.Equal(t, "ap 1 false false\n", run(cmd.Clone(), "--region", "ap", "--no-color", "deploy"))
+
+	// the command itself keeps the values of its run
+	assert.Equal(t, "us", cmd.String("region"))
+	assert.NotSame(t, cmd.Commands[0], cmd.Clone().Commands[0])
+}
+
+func TestCommand_CloneSharesPersistentFlags(t *testing.T) {
+	cmd := newCloneTestCommand()
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "deploy"}))
+
+	clone := cmd.Clone()
+	region := clone.lookupFlag("region")
+	require.NotNil(t, region)
+	assert.NotSame(t, cmd.lookupFlag("region"), region)
+	assert.Same(t, region, clone.Commands[0].lookupFlag("region"))
+	assert.Same(t, clone, clone.Commands[0].parent)
+}
+
+// TestCommand_CloneConcurrent is meant to be run with the race detector
+func TestCommand_CloneConcurrent(t *testing.T) {
+	cmd := newCloneTestCommand()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			var out bytes.Buffer
+			c := cmd.Clone()
+			c.Writer = &out
+			region := fmt.Sprintf("r%d", i)
+			assert.NoError(t, c.Run(context.Background(), []string{"app", "--region", region, "deploy", "--replicas", fmt.Sprint(i)}))
+			assert.Equal(t, fmt.Sprintf("%s %d false false\n", region, i), out.String())
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCommand_CloneAfterParseArgsAndRun(t *testing.T) {
+	cmd := newCloneTestCommand()
+	cmd.Metadata = map[string]any{"owner": "ops"}
+
+	_, err := ParseArgs(cmd, []string{"app", "--region", "us", "deploy"})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	clone := cmd.Clone()
+	clone.Writer = &out
+	require.NoError(t, clone.Run(buildTestContext(t), []string{"app", "deploy"}))
+	assert.Equal(t, "eu 1 false false\n", out.String())
+
+	clone = clone.Clone()
+	assert.Equal(t, runState{}, clone.runState)
+	assert.Equal(t, runState{}, clone.Commands[0].runState)
+
+	clone.Metadata["owner"] = "dev"
+	assert.Equal(t, "ops", cmd.Metadata["owner"])
+}
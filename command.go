@@ -3,15 +3,21 @@ package cli
 import (
 	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime/debug"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
 )
 
@@ -20,6 +26,14 @@ const (
 	ignoreFlagPrefix = "test."
 
 	commandContextKey = contextKey("cli.context")
+
+	// name of the flag added to override Command.Timeout
+	timeoutFlagName = "timeout"
+	// name of the flag added to bypass Command.Cooldown
+	forceFlagName = "force"
+	// name of the flag added to list commands hidden with
+	// Command.HiddenUnless in help
+	helpAllFlagName = "all"
 )
 
 type contextKey string
@@ -38,15 +52,31 @@ type Command struct {
 	UsageText string `json:"usageText"`
 	// A short description of the arguments of this command
 	ArgsUsage string `json:"argsUsage"`
+	// Minimum number of arguments the command accepts, checked before
+	// the action runs
+	ArgsMin int `json:"argsMin"`
+	// Maximum number of arguments the command accepts, checked before
+	// the action runs, 0 means no maximum
+	ArgsMax int `json:"argsMax"`
 	// Version of the command
 	Version string `json:"version"`
+	// Additional information printed with the version by VersionCommand and
+	// with --version --json, e.g. the build configuration
+	ExtraVersionInfo map[string]string `json:"extraVersionInfo"`
 	// Longer explanation of how the command works
 	Description string `json:"description"`
 	// DefaultCommand is the (optional) name of a command
-	// to run if no command names are passed as CLI arguments.
+	// to run if no command names are passed as CLI arguments,
+	// e.g. when only flags are given.
 	DefaultCommand string `json:"defaultCommand"`
 	// The category the command is part of
 	Category string `json:"category"`
+	// Help entries for concepts rather than commands, shown with "help NAME"
+	// for this command and its subcommands
+	HelpTopics []HelpTopic `json:"helpTopics"`
+	// Descriptions and order of the categories of the subcommands of this
+	// command and its subcommands
+	Categories []CategoryDefinition `json:"categories"`
 	// List of child commands
 	Commands []*Command `json:"commands"`
 	// List of flags to parse
@@ -64,21 +94,69 @@ type Command struct {
 	// The function to call when checking for shell command completions
 	ShellComplete ShellCompleteFunc `json:"-"`
 	// An action to execute before any subcommands are run, but after the context is ready
-	// If a non-nil error is returned, no subcommands are run
+	// If a non-nil error is returned, no subcommands are run. The Before funcs of all
+	// commands from the root down to the invoked command run in that order, each one
+	// receiving the context returned by the previous one
 	Before BeforeFunc `json:"-"`
 	// An action to execute after any subcommands are run, but after the subcommand has finished
-	// It is run even if Action() panics
+	// It is run even if Action() panics or returns an error. The After funcs run from the
+	// invoked command up to the root, each one receiving the context returned by the
+	// Before func of its command
 	After AfterFunc `json:"-"`
 	// The function to call when this command is invoked
 	Action ActionFunc `json:"-"`
+	// The prompt of the shell started by RunShell, defaults to the name of
+	// the command followed by "> "
+	ShellPrompt string `json:"shellPrompt"`
+	// File the lines entered in the shell started by RunShell are appended
+	// to, and loaded from when the shell starts
+	ShellHistoryFile string `json:"shellHistoryFile"`
+	// Function reading the lines of the shell started by RunShell instead of
+	// the Reader, e.g. to use a line editor
+	ShellReadLine ShellReadLineFunc `json:"-"`
+	// Aliases for the subcommands of the root command defined by users, e.g.
+	// loaded with LoadUserAliases, mapping a name to the arguments it is
+	// replaced with when given as the first positional argument
+	UserAliases map[string][]string `json:"userAliases"`
+	// Function building the actual command when it is invoked, which allows
+	// deferring the construction of heavy command trees. The Name, Aliases,
+	// Usage and Category of the command are used for help and completion
+	// until it is loaded and as defaults for the loaded command
+	Load func() *Command `json:"-"`
+	// Middleware wrapping the Action of this command and all its
+	// subcommands, the first one is the outermost. See also Use
+	Middleware []MiddlewareFunc `json:"-"`
 	// Execute this function if the proper command cannot be found
 	CommandNotFound CommandNotFoundFunc `json:"-"`
 	// Execute this function if a usage error occurs.
 	OnUsageError OnUsageErrorFunc `json:"-"`
 	// Execute this function when an invalid flag is accessed from the context
 	InvalidFlagAccessHandler InvalidFlagAccessFunc `json:"-"`
+	// Width help is wrapped at, taken from the COLUMNS env var or the width
+	// of the terminal when writing to one if not set. A negative width
+	// disables wrapping.
+	HelpWidth int `json:"helpWidth"`
+	// Style of the help output when writing to a terminal, e.g.
+	// DefaultHelpStyle. It is disabled by the NO_COLOR env var and the
+	// NoColorFlag, which is added to the command.
+	HelpStyle *HelpStyle `json:"-"`
+	// Pager showing help longer than the terminal when writing to a
+	// terminal, e.g. clipager.Pager running the pager in the PAGER env var.
+	// Setting the NO_PAGER env var disables the pager.
+	Pager PagerFunc `json:"-"`
+	// Whether to always list flags in help grouped by category, with
+	// required flags first marked as (required) and the values accepted by
+	// a flag shown. Set on the root command, applies to all commands.
+	GroupFlags bool `json:"groupFlags"`
+	// Config file flag values are read from, set on the root command
+	ConfigFile *ConfigFile `json:"-"`
 	// Boolean to hide this command from help or completion
 	Hidden bool `json:"hidden"`
+	// Function deciding whether to show this command in help or completion,
+	// the command is hidden unless it returns true. The parent command and
+	// its help command get an --all flag, so it can show the command only
+	// in help asked for with --all, see HelpAll.
+	HiddenUnless func(cmd *Command) bool `json:"-"`
 	// List of all authors who contributed (string or fmt.Stringer)
 	// TODO: ~string | fmt.Stringer when interface unions are available
 	Authors []any `json:"authors"`
@@ -88,12 +166,102 @@ type Command struct {
 	Reader io.Reader `json:"-"`
 	// Writer writer to write output to
 	Writer io.Writer `json:"-"`
-	// ErrWriter writes error output
+	// ErrWriter writes error output. The messages of errors exiting the
+	// process are written to the package level ErrWriter if it is not set.
 	ErrWriter io.Writer `json:"-"`
 	// ExitErrHandler processes any error encountered while running an App before
 	// it is returned to the caller. If no function is provided, HandleExitCoder
 	// is used as the default behavior.
 	ExitErrHandler ExitErrHandlerFunc `json:"-"`
+	// OsExiter is called with the exit code when an error exits the process,
+	// applicable to root command only. Defaults to the package level OsExiter.
+	OsExiter func(code int) `json:"-"`
+	// HelpPrinter writes the help output, applicable to root command only.
+	// Defaults to the package level HelpPrinter.
+	HelpPrinter func(w io.Writer, templ string, data any) `json:"-"`
+	// Signals, e.g. os.Interrupt and syscall.SIGTERM, which cancel the
+	// context passed to Before, Action and After funcs so the command can
+	// shut down gracefully. A second signal exits immediately with code 130
+	// applicable to root command only
+	HandleSignals []os.Signal `json:"-"`
+	// Maximum time the Action may run, after which the context passed to it
+	// is canceled and the command fails with exit code 124. Can be
+	// overridden with a --timeout flag which is added to the command unless
+	// it already defines a flag of that name
+	Timeout time.Duration `json:"timeout"`
+	// Whether only one instance of this command and its subcommands may run
+	// at a time for a user. A lock file named after the command in the
+	// CacheDir of the root command is locked while the Action runs, and
+	// running another instance fails with ErrLocked. Exclusive commands fail to run on platforms without
+	// file locks, like wasip1.
+	Exclusive bool `json:"exclusive"`
+	// Path of the lock file of an exclusive command, setting it makes the
+	// command and its subcommands exclusive
+	LockFile string `json:"lockFile"`
+	// Minimum time between runs of the Action, e.g. for checking for updates.
+	// The Action is skipped if it ran successfully less than the Cooldown
+	// ago, unless --force is set. The flag is added to the command unless it
+	// already defines a flag of that name. The time of the last run is stored
+	// in the CacheDir.
+	Cooldown time.Duration `json:"cooldown"`
+	// Whether to recover panics in Actions and hooks, which are then
+	// reported with a crash report including the running command, its flag
+	// values and the stack instead of crashing the program
+	// applicable to root command only
+	Recover bool `json:"recover"`
+	// Function to call instead of printing a crash report for recovered panics
+	PanicHandler PanicHandlerFunc `json:"-"`
+	// Exit code for recovered panics, defaults to 2
+	PanicExitCode int `json:"panicExitCode"`
+	// File to additionally write the crash report of recovered panics to
+	CrashFile string `json:"crashFile"`
+	// Function resolving unknown subcommands of this command and its
+	// subcommands to plugins, which are then run with the remaining
+	// arguments, see cliplugin.PathResolver for external executables
+	PluginResolver PluginResolverFunc `json:"-"`
+	// Observer notified of lifecycle events like the command being resolved
+	// or its Action being started
+	// applicable to root command only
+	Observer Observer `json:"-"`
+	// Instrumentation creating a span for the run of the resolved command,
+	// e.g. with OpenTelemetry
+	// applicable to root command only
+	Instrumentation Instrumentation `json:"-"`
+	// Anonymous usage telemetry of running commands, only recorded after the
+	// user opted in, see TelemetryCommand
+	// applicable to root command only
+	Telemetry *TelemetryConfig `json:"-"`
+	// Writer an audit record of each run of a command is appended to as a
+	// JSON line, see AuditRecord
+	// applicable to root command only
+	AuditWriter io.Writer `json:"-"`
+	// Path of a file audit records are appended to like to AuditWriter
+	// applicable to root command only
+	AuditFile string `json:"auditFile"`
+	// Store of the values returned by State, a file in the DataDir if nil
+	// applicable to root command only
+	StateStore StateStore `json:"-"`
+	// Store of the secrets returned by Credentials, e.g. the keychain of the
+	// OS returned by clikeychain.Store
+	// applicable to root command only
+	CredentialStore CredentialStore `json:"-"`
+	// Auth contexts returned by Auth, see AuthCommands
+	// applicable to root command only
+	AuthConfig *AuthConfig `json:"-"`
+	// Clock returning the current time, e.g. to test commands with a fixed
+	// time, time.Now if nil
+	// applicable to root command only
+	Clock func() time.Time `json:"-"`
+	// Whether to print errors as JSON objects like {"error": "...", "code": 1}
+	// to ErrWriter and exit with their exit code instead of printing them as
+	// text, usage errors are reported with code 64 and category "usage"
+	// applicable to root command only
+	JSONErrors bool `json:"jsonErrors"`
+	// OnError is called with any error returned by an Action, Before/After
+	// or flag action function of this command or its subcommands, before the
+	// error is passed on to ExitErrHandler. The OnError funcs of nested
+	// commands are called first
+	OnError OnErrorFunc `json:"-"`
 	// Other custom info
 	Metadata map[string]interface{} `json:"metadata"`
 	// Carries a function which returns app specific info.
@@ -110,17 +278,32 @@ type Command struct {
 	// single-character bool arguments into one
 	// i.e. foobar -o -v -> foobar -ov
 	UseShortOptionHandling bool `json:"useShortOptionHandling"`
-	// Enable suggestions for commands and flags
+	// Enable suggestions for commands and flags, applies to all subcommands
 	Suggest bool `json:"suggest"`
+	// Disable suggestions for this command and its subcommands even if a
+	// parent command has Suggest set
+	SuggestionsDisabled bool `json:"suggestionsDisabled"`
+	// Minimum similarity between 0 and 1 a candidate must have to be
+	// suggested, the default of 0 always suggests the closest candidate
+	SuggestThreshold float64 `json:"suggestThreshold"`
 	// Allows global flags set by libraries which use flag.XXXVar(...) directly
 	// to be parsed through this library
 	AllowExtFlags bool `json:"allowExtFlags"`
 	// Treat all flags as normal arguments if true
 	SkipFlagParsing bool `json:"skipFlagParsing"`
+	// Treat everything starting at the first positional argument as normal
+	// arguments, so flags are only parsed before it
+	SkipFlagParsingAfterFirstArg bool `json:"skipFlagParsingAfterFirstArg"`
 	// CustomHelpTemplate the text template for the command help topic.
 	// cli.go uses text/template to render templates. You can
 	// render custom help text by setting this variable.
 	CustomHelpTemplate string `json:"-"`
+	// Help templates used instead of the package level templates for this
+	// command and its subcommands, unless they set a custom template
+	HelpTemplates *HelpTemplates `json:"-"`
+	// Functions available in the help templates of this command and its
+	// subcommands in addition to the default ones
+	HelpTemplateFuncs map[string]any `json:"-"`
 	// Use longest prefix match for commands
 	PrefixMatchCommands bool `json:"prefixMatchCommands"`
 	// Custom suggest command for matching
@@ -132,24 +315,146 @@ type Command struct {
 	// Whether to read arguments from stdin
 	// applicable to root command only
 	ReadArgsFromStdin bool `json:"readArgsFromStdin"`
+	// Whether to expand arguments of the form @FILE to the whitespace
+	// separated arguments read from FILE, use @@ for a literal @
+	// applicable to root command only
+	ResponseFiles bool `json:"responseFiles"`
+	// Dotenv files env vars are loaded from before flag values are read
+	// from env vars, e.g. .env and .env.local. Values of later files take
+	// precedence, env vars which are already set are not changed.
+	// applicable to root command only
+	DotEnv []string `json:"dotEnv"`
+	// Whether a missing dotenv file is an error instead of being ignored
+	DotEnvRequired bool `json:"dotEnvRequired"`
+	// Prefix used to derive an env var for each flag of this command and
+	// its subcommands, e.g. the flag listen-addr is read from
+	// MYAPP_LISTEN_ADDR for the prefix MYAPP
+	EnvVarPrefix string `json:"envVarPrefix"`
+	// Whether the flags of this command and its subcommands can also be
+	// read from a file whose path is given by the env var suffixed with
+	// _FILE, e.g. MYAPP_TOKEN_FILE for MYAPP_TOKEN
+	FilePathEnvVars bool `json:"filePathEnvVars"`
+	// Directories the flags of this command and its subcommands are read
+	// from after their other sources, from a file named like the flag in
+	// the first directory having one, e.g. /var/run/secrets/myapp/token for
+	// the flag token, like secrets and config maps mounted by Kubernetes
+	ValueDirs []string `json:"valueDirs"`
+	// Sources the flags of this command and its subcommands are looked up
+	// in by their name, in order after their other sources and ValueDirs,
+	// e.g. a secret store holding the values of several flags
+	FlagSources []MapSource `json:"-"`
+	// How to handle flags which are not defined for this command
+	UnknownFlagPolicy UnknownFlagPolicy `json:"unknownFlagPolicy"`
+	// Names of unknown flags which take a value. With an UnknownFlagPolicy
+	// other than UnknownFlagError, a value given as a separate argument like
+	// in --output json is skipped or collected together with such a flag
+	// instead of becoming an argument.
+	UnknownValueFlags []string `json:"unknownValueFlags"`
+	// Whether to match commands and flags of this command and its
+	// subcommands case-insensitively, exact matches take precedence
+	CaseInsensitive bool `json:"caseInsensitive"`
+	// Whether to accept unambiguous prefixes of the names of the
+	// subcommands and long flags of this command and its subcommands
+	AllowAbbreviations bool `json:"allowAbbreviations"`
+	// Whether to print for each flag where its value was read from to
+	// ErrWriter before running the action, also enabled by setting the env
+	// var <PREFIX>_CLI_DEBUG to true, where PREFIX is the EnvVarPrefix or
+	// the upper case name of the command, e.g. MYAPP_CLI_DEBUG
+	// applicable to root command only
+	DebugResolution bool `json:"debugResolution"`
+	// Whether to prompt for the values of missing required flags on the
+	// terminal instead of failing, input of sensitive flags is hidden
+	// applicable to root command only
+	PromptForMissing bool `json:"promptForMissing"`
 
 	// categories contains the categorized commands and is populated on app startup
 	categories CommandCategories
+	// whether the command was returned by ConfigCommands, its flags are not
+	// written to config files
+	isConfigCommand bool
+	// whether the command was returned by TelemetryCommand, running it is
+	// not recorded
+	isTelemetryCommand bool
+	// whether ErrWriter was not set and defaults to os.Stderr
+	defaultErrWriter bool
+	// The parent of this command. This value will be nil for the
+	// command at the root of the graph.
+	parent *Command
+	// flag added to override Timeout
+	timeoutFlag *DurationFlag
+	// flag added to bypass Cooldown
+	forceFlag *BoolFlag
+	// flag added to list the subcommands hidden with HiddenUnless in help
+	helpAllFlag *BoolFlag
+	// track state of defaults
+	didSetupDefaults bool
+
+	// state of running the command, which is not copied by Clone
+	runState
+}
+
+// runState is the state of running a command, set while it is parsed and
+// run. Clone copies commands without it.
+type runState struct {
+	// values read from the config files and their joined paths
+	configLayers []configLayer
+	configKey    string
+	// descriptions of the config keys flags of this command were set from
+	configSources map[string]string
+	// guards the flag values and config state of the root command against
+	// WatchConfig, set by WatchConfig
+	valuesMu *sync.RWMutex
+	// reader of the input of prompts and the Reader it reads from
+	promptReader *bufio.Reader
+	promptSource io.Reader
+	// logger returned by Logger
+	logger *slog.Logger
+	// index of the Flags by name into the slice, built when the flags are
+	// parsed, and the Flags it was built from
+	flagIndex   map[string]int
+	flagIndexOf []Flag
+	// number of times the root command has been run, to read the sources
+	// of the flags once per run
+	runs int
+	// whether the command is only parsed by ParseArgs, not run
+	parseOnly bool
+	// default store returned by State
+	state StateStore
+	// ends the span started by the Instrumentation
+	spanEnd func(error)
+	// start of running the resolved command, for its telemetry event and
+	// audit record
+	runStart time.Time
+	// closed once the telemetry events sent in the background were sent,
+	// tracked on the root command
+	telemetryDone chan struct{}
 	// flagCategories contains the categorized flags and is populated on app startup
 	flagCategories FlagCategories
 	// flags that have been applied in current parse
 	appliedFlags []Flag
-	// The parent of this command. This value will be nil for the
-	// command at the root of the graph.
-	parent *Command
 	// the flag.FlagSet for this command
 	flagSet *flag.FlagSet
 	// parsed args
 	parsedArgs Args
+	// unknown flags collected with UnknownFlagCollect
+	unknownArgs []string
+	// innermost command currently running, tracked on the root command
+	runningCmd *Command
+	// funcs registered with OnShutdown
+	shutdownFuncs []func() error
+	// context returned by the Before funcs up to this command
+	beforeCtx context.Context
+	// lines entered in the shell started by RunShell
+	shellHistory []string
+	// format of the help selected with --help=FORMAT, tracked on the root
+	// command
+	helpFormat string
+	// whether help is shown with --all, tracked on the root command
+	helpAll bool
+	// whether running lines of the shell started by RunShell
+	inShell bool
 	// track state of error handling
 	isInError bool
-	// track state of defaults
-	didSetupDefaults bool
 	// whether in shell completion mode
 	shellCompletion bool
 }
@@ -168,13 +473,116 @@ func (cmd *Command) FullName() string {
 }
 
 func (cmd *Command) Command(name string) *Command {
+	subCmd, _ := cmd.lookupCommand(name)
+	return subCmd
+}
+
+// lookupCommand finds the subcommand with the given name, taking the
+// CaseInsensitive and AllowAbbreviations settings into account. An error
+// is returned if name is an ambiguous abbreviation
+func (cmd *Command) lookupCommand(name string) (*Command, error) {
 	for _, subCmd := range cmd.Commands {
 		if subCmd.HasName(name) {
-			return subCmd
+			return subCmd, nil
 		}
 	}
 
-	return nil
+	caseInsensitive := cmd.caseInsensitive()
+	if caseInsensitive {
+		for _, subCmd := range cmd.Commands {
+			for _, n := range subCmd.Names() {
+				if strings.EqualFold(n, name) {
+					tracef("matched command %[1]q case-insensitively for %[2]q (cmd=%[3]q)", n, name, cmd.Name)
+					return subCmd, nil
+				}
+			}
+		}
+	}
+
+	if name == "" || !cmd.allowAbbreviations() {
+		return nil, nil
+	}
+
+	var matches []*Command
+	var candidates []string
+	for _, subCmd := range cmd.Commands {
+		for _, n := range subCmd.Names() {
+			if hasPrefix(n, name, caseInsensitive) {
+				matches = append(matches, subCmd)
+				candidates = append(candidates, subCmd.Name)
+				break
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		tracef("matched command %[1]q by abbreviation %[2]q (cmd=%[3]q)", matches[0].Name, name, cmd.Name)
+		return matches[0], nil
+	}
+
+	return nil, fmt.Errorf("ambiguous command %[1]q, could be: %[2]s", name, strings.Join(candidates, ", "))
+}
+
+// caseInsensitive returns true if the command or any of its parents
+// have CaseInsensitive set
+func (cmd *Command) caseInsensitive() bool {
+	for _, pCmd := range cmd.Lineage() {
+		if pCmd.CaseInsensitive {
+			return true
+		}
+	}
+
+	return false
+}
+
+// suggest returns true if suggestions are enabled by the closest command
+// in the lineage which has Suggest or SuggestionsDisabled set
+func (cmd *Command) suggest() bool {
+	for _, pCmd := range cmd.Lineage() {
+		if pCmd.SuggestionsDisabled {
+			return false
+		}
+		if pCmd.Suggest {
+			return true
+		}
+	}
+
+	return false
+}
+
+// closeEnough returns true if the suggestion is at least as similar to the
+// provided string as the SuggestThreshold of the command or its parents
+func (cmd *Command) closeEnough(suggestion, provided string) bool {
+	for _, pCmd := range cmd.Lineage() {
+		if pCmd.SuggestThreshold > 0 {
+			return jaroWinkler(suggestion, provided) >= pCmd.SuggestThreshold
+		}
+	}
+
+	return true
+}
+
+// allowAbbreviations returns true if the command or any of its parents
+// have AllowAbbreviations set
+func (cmd *Command) allowAbbreviations() bool {
+	for _, pCmd := range cmd.Lineage() {
+		if pCmd.AllowAbbreviations {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasPrefix reports whether s begins with prefix, optionally ignoring case
+func hasPrefix(s, prefix string, caseInsensitive bool) bool {
+	if caseInsensitive {
+		return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+	}
+	return strings.HasPrefix(s, prefix)
 }
 
 func (cmd *Command) setupDefaults(osArgs []string) {
@@ -227,6 +635,7 @@ func (cmd *Command) setupDefaults(osArgs []string) {
 	if cmd.ErrWriter == nil {
 		tracef("setting default ErrWriter as os.Stderr (cmd=%[1]q)", cmd.Name)
 		cmd.ErrWriter = os.Stderr
+		cmd.defaultErrWriter = true
 	}
 
 	if cmd.AllowExtFlags {
@@ -246,12 +655,29 @@ func (cmd *Command) setupDefaults(osArgs []string) {
 	}
 
 	cmd.ensureHelp()
+	cmd.ensureTimeoutFlag()
+	cmd.ensureForceFlag()
 
 	if !cmd.HideVersion && isRoot {
 		tracef("appending version flag (cmd=%[1]q)", cmd.Name)
 		cmd.appendFlag(VersionFlag)
 	}
 
+	if cmd.ConfigFile != nil && cmd.ConfigFile.Flag != "" && cmd.lookupFlag(cmd.ConfigFile.Flag) == nil && isRoot {
+		tracef("appending config file flag (cmd=%[1]q)", cmd.Name)
+		cmd.appendFlag(cmd.ConfigFile.configFileFlag())
+	}
+
+	if cmd.ConfigFile != nil && cmd.ConfigFile.ProfileFlag != "" && cmd.lookupFlag(cmd.ConfigFile.ProfileFlag) == nil && isRoot {
+		tracef("appending config profile flag (cmd=%[1]q)", cmd.Name)
+		cmd.appendFlag(cmd.ConfigFile.profileFlag())
+	}
+
+	if cmd.HelpStyle != nil && NoColorFlag != nil && isRoot {
+		tracef("appending no-color flag (cmd=%[1]q)", cmd.Name)
+		cmd.appendFlag(NoColorFlag)
+	}
+
 	if cmd.PrefixMatchCommands && cmd.SuggestCommandFunc == nil {
 		tracef("setting default SuggestCommandFunc (cmd=%[1]q)", cmd.Name)
 		cmd.SuggestCommandFunc = suggestCommand
@@ -279,6 +705,7 @@ func (cmd *Command) setupDefaults(osArgs []string) {
 	for _, subCmd := range cmd.Commands {
 		cmd.categories.AddCommand(subCmd.Category, subCmd)
 	}
+	cmd.defineCategories()
 
 	tracef("sorting command categories (cmd=%[1]q)", cmd.Name)
 	sort.Sort(cmd.categories.(*commandCategories))
@@ -288,21 +715,73 @@ func (cmd *Command) setupDefaults(osArgs []string) {
 		grp.propagateCategory()
 	}
 
+	cmd.setupFlagEnvSources()
+
 	tracef("setting flag categories (cmd=%[1]q)", cmd.Name)
-	cmd.flagCategories = newFlagCategoriesFromFlags(cmd.allFlags())
+	cmd.flagCategories = cmd.newFlagCategories()
 
 	if cmd.Metadata == nil {
 		tracef("setting default Metadata (cmd=%[1]q)", cmd.Name)
 		cmd.Metadata = map[string]any{}
 	}
+}
+
+// ensureLoaded replaces the command with the one returned by its Load func,
+// if any, and sets up the loaded command graph
+func (cmd *Command) ensureLoaded() {
+	if cmd.Load == nil {
+		return
+	}
+
+	tracef("loading command (cmd=%[1]q)", cmd.Name)
+	loaded := cmd.Load()
+	if loaded == nil {
+		cmd.Load = nil
+		return
+	}
+
+	if loaded.Name == "" {
+		loaded.Name = cmd.Name
+	}
+	if len(loaded.Aliases) == 0 {
+		loaded.Aliases = cmd.Aliases
+	}
+	if loaded.Usage == "" {
+		loaded.Usage = cmd.Usage
+	}
+	if loaded.Category == "" {
+		loaded.Category = cmd.Category
+	}
+
+	parent := cmd.parent
+	*cmd = *loaded
+	cmd.Load = nil
+	cmd.parent = parent
+
+	if parent != nil {
+		cmd.setupSubcommand()
+		cmd.setupCommandGraph()
+	}
+}
 
-	if len(cmd.SliceFlagSeparator) != 0 {
-		tracef("setting defaultSliceFlagSeparator from cmd.SliceFlagSeparator (cmd=%[1]q)", cmd.Name)
-		defaultSliceFlagSeparator = cmd.SliceFlagSeparator
+// LoadCommands loads all commands of the command graph which have a Load
+// func, e.g. before generating documentation for the whole graph.
+func (cmd *Command) LoadCommands() {
+	cmd.ensureLoaded()
+	for _, subCmd := range cmd.Commands {
+		subCmd.LoadCommands()
 	}
+}
 
-	tracef("setting disableSliceFlagSeparator from cmd.DisableSliceFlagSeparator (cmd=%[1]q)", cmd.Name)
-	disableSliceFlagSeparator = cmd.DisableSliceFlagSeparator
+// defineCategories applies the category definitions of the command and its
+// parents to its categories, the nearest definition of a category wins
+func (cmd *Command) defineCategories() {
+	lineage := cmd.Lineage()
+	for i := len(lineage) - 1; i >= 0; i-- {
+		for _, def := range lineage[i].Categories {
+			cmd.categories.(*commandCategories).define(def)
+		}
+	}
 }
 
 func (cmd *Command) setupCommandGraph() {
@@ -319,6 +798,8 @@ func (cmd *Command) setupSubcommand() {
 	tracef("setting up self as sub-command (cmd=%[1]q)", cmd.Name)
 
 	cmd.ensureHelp()
+	cmd.ensureTimeoutFlag()
+	cmd.ensureForceFlag()
 
 	tracef("setting command categories (cmd=%[1]q)", cmd.Name)
 	cmd.categories = newCommandCategories()
@@ -326,6 +807,7 @@ func (cmd *Command) setupSubcommand() {
 	for _, subCmd := range cmd.Commands {
 		cmd.categories.AddCommand(subCmd.Category, subCmd)
 	}
+	cmd.defineCategories()
 
 	tracef("sorting command categories (cmd=%[1]q)", cmd.Name)
 	sort.Sort(cmd.categories.(*commandCategories))
@@ -335,8 +817,58 @@ func (cmd *Command) setupSubcommand() {
 		grp.propagateCategory()
 	}
 
+	cmd.setupFlagEnvSources()
+
 	tracef("setting flag categories (cmd=%[1]q)", cmd.Name)
-	cmd.flagCategories = newFlagCategoriesFromFlags(cmd.allFlags())
+	cmd.flagCategories = cmd.newFlagCategories()
+}
+
+// setupFlagEnvSources derives env vars and further sources for the flags of
+// the command from the EnvVarPrefix, FilePathEnvVars, ValueDirs and
+// FlagSources settings of the command or the closest parent which sets them
+func (cmd *Command) setupFlagEnvSources() {
+	prefix := ""
+	filePathEnvVars := false
+	var dirs []string
+	var flagSources []MapSource
+	for c := cmd; c != nil; c = c.parent {
+		if prefix == "" {
+			prefix = c.EnvVarPrefix
+		}
+		if dirs == nil {
+			dirs = c.ValueDirs
+		}
+		if flagSources == nil {
+			flagSources = c.FlagSources
+		}
+		filePathEnvVars = filePathEnvVars || c.FilePathEnvVars
+	}
+
+	sources := make([]MapSource, 0, len(dirs)+len(flagSources))
+	for _, dir := range dirs {
+		sources = append(sources, Dir(dir))
+	}
+	sources = append(sources, flagSources...)
+
+	if prefix == "" && !filePathEnvVars && len(sources) == 0 {
+		return
+	}
+
+	tracef("setting up flag env sources with prefix %[1]q, file path env vars %[2]v and sources %[3]v (cmd=%[4]q)", prefix, filePathEnvVars, sources, cmd.Name)
+	for _, fl := range cmd.allFlags() {
+		if fl == HelpFlag || fl == VersionFlag {
+			continue
+		}
+		ef, ok := fl.(envSourceFlag)
+		if !ok {
+			continue
+		}
+		ef.addPrefixedEnvVar(prefix)
+		if filePathEnvVars {
+			ef.addFilePathEnvVars()
+		}
+		ef.addMapSources(sources)
+	}
 }
 
 func (cmd *Command) hideHelp() bool {
@@ -367,7 +899,97 @@ func (cmd *Command) ensureHelp() {
 			tracef("appending HelpFlag (cmd=%[1]q)", cmd.Name)
 			cmd.appendFlag(HelpFlag)
 		}
+
+		if slices.ContainsFunc(cmd.Commands, func(subCmd *Command) bool { return subCmd.HiddenUnless != nil }) {
+			cmd.ensureHelpAllFlag()
+			helpCommand.ensureHelpAllFlag()
+		}
+	}
+}
+
+// ensureHelpAllFlag adds an --all flag listing the commands hidden with
+// HiddenUnless in help unless the command already defines a flag of that
+// name
+func (cmd *Command) ensureHelpAllFlag() {
+	if cmd.helpAllFlag != nil {
+		return
+	}
+
+	for _, fl := range cmd.Flags {
+		if slices.Contains(fl.Names(), helpAllFlagName) {
+			tracef("not adding help all flag as it is already defined (cmd=%[1]q)", cmd.Name)
+			return
+		}
+	}
+
+	tracef("appending help all flag (cmd=%[1]q)", cmd.Name)
+	cmd.helpAllFlag = &BoolFlag{
+		Name:        helpAllFlagName,
+		Usage:       "show all commands in help",
+		HideDefault: true,
+		Local:       true,
+	}
+	cmd.appendFlag(cmd.helpAllFlag)
+}
+
+// HelpAll returns true if help is shown with --all, for HiddenUnless to
+// show commands only then
+func (cmd *Command) HelpAll() bool {
+	return cmd.Root().helpAll
+}
+
+// OnShutdown registers a function to be called when the root command returns
+// from Run, whether normally, with an error or because of a panic. The
+// functions are called in reverse order of registration after all After
+// funcs have run, and their errors are added to the error returned by Run.
+func (cmd *Command) OnShutdown(fn func() error) {
+	root := cmd.Root()
+	root.shutdownFuncs = append(root.shutdownFuncs, fn)
+}
+
+func (cmd *Command) runShutdownFuncs() error {
+	fns := cmd.shutdownFuncs
+	cmd.shutdownFuncs = nil
+
+	var errs []error
+	for i := len(fns) - 1; i >= 0; i-- {
+		tracef("running shutdown func %[1]d (cmd=%[2]q)", i, cmd.Name)
+		if err := fns[i](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return newMultiError(errs...)
+}
+
+// ensureTimeoutFlag adds a flag to override Timeout unless the command
+// already defines a flag of that name
+func (cmd *Command) ensureTimeoutFlag() {
+	if cmd.Timeout <= 0 || cmd.timeoutFlag != nil {
+		return
+	}
+
+	for _, fl := range cmd.Flags {
+		if slices.Contains(fl.Names(), timeoutFlagName) {
+			tracef("not adding timeout flag as it is already defined (cmd=%[1]q)", cmd.Name)
+			return
+		}
 	}
+
+	tracef("appending timeout flag (cmd=%[1]q)", cmd.Name)
+	cmd.timeoutFlag = &DurationFlag{
+		Name:  timeoutFlagName,
+		Usage: "maximum time the command may run",
+		Value: cmd.Timeout,
+		Local: true,
+	}
+	cmd.appendFlag(cmd.timeoutFlag)
 }
 
 func (cmd *Command) parseArgsFromStdin() ([]string, error) {
@@ -459,7 +1081,31 @@ func (cmd *Command) Run(ctx context.Context, osArgs []string) (deferErr error) {
 		cmd.parent = v
 	}
 
+	cmd.Root().runningCmd = cmd
+
 	if cmd.parent == nil {
+		if cmd.Recover {
+			defer func() {
+				if r := recover(); r != nil {
+					deferErr = cmd.handlePanic(ctx, r, debug.Stack())
+				}
+			}()
+		}
+		defer cmd.waitTelemetry()
+		defer func() {
+			if err := cmd.runShutdownFuncs(); err != nil {
+				if deferErr != nil {
+					deferErr = newMultiError(deferErr, err)
+				} else {
+					deferErr = err
+				}
+			}
+		}()
+		if len(cmd.HandleSignals) > 0 {
+			var stop func()
+			ctx, stop = cmd.notifySignals(ctx)
+			defer stop()
+		}
 		if cmd.ReadArgsFromStdin {
 			if args, err := cmd.parseArgsFromStdin(); err != nil {
 				return err
@@ -467,6 +1113,19 @@ func (cmd *Command) Run(ctx context.Context, osArgs []string) (deferErr error) {
 				osArgs = append(osArgs, args...)
 			}
 		}
+		if cmd.ResponseFiles {
+			args, err := expandResponseFiles(osArgs)
+			if err != nil {
+				return err
+			}
+			osArgs = args
+		}
+		if len(cmd.DotEnv) > 0 {
+			if err := cmd.loadDotEnv(); err != nil {
+				return err
+			}
+		}
+		osArgs, cmd.helpFormat = extractHelpFormat(osArgs)
 		// handle the completion flag separately from the flagset since
 		// completion could be attempted after a flag, but before its value was put
 		// on the command line. this causes the flagset to interpret the completion
@@ -486,6 +1145,7 @@ func (cmd *Command) Run(ctx context.Context, osArgs []string) (deferErr error) {
 	ctx = context.WithValue(ctx, commandContextKey, cmd)
 
 	if cmd.parent == nil {
+		cmd.runs++
 		cmd.setupCommandGraph()
 	}
 
@@ -507,8 +1167,11 @@ func (cmd *Command) Run(ctx context.Context, osArgs []string) (deferErr error) {
 			err = cmd.handleExitCoder(ctx, err)
 			return err
 		}
+		if cmd.Root().JSONErrors {
+			return cmd.handleExitCoder(ctx, newUsageError(err))
+		}
 		fmt.Fprintf(cmd.Root().ErrWriter, "Incorrect Usage: %s\n\n", err.Error())
-		if cmd.Suggest {
+		if cmd.suggest() {
 			if suggestion, err := cmd.suggestFlagFromError(err, ""); err == nil {
 				fmt.Fprintf(cmd.Root().ErrWriter, "%s", suggestion)
 			}
@@ -521,7 +1184,7 @@ func (cmd *Command) Run(ctx context.Context, osArgs []string) (deferErr error) {
 				}
 			} else {
 				tracef("running ShowCommandHelp with %[1]q", cmd.Name)
-				if err := ShowCommandHelp(ctx, cmd, cmd.Name); err != nil {
+				if err := ShowCommandHelp(ctx, cmd.parent, cmd.Name); err != nil {
 					tracef("SILENTLY IGNORING ERROR running ShowCommandHelp with %[1]q %[2]v", cmd.Name, err)
 				}
 			}
@@ -530,6 +1193,8 @@ func (cmd *Command) Run(ctx context.Context, osArgs []string) (deferErr error) {
 		return err
 	}
 
+	cmd.notify(ctx, EventFlagsParsed, nil)
+
 	if cmd.checkHelp() {
 		return helpCommandAction(ctx, cmd)
 	} else {
@@ -542,14 +1207,31 @@ func (cmd *Command) Run(ctx context.Context, osArgs []string) (deferErr error) {
 	}
 
 	for _, flag := range cmd.Flags {
-		if err := flag.PostParse(); err != nil {
+		if flag == HelpFlag || flag == VersionFlag {
+			// copies of the builtin flags are applied, see applyBuiltinFlag
+			continue
+		}
+		if lf, ok := flag.(lazySourceFlag); ok {
+			if err := lf.deferSources(ctx, cmd.Root().runs); err != nil {
+				return err
+			}
+		} else if err := flag.PostParse(); err != nil {
 			return err
 		}
 	}
 
+	if err := cmd.applyConfig(); err != nil {
+		return err
+	}
+
+	cmd.beforeCtx = nil
 	if cmd.After != nil && !cmd.Root().shellCompletion {
 		defer func() {
-			if err := cmd.After(ctx, cmd); err != nil {
+			actx := ctx
+			if cmd.beforeCtx != nil {
+				actx = cmd.beforeCtx
+			}
+			if err := cmd.After(actx, cmd); err != nil {
 				err = cmd.handleExitCoder(ctx, err)
 
 				if deferErr != nil {
@@ -568,50 +1250,43 @@ func (cmd *Command) Run(ctx context.Context, osArgs []string) (deferErr error) {
 		}
 	}
 
-	var subCmd *Command
 	if args.Present() {
 		tracef("checking positional args %[1]q (cmd=%[2]q)", args, cmd.Name)
 
-		name := args.First()
-
-		tracef("using first positional argument as sub-command name=%[1]q (cmd=%[2]q)", name, cmd.Name)
-
-		if cmd.SuggestCommandFunc != nil {
-			name = cmd.SuggestCommandFunc(cmd.Commands, name)
-		}
-		subCmd = cmd.Command(name)
-		if subCmd == nil {
-			hasDefault := cmd.DefaultCommand != ""
-			isFlagName := checkStringSliceIncludes(name, cmd.FlagNames())
-
-			if hasDefault {
-				tracef("using default command=%[1]q (cmd=%[2]q)", cmd.DefaultCommand, cmd.Name)
-			}
-
-			if isFlagName || hasDefault {
-				argsWithDefault := cmd.argsWithDefaultCommand(args)
-				tracef("using default command args=%[1]q (cmd=%[2]q)", argsWithDefault, cmd.Name)
-				if !reflect.DeepEqual(args, argsWithDefault) {
-					subCmd = cmd.Command(argsWithDefault.First())
-				}
+		if cmd.parent == nil && len(cmd.UserAliases) > 0 {
+			expanded, err := cmd.expandUserAlias(args.Slice())
+			if err != nil {
+				return cmd.handleExitCoder(ctx, err)
 			}
+			args = &stringSliceArgs{v: expanded}
+			cmd.parsedArgs = args
 		}
-	} else if cmd.parent == nil && cmd.DefaultCommand != "" {
-		tracef("no positional args present; checking default command %[1]q (cmd=%[2]q)", cmd.DefaultCommand, cmd.Name)
+	}
 
-		if dc := cmd.Command(cmd.DefaultCommand); dc != cmd {
-			subCmd = dc
-		}
+	subCmd, plugin, err := cmd.resolveSubcommand(args)
+	if err != nil {
+		return err
+	}
+	if plugin != nil {
+		tracef("running plugin %[1]q with arguments %[2]q (cmd=%[3]q)", plugin.name, args.Tail(), cmd.Name)
+		return cmd.handleExitCoder(ctx, plugin.run(ctx, cmd, args.Tail()))
 	}
 
 	// If a subcommand has been resolved, let it handle the remaining execution.
 	if subCmd != nil {
+		subCmd.ensureLoaded()
 		tracef("running sub-command %[1]q with arguments %[2]q (cmd=%[3]q)", subCmd.Name, cmd.Args(), cmd.Name)
 		return subCmd.Run(ctx, cmd.Args().Slice())
 	}
 
 	// This code path is the innermost command execution. Here we actually
 	// perform the command action.
+	cmd.notify(ctx, EventCommandResolved, nil)
+	cmd.printFlagResolution()
+
+	ctx = cmd.startRun(ctx)
+	defer func() { cmd.endRun(deferErr) }()
+
 	//
 	// First, resolve the chain of nested commands up to the parent.
 	var cmdChain []*Command
@@ -620,17 +1295,30 @@ func (cmd *Command) Run(ctx context.Context, osArgs []string) (deferErr error) {
 	}
 	slices.Reverse(cmdChain)
 
-	// Run Before actions in order.
+	// Read the sources of the flags not read yet, so that invalid values
+	// fail the command before any of its actions run.
 	for _, cmd := range cmdChain {
-		if cmd.Before == nil {
-			continue
+		if err := cmd.resolveFlagSources(); err != nil {
+			return err
 		}
-		if bctx, err := cmd.Before(ctx, cmd); err != nil {
-			deferErr = cmd.handleExitCoder(ctx, err)
-			return deferErr
-		} else if bctx != nil {
-			ctx = bctx
+	}
+
+	// Run Before actions in order.
+	for i, cmd := range cmdChain {
+		if cmd.Before != nil {
+			if bctx, err := cmd.Before(ctx, cmd); err != nil {
+				// the After funcs of the remaining commands get the
+				// context as it was when the failing Before was called
+				for _, c := range cmdChain[i:] {
+					c.beforeCtx = ctx
+				}
+				deferErr = cmd.handleExitCoder(ctx, err)
+				return deferErr
+			} else if bctx != nil {
+				ctx = bctx
+			}
 		}
+		cmd.beforeCtx = ctx
 	}
 
 	// Run flag actions in order.
@@ -647,8 +1335,25 @@ func (cmd *Command) Run(ctx context.Context, osArgs []string) (deferErr error) {
 	if cmd.Action == nil {
 		cmd.Action = helpCommandAction
 	} else {
+		cmd.promptForMissingFlags()
 		if err := cmd.checkAllRequiredFlags(); err != nil {
 			cmd.isInError = true
+			if cmd.Root().JSONErrors {
+				return cmd.handleExitCoder(ctx, newUsageError(err))
+			}
+			_ = ShowSubcommandHelp(cmd)
+			return err
+		}
+
+		if err := cmd.checkArgsCount(); err != nil {
+			cmd.isInError = true
+			if cmd.OnUsageError != nil {
+				err = cmd.OnUsageError(ctx, cmd, err, cmd.parent != nil)
+				return cmd.handleExitCoder(ctx, err)
+			}
+			if cmd.Root().JSONErrors {
+				return cmd.handleExitCoder(ctx, newUsageError(err))
+			}
 			_ = ShowSubcommandHelp(cmd)
 			return err
 		}
@@ -666,17 +1371,141 @@ func (cmd *Command) Run(ctx context.Context, osArgs []string) (deferErr error) {
 			}
 			cmd.parsedArgs = &stringSliceArgs{v: rargs}
 		}
+
+		unlock, err := cmd.lock()
+		if err != nil {
+			deferErr = cmd.handleExitCoder(ctx, err)
+			return deferErr
+		}
+		defer unlock()
+
+		if cmd.inCooldown() {
+			return deferErr
+		}
 	}
 
-	if err := cmd.Action(ctx, cmd); err != nil {
+	if err := cmd.runAction(ctx); err != nil {
 		tracef("calling handleExitCoder with %[1]v (cmd=%[2]q)", err, cmd.Name)
 		deferErr = cmd.handleExitCoder(ctx, err)
+	} else {
+		cmd.markCooldown()
 	}
 
 	tracef("returning deferErr (cmd=%[1]q) %[2]q", cmd.Name, deferErr)
 	return deferErr
 }
 
+// resolvedPlugin is a plugin named by the first positional argument
+type resolvedPlugin struct {
+	run  PluginFunc
+	name string
+}
+
+// resolveSubcommand returns the subcommand named by the first of the
+// positional args, or else the default command, or the plugin the name
+// resolves to. It returns no command if the command should run itself.
+func (cmd *Command) resolveSubcommand(args Args) (*Command, *resolvedPlugin, error) {
+	if !args.Present() {
+		if cmd.DefaultCommand != "" {
+			tracef("no positional args present; checking default command %[1]q (cmd=%[2]q)", cmd.DefaultCommand, cmd.Name)
+
+			if dc := cmd.Command(cmd.DefaultCommand); dc != cmd {
+				return dc, nil, nil
+			}
+		}
+		return nil, nil, nil
+	}
+
+	name := args.First()
+
+	tracef("using first positional argument as sub-command name=%[1]q (cmd=%[2]q)", name, cmd.Name)
+
+	if cmd.SuggestCommandFunc != nil {
+		name = cmd.SuggestCommandFunc(cmd.Commands, name)
+	}
+	subCmd, err := cmd.lookupCommand(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if subCmd == nil && !strings.HasPrefix(name, "-") {
+		if resolver := cmd.pluginResolver(); resolver != nil {
+			if run, ok := resolver(cmd, name); ok && run != nil {
+				return nil, &resolvedPlugin{run: run, name: name}, nil
+			}
+		}
+	}
+	if subCmd == nil {
+		hasDefault := cmd.DefaultCommand != ""
+		isFlagName := checkStringSliceIncludes(name, cmd.FlagNames())
+
+		if hasDefault {
+			tracef("using default command=%[1]q (cmd=%[2]q)", cmd.DefaultCommand, cmd.Name)
+		}
+
+		if isFlagName || hasDefault {
+			argsWithDefault := cmd.argsWithDefaultCommand(args)
+			tracef("using default command args=%[1]q (cmd=%[2]q)", argsWithDefault, cmd.Name)
+			if !reflect.DeepEqual(args, argsWithDefault) {
+				subCmd = cmd.Command(argsWithDefault.First())
+			}
+		}
+	}
+	return subCmd, nil, nil
+}
+
+// Use adds middleware wrapping the Action of this command and all its
+// subcommands. Middleware of parent commands wraps that of subcommands and
+// is called in the order it was added.
+func (cmd *Command) Use(mw ...MiddlewareFunc) {
+	cmd.Middleware = append(cmd.Middleware, mw...)
+}
+
+// wrappedAction returns the Action wrapped with the middleware of the
+// command and its parents
+func (cmd *Command) wrappedAction() ActionFunc {
+	action := cmd.Action
+	for _, pCmd := range cmd.Lineage() {
+		for i := len(pCmd.Middleware) - 1; i >= 0; i-- {
+			action = pCmd.Middleware[i](action)
+		}
+	}
+	return action
+}
+
+// runAction runs the Action with the context limited to the Timeout of the
+// command, if any
+func (cmd *Command) runAction(ctx context.Context) error {
+	action := cmd.wrappedAction()
+
+	timeout := cmd.Timeout
+	if cmd.timeoutFlag != nil {
+		timeout = cmd.Duration(timeoutFlagName)
+	}
+
+	cmd.notify(ctx, EventActionStarted, nil)
+
+	if timeout <= 0 {
+		err := action(ctx, cmd)
+		cmd.notify(ctx, EventActionFinished, err)
+		return err
+	}
+
+	tracef("running action with timeout %[1]v (cmd=%[2]q)", timeout, cmd.Name)
+	tctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// sources read while the action runs are limited to the timeout too
+	cmd.setSourcesContext(tctx)
+	defer cmd.setSourcesContext(ctx)
+
+	err := action(tctx, cmd)
+	if err != nil && errors.Is(tctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		err = ExitWithCategory(fmt.Sprintf("%s timed out after %v", cmd.FullName(), timeout), timeoutExitCode, timeoutErrorCategory)
+	}
+	cmd.notify(ctx, EventActionFinished, err)
+	return err
+}
+
 func (cmd *Command) checkHelp() bool {
 	tracef("checking if help is wanted (cmd=%[1]q)", cmd.Name)
 
@@ -685,7 +1514,7 @@ func (cmd *Command) checkHelp() bool {
 	}
 
 	for _, name := range HelpFlag.Names() {
-		if cmd.Bool(name) {
+		if cmd.lookupFlag(name) == HelpFlag && cmd.Bool(name) {
 			return true
 		}
 	}
@@ -695,6 +1524,9 @@ func (cmd *Command) checkHelp() bool {
 
 func (cmd *Command) newFlagSet() (*flag.FlagSet, error) {
 	allFlags := cmd.allFlags()
+	for _, fl := range allFlags {
+		cmd.setFlagSeparator(fl)
+	}
 
 	cmd.appliedFlags = append(cmd.appliedFlags, allFlags...)
 
@@ -703,6 +1535,15 @@ func (cmd *Command) newFlagSet() (*flag.FlagSet, error) {
 	return newFlagSet(cmd.Name, allFlags)
 }
 
+// setFlagSeparator passes the separator settings of the root command to
+// slice and map flags, so commands running concurrently do not share them
+func (cmd *Command) setFlagSeparator(fl Flag) {
+	if mf, ok := fl.(multiValueFlag); ok {
+		root := cmd.Root()
+		mf.setCommandSeparator(root.SliceFlagSeparator, root.DisableSliceFlagSeparator)
+	}
+}
+
 func (cmd *Command) allFlags() []Flag {
 	var flags []Flag
 	flags = append(flags, cmd.Flags...)
@@ -745,7 +1586,7 @@ func (cmd *Command) suggestFlagFromError(err error, commandName string) (string,
 	}
 
 	suggestion := SuggestFlag(flags, fl, hideHelp)
-	if len(suggestion) == 0 {
+	if len(suggestion) == 0 || !cmd.closeEnough(strings.TrimLeft(suggestion, "-"), fl) {
 		return "", err
 	}
 
@@ -756,6 +1597,8 @@ func (cmd *Command) parseFlags(args Args) (Args, error) {
 	tracef("parsing flags from arguments %[1]q (cmd=%[2]q)", args, cmd.Name)
 
 	cmd.parsedArgs = nil
+	cmd.unknownArgs = nil
+	cmd.indexFlags()
 	if v, err := cmd.newFlagSet(); err != nil {
 		return args, err
 	} else {
@@ -791,15 +1634,12 @@ func (cmd *Command) parseFlags(args Args) (Args, error) {
 			)
 
 			applyPersistentFlag := true
-
-			cmd.flagSet.VisitAll(func(f *flag.Flag) {
-				for _, name := range flNames {
-					if name == f.Name {
-						applyPersistentFlag = false
-						break
-					}
+			for _, name := range flNames {
+				if cmd.flagSet.Lookup(name) != nil {
+					applyPersistentFlag = false
+					break
 				}
-			})
+			}
 
 			if !applyPersistentFlag {
 				tracef("not applying as persistent flag=%[1]q (cmd=%[2]q)", flNames, cmd.Name)
@@ -809,6 +1649,7 @@ func (cmd *Command) parseFlags(args Args) (Args, error) {
 
 			tracef("applying as persistent flag=%[1]q (cmd=%[2]q)", flNames, cmd.Name)
 
+			cmd.setFlagSeparator(fl)
 			if err := fl.Apply(cmd.flagSet); err != nil {
 				return cmd.Args(), err
 			}
@@ -822,6 +1663,13 @@ func (cmd *Command) parseFlags(args Args) (Args, error) {
 	defer tracef("done parsing flags (cmd=%[1]q)", cmd.Name)
 
 	rargs := args.Tail()
+	if cmd.caseInsensitive() || cmd.allowAbbreviations() {
+		var err error
+		if rargs, err = cmd.normalizeFlagNames(rargs); err != nil {
+			cmd.parsedArgs = &stringSliceArgs{rargs}
+			return cmd.parsedArgs, err
+		}
+	}
 	posArgs := []string{}
 	for {
 		tracef("rearrange:1 (cmd=%[1]q) %[2]q", cmd.Name, rargs)
@@ -859,6 +1707,14 @@ func (cmd *Command) parseFlags(args Args) (Args, error) {
 				return cmd.parsedArgs, nil
 			}
 
+			// pass everything starting at the first positional argument
+			// on as is, e.g. for commands wrapping other programs or plugins
+			if cmd.SkipFlagParsingAfterFirstArg || (len(posArgs) == 0 && cmd.isPlugin(rargs[0])) {
+				posArgs = append(posArgs, rargs...)
+				cmd.parsedArgs = &stringSliceArgs{posArgs}
+				return cmd.parsedArgs, nil
+			}
+
 			posArgs = append(posArgs, rargs[0])
 
 			// if this is the sole argument then
@@ -871,6 +1727,13 @@ func (cmd *Command) parseFlags(args Args) (Args, error) {
 			rargs = rargs[1:]
 		}
 		if err := parseIter(cmd.flagSet, cmd, rargs, cmd.Root().shellCompletion); err != nil {
+			if remaining, ok := cmd.skipUnknownFlag(err, rargs); ok {
+				rargs = remaining
+				if len(rargs) == 0 {
+					break
+				}
+				continue
+			}
 			posArgs = append(posArgs, cmd.flagSet.Args()...)
 			tracef("returning-1 (cmd=%[1]q) args %[2]q", cmd.Name, posArgs)
 			cmd.parsedArgs = &stringSliceArgs{posArgs}
@@ -905,6 +1768,12 @@ func (cmd *Command) HasName(name string) bool {
 	return false
 }
 
+// isHidden returns true if the command is hidden from help and completion,
+// see Hidden and HiddenUnless
+func (cmd *Command) isHidden() bool {
+	return cmd.Hidden || (cmd.HiddenUnless != nil && !cmd.HiddenUnless(cmd))
+}
+
 // VisibleCategories returns a slice of categories and commands that are
 // Hidden=false
 func (cmd *Command) VisibleCategories() []CommandCategory {
@@ -926,7 +1795,7 @@ func (cmd *Command) VisibleCategories() []CommandCategory {
 func (cmd *Command) VisibleCommands() []*Command {
 	var ret []*Command
 	for _, command := range cmd.Commands {
-		if command.Hidden || command.Name == helpName {
+		if command.isHidden() || command.Name == helpName {
 			continue
 		}
 		ret = append(ret, command)
@@ -937,11 +1806,18 @@ func (cmd *Command) VisibleCommands() []*Command {
 // VisibleFlagCategories returns a slice containing all the visible flag categories with the flags they contain
 func (cmd *Command) VisibleFlagCategories() []VisibleFlagCategory {
 	if cmd.flagCategories == nil {
-		cmd.flagCategories = newFlagCategoriesFromFlags(cmd.allFlags())
+		cmd.flagCategories = cmd.newFlagCategories()
 	}
 	return cmd.flagCategories.VisibleCategories()
 }
 
+func (cmd *Command) newFlagCategories() FlagCategories {
+	if cmd.Root().GroupFlags {
+		return newGroupedFlagCategoriesFromFlags(cmd.allFlags())
+	}
+	return newFlagCategoriesFromFlags(cmd.allFlags())
+}
+
 // VisibleFlags returns a slice of the Flags with Hidden=false
 func (cmd *Command) VisibleFlags() []Flag {
 	return visibleFlags(cmd.allFlags())
@@ -973,20 +1849,82 @@ func (cmd *Command) appendCommand(aCmd *Command) {
 	}
 }
 
+// now returns the current time of the Clock of the root command
+func (cmd *Command) now() time.Time {
+	if clock := cmd.Root().Clock; clock != nil {
+		return clock()
+	}
+	return time.Now()
+}
+
+// startRun notes the start of running the resolved command, starts sending
+// the queued telemetry events and starts its span
+func (cmd *Command) startRun(ctx context.Context) context.Context {
+	cmd.Root().runStart = cmd.now()
+	cmd.flushTelemetry()
+	return cmd.startSpan(ctx)
+}
+
+// endRun ends the span, records the telemetry event and writes the audit
+// record of running the command, if they have not been yet
+func (cmd *Command) endRun(err error) {
+	root := cmd.Root()
+	if root.runStart.IsZero() {
+		return
+	}
+	start := root.runStart
+	root.runStart = time.Time{}
+
+	cmd.endSpan(err)
+	cmd.recordTelemetry(start, err)
+	cmd.writeAudit(start, err)
+}
+
 func (cmd *Command) handleExitCoder(ctx context.Context, err error) error {
-	if cmd.parent != nil {
-		return cmd.parent.handleExitCoder(ctx, err)
+	for _, pCmd := range cmd.Lineage() {
+		if pCmd.OnError == nil || err == nil {
+			continue
+		}
+		tracef("calling OnError of %[1]q with %[2]v (cmd=%[3]q)", pCmd.Name, err, cmd.Name)
+		err = pCmd.OnError(ctx, cmd, err)
+	}
+
+	// the error may exit the process
+	cmd.endRun(err)
+
+	root := cmd.Root()
+	if root.inShell {
+		return err
+	}
+
+	if root.ExitErrHandler != nil {
+		root.ExitErrHandler(ctx, root, err)
+		return err
 	}
 
-	if cmd.ExitErrHandler != nil {
-		cmd.ExitErrHandler(ctx, cmd, err)
+	if root.JSONErrors && err != nil {
+		cmd.exit(writeJSONError(root.ErrWriter, err))
 		return err
 	}
 
-	HandleExitCoder(err)
+	errWriter := root.ErrWriter
+	if errWriter == nil || root.defaultErrWriter && errWriter == os.Stderr {
+		errWriter = ErrWriter
+	}
+	exitWithCode(err, errWriter, cmd.exit)
 	return err
 }
 
+// exit calls the OsExiter of the root command with the code, or else the
+// package level OsExiter
+func (cmd *Command) exit(code int) {
+	if exiter := cmd.Root().OsExiter; exiter != nil {
+		exiter(code)
+		return
+	}
+	OsExiter(code)
+}
+
 func (cmd *Command) argsWithDefaultCommand(oldArgs Args) Args {
 	if cmd.DefaultCommand != "" {
 		rawArgs := append([]string{cmd.DefaultCommand}, oldArgs.Slice()...)
@@ -1009,13 +1947,9 @@ func (cmd *Command) Root() *Command {
 
 func (cmd *Command) lookupFlag(name string) Flag {
 	for _, pCmd := range cmd.Lineage() {
-		for _, f := range pCmd.Flags {
-			for _, n := range f.Names() {
-				if n == name {
-					tracef("flag found for name %[1]q (cmd=%[2]q)", name, cmd.Name)
-					return f
-				}
-			}
+		if f := pCmd.flagByName(name); f != nil {
+			tracef("flag found for name %[1]q (cmd=%[2]q)", name, cmd.Name)
+			return f
 		}
 	}
 
@@ -1023,6 +1957,88 @@ func (cmd *Command) lookupFlag(name string) Flag {
 	return nil
 }
 
+// lazySourceFlag is implemented by flags reading their sources like env
+// vars only when their value is first needed rather than in PostParse
+type lazySourceFlag interface {
+	deferSources(ctx context.Context, run int) error
+	resolveSources() error
+	// isSetResolved is like IsSet, but does not read the sources if
+	// they have not been read yet
+	isSetResolved() bool
+}
+
+// setSourcesContext sets the context the sources of the flags of the command
+// and its parents are read with, if they have not been read yet
+func (cmd *Command) setSourcesContext(ctx context.Context) {
+	run := cmd.Root().runs
+	for _, pCmd := range cmd.Lineage() {
+		for _, fl := range pCmd.Flags {
+			if lf, ok := fl.(lazySourceFlag); ok && fl != HelpFlag && fl != VersionFlag {
+				_ = lf.deferSources(ctx, run)
+			}
+		}
+	}
+}
+
+// resolveFlagSources reads the sources of the flags of the command that
+// were deferred and not needed so far
+func (cmd *Command) resolveFlagSources() error {
+	for _, fl := range cmd.Flags {
+		if fl == HelpFlag || fl == VersionFlag {
+			continue
+		}
+		if lf, ok := fl.(lazySourceFlag); ok {
+			if err := lf.resolveSources(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flagByName returns the first of the Flags of the command with the name.
+// It uses the index built when the flags are parsed, unless Flags was
+// replaced or appended to since then or the indexed flag no longer has the
+// name.
+func (cmd *Command) flagByName(name string) Flag {
+	if cmd.flagIndex != nil && sameSlice(cmd.flagIndexOf, cmd.Flags) {
+		i, ok := cmd.flagIndex[name]
+		if !ok {
+			return nil
+		}
+		if f := cmd.Flags[i]; slices.Contains(f.Names(), name) {
+			return f
+		}
+	}
+
+	for _, f := range cmd.Flags {
+		if slices.Contains(f.Names(), name) {
+			return f
+		}
+	}
+	return nil
+}
+
+// indexFlags builds the index of the Flags of the command by name
+func (cmd *Command) indexFlags() {
+	cmd.flagIndex = make(map[string]int, len(cmd.Flags))
+	cmd.flagIndexOf = cmd.Flags
+	for i, f := range cmd.Flags {
+		for _, name := range f.Names() {
+			if _, ok := cmd.flagIndex[name]; !ok {
+				cmd.flagIndex[name] = i
+			}
+		}
+	}
+}
+
+// sameSlice returns whether the slices share the same backing array and
+// length, i.e. one was not replaced or appended to since the other was
+// taken from it
+func sameSlice[T any](a, b []T) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
 func (cmd *Command) lookupFlagSet(name string) *flag.FlagSet {
 	for _, pCmd := range cmd.Lineage() {
 		if pCmd.flagSet == nil {
@@ -1065,6 +2081,28 @@ func (cmd *Command) checkRequiredFlag(f Flag) (bool, string) {
 	return true, ""
 }
 
+// checkArgsCount checks the number of arguments against ArgsMin and ArgsMax
+func (cmd *Command) checkArgsCount() error {
+	if cmd.ArgsMin <= 0 && cmd.ArgsMax <= 0 {
+		return nil
+	}
+
+	got := cmd.NArg()
+	if got >= cmd.ArgsMin && (cmd.ArgsMax <= 0 || got <= cmd.ArgsMax) {
+		return nil
+	}
+
+	tracef("found %[1]d arguments, expected %[2]d to %[3]d (cmd=%[4]q)", got, cmd.ArgsMin, cmd.ArgsMax, cmd.Name)
+
+	return &errArgsCount{
+		command:   cmd.FullName(),
+		argsUsage: cmd.ArgsUsage,
+		min:       cmd.ArgsMin,
+		max:       cmd.ArgsMax,
+		got:       got,
+	}
+}
+
 func (cmd *Command) checkAllRequiredFlags() requiredFlagsErr {
 	for pCmd := cmd; pCmd != nil; pCmd = pCmd.parent {
 		if err := pCmd.checkRequiredFlags(); err != nil {
@@ -1113,6 +2151,11 @@ func (cmd *Command) NumFlags() int {
 
 // Set sets a context flag to a value.
 func (cmd *Command) Set(name, value string) error {
+	if mu := cmd.Root().valuesMu; mu != nil {
+		mu.Lock()
+		defer mu.Unlock()
+	}
+
 	if fs := cmd.lookupFlagSet(name); fs != nil {
 		return fs.Set(name, value)
 	}
@@ -1122,6 +2165,11 @@ func (cmd *Command) Set(name, value string) error {
 
 // IsSet determines if the flag was actually set
 func (cmd *Command) IsSet(name string) bool {
+	if mu := cmd.Root().valuesMu; mu != nil {
+		mu.RLock()
+		defer mu.RUnlock()
+	}
+
 	flSet := cmd.lookupFlagSet(name)
 
 	if flSet == nil {
@@ -1157,6 +2205,157 @@ func (cmd *Command) IsSet(name string) bool {
 	return isSet
 }
 
+// isSetResolved returns whether the flag with the given name was set on the
+// command line or from one of its sources, without reading the sources if
+// they have not been read yet. It is used while exiting, when the command
+// may have failed before its sources were read and reading them could fail
+// or block again.
+func (cmd *Command) isSetResolved(name string) bool {
+	if mu := cmd.Root().valuesMu; mu != nil {
+		mu.RLock()
+		defer mu.RUnlock()
+	}
+
+	for _, pCmd := range cmd.Lineage() {
+		if pCmd.flagSet == nil || pCmd.flagSet.Lookup(name) == nil {
+			continue
+		}
+		isSet := false
+		pCmd.flagSet.Visit(func(f *flag.Flag) {
+			if f.Name == name {
+				isSet = true
+			}
+		})
+		if isSet {
+			return true
+		}
+	}
+
+	switch fl := cmd.lookupFlag(name).(type) {
+	case nil:
+		return false
+	case lazySourceFlag:
+		return fl.isSetResolved()
+	default:
+		return fl.IsSet()
+	}
+}
+
+// ValueSource returns where the value of the flag with the given name came
+// from: "command line", the source it was read from like
+// environment variable "PORT" or key "port" in config file "app.yaml", or
+// "default" if it is not set. An empty string is returned if there is no
+// such flag.
+func (cmd *Command) ValueSource(name string) string {
+	if mu := cmd.Root().valuesMu; mu != nil {
+		mu.RLock()
+		defer mu.RUnlock()
+	}
+
+	_, desc := cmd.flagSource(name)
+	return desc
+}
+
+// IsSetFrom returns the kind of source the value of the flag with the given
+// name came from. Unlike IsSet it tells a flag given on the command line
+// apart from one read from an env var or a config file, e.g. to warn about
+// secrets passed on the command line.
+func (cmd *Command) IsSetFrom(name string) FlagSource {
+	if mu := cmd.Root().valuesMu; mu != nil {
+		mu.RLock()
+		defer mu.RUnlock()
+	}
+
+	src, _ := cmd.flagSource(name)
+	return src
+}
+
+// flagSource returns the kind and the description of the source the value
+// of the flag with the given name came from
+func (cmd *Command) flagSource(name string) (FlagSource, string) {
+	fl := cmd.lookupFlag(name)
+	if fl == nil {
+		return SourceUnknown, ""
+	}
+
+	// persistent flags are applied to the flag sets of all commands in
+	// the lineage and may have been set in any of them
+	for _, pCmd := range cmd.Lineage() {
+		if pCmd.flagSet == nil || pCmd.flagSet.Lookup(name) == nil {
+			continue
+		}
+		if src, ok := pCmd.configSources[fl.Names()[0]]; ok {
+			// a flag removed from the config files by WatchConfig is
+			// reset to its default value
+			if src == "" {
+				return SourceDefault, "default"
+			}
+			return SourceConfigFile, src
+		}
+
+		isSet := false
+		pCmd.flagSet.Visit(func(f *flag.Flag) {
+			if slices.Contains(fl.Names(), f.Name) {
+				isSet = true
+			}
+		})
+		if isSet {
+			return SourceCommandLine, "command line"
+		}
+	}
+
+	if vs, ok := fl.(interface{ valueSource() ValueSource }); ok && vs.valueSource() != nil {
+		src := vs.valueSource()
+		if es, ok := src.(EnvValueSource); ok && es.IsFromEnv() {
+			return SourceEnvVar, src.String()
+		}
+		return SourceOther, src.String()
+	}
+
+	return SourceDefault, "default"
+}
+
+// appEnvVar returns the name of an env var of the root command, prefixed
+// with its EnvVarPrefix or else its name, e.g. APP_CLI_DEBUG for cli-debug
+func (cmd *Command) appEnvVar(name string) string {
+	root := cmd.Root()
+	prefix := root.EnvVarPrefix
+	if prefix == "" {
+		prefix = strings.ToUpper(strings.ReplaceAll(root.Name, "-", "_"))
+	}
+	return prefixedEnvVar(prefix, name)
+}
+
+// printFlagResolution prints for each flag of the command and its parents
+// where its value was read from if DebugResolution is enabled
+func (cmd *Command) printFlagResolution() {
+	root := cmd.Root()
+	if !root.DebugResolution {
+		if enabled, _ := strconv.ParseBool(os.Getenv(root.appEnvVar("cli-debug"))); !enabled {
+			return
+		}
+	}
+
+	seen := map[string]bool{}
+	lineage := cmd.Lineage()
+	for i := len(lineage) - 1; i >= 0; i-- {
+		pCmd := lineage[i]
+		for _, fl := range pCmd.Flags {
+			names := fl.Names()
+			if len(names) == 0 || seen[names[0]] || fl == HelpFlag || fl == VersionFlag {
+				continue
+			}
+			seen[names[0]] = true
+
+			value := "REDACTED"
+			if sf, ok := fl.(SensitiveFlag); !ok || !sf.IsSensitive() {
+				value = fmt.Sprintf("%v", cmd.Value(names[0]))
+			}
+			fmt.Fprintf(root.ErrWriter, "%s: flag --%s=%s from %s\n", pCmd.FullName(), names[0], value, cmd.ValueSource(names[0]))
+		}
+	}
+}
+
 // LocalFlagNames returns a slice of flag names used in this
 // command.
 func (cmd *Command) LocalFlagNames() []string {
@@ -1222,6 +2421,16 @@ func (cmd *Command) Count(name string) int {
 
 // Value returns the value of the flag corresponding to `name`
 func (cmd *Command) Value(name string) interface{} {
+	if mu := cmd.Root().valuesMu; mu != nil {
+		mu.RLock()
+		defer mu.RUnlock()
+	}
+
+	return cmd.value(name)
+}
+
+// value is like Value but does not lock the values against WatchConfig
+func (cmd *Command) value(name string) interface{} {
 	if fs := cmd.lookupFlagSet(name); fs != nil {
 		tracef("value found for name %[1]q (cmd=%[2]q)", name, cmd.Name)
 		return fs.Lookup(name).Value.(flag.Getter).Get()
@@ -1231,6 +2440,129 @@ func (cmd *Command) Value(name string) interface{} {
 	return nil
 }
 
+// resolvedValue is like Value, but does not read the sources of the flag if
+// they have not been read yet, see isSetResolved
+func (cmd *Command) resolvedValue(name string) any {
+	if mu := cmd.Root().valuesMu; mu != nil {
+		mu.RLock()
+		defer mu.RUnlock()
+	}
+
+	for _, pCmd := range cmd.Lineage() {
+		if pCmd.flagSet == nil {
+			continue
+		}
+		f := pCmd.flagSet.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if fv, ok := f.Value.(*fnValue); ok {
+			return fv.v.Get()
+		}
+		if g, ok := f.Value.(flag.Getter); ok {
+			return g.Get()
+		}
+		return f.Value.String()
+	}
+	return nil
+}
+
+// flagValue returns the value of the flag with the given name as T, or the
+// zero value of T if there is no such flag or it has another type. Kind
+// names the type in traces. Unlike Value it does not allocate.
+func flagValue[T any](cmd *Command, name, kind string) T {
+	v, ok := lookupValue[T](cmd, name)
+	if isTracingOn {
+		if ok {
+			tracef("%[1]s available for flag name %[2]q with value=%[3]v (cmd=%[4]q)", kind, name, v, cmd.Name)
+		} else {
+			tracef("%[1]s NOT available for flag name %[2]q (cmd=%[3]q)", kind, name, cmd.Name)
+		}
+	}
+	return v
+}
+
+// lookupValue returns the value of the flag with the given name if it is
+// of type T. The value is read from the flag directly if possible rather
+// than boxed by the Get method of its Value.
+func lookupValue[T any](cmd *Command, name string) (T, bool) {
+	if mu := cmd.Root().valuesMu; mu != nil {
+		mu.RLock()
+		defer mu.RUnlock()
+	}
+
+	for pCmd := cmd; pCmd != nil; pCmd = pCmd.parent {
+		if pCmd.flagSet == nil {
+			continue
+		}
+		if f := pCmd.flagSet.Lookup(name); f != nil {
+			if fv, ok := f.Value.(*fnValue); ok {
+				if tf, ok := fv.flag.(interface{ typedValue() T }); ok {
+					return tf.typedValue(), true
+				}
+			}
+			break
+		}
+	}
+
+	v, ok := cmd.value(name).(T)
+	return v, ok
+}
+
+// Get looks up the value of the flag with the given name and returns it as
+// T, returns the zero value of T if the flag is not found or its value is
+// not of type T. For a [GenericFlag] the wrapped [Value] itself is returned
+// if it is of type T. If there is no such flag the value of the argument
+// with the given name is returned, see [Command.Arg].
+func Get[T any](cmd *Command, name string) T {
+	v, _ := GetOk[T](cmd, name)
+	return v
+}
+
+// GetOk is like [Get] but additionally reports whether the flag was found
+// and its value is of type T.
+func GetOk[T any](cmd *Command, name string) (T, bool) {
+	if v, ok := cmd.Value(name).(T); ok {
+		tracef("%[1]T available for flag name %[2]q with value=%[3]v (cmd=%[4]q)", v, name, v, cmd.Name)
+		return v, true
+	}
+
+	if gf, ok := cmd.lookupFlag(name).(*GenericFlag); ok {
+		if gv, ok := gf.value.(*genericValue); ok {
+			if v, ok := gv.val.(T); ok {
+				tracef("%[1]T available for generic flag name %[2]q (cmd=%[3]q)", v, name, cmd.Name)
+				return v, true
+			}
+		}
+	}
+
+	if v, ok := cmd.Arg(name).(T); ok {
+		tracef("%[1]T available for argument name %[2]q with value=%[3]v (cmd=%[4]q)", v, name, v, cmd.Name)
+		return v, true
+	}
+
+	var t T
+	tracef("%[1]T NOT available for flag name %[2]q (cmd=%[3]q)", t, name, cmd.Name)
+	return t, false
+}
+
+// Arg returns the parsed value of the argument with the given name from
+// [Command.Arguments], or nil if there is no such argument. Arguments
+// accepting at most one value return that value or their default, all
+// others a slice of the values.
+func (cmd *Command) Arg(name string) any {
+	for _, arg := range cmd.Arguments {
+		if av, ok := arg.(argumentValue); ok && av.argName() == name {
+			v := av.argValue()
+			tracef("argument available for name %[1]q with value=%[2]v (cmd=%[3]q)", name, v, cmd.Name)
+			return v
+		}
+	}
+
+	tracef("argument NOT available for name %[1]q (cmd=%[2]q)", name, cmd.Name)
+	return nil
+}
+
 // Args returns the command line arguments associated with the
 // command.
 func (cmd *Command) Args() Args {
@@ -1240,6 +2572,12 @@ func (cmd *Command) Args() Args {
 	return &stringSliceArgs{v: cmd.flagSet.Args()}
 }
 
+// UnknownArgs returns the unknown flags collected while parsing the
+// command line arguments with UnknownFlagCollect, in their original order
+func (cmd *Command) UnknownArgs() []string {
+	return cmd.unknownArgs
+}
+
 // NArg returns the number of the command line arguments.
 func (cmd *Command) NArg() int {
 	return cmd.Args().Len()
@@ -1256,17 +2594,17 @@ func hasCommand(commands []*Command, command *Command) bool {
 }
 
 func (cmd *Command) runFlagActions(ctx context.Context) error {
+	// check only local flagset for running local flag actions
+	setNames := make(map[string]bool)
+	cmd.flagSet.Visit(func(f *flag.Flag) {
+		setNames[f.Name] = true
+	})
+
 	for _, fl := range cmd.appliedFlags {
 		isSet := false
-
-		// check only local flagset for running local flag actions
 		for _, name := range fl.Names() {
-			cmd.flagSet.Visit(func(f *flag.Flag) {
-				if f.Name == name {
-					isSet = true
-				}
-			})
-			if isSet {
+			if setNames[name] {
+				isSet = true
 				break
 			}
 		}
@@ -10,9 +10,13 @@ import (
 	"io"
 	"net/mail"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -208,16 +212,19 @@ func TestParseAndRunShortOpts(t *testing.T) {
 		{testArgs: &stringSliceArgs{v: []string{"test", "-cf"}}, expectedArgs: &stringSliceArgs{v: []string{}}},
 		{testArgs: &stringSliceArgs{v: []string{"test", "-acf"}}, expectedArgs: &stringSliceArgs{v: []string{}}},
 		{testArgs: &stringSliceArgs{v: []string{"test", "--acf"}}, expectedErr: "flag provided but not defined: -acf"},
-		{testArgs: &stringSliceArgs{v: []string{"test", "-invalid"}}, expectedErr: "flag provided but not defined: -invalid"},
-		{testArgs: &stringSliceArgs{v: []string{"test", "-acf", "-invalid"}}, expectedErr: "flag provided but not defined: -invalid"},
+		{testArgs: &stringSliceArgs{v: []string{"test", "-xinvalid"}}, expectedErr: "flag provided but not defined: -xinvalid"},
+		{testArgs: &stringSliceArgs{v: []string{"test", "-acf", "-xinvalid"}}, expectedErr: "flag provided but not defined: -xinvalid"},
 		{testArgs: &stringSliceArgs{v: []string{"test", "--invalid"}}, expectedErr: "flag provided but not defined: -invalid"},
 		{testArgs: &stringSliceArgs{v: []string{"test", "-acf", "--invalid"}}, expectedErr: "flag provided but not defined: -invalid"},
-		{testArgs: &stringSliceArgs{v: []string{"test", "-acf", "arg1", "-invalid"}}, expectedErr: "flag provided but not defined: -invalid"},
+		{testArgs: &stringSliceArgs{v: []string{"test", "-acf", "arg1", "-xinvalid"}}, expectedErr: "flag provided but not defined: -xinvalid"},
 		{testArgs: &stringSliceArgs{v: []string{"test", "-acf", "arg1", "--invalid"}}, expectedErr: "flag provided but not defined: -invalid"},
-		{testArgs: &stringSliceArgs{v: []string{"test", "-acfi", "not-arg", "arg1", "-invalid"}}, expectedErr: "flag provided but not defined: -invalid"},
+		{testArgs: &stringSliceArgs{v: []string{"test", "-acfi", "not-arg", "arg1", "-xinvalid"}}, expectedErr: "flag provided but not defined: -xinvalid"},
 		{testArgs: &stringSliceArgs{v: []string{"test", "-i", "ivalue"}}, expectedArgs: &stringSliceArgs{v: []string{}}},
 		{testArgs: &stringSliceArgs{v: []string{"test", "-i", "ivalue", "arg1"}}, expectedArgs: &stringSliceArgs{v: []string{"arg1"}}},
 		{testArgs: &stringSliceArgs{v: []string{"test", "-i"}}, expectedErr: "flag needs an argument: -i"},
+		{testArgs: &stringSliceArgs{v: []string{"test", "-invalid"}}, expectedArgs: &stringSliceArgs{v: []string{}}},
+		{testArgs: &stringSliceArgs{v: []string{"test", "-acivalue", "arg1"}}, expectedArgs: &stringSliceArgs{v: []string{"arg1"}}},
+		{testArgs: &stringSliceArgs{v: []string{"test", "-ia"}}, expectedArgs: &stringSliceArgs{v: []string{}}},
 	}
 
 	for _, tc := range testCases {
@@ -800,6 +807,36 @@ var defaultCommandFlagTests = []struct {
 	{"nothing", "--carly", "", false},
 }
 
+func TestCommand_RunDefaultCommandNoArgs(t *testing.T) {
+	var ran []string
+	record := func(_ context.Context, cmd *Command) error {
+		ran = append(ran, cmd.FullName())
+		return nil
+	}
+
+	cmd := &Command{
+		Name:           "myapp",
+		DefaultCommand: "serve",
+		Flags:          []Flag{&BoolFlag{Name: "verbose"}},
+		Commands: []*Command{
+			{Name: "serve", Action: record},
+			{
+				Name:           "db",
+				DefaultCommand: "migrate",
+				Commands: []*Command{
+					{Name: "migrate", Action: record},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"myapp"}))
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"myapp", "--verbose"}))
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"myapp", "db"}))
+	assert.Equal(t, []string{"myapp serve", "myapp serve", "myapp db migrate"}, ran)
+	assert.True(t, cmd.Bool("verbose"))
+}
+
 func TestCommand_RunDefaultCommandWithFlags(t *testing.T) {
 	for _, test := range defaultCommandFlagTests {
 		testTitle := fmt.Sprintf("command=%[1]s-flag=%[2]s-default=%[3]s", test.cmdName, test.flag, test.defaultCmd)
@@ -1079,6 +1116,48 @@ func TestCommand_UseShortOptionHandling(t *testing.T) {
 	assert.Equal(t, name, expected)
 }
 
+func TestCommand_UseShortOptionHandling_attachedValue(t *testing.T) {
+	tests := []struct {
+		args    []string
+		verbose bool
+		num     int64
+		output  string
+		err     string
+	}{
+		{args: []string{"", "-n5"}, num: 5},
+		{args: []string{"", "-vn5"}, verbose: true, num: 5},
+		{args: []string{"", "-ofile.txt"}, output: "file.txt"},
+		{args: []string{"", "-vo", "file.txt", "-n", "3"}, verbose: true, output: "file.txt", num: 3},
+		{args: []string{"", "-ov"}, output: "v"},
+		{args: []string{"", "-oxyz"}, output: "xyz"},
+		{args: []string{"", "-nv"}, err: `invalid value "v" for flag -n`},
+		{args: []string{"", "-vnx"}, err: `invalid value "x" for flag -n`},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args[1:], " "), func(t *testing.T) {
+			cmd := buildMinimalTestCommand()
+			cmd.UseShortOptionHandling = true
+			cmd.Flags = []Flag{
+				&BoolFlag{Name: "verbose", Aliases: []string{"v"}},
+				&IntFlag{Name: "num", Aliases: []string{"n"}},
+				&StringFlag{Name: "output", Aliases: []string{"o"}},
+			}
+
+			err := cmd.Run(buildTestContext(t), test.args)
+			if test.err != "" {
+				require.ErrorContains(t, err, test.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.verbose, cmd.Bool("verbose"))
+			assert.Equal(t, test.num, cmd.Int("num"))
+			assert.Equal(t, test.output, cmd.String("output"))
+		})
+	}
+}
+
 func TestCommand_UseShortOptionHandling_missing_value(t *testing.T) {
 	cmd := buildMinimalTestCommand()
 	cmd.UseShortOptionHandling = true
@@ -1527,6 +1606,83 @@ func TestCommand_BeforeAfterFuncShellCompletion(t *testing.T) {
 	r.Equal(0, counts.SubCommand, "SubCommand was run")
 }
 
+func TestCommand_BeforeAfterNested(t *testing.T) {
+	type ctxKey string
+
+	var calls []string
+	hooks := func(name string, fail bool) (BeforeFunc, AfterFunc) {
+		before := func(ctx context.Context, _ *Command) (context.Context, error) {
+			calls = append(calls, "before "+name)
+			if fail {
+				return nil, errors.New("before " + name + " failed")
+			}
+			return context.WithValue(ctx, ctxKey(name), name), nil
+		}
+		after := func(ctx context.Context, _ *Command) error {
+			calls = append(calls, fmt.Sprintf("after %s root=%v mid=%v leaf=%v", name, ctx.Value(ctxKey("root")), ctx.Value(ctxKey("mid")), ctx.Value(ctxKey("leaf"))))
+			return nil
+		}
+		return before, after
+	}
+
+	build := func(failMid bool, actionErr error) *Command {
+		rootBefore, rootAfter := hooks("root", false)
+		midBefore, midAfter := hooks("mid", failMid)
+		leafBefore, leafAfter := hooks("leaf", false)
+		return &Command{
+			Name:   "root",
+			Before: rootBefore,
+			After:  rootAfter,
+			Commands: []*Command{
+				{
+					Name:   "mid",
+					Before: midBefore,
+					After:  midAfter,
+					Commands: []*Command{
+						{
+							Name:   "leaf",
+							Before: leafBefore,
+							After:  leafAfter,
+							Action: func(ctx context.Context, _ *Command) error {
+								calls = append(calls, fmt.Sprintf("action root=%v mid=%v leaf=%v", ctx.Value(ctxKey("root")), ctx.Value(ctxKey("mid")), ctx.Value(ctxKey("leaf"))))
+								return actionErr
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	calls = nil
+	require.NoError(t, build(false, nil).Run(buildTestContext(t), []string{"root", "mid", "leaf"}))
+	assert.Equal(t, []string{
+		"before root",
+		"before mid",
+		"before leaf",
+		"action root=root mid=mid leaf=leaf",
+		"after leaf root=root mid=mid leaf=leaf",
+		"after mid root=root mid=mid leaf=<nil>",
+		"after root root=root mid=<nil> leaf=<nil>",
+	}, calls)
+
+	calls = nil
+	err := build(false, errors.New("action failed")).Run(buildTestContext(t), []string{"root", "mid", "leaf"})
+	assert.EqualError(t, err, "action failed")
+	assert.Len(t, calls, 7)
+
+	calls = nil
+	err = build(true, nil).Run(buildTestContext(t), []string{"root", "mid", "leaf"})
+	assert.EqualError(t, err, "before mid failed")
+	assert.Equal(t, []string{
+		"before root",
+		"before mid",
+		"after leaf root=root mid=<nil> leaf=<nil>",
+		"after mid root=root mid=<nil> leaf=<nil>",
+		"after root root=root mid=<nil> leaf=<nil>",
+	}, calls)
+}
+
 func TestCommand_AfterFunc(t *testing.T) {
 	counts := &opCounts{}
 	afterError := fmt.Errorf("fail")
@@ -2128,6 +2284,80 @@ func TestCommand_Run_Version(t *testing.T) {
 	}
 }
 
+func TestCommand_VersionInfo(t *testing.T) {
+	defer func(old func() (*debug.BuildInfo, bool)) { readBuildInfo = old }(readBuildInfo)
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			GoVersion: "go1.22.1",
+			Main:      debug.Module{Version: "v1.2.3"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "abc123"},
+				{Key: "vcs.modified", Value: "true"},
+				{Key: "vcs.time", Value: "2024-03-01T10:00:00Z"},
+			},
+		}, true
+	}
+
+	newCmd := func(buf *bytes.Buffer, version string) *Command {
+		return &Command{
+			Name:             "boom",
+			Version:          version,
+			Writer:           buf,
+			ExtraVersionInfo: map[string]string{"tags": "netgo", "arch": "amd64"},
+			Flags:            []Flag{&BoolFlag{Name: "json"}},
+			Commands:         []*Command{VersionCommand()},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		version  string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "flag",
+			version:  "0.1.0",
+			args:     []string{"boom", "--version"},
+			expected: "boom version 0.1.0\n",
+		},
+		{
+			name:     "flag json",
+			version:  "0.1.0",
+			args:     []string{"boom", "--version", "--json"},
+			expected: `{"name":"boom","version":"0.1.0","revision":"abc123","dirty":true,"time":"2024-03-01T10:00:00Z","goVersion":"go1.22.1","extra":{"arch":"amd64","tags":"netgo"}}`,
+		},
+		{
+			name:    "command",
+			version: "0.1.0",
+			args:    []string{"boom", "version"},
+			expected: "boom version 0.1.0\n" +
+				"revision: abc123 (dirty)\n" +
+				"built: 2024-03-01T10:00:00Z\n" +
+				"go: go1.22.1\n" +
+				"arch: amd64\n" +
+				"tags: netgo\n",
+		},
+		{
+			name:     "command short from build info",
+			args:     []string{"boom", "version", "--short"},
+			expected: "v1.2.3\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, newCmd(buf, test.version).Run(buildTestContext(t), test.args))
+			if strings.HasPrefix(test.expected, "{") {
+				assert.JSONEq(t, test.expected, buf.String())
+			} else {
+				assert.Equal(t, test.expected, buf.String())
+			}
+		})
+	}
+}
+
 func TestCommand_Run_Categories(t *testing.T) {
 	buf := new(bytes.Buffer)
 
@@ -2444,130 +2674,562 @@ func TestHandleExitCoder_Custom(t *testing.T) {
 	assert.Contains(t, output, "Custom", "Expected Custom Behavior from Error Handler")
 }
 
-func TestShellCompletionForIncompleteFlags(t *testing.T) {
+func TestCommand_OnError(t *testing.T) {
+	errNotFound := errors.New("not found")
+	var calls []string
+	var exitCode int
+
 	cmd := &Command{
-		Flags: []Flag{
-			&IntFlag{
-				Name: "test-completion",
+		Name: "app",
+		OnError: func(_ context.Context, cmd *Command, err error) error {
+			calls = append(calls, "app "+cmd.Name)
+			if errors.Is(err, errNotFound) {
+				return Exit(err.Error()+", run with --debug for details", 4)
+			}
+			return err
+		},
+		ExitErrHandler: func(_ context.Context, _ *Command, err error) {
+			if ec, ok := err.(ExitCoder); ok {
+				exitCode = ec.ExitCode()
+			}
+		},
+		Commands: []*Command{
+			{
+				Name: "get",
+				OnError: func(_ context.Context, cmd *Command, err error) error {
+					calls = append(calls, "get "+cmd.Name)
+					return fmt.Errorf("get: %w", err)
+				},
+				Action: func(context.Context, *Command) error {
+					return errNotFound
+				},
+			},
+			{
+				Name: "ignore",
+				OnError: func(context.Context, *Command, error) error {
+					return nil
+				},
+				Before: func(context.Context, *Command) (context.Context, error) {
+					return nil, errors.New("ignored")
+				},
 			},
 		},
-		EnableShellCompletion: true,
-		ShellComplete: func(_ context.Context, cmd *Command) {
-			for _, command := range cmd.Commands {
-				if command.Hidden {
-					continue
-				}
+	}
 
-				for _, name := range command.Names() {
-					_, _ = fmt.Fprintln(cmd.Writer, name)
-				}
-			}
+	err := cmd.Run(buildTestContext(t), []string{"app", "get"})
+	assert.EqualError(t, err, "get: not found, run with --debug for details")
+	assert.Equal(t, 4, exitCode)
+	assert.Equal(t, []string{"get get", "app get"}, calls)
 
-			for _, fl := range cmd.Flags {
-				for _, name := range fl.Names() {
-					if name == GenerateShellCompletionFlag.Names()[0] {
-						continue
-					}
+	calls = nil
+	assert.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "ignore"}))
+	assert.Empty(t, calls)
+}
 
-					switch name = strings.TrimSpace(name); len(name) {
-					case 0:
-					case 1:
-						_, _ = fmt.Fprintln(cmd.Writer, "-"+name)
-					default:
-						_, _ = fmt.Fprintln(cmd.Writer, "--"+name)
-					}
-				}
-			}
+func TestCommand_JSONErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+		code     int
+	}{
+		{
+			name:     "usage error",
+			args:     []string{"app", "--nope"},
+			expected: `{"error":"flag provided but not defined: -nope","code":64,"category":"usage"}`,
+			code:     64,
 		},
-		Action: func(context.Context, *Command) error {
-			return fmt.Errorf("should not get here")
+		{
+			name:     "required flag",
+			args:     []string{"app", "run"},
+			expected: `{"error":"Required flag \"name\" not set","code":64,"category":"usage"}`,
+			code:     64,
+		},
+		{
+			name:     "action error",
+			args:     []string{"app", "run", "--name", "x"},
+			expected: `{"error":"failed","code":3,"category":"runtime"}`,
+			code:     3,
 		},
-		Writer: io.Discard,
 	}
 
-	err := cmd.Run(buildTestContext(t), []string{"", "--test-completion", completionFlag})
-	assert.NoError(t, err, "app should not return an error")
-}
-
-func TestWhenExitSubCommandWithCodeThenCommandQuitUnexpectedly(t *testing.T) {
-	testCode := 104
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exitCode := 0
+			OsExiter = func(rc int) { exitCode = rc }
+			defer func() { OsExiter = fakeOsExiter }()
 
-	cmd := buildMinimalTestCommand()
-	cmd.Commands = []*Command{
-		{
-			Name: "cmd",
-			Commands: []*Command{
-				{
-					Name: "subcmd",
-					Action: func(context.Context, *Command) error {
-						return Exit("exit error", testCode)
+			var errBuf, outBuf bytes.Buffer
+			cmd := &Command{
+				Name:       "app",
+				JSONErrors: true,
+				ErrWriter:  &errBuf,
+				Writer:     &outBuf,
+				Commands: []*Command{
+					{
+						Name:  "run",
+						Flags: []Flag{&StringFlag{Name: "name", Required: true}},
+						Action: func(context.Context, *Command) error {
+							return ExitWithCategory("failed", 3, "runtime")
+						},
 					},
 				},
-			},
+			}
+
+			assert.Error(t, cmd.Run(buildTestContext(t), test.args))
+			assert.Equal(t, test.expected+"\n", errBuf.String())
+			assert.Empty(t, outBuf.String())
+			assert.Equal(t, test.code, exitCode)
+		})
+	}
+}
+
+func TestCommand_HandleSignals(t *testing.T) {
+	sigCh := make(chan chan<- os.Signal, 1)
+	signalNotify = func(c chan<- os.Signal, _ ...os.Signal) { sigCh <- c }
+	defer func() { signalNotify = signal.Notify }()
+
+	exitCode := make(chan int, 1)
+	OsExiter = func(rc int) { exitCode <- rc }
+	defer func() { OsExiter = fakeOsExiter }()
+
+	var afterErr error
+	cmd := &Command{
+		Name:          "app",
+		HandleSignals: []os.Signal{os.Interrupt},
+		Action: func(ctx context.Context, _ *Command) error {
+			c := <-sigCh
+			c <- os.Interrupt
+			<-ctx.Done()
+			c <- os.Interrupt
+			assert.Equal(t, interruptedExitCode, <-exitCode)
+			return context.Cause(ctx)
+		},
+		After: func(ctx context.Context, _ *Command) error {
+			afterErr = context.Cause(ctx)
+			return nil
 		},
 	}
 
-	// set user function as ExitErrHandler
-	exitCodeFromExitErrHandler := int(0)
-	cmd.ExitErrHandler = func(_ context.Context, _ *Command, err error) {
-		if exitErr, ok := err.(ExitCoder); ok {
-			exitCodeFromExitErrHandler = exitErr.ExitCode()
+	err := cmd.Run(buildTestContext(t), []string{"app"})
+	assert.EqualError(t, err, "received signal interrupt")
+	assert.EqualError(t, afterErr, "received signal interrupt")
+}
+
+func TestCommand_Timeout(t *testing.T) {
+	wait := func(ctx context.Context, cmd *Command) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+			return nil
 		}
 	}
 
-	// keep and restore original OsExiter
-	origExiter := OsExiter
-	t.Cleanup(func() { OsExiter = origExiter })
-
-	// set user function as OsExiter
-	exitCodeFromOsExiter := int(0)
-	OsExiter = func(exitCode int) {
-		exitCodeFromOsExiter = exitCode
+	cmd := &Command{
+		Name: "app",
+		Commands: []*Command{
+			{Name: "job", Timeout: 10 * time.Millisecond, Action: wait},
+		},
 	}
 
-	r := require.New(t)
+	err := cmd.Run(buildTestContext(t), []string{"app", "job"})
+	var ec ExitCoder
+	require.ErrorAs(t, err, &ec)
+	assert.Equal(t, 124, ec.ExitCode())
+	assert.EqualError(t, err, "app job timed out after 10ms")
 
-	r.Error(cmd.Run(buildTestContext(t), []string{
-		"myapp",
-		"cmd",
-		"subcmd",
-	}))
+	err = cmd.Run(buildTestContext(t), []string{"app", "job", "--timeout", "5s"})
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cmd.Command("job").Duration("timeout"))
 
-	r.Equal(0, exitCodeFromOsExiter)
-	r.Equal(testCode, exitCodeFromExitErrHandler)
+	cmd = &Command{
+		Name:    "app",
+		Timeout: time.Millisecond,
+		Flags:   []Flag{&StringFlag{Name: "timeout"}},
+		Action: func(_ context.Context, cmd *Command) error {
+			assert.Equal(t, "custom", cmd.String("timeout"))
+			return nil
+		},
+	}
+	assert.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "--timeout", "custom"}))
 }
 
-func buildMinimalTestCommand() *Command {
-	// reset the help flag because tests may have set it
-	HelpFlag.(*BoolFlag).hasBeenSet = false
-	return &Command{Writer: io.Discard}
-}
+func TestCommand_OnShutdown(t *testing.T) {
+	var calls []string
+	register := func(cmd *Command, name string, err error) {
+		cmd.OnShutdown(func() error {
+			calls = append(calls, name)
+			return err
+		})
+	}
 
-func TestSetupInitializesBothWriters(t *testing.T) {
-	cmd := &Command{}
+	cmd := &Command{
+		Name: "app",
+		After: func(context.Context, *Command) error {
+			calls = append(calls, "after")
+			return nil
+		},
+		Commands: []*Command{
+			{
+				Name: "ok",
+				Action: func(_ context.Context, cmd *Command) error {
+					register(cmd, "first", nil)
+					register(cmd, "second", nil)
+					return nil
+				},
+			},
+			{
+				Name: "fail",
+				Action: func(_ context.Context, cmd *Command) error {
+					register(cmd, "first", errors.New("cleanup failed"))
+					return errors.New("action failed")
+				},
+			},
+			{
+				Name: "panic",
+				Action: func(_ context.Context, cmd *Command) error {
+					register(cmd, "first", nil)
+					panic("boom")
+				},
+			},
+		},
+	}
 
-	cmd.setupDefaults([]string{"cli.test"})
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "ok"}))
+	assert.Equal(t, []string{"after", "second", "first"}, calls)
 
-	assert.Equal(t, cmd.ErrWriter, os.Stderr, "expected a.ErrWriter to be os.Stderr")
-	assert.Equal(t, cmd.Writer, os.Stdout, "expected a.Writer to be os.Stdout")
+	calls = nil
+	err := cmd.Run(buildTestContext(t), []string{"app", "fail"})
+	assert.EqualError(t, err, "action failed\ncleanup failed")
+	assert.Equal(t, []string{"after", "first"}, calls)
+
+	calls = nil
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = cmd.Run(buildTestContext(t), []string{"app", "panic"})
+	})
+	assert.Equal(t, []string{"after", "first"}, calls)
 }
 
-func TestSetupInitializesOnlyNilWriters(t *testing.T) {
-	wr := &bytes.Buffer{}
+func TestCommand_Recover(t *testing.T) {
+	crashFile := filepath.Join(t.TempDir(), "crash.txt")
+
+	var handled error
 	cmd := &Command{
-		ErrWriter: wr,
+		Name:          "app",
+		Recover:       true,
+		PanicExitCode: 70,
+		CrashFile:     crashFile,
+		ExitErrHandler: func(_ context.Context, _ *Command, err error) {
+			handled = err
+		},
+		Flags: []Flag{
+			&StringFlag{Name: "token", Sensitive: true},
+			&IntFlag{Name: "retries"},
+		},
+		Commands: []*Command{
+			{
+				Name: "crash",
+				Action: func(context.Context, *Command) error {
+					panic("boom")
+				},
+			},
+		},
 	}
 
-	cmd.setupDefaults([]string{"cli.test"})
+	err := cmd.Run(buildTestContext(t), []string{"app", "--token", "s3cr3t", "--retries", "3", "crash"})
+	require.EqualError(t, err, "panic: boom")
+	assert.Equal(t, err, handled)
 
-	assert.Equal(t, cmd.ErrWriter, wr, "expected a.ErrWriter to be a *bytes.Buffer instance")
-	assert.Equal(t, cmd.Writer, os.Stdout, "expected a.Writer to be os.Stdout")
-}
+	var ec ExitCoder
+	require.ErrorAs(t, err, &ec)
+	assert.Equal(t, 70, ec.ExitCode())
 
-func TestFlagAction(t *testing.T) {
-	now := time.Now().UTC().Truncate(time.Minute)
-	testCases := []struct {
-		name string
+	report := fmt.Sprintf("%+v", err)
+	assert.Contains(t, report, "panic: boom\n\ncommand: app crash\nflags:\n   --token=REDACTED\n   --retries=3\n")
+	assert.Contains(t, report, "TestCommand_Recover")
+	assert.NotContains(t, report, "runtime/debug.Stack")
+	assert.NotContains(t, report, "s3cr3t")
+
+	content, rerr := os.ReadFile(crashFile)
+	require.NoError(t, rerr)
+	assert.Contains(t, string(content), "command: app crash")
+
+	cmd = &Command{
+		Name:    "app",
+		Recover: true,
+		PanicHandler: func(_ context.Context, cmd *Command, value any, stack []byte) error {
+			return fmt.Errorf("%s crashed: %v", cmd.Name, value)
+		},
+		Action: func(context.Context, *Command) error {
+			panic("boom")
+		},
+	}
+	assert.EqualError(t, cmd.Run(buildTestContext(t), []string{"app"}), "app crashed: boom")
+}
+
+func TestCommand_Use(t *testing.T) {
+	var calls []string
+	trace := func(name string) MiddlewareFunc {
+		return func(next ActionFunc) ActionFunc {
+			return func(ctx context.Context, cmd *Command) error {
+				calls = append(calls, name+" before "+cmd.Name)
+				err := next(ctx, cmd)
+				calls = append(calls, name+" after "+cmd.Name)
+				return err
+			}
+		}
+	}
+	deny := func(next ActionFunc) ActionFunc {
+		return func(ctx context.Context, cmd *Command) error {
+			return errors.New("permission denied")
+		}
+	}
+
+	cmd := &Command{
+		Name: "app",
+		Commands: []*Command{
+			{
+				Name:       "deploy",
+				Middleware: []MiddlewareFunc{trace("deploy")},
+				Action: func(context.Context, *Command) error {
+					calls = append(calls, "action")
+					return nil
+				},
+			},
+			{
+				Name:       "destroy",
+				Middleware: []MiddlewareFunc{deny},
+				Action: func(context.Context, *Command) error {
+					calls = append(calls, "action")
+					return nil
+				},
+			},
+		},
+	}
+	cmd.Use(trace("first"), trace("second"))
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "deploy"}))
+	assert.Equal(t, []string{
+		"first before deploy",
+		"second before deploy",
+		"deploy before deploy",
+		"action",
+		"deploy after deploy",
+		"second after deploy",
+		"first after deploy",
+	}, calls)
+
+	calls = nil
+	assert.EqualError(t, cmd.Run(buildTestContext(t), []string{"app", "destroy"}), "permission denied")
+	assert.Equal(t, []string{"first before destroy", "second before destroy", "second after destroy", "first after destroy"}, calls)
+}
+
+func TestCommand_Observer(t *testing.T) {
+	var events []string
+	cmd := &Command{
+		Name:   "app",
+		Writer: io.Discard,
+		Observer: ObserverFunc(func(_ context.Context, ev Event) {
+			events = append(events, fmt.Sprintf("%v %s %v", ev.Type, ev.Command.Name, ev.Err))
+		}),
+		Commands: []*Command{
+			{
+				Name: "deploy",
+				Action: func(context.Context, *Command) error {
+					return errors.New("failed")
+				},
+			},
+		},
+	}
+
+	assert.Error(t, cmd.Run(buildTestContext(t), []string{"app", "deploy"}))
+	assert.Equal(t, []string{
+		"FlagsParsed app <nil>",
+		"FlagsParsed deploy <nil>",
+		"CommandResolved deploy <nil>",
+		"ActionStarted deploy <nil>",
+		"ActionFinished deploy failed",
+	}, events)
+
+	events = nil
+	assert.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "deploy", "--help"}))
+	assert.Equal(t, []string{
+		"FlagsParsed app <nil>",
+		"FlagsParsed deploy <nil>",
+		"HelpShown deploy <nil>",
+	}, events)
+
+	assert.Equal(t, "EventType(42)", EventType(42).String())
+}
+
+func TestCommand_PluginResolver(t *testing.T) {
+	var calls []string
+	resolver := func(cmd *Command, name string) (PluginFunc, bool) {
+		if name != "deploy" {
+			return nil, false
+		}
+		return func(_ context.Context, cmd *Command, args []string) error {
+			calls = append(calls, cmd.FullName()+" "+name+"|"+strings.Join(args, " "))
+			if len(args) > 0 && args[0] != "0" {
+				return Exit("", 3)
+			}
+			return nil
+		}, true
+	}
+
+	cmd := &Command{
+		Name:           "app",
+		ExitErrHandler: func(context.Context, *Command, error) {},
+		PluginResolver: resolver,
+		Flags:          []Flag{&BoolFlag{Name: "verbose"}},
+		Commands: []*Command{
+			{
+				Name: "cloud",
+				Commands: []*Command{
+					{Name: "status", Action: func(context.Context, *Command) error { return nil }},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "--verbose", "cloud", "deploy", "0", "--force"}))
+	assert.Equal(t, []string{"app cloud deploy|0 --force"}, calls)
+
+	err := cmd.Run(buildTestContext(t), []string{"app", "cloud", "deploy", "3"})
+	var ec ExitCoder
+	require.ErrorAs(t, err, &ec)
+	assert.Equal(t, 3, ec.ExitCode())
+
+	calls = nil
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "cloud", "status"}))
+	assert.Empty(t, calls)
+}
+
+func TestShellCompletionForIncompleteFlags(t *testing.T) {
+	cmd := &Command{
+		Flags: []Flag{
+			&IntFlag{
+				Name: "test-completion",
+			},
+		},
+		EnableShellCompletion: true,
+		ShellComplete: func(_ context.Context, cmd *Command) {
+			for _, command := range cmd.Commands {
+				if command.Hidden {
+					continue
+				}
+
+				for _, name := range command.Names() {
+					_, _ = fmt.Fprintln(cmd.Writer, name)
+				}
+			}
+
+			for _, fl := range cmd.Flags {
+				for _, name := range fl.Names() {
+					if name == GenerateShellCompletionFlag.Names()[0] {
+						continue
+					}
+
+					switch name = strings.TrimSpace(name); len(name) {
+					case 0:
+					case 1:
+						_, _ = fmt.Fprintln(cmd.Writer, "-"+name)
+					default:
+						_, _ = fmt.Fprintln(cmd.Writer, "--"+name)
+					}
+				}
+			}
+		},
+		Action: func(context.Context, *Command) error {
+			return fmt.Errorf("should not get here")
+		},
+		Writer: io.Discard,
+	}
+
+	err := cmd.Run(buildTestContext(t), []string{"", "--test-completion", completionFlag})
+	assert.NoError(t, err, "app should not return an error")
+}
+
+func TestWhenExitSubCommandWithCodeThenCommandQuitUnexpectedly(t *testing.T) {
+	testCode := 104
+
+	cmd := buildMinimalTestCommand()
+	cmd.Commands = []*Command{
+		{
+			Name: "cmd",
+			Commands: []*Command{
+				{
+					Name: "subcmd",
+					Action: func(context.Context, *Command) error {
+						return Exit("exit error", testCode)
+					},
+				},
+			},
+		},
+	}
+
+	// set user function as ExitErrHandler
+	exitCodeFromExitErrHandler := int(0)
+	cmd.ExitErrHandler = func(_ context.Context, _ *Command, err error) {
+		if exitErr, ok := err.(ExitCoder); ok {
+			exitCodeFromExitErrHandler = exitErr.ExitCode()
+		}
+	}
+
+	// keep and restore original OsExiter
+	origExiter := OsExiter
+	t.Cleanup(func() { OsExiter = origExiter })
+
+	// set user function as OsExiter
+	exitCodeFromOsExiter := int(0)
+	OsExiter = func(exitCode int) {
+		exitCodeFromOsExiter = exitCode
+	}
+
+	r := require.New(t)
+
+	r.Error(cmd.Run(buildTestContext(t), []string{
+		"myapp",
+		"cmd",
+		"subcmd",
+	}))
+
+	r.Equal(0, exitCodeFromOsExiter)
+	r.Equal(testCode, exitCodeFromExitErrHandler)
+}
+
+func buildMinimalTestCommand() *Command {
+	// reset the help flag because tests may have set it
+	HelpFlag.(*BoolFlag).hasBeenSet = false
+	return &Command{Writer: io.Discard}
+}
+
+func TestSetupInitializesBothWriters(t *testing.T) {
+	cmd := &Command{}
+
+	cmd.setupDefaults([]string{"cli.test"})
+
+	assert.Equal(t, cmd.ErrWriter, os.Stderr, "expected a.ErrWriter to be os.Stderr")
+	assert.Equal(t, cmd.Writer, os.Stdout, "expected a.Writer to be os.Stdout")
+}
+
+func TestSetupInitializesOnlyNilWriters(t *testing.T) {
+	wr := &bytes.Buffer{}
+	cmd := &Command{
+		ErrWriter: wr,
+	}
+
+	cmd.setupDefaults([]string{"cli.test"})
+
+	assert.Equal(t, cmd.ErrWriter, wr, "expected a.ErrWriter to be a *bytes.Buffer instance")
+	assert.Equal(t, cmd.Writer, os.Stdout, "expected a.Writer to be os.Stdout")
+}
+
+func TestFlagAction(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Minute)
+	testCases := []struct {
+		name string
 		args []string
 		err  string
 		exp  string
@@ -3233,8 +3895,8 @@ func TestCommand_Int(t *testing.T) {
 
 	parentSet := flag.NewFlagSet("test", 0)
 	parentSet.Int64("top-flag", 13, "doc")
-	pCmd := &Command{flagSet: parentSet}
-	cmd := &Command{flagSet: set, parent: pCmd}
+	pCmd := &Command{runState: runState{flagSet: parentSet}}
+	cmd := &Command{runState: runState{flagSet: set}, parent: pCmd}
 
 	require.Equal(t, int64(12), cmd.Int("myflag"))
 	require.Equal(t, int64(13), cmd.Int("top-flag"))
@@ -3245,8 +3907,8 @@ func TestCommand_Uint(t *testing.T) {
 	set.Uint64("myflagUint", uint64(13), "doc")
 	parentSet := flag.NewFlagSet("test", 0)
 	parentSet.Uint64("top-flag", uint64(14), "doc")
-	pCmd := &Command{flagSet: parentSet}
-	cmd := &Command{flagSet: set, parent: pCmd}
+	pCmd := &Command{runState: runState{flagSet: parentSet}}
+	cmd := &Command{runState: runState{flagSet: set}, parent: pCmd}
 
 	require.Equal(t, uint64(13), cmd.Uint("myflagUint"))
 	require.Equal(t, uint64(14), cmd.Uint("top-flag"))
@@ -3257,8 +3919,8 @@ func TestCommand_Float64(t *testing.T) {
 	set.Float64("myflag", float64(17), "doc")
 	parentSet := flag.NewFlagSet("test", 0)
 	parentSet.Float64("top-flag", float64(18), "doc")
-	pCmd := &Command{flagSet: parentSet}
-	cmd := &Command{flagSet: set, parent: pCmd}
+	pCmd := &Command{runState: runState{flagSet: parentSet}}
+	cmd := &Command{runState: runState{flagSet: set}, parent: pCmd}
 
 	r := require.New(t)
 	r.Equal(float64(17), cmd.Float("myflag"))
@@ -3271,9 +3933,9 @@ func TestCommand_Duration(t *testing.T) {
 
 	parentSet := flag.NewFlagSet("test", 0)
 	parentSet.Duration("top-flag", 13*time.Second, "doc")
-	pCmd := &Command{flagSet: parentSet}
+	pCmd := &Command{runState: runState{flagSet: parentSet}}
 
-	cmd := &Command{flagSet: set, parent: pCmd}
+	cmd := &Command{runState: runState{flagSet: set}, parent: pCmd}
 
 	r := require.New(t)
 	r.Equal(12*time.Second, cmd.Duration("myflag"))
@@ -3322,8 +3984,8 @@ func TestCommand_String(t *testing.T) {
 	set.String("myflag", "hello world", "doc")
 	parentSet := flag.NewFlagSet("test", 0)
 	parentSet.String("top-flag", "hai veld", "doc")
-	pCmd := &Command{flagSet: parentSet}
-	cmd := &Command{flagSet: set, parent: pCmd}
+	pCmd := &Command{runState: runState{flagSet: parentSet}}
+	cmd := &Command{runState: runState{flagSet: set}, parent: pCmd}
 
 	r := require.New(t)
 	r.Equal("hello world", cmd.String("myflag"))
@@ -3338,8 +4000,8 @@ func TestCommand_Bool(t *testing.T) {
 	set.Bool("myflag", false, "doc")
 	parentSet := flag.NewFlagSet("test", 0)
 	parentSet.Bool("top-flag", true, "doc")
-	pCmd := &Command{flagSet: parentSet}
-	cmd := &Command{flagSet: set, parent: pCmd}
+	pCmd := &Command{runState: runState{flagSet: parentSet}}
+	cmd := &Command{runState: runState{flagSet: set}, parent: pCmd}
 
 	r := require.New(t)
 	r.False(cmd.Bool("myflag"))
@@ -3403,6 +4065,38 @@ func TestCommand_Value(t *testing.T) {
 	})
 }
 
+func TestGet(t *testing.T) {
+	parser := &Parser{}
+	cmd := &Command{
+		Flags: []Flag{
+			&IntFlag{Name: "count", Aliases: []string{"c"}},
+			&StringSliceFlag{Name: "name"},
+			&GenericFlag{Name: "pair", Value: parser},
+		},
+	}
+
+	r := require.New(t)
+	r.NoError(cmd.Run(buildTestContext(t), []string{"main", "-c", "3", "--name", "a", "--name", "b", "--pair", "x,y"}))
+
+	r.Equal(int64(3), Get[int64](cmd, "count"))
+	r.Equal(int64(3), Get[int64](cmd, "c"))
+	r.Equal([]string{"a", "b"}, Get[[]string](cmd, "name"))
+	r.Equal(&Parser{"x", "y"}, Get[*Parser](cmd, "pair"))
+	r.Same(parser, Get[*Parser](cmd, "pair"))
+
+	v, ok := GetOk[int64](cmd, "count")
+	r.True(ok)
+	r.Equal(int64(3), v)
+
+	s, ok := GetOk[string](cmd, "count")
+	r.False(ok)
+	r.Empty(s)
+
+	_, ok = GetOk[int64](cmd, "unknown-flag")
+	r.False(ok)
+	r.Zero(Get[int64](cmd, "unknown-flag"))
+}
+
 func TestCommand_Value_InvalidFlagAccessHandler(t *testing.T) {
 	var flagName string
 	cmd := &Command{
@@ -3427,25 +4121,408 @@ func TestCommand_Value_InvalidFlagAccessHandler(t *testing.T) {
 
 	r := require.New(t)
 
-	r.NoError(cmd.Run(buildTestContext(t), []string{"run", "command", "subcommand"}))
-	r.Equal("missing", flagName)
+	r.NoError(cmd.Run(buildTestContext(t), []string{"run", "command", "subcommand"}))
+	r.Equal("missing", flagName)
+}
+
+func TestCommand_Args(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.Bool("myflag", false, "doc")
+	cmd := &Command{runState: runState{flagSet: set}}
+	_ = set.Parse([]string{"--myflag", "bat", "baz"})
+
+	r := require.New(t)
+	r.Equal(2, cmd.Args().Len())
+	r.True(cmd.Bool("myflag"))
+}
+
+func TestCommand_FlagsAfterArgs(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		expectedArgs []string
+		force        bool
+		mode         string
+	}{
+		{
+			name:         "flags after args",
+			args:         []string{"copy", "src", "dst", "--force"},
+			expectedArgs: []string{"src", "dst"},
+			force:        true,
+		},
+		{
+			name:         "flags between args",
+			args:         []string{"copy", "src", "--mode", "0644", "dst", "--force"},
+			expectedArgs: []string{"src", "dst"},
+			force:        true,
+			mode:         "0644",
+		},
+		{
+			name:         "terminator stops flag parsing",
+			args:         []string{"copy", "src", "--", "dst", "--force"},
+			expectedArgs: []string{"src", "--", "dst", "--force"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd := &Command{
+				Name: "copy",
+				Flags: []Flag{
+					&BoolFlag{Name: "force"},
+					&StringFlag{Name: "mode"},
+				},
+				Action: func(_ context.Context, cmd *Command) error {
+					assert.Equal(t, test.expectedArgs, cmd.Args().Slice())
+					assert.Equal(t, test.force, cmd.Bool("force"))
+					assert.Equal(t, test.mode, cmd.String("mode"))
+					return nil
+				},
+			}
+			require.NoError(t, cmd.Run(buildTestContext(t), test.args))
+		})
+	}
+}
+
+func TestCommand_SkipFlagParsingAfterFirstArg(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		expectedArgs []string
+		verbose      bool
+		dir          string
+	}{
+		{
+			name:         "flags after first arg are passed on",
+			args:         []string{"app", "exec", "ls", "-la", "--verbose"},
+			expectedArgs: []string{"ls", "-la", "--verbose"},
+		},
+		{
+			name:         "flags before first arg are parsed",
+			args:         []string{"app", "exec", "--verbose", "--dir", "/tmp", "ls", "-la"},
+			expectedArgs: []string{"ls", "-la"},
+			verbose:      true,
+			dir:          "/tmp",
+		},
+		{
+			name:         "terminator is kept after first arg",
+			args:         []string{"app", "exec", "--verbose", "git", "--", "log"},
+			expectedArgs: []string{"git", "--", "log"},
+			verbose:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd := &Command{
+				Name: "app",
+				Commands: []*Command{
+					{
+						Name:                         "exec",
+						SkipFlagParsingAfterFirstArg: true,
+						Flags: []Flag{
+							&BoolFlag{Name: "verbose"},
+							&StringFlag{Name: "dir"},
+						},
+						Action: func(_ context.Context, cmd *Command) error {
+							assert.Equal(t, test.expectedArgs, cmd.Args().Slice())
+							assert.Equal(t, test.verbose, cmd.Bool("verbose"))
+							assert.Equal(t, test.dir, cmd.String("dir"))
+							return nil
+						},
+					},
+				},
+			}
+			require.NoError(t, cmd.Run(buildTestContext(t), test.args))
+		})
+	}
+}
+
+func TestCommand_ArgsMinMax(t *testing.T) {
+	tests := []struct {
+		name        string
+		min, max    int
+		args        []string
+		expectedErr string
+	}{
+		{name: "no limits", args: []string{"a", "b", "c"}},
+		{name: "exact", min: 2, max: 2, args: []string{"a", "b"}},
+		{name: "range", min: 1, max: 3, args: []string{"a", "b", "c"}},
+		{name: "min only", min: 1, args: []string{"a", "b", "c", "d"}},
+		{
+			name:        "too few exact",
+			min:         2,
+			max:         2,
+			args:        []string{"a"},
+			expectedErr: "app copy expects exactly 2 arguments but got 1, usage: app copy SRC DST",
+		},
+		{
+			name:        "too few",
+			min:         1,
+			expectedErr: "app copy expects at least 1 argument but got 0, usage: app copy SRC DST",
+		},
+		{
+			name:        "too many",
+			min:         1,
+			max:         2,
+			args:        []string{"a", "b", "c"},
+			expectedErr: "app copy expects at most 2 arguments but got 3, usage: app copy SRC DST",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actionCalled := false
+			cmd := &Command{
+				Name:   "app",
+				Writer: io.Discard,
+				Commands: []*Command{
+					{
+						Name:      "copy",
+						ArgsUsage: "SRC DST",
+						ArgsMin:   test.min,
+						ArgsMax:   test.max,
+						Action: func(context.Context, *Command) error {
+							actionCalled = true
+							return nil
+						},
+					},
+				},
+			}
+
+			err := cmd.Run(buildTestContext(t), append([]string{"app", "copy"}, test.args...))
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				assert.False(t, actionCalled)
+			} else {
+				assert.NoError(t, err)
+				assert.True(t, actionCalled)
+			}
+		})
+	}
+}
+
+func TestCommand_ArgsMinMaxOnUsageError(t *testing.T) {
+	cmd := &Command{
+		Name:    "app",
+		ArgsMin: 1,
+		OnUsageError: func(_ context.Context, _ *Command, err error, _ bool) error {
+			return Exit("usage: "+err.Error(), 2)
+		},
+		Action: func(context.Context, *Command) error {
+			return nil
+		},
+	}
+
+	err := cmd.Run(buildTestContext(t), []string{"app"})
+	assert.EqualError(t, err, "usage: app expects at least 1 argument but got 0")
+}
+
+func TestCommand_PersistentFlagsAfterSubcommand(t *testing.T) {
+	for _, args := range [][]string{
+		{"app", "--verbose", "--env", "prod", "deploy", "web"},
+		{"app", "deploy", "--verbose", "web", "--env", "prod"},
+		{"app", "deploy", "web", "--env=prod", "--verbose"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			var beforeVerbose bool
+			cmd := &Command{
+				Name: "app",
+				Flags: []Flag{
+					&BoolFlag{Name: "verbose"},
+					&StringFlag{Name: "env"},
+				},
+				Before: func(ctx context.Context, cmd *Command) (context.Context, error) {
+					beforeVerbose = cmd.Bool("verbose")
+					return ctx, nil
+				},
+				Commands: []*Command{
+					{
+						Name: "deploy",
+						Action: func(_ context.Context, cmd *Command) error {
+							assert.Equal(t, []string{"web"}, cmd.Args().Slice())
+							assert.True(t, cmd.Bool("verbose"))
+							assert.Equal(t, "prod", cmd.String("env"))
+							return nil
+						},
+					},
+				},
+			}
+
+			require.NoError(t, cmd.Run(buildTestContext(t), args))
+			assert.True(t, beforeVerbose)
+			assert.True(t, cmd.IsSet("verbose"))
+			assert.Equal(t, "prod", cmd.String("env"))
+		})
+	}
+}
+
+func TestCommand_UnknownFlagPolicy(t *testing.T) {
+	tests := []struct {
+		name            string
+		policy          UnknownFlagPolicy
+		valueFlags      []string
+		args            []string
+		expectedArgs    []string
+		expectedUnknown []string
+		expectedErr     string
+	}{
+		{
+			name:        "error",
+			policy:      UnknownFlagError,
+			args:        []string{"wrap", "--bogus", "arg"},
+			expectedErr: "flag provided but not defined: -bogus",
+		},
+		{
+			name:         "ignore",
+			policy:       UnknownFlagIgnore,
+			args:         []string{"wrap", "--bogus", "arg", "--verbose", "-x=1"},
+			expectedArgs: []string{"arg"},
+		},
+		{
+			name:            "collect",
+			policy:          UnknownFlagCollect,
+			args:            []string{"wrap", "--bogus", "arg", "--verbose", "-x=1", "other", "--output=json"},
+			expectedArgs:    []string{"arg", "other"},
+			expectedUnknown: []string{"--bogus", "-x=1", "--output=json"},
+		},
+		{
+			name:            "collect with values",
+			policy:          UnknownFlagCollect,
+			valueFlags:      []string{"output", "o"},
+			args:            []string{"wrap", "--output", "json", "arg", "--verbose", "-o=yaml", "--bogus", "other", "-o"},
+			expectedArgs:    []string{"arg", "other"},
+			expectedUnknown: []string{"--output", "json", "-o=yaml", "--bogus", "-o"},
+		},
+		{
+			name:         "ignore with values",
+			policy:       UnknownFlagIgnore,
+			valueFlags:   []string{"output"},
+			args:         []string{"wrap", "--output", "json", "arg", "--verbose"},
+			expectedArgs: []string{"arg"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd := &Command{
+				Name:              "wrap",
+				UnknownFlagPolicy: test.policy,
+				UnknownValueFlags: test.valueFlags,
+				Flags:             []Flag{&BoolFlag{Name: "verbose"}},
+				Writer:            io.Discard,
+				Action: func(_ context.Context, cmd *Command) error {
+					assert.True(t, cmd.Bool("verbose"))
+					assert.Equal(t, test.expectedArgs, cmd.Args().Slice())
+					assert.Equal(t, test.expectedUnknown, cmd.UnknownArgs())
+					return nil
+				},
+			}
+
+			err := cmd.Run(buildTestContext(t), test.args)
+			if test.expectedErr != "" {
+				require.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCommand_CaseInsensitive(t *testing.T) {
+	var force, verbose, upper bool
+	var name string
+	cmd := &Command{
+		Name:            "myapp",
+		CaseInsensitive: true,
+		Writer:          io.Discard,
+		Flags: []Flag{
+			&BoolFlag{Name: "verbose", Aliases: []string{"v"}},
+		},
+		Commands: []*Command{
+			{
+				Name: "deploy",
+				Flags: []Flag{
+					&BoolFlag{Name: "force"},
+					&StringFlag{Name: "name"},
+					&BoolFlag{Name: "V"},
+				},
+				Action: func(_ context.Context, cmd *Command) error {
+					force = cmd.Bool("force")
+					verbose = cmd.Bool("verbose")
+					upper = cmd.Bool("V")
+					name = cmd.String("name")
+					return nil
+				},
+			},
+		},
+	}
+
+	r := require.New(t)
+	r.NoError(cmd.Run(buildTestContext(t), []string{"MyApp", "--Verbose", "Deploy", "--Force", "--NAME=Web", "-V"}))
+	r.True(force)
+	r.True(verbose)
+	r.True(upper)
+	r.Equal("Web", name)
+	r.Equal("deploy", cmd.Command("DEPLOY").Name)
+
+	cmd = &Command{
+		Name:     "myapp",
+		Writer:   io.Discard,
+		Flags:    []Flag{&BoolFlag{Name: "force"}},
+		Commands: []*Command{{Name: "deploy"}},
+	}
+	r.EqualError(cmd.Run(buildTestContext(t), []string{"myapp", "--Force"}), "flag provided but not defined: -Force")
+	r.Nil(cmd.Command("Deploy"))
 }
 
-func TestCommand_Args(t *testing.T) {
-	set := flag.NewFlagSet("test", 0)
-	set.Bool("myflag", false, "doc")
-	cmd := &Command{flagSet: set}
-	_ = set.Parse([]string{"--myflag", "bat", "baz"})
+func TestCommand_AllowAbbreviations(t *testing.T) {
+	newCmd := func(action ActionFunc) *Command {
+		return &Command{
+			Name:               "myapp",
+			AllowAbbreviations: true,
+			Writer:             io.Discard,
+			ErrWriter:          io.Discard,
+			Commands: []*Command{
+				{
+					Name: "status",
+					Flags: []Flag{
+						&DurationFlag{Name: "timeout", Aliases: []string{"timeout-after"}},
+						&StringFlag{Name: "title"},
+						&BoolFlag{Name: "verbose"},
+					},
+					Action: action,
+				},
+				{Name: "start"},
+				{Name: "stop"},
+			},
+		}
+	}
+
+	var timeout time.Duration
+	var verbose bool
+	cmd := newCmd(func(_ context.Context, cmd *Command) error {
+		timeout = cmd.Duration("timeout")
+		verbose = cmd.Bool("verbose")
+		return nil
+	})
 
 	r := require.New(t)
-	r.Equal(2, cmd.Args().Len())
-	r.True(cmd.Bool("myflag"))
+	r.NoError(cmd.Run(buildTestContext(t), []string{"myapp", "stat", "--time=5s", "--verb"}))
+	r.Equal(5*time.Second, timeout)
+	r.True(verbose)
+
+	err := newCmd(nil).Run(buildTestContext(t), []string{"myapp", "st"})
+	r.EqualError(err, `ambiguous command "st", could be: status, start, stop`)
+
+	err = newCmd(nil).Run(buildTestContext(t), []string{"myapp", "status", "--ti", "x"})
+	r.EqualError(err, "ambiguous flag --ti, could be: --timeout, --title")
 }
 
 func TestCommand_NArg(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.Bool("myflag", false, "doc")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 	_ = set.Parse([]string{"--myflag", "bat", "baz"})
 
 	require.Equal(t, 2, cmd.NArg())
@@ -3458,8 +4535,8 @@ func TestCommand_IsSet(t *testing.T) {
 	set.String("three-flag", "hello world", "doc")
 	parentSet := flag.NewFlagSet("test", 0)
 	parentSet.Bool("top-flag", true, "doc")
-	pCmd := &Command{flagSet: parentSet}
-	cmd := &Command{flagSet: set, parent: pCmd}
+	pCmd := &Command{runState: runState{flagSet: parentSet}}
+	cmd := &Command{runState: runState{flagSet: set}, parent: pCmd}
 
 	_ = set.Parse([]string{"--one-flag", "--two-flag", "--three-flag", "frob"})
 	_ = parentSet.Parse([]string{"--top-flag"})
@@ -3529,8 +4606,8 @@ func TestCommand_NumFlags(t *testing.T) {
 	set.String("otherflag", "hello world", "doc")
 	globalSet := flag.NewFlagSet("test", 0)
 	globalSet.Bool("myflagGlobal", true, "doc")
-	globalCmd := &Command{flagSet: globalSet}
-	cmd := &Command{flagSet: set, parent: globalCmd}
+	globalCmd := &Command{runState: runState{flagSet: globalSet}}
+	cmd := &Command{runState: runState{flagSet: set}, parent: globalCmd}
 	_ = set.Parse([]string{"--myflag", "--otherflag=foo"})
 	_ = globalSet.Parse([]string{"--myflagGlobal"})
 	require.Equal(t, 2, cmd.NumFlags())
@@ -3539,7 +4616,7 @@ func TestCommand_NumFlags(t *testing.T) {
 func TestCommand_Set(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.Int64("int", int64(5), "an int")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 
 	r := require.New(t)
 
@@ -3556,7 +4633,7 @@ func TestCommand_Set_InvalidFlagAccessHandler(t *testing.T) {
 		InvalidFlagAccessHandler: func(_ context.Context, _ *Command, name string) {
 			flagName = name
 		},
-		flagSet: set,
+		runState: runState{flagSet: set},
 	}
 
 	r := require.New(t)
@@ -3571,8 +4648,8 @@ func TestCommand_LocalFlagNames(t *testing.T) {
 	set.String("two-flag", "hello world", "doc")
 	parentSet := flag.NewFlagSet("test", 0)
 	parentSet.Bool("top-flag", true, "doc")
-	pCmd := &Command{flagSet: parentSet}
-	cmd := &Command{flagSet: set, parent: pCmd}
+	pCmd := &Command{runState: runState{flagSet: parentSet}}
+	cmd := &Command{runState: runState{flagSet: set}, parent: pCmd}
 	_ = set.Parse([]string{"--one-flag", "--two-flag=foo"})
 	_ = parentSet.Parse([]string{"--top-flag"})
 
@@ -3588,8 +4665,8 @@ func TestCommand_FlagNames(t *testing.T) {
 	set.String("two-flag", "hello world", "doc")
 	parentSet := flag.NewFlagSet("test", 0)
 	parentSet.Bool("top-flag", true, "doc")
-	pCmd := &Command{flagSet: parentSet}
-	cmd := &Command{flagSet: set, parent: pCmd}
+	pCmd := &Command{runState: runState{flagSet: parentSet}}
+	cmd := &Command{runState: runState{flagSet: set}, parent: pCmd}
 	_ = set.Parse([]string{"--one-flag", "--two-flag=foo"})
 	_ = parentSet.Parse([]string{"--top-flag"})
 
@@ -3604,8 +4681,8 @@ func TestCommand_Lineage(t *testing.T) {
 	set.Bool("local-flag", false, "doc")
 	parentSet := flag.NewFlagSet("test", 0)
 	parentSet.Bool("top-flag", true, "doc")
-	pCmd := &Command{flagSet: parentSet}
-	cmd := &Command{flagSet: set, parent: pCmd}
+	pCmd := &Command{runState: runState{flagSet: parentSet}}
+	cmd := &Command{runState: runState{flagSet: set}, parent: pCmd}
 	_ = set.Parse([]string{"--local-flag"})
 	_ = parentSet.Parse([]string{"--top-flag"})
 
@@ -3622,8 +4699,8 @@ func TestCommand_lookupFlagSet(t *testing.T) {
 	set.Bool("local-flag", false, "doc")
 	parentSet := flag.NewFlagSet("test", 0)
 	parentSet.Bool("top-flag", true, "doc")
-	pCmd := &Command{flagSet: parentSet}
-	cmd := &Command{flagSet: set, parent: pCmd}
+	pCmd := &Command{runState: runState{flagSet: parentSet}}
+	cmd := &Command{runState: runState{flagSet: set}, parent: pCmd}
 	_ = set.Parse([]string{"--local-flag"})
 	_ = parentSet.Parse([]string{"--top-flag"})
 
@@ -3694,7 +4771,7 @@ func TestCommandAttributeAccessing(t *testing.T) {
 		t.Run(test.testCase, func(t *testing.T) {
 			set := flag.NewFlagSet("some-flag-set-name", 0)
 			set.Bool(test.setBoolInput, false, "usage documentation")
-			cmd := &Command{flagSet: set, parent: test.parent}
+			cmd := &Command{runState: runState{flagSet: set}, parent: test.parent}
 
 			require.False(t, cmd.Bool(test.ctxBoolInput))
 		})
@@ -3872,9 +4949,9 @@ func TestCommand_ParentCommand_Set(t *testing.T) {
 	parentSet.String("Name", "", "")
 
 	cmd := &Command{
-		flagSet: flag.NewFlagSet("child", flag.ContinueOnError),
+		runState: runState{flagSet: flag.NewFlagSet("child", flag.ContinueOnError)},
 		parent: &Command{
-			flagSet: parentSet,
+			runState: runState{flagSet: parentSet},
 		},
 	}
 
@@ -4090,6 +5167,82 @@ func TestCommandReadArgsFromStdIn(t *testing.T) {
 	}
 }
 
+func TestCommandResponseFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	common := writeFile("common.args", "--ssf common # shared flags\n")
+	build := writeFile("build.args", "--if 10\n--ssf 'with space' --ssf \"say \\\"hi\\\"\"\n@"+common+"\n")
+	loop := writeFile("loop.args", "@"+filepath.Join(dir, "loop.args"))
+	unterminated := writeFile("unterminated.args", "--ssf 'oops")
+
+	tests := []struct {
+		name          string
+		args          []string
+		expectedInt   int64
+		expectedSlice []string
+		expectedArgs  []string
+		expectedErr   string
+	}{
+		{
+			name:          "nested files",
+			args:          []string{"foo", "@" + build, "arg"},
+			expectedInt:   10,
+			expectedSlice: []string{"with space", `say "hi"`, "common"},
+			expectedArgs:  []string{"arg"},
+		},
+		{
+			name:          "escaped and after terminator",
+			args:          []string{"foo", "@@literal", "--", "@" + build},
+			expectedSlice: []string{},
+			expectedArgs:  []string{"@literal", "--", "@" + build},
+		},
+		{
+			name:        "recursion",
+			args:        []string{"foo", "@" + loop},
+			expectedErr: "includes itself",
+		},
+		{
+			name:        "unterminated quote",
+			args:        []string{"foo", "@" + unterminated},
+			expectedErr: "unterminated ' quote",
+		},
+		{
+			name:        "missing file",
+			args:        []string{"foo", "@" + filepath.Join(dir, "missing.args")},
+			expectedErr: "could not read response file",
+		},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			cmd := buildMinimalTestCommand()
+			cmd.ResponseFiles = true
+			cmd.Flags = []Flag{
+				&IntFlag{Name: "if"},
+				&StringSliceFlag{Name: "ssf"},
+			}
+			cmd.Action = func(_ context.Context, cmd *Command) error {
+				assert.Equal(t, tst.expectedInt, cmd.Int("if"))
+				assert.Equal(t, tst.expectedSlice, cmd.StringSlice("ssf"))
+				assert.Equal(t, tst.expectedArgs, cmd.Args().Slice())
+				return nil
+			}
+
+			err := cmd.Run(buildTestContext(t), tst.args)
+			if tst.expectedErr != "" {
+				assert.ErrorContains(t, err, tst.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestZeroValueCommand(t *testing.T) {
 	var cmd Command
 	assert.NoError(t, cmd.Run(context.Background(), []string{"foo"}))
@@ -4138,6 +5291,204 @@ func TestCommandCategories(t *testing.T) {
 	}
 }
 
+func TestCommandEnvVarPrefix(t *testing.T) {
+	t.Setenv("MYAPP_LISTEN_ADDR", "0.0.0.0:80")
+	t.Setenv("MYAPP_DEBUG", "true")
+	t.Setenv("MYAPP_RETRIES", "5")
+	t.Setenv("MYAPP_TOKEN", "secret")
+	t.Setenv("TOKEN", "explicit")
+
+	var out bytes.Buffer
+	subCmd := &Command{
+		Name: "serve",
+		Flags: []Flag{
+			&IntFlag{Name: "retries"},
+			&StringFlag{Name: "token", Sources: EnvVars("TOKEN"), NoEnvVarPrefix: true},
+		},
+		Action: func(_ context.Context, cmd *Command) error {
+			assert.Equal(t, "0.0.0.0:80", cmd.String("listen-addr"))
+			assert.True(t, cmd.Bool("debug"))
+			assert.Equal(t, int64(5), cmd.Int("retries"))
+			assert.Equal(t, "explicit", cmd.String("token"))
+			return nil
+		},
+	}
+	cmd := &Command{
+		Name:         "myapp",
+		EnvVarPrefix: "MYAPP",
+		Writer:       &out,
+		Flags: []Flag{
+			&StringFlag{Name: "listen-addr"},
+			&BoolFlag{Name: "debug", Sources: EnvVars("APP_DEBUG")},
+		},
+		Commands: []*Command{subCmd},
+	}
+
+	r := require.New(t)
+	r.NoError(cmd.Run(buildTestContext(t), []string{"myapp", "serve"}))
+	r.Equal([]string{"APP_DEBUG", "MYAPP_DEBUG"}, cmd.Flags[1].(*BoolFlag).GetEnvVars())
+
+	r.NoError(cmd.Run(buildTestContext(t), []string{"myapp", "--help"}))
+	r.Contains(out.String(), "--listen-addr string   [$MYAPP_LISTEN_ADDR]")
+	r.Contains(out.String(), "[$APP_DEBUG, $MYAPP_DEBUG]")
+	r.NotContains(out.String(), "MYAPP_HELP")
+}
+
+func TestCommandFilePathEnvVars(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("from-file\n"), 0o600))
+
+	t.Run("command wide", func(t *testing.T) {
+		t.Setenv("MYAPP_TOKEN_FILE", tokenFile)
+
+		fl := &StringFlag{Name: "token"}
+		cmd := &Command{
+			EnvVarPrefix:    "MYAPP",
+			FilePathEnvVars: true,
+			Flags:           []Flag{fl},
+			Action: func(_ context.Context, cmd *Command) error {
+				assert.Equal(t, "from-file", cmd.String("token"))
+				return nil
+			},
+		}
+		require.NoError(t, cmd.Run(buildTestContext(t), []string{"myapp"}))
+		assert.Equal(t, []string{"MYAPP_TOKEN", "MYAPP_TOKEN_FILE"}, fl.GetEnvVars())
+	})
+
+	t.Run("env var takes precedence", func(t *testing.T) {
+		t.Setenv("APP_TOKEN", "from-env")
+		t.Setenv("APP_TOKEN_FILE", tokenFile)
+
+		cmd := &Command{
+			Flags: []Flag{
+				&StringFlag{Name: "token", Sources: EnvVars("APP_TOKEN"), FilePathEnvVars: true},
+			},
+			Action: func(_ context.Context, cmd *Command) error {
+				assert.Equal(t, "from-env", cmd.String("token"))
+				return nil
+			},
+		}
+		require.NoError(t, cmd.Run(buildTestContext(t), []string{"myapp"}))
+	})
+
+	t.Run("per flag", func(t *testing.T) {
+		t.Setenv("APP_TOKEN_FILE", tokenFile)
+
+		cmd := &Command{
+			Flags: []Flag{
+				&StringFlag{Name: "token", Sources: EnvVars("APP_TOKEN"), FilePathEnvVars: true},
+				&StringFlag{Name: "other", Sources: EnvVars("APP_TOKEN")},
+			},
+			Action: func(_ context.Context, cmd *Command) error {
+				assert.Equal(t, "from-file", cmd.String("token"))
+				assert.Empty(t, cmd.String("other"))
+				return nil
+			},
+		}
+		require.NoError(t, cmd.Run(buildTestContext(t), []string{"myapp"}))
+	})
+}
+
+func TestCommandValueDirs(t *testing.T) {
+	secrets, config := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(secrets, "token"), []byte("from-secret\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(config, "token"), []byte("from-config"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(config, "region"), []byte("eu"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(config, "verbose"), []byte("true"), 0o644))
+	t.Setenv("APP_REGION", "us")
+
+	var sources []string
+	cmd := &Command{
+		Name:      "myapp",
+		ValueDirs: []string{secrets, config},
+		Flags: []Flag{
+			&StringFlag{Name: "token"},
+			&StringFlag{Name: "region", Sources: EnvVars("APP_REGION")},
+		},
+		Commands: []*Command{
+			{
+				Name:  "serve",
+				Flags: []Flag{&BoolFlag{Name: "verbose"}, &IntFlag{Name: "port", Value: 80}},
+				Action: func(_ context.Context, cmd *Command) error {
+					assert.Equal(t, "from-secret", cmd.String("token"))
+					assert.Equal(t, "us", cmd.String("region"))
+					assert.True(t, cmd.Bool("verbose"))
+					assert.Equal(t, int64(80), cmd.Int("port"))
+					sources = []string{cmd.ValueSource("token"), cmd.ValueSource("verbose")}
+					return nil
+				},
+			},
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"myapp", "serve"}))
+	assert.Equal(t, []string{
+		`key "token" from directory "` + secrets + `"`,
+		`key "verbose" from directory "` + config + `"`,
+	}, sources)
+
+	cmd.resetRunState()
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"myapp", "serve"}))
+	assert.Len(t, cmd.Flags[0].(*StringFlag).Sources.Chain, 2)
+}
+
+func TestCommandFlagSources(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "region"), []byte("from-dir"), 0o644))
+	t.Setenv("APP_USER", "from-env")
+
+	vault := NewFuncSource("vault", func(key string) (string, bool, error) {
+		switch key {
+		case "token", "user", "region":
+			return "from-vault", true, nil
+		case "broken":
+			return "", false, errors.New("permission denied")
+		}
+		return "", false, nil
+	})
+	ssm := NewFuncSource("ssm", func(key string) (string, bool, error) {
+		return "from-ssm", key == "token" || key == "port", nil
+	})
+
+	var token, user, region, port string
+	cmd := &Command{
+		Name:        "myapp",
+		ValueDirs:   []string{dir},
+		FlagSources: []MapSource{vault, ssm},
+		Flags: []Flag{
+			&StringFlag{Name: "token"},
+			&StringFlag{Name: "user", Sources: EnvVars("APP_USER")},
+		},
+		Commands: []*Command{
+			{
+				Name:  "serve",
+				Flags: []Flag{&StringFlag{Name: "region"}, &StringFlag{Name: "port"}},
+				Action: func(_ context.Context, cmd *Command) error {
+					token, user, region, port = cmd.String("token"), cmd.String("user"), cmd.String("region"), cmd.String("port")
+					return nil
+				},
+			},
+			{
+				Name:  "broken",
+				Flags: []Flag{&StringFlag{Name: "broken"}},
+			},
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"myapp", "serve"}))
+	assert.Equal(t, []string{"from-vault", "from-env", "from-dir", "from-ssm"}, []string{token, user, region, port})
+	assert.Len(t, cmd.Flags[0].(*StringFlag).Sources.Chain, 3)
+
+	cmd.resetRunState()
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"myapp", "serve"}))
+	assert.Len(t, cmd.Flags[0].(*StringFlag).Sources.Chain, 3)
+
+	cmd.resetRunState()
+	err := cmd.Run(buildTestContext(t), []string{"myapp", "broken"})
+	assert.ErrorContains(t, err, `could not read value for flag broken from key "broken" from vault: permission denied`)
+}
+
 func TestJSONExportCommand(t *testing.T) {
 	cmd := buildExtendedTestCommand()
 	cmd.Arguments = []Argument{
@@ -4207,6 +5558,13 @@ func TestJSONExportCommand(t *testing.T) {
 					  "TrimSpace": false
 					},
 					"onlyOnce": false,
+					"separator": "",
+					"disableSeparator": false,
+					"sensitive": false,
+					"placeholder": "",
+					"noEnvVarPrefix": false,
+					"filePathEnvVars": false,
+					"expandEnv": false,
 					"validateDefaults" : false
 				  },
 				  {
@@ -4227,6 +5585,13 @@ func TestJSONExportCommand(t *testing.T) {
 					  "Count": null
 					},
 					"onlyOnce": false,
+					"separator": "",
+					"disableSeparator": false,
+					"sensitive": false,
+					"placeholder": "",
+					"noEnvVarPrefix": false,
+					"filePathEnvVars": false,
+					"expandEnv": false,
 					"validateDefaults" : false
 				  }
 				],
@@ -4246,6 +5611,40 @@ func TestJSONExportCommand(t *testing.T) {
 				"prefixMatchCommands": false,
 				"mutuallyExclusiveFlags": null,
 				"arguments": null,
+				"envVarPrefix": "",
+				"filePathEnvVars": false,
+				"unknownFlagPolicy": 0,
+				"unknownValueFlags": null,
+				"caseInsensitive": false,
+				"allowAbbreviations": false,
+				"suggestionsDisabled": false,
+				"suggestThreshold": 0,
+				"responseFiles": false,
+				"skipFlagParsingAfterFirstArg": false,
+				"argsMin": 0,
+				"argsMax": 0,
+				"jsonErrors": false,
+				"timeout": 0,
+				"recover": false,
+				"panicExitCode": 0,
+				"crashFile": "",
+				"shellPrompt": "",
+				"shellHistoryFile": "",
+				"userAliases": null,
+				"categories": null,
+				"extraVersionInfo": null,
+				"helpWidth": 0,
+				"helpTopics": null,
+				"groupFlags": false,
+				"dotEnv": null,
+				"dotEnvRequired": false,
+				"valueDirs": null,
+				"debugResolution": false,
+				"promptForMissing": false,
+				"auditFile": "",
+				"exclusive": false,
+				"lockFile": "",
+				"cooldown": 0,
 				"readArgsFromStdin": false
 			  }
 			],
@@ -4269,6 +5668,13 @@ func TestJSONExportCommand(t *testing.T) {
 				  "TrimSpace": false
 				},
 				"onlyOnce": false,
+				"separator": "",
+				"disableSeparator": false,
+				"sensitive": false,
+				"placeholder": "",
+				"noEnvVarPrefix": false,
+				"filePathEnvVars": false,
+				"expandEnv": false,
 				"validateDefaults" : false
 			  },
 			  {
@@ -4289,6 +5695,13 @@ func TestJSONExportCommand(t *testing.T) {
 				  "Count": null
 				},
 				"onlyOnce": false,
+				"separator": "",
+				"disableSeparator": false,
+				"sensitive": false,
+				"placeholder": "",
+				"noEnvVarPrefix": false,
+				"filePathEnvVars": false,
+				"expandEnv": false,
 				"validateDefaults" : false
 			  }
 			],
@@ -4308,6 +5721,40 @@ func TestJSONExportCommand(t *testing.T) {
 			"prefixMatchCommands": false,
 			"mutuallyExclusiveFlags": null,
 			"arguments": null,
+			"envVarPrefix": "",
+			"filePathEnvVars": false,
+			"unknownFlagPolicy": 0,
+			"unknownValueFlags": null,
+			"caseInsensitive": false,
+			"allowAbbreviations": false,
+			"suggestionsDisabled": false,
+			"suggestThreshold": 0,
+			"responseFiles": false,
+			"skipFlagParsingAfterFirstArg": false,
+			"argsMin": 0,
+			"argsMax": 0,
+			"jsonErrors": false,
+			"timeout": 0,
+			"recover": false,
+			"panicExitCode": 0,
+			"crashFile": "",
+			"shellPrompt": "",
+			"shellHistoryFile": "",
+			"userAliases": null,
+			"categories": null,
+			"extraVersionInfo": null,
+			"helpWidth": 0,
+			"helpTopics": null,
+			"groupFlags": false,
+			"dotEnv": null,
+			"dotEnvRequired": false,
+			"valueDirs": null,
+			"debugResolution": false,
+			"promptForMissing": false,
+			"auditFile": "",
+			"exclusive": false,
+			"lockFile": "",
+			"cooldown": 0,
 			"readArgsFromStdin": false
 		  },
 		  {
@@ -4341,6 +5788,40 @@ func TestJSONExportCommand(t *testing.T) {
 			"prefixMatchCommands": false,
 			"mutuallyExclusiveFlags": null,
 			"arguments": null,
+			"envVarPrefix": "",
+			"filePathEnvVars": false,
+			"unknownFlagPolicy": 0,
+			"unknownValueFlags": null,
+			"caseInsensitive": false,
+			"allowAbbreviations": false,
+			"suggestionsDisabled": false,
+			"suggestThreshold": 0,
+			"responseFiles": false,
+			"skipFlagParsingAfterFirstArg": false,
+			"argsMin": 0,
+			"argsMax": 0,
+			"jsonErrors": false,
+			"timeout": 0,
+			"recover": false,
+			"panicExitCode": 0,
+			"crashFile": "",
+			"shellPrompt": "",
+			"shellHistoryFile": "",
+			"userAliases": null,
+			"categories": null,
+			"extraVersionInfo": null,
+			"helpWidth": 0,
+			"helpTopics": null,
+			"groupFlags": false,
+			"dotEnv": null,
+			"dotEnvRequired": false,
+			"valueDirs": null,
+			"debugResolution": false,
+			"promptForMissing": false,
+			"auditFile": "",
+			"exclusive": false,
+			"lockFile": "",
+			"cooldown": 0,
 			"readArgsFromStdin": false
 		  },
 		  {
@@ -4371,6 +5852,40 @@ func TestJSONExportCommand(t *testing.T) {
 			"prefixMatchCommands": false,
 			"mutuallyExclusiveFlags": null,
 			"arguments": null,
+			"envVarPrefix": "",
+			"filePathEnvVars": false,
+			"unknownFlagPolicy": 0,
+			"unknownValueFlags": null,
+			"caseInsensitive": false,
+			"allowAbbreviations": false,
+			"suggestionsDisabled": false,
+			"suggestThreshold": 0,
+			"responseFiles": false,
+			"skipFlagParsingAfterFirstArg": false,
+			"argsMin": 0,
+			"argsMax": 0,
+			"jsonErrors": false,
+			"timeout": 0,
+			"recover": false,
+			"panicExitCode": 0,
+			"crashFile": "",
+			"shellPrompt": "",
+			"shellHistoryFile": "",
+			"userAliases": null,
+			"categories": null,
+			"extraVersionInfo": null,
+			"helpWidth": 0,
+			"helpTopics": null,
+			"groupFlags": false,
+			"dotEnv": null,
+			"dotEnvRequired": false,
+			"valueDirs": null,
+			"debugResolution": false,
+			"promptForMissing": false,
+			"auditFile": "",
+			"exclusive": false,
+			"lockFile": "",
+			"cooldown": 0,
 			"readArgsFromStdin": false
 		  },
 		  {
@@ -4401,6 +5916,40 @@ func TestJSONExportCommand(t *testing.T) {
 			"prefixMatchCommands": false,
 			"mutuallyExclusiveFlags": null,
 			"arguments": null,
+			"envVarPrefix": "",
+			"filePathEnvVars": false,
+			"unknownFlagPolicy": 0,
+			"unknownValueFlags": null,
+			"caseInsensitive": false,
+			"allowAbbreviations": false,
+			"suggestionsDisabled": false,
+			"suggestThreshold": 0,
+			"responseFiles": false,
+			"skipFlagParsingAfterFirstArg": false,
+			"argsMin": 0,
+			"argsMax": 0,
+			"jsonErrors": false,
+			"timeout": 0,
+			"recover": false,
+			"panicExitCode": 0,
+			"crashFile": "",
+			"shellPrompt": "",
+			"shellHistoryFile": "",
+			"userAliases": null,
+			"categories": null,
+			"extraVersionInfo": null,
+			"helpWidth": 0,
+			"helpTopics": null,
+			"groupFlags": false,
+			"dotEnv": null,
+			"dotEnvRequired": false,
+			"valueDirs": null,
+			"debugResolution": false,
+			"promptForMissing": false,
+			"auditFile": "",
+			"exclusive": false,
+			"lockFile": "",
+			"cooldown": 0,
 			"readArgsFromStdin": false
 		  },
 		  {
@@ -4448,6 +5997,13 @@ func TestJSONExportCommand(t *testing.T) {
 					  "Count": null
 					},
 					"onlyOnce": false,
+					"separator": "",
+					"disableSeparator": false,
+					"sensitive": false,
+					"placeholder": "",
+					"noEnvVarPrefix": false,
+					"filePathEnvVars": false,
+					"expandEnv": false,
 					"validateDefaults" : false
 				  }
 				],
@@ -4467,6 +6023,40 @@ func TestJSONExportCommand(t *testing.T) {
 				"prefixMatchCommands": false,
 				"mutuallyExclusiveFlags": null,
 				"arguments": null,
+				"envVarPrefix": "",
+				"filePathEnvVars": false,
+				"unknownFlagPolicy": 0,
+				"unknownValueFlags": null,
+				"caseInsensitive": false,
+				"allowAbbreviations": false,
+				"suggestionsDisabled": false,
+				"suggestThreshold": 0,
+				"responseFiles": false,
+				"skipFlagParsingAfterFirstArg": false,
+				"argsMin": 0,
+				"argsMax": 0,
+				"jsonErrors": false,
+				"timeout": 0,
+				"recover": false,
+				"panicExitCode": 0,
+				"crashFile": "",
+				"shellPrompt": "",
+				"shellHistoryFile": "",
+				"userAliases": null,
+				"categories": null,
+				"extraVersionInfo": null,
+				"helpWidth": 0,
+				"helpTopics": null,
+				"groupFlags": false,
+				"dotEnv": null,
+				"dotEnvRequired": false,
+				"valueDirs": null,
+				"debugResolution": false,
+				"promptForMissing": false,
+				"auditFile": "",
+				"exclusive": false,
+				"lockFile": "",
+				"cooldown": 0,
 				"readArgsFromStdin": false
 			  }
 			],
@@ -4490,6 +6080,13 @@ func TestJSONExportCommand(t *testing.T) {
 				  "TrimSpace": false
 				},
 				"onlyOnce": false,
+				"separator": "",
+				"disableSeparator": false,
+				"sensitive": false,
+				"placeholder": "",
+				"noEnvVarPrefix": false,
+				"filePathEnvVars": false,
+				"expandEnv": false,
 				"validateDefaults" : false
 			  },
 			  {
@@ -4510,6 +6107,13 @@ func TestJSONExportCommand(t *testing.T) {
 				  "Count": null
 				},
 				"onlyOnce": false,
+				"separator": "",
+				"disableSeparator": false,
+				"sensitive": false,
+				"placeholder": "",
+				"noEnvVarPrefix": false,
+				"filePathEnvVars": false,
+				"expandEnv": false,
 				"validateDefaults" : false
 			  }
 			],
@@ -4529,6 +6133,40 @@ func TestJSONExportCommand(t *testing.T) {
 			"prefixMatchCommands": false,
 			"mutuallyExclusiveFlags": null,
 			"arguments": null,
+			"envVarPrefix": "",
+			"filePathEnvVars": false,
+			"unknownFlagPolicy": 0,
+			"unknownValueFlags": null,
+			"caseInsensitive": false,
+			"allowAbbreviations": false,
+			"suggestionsDisabled": false,
+			"suggestThreshold": 0,
+			"responseFiles": false,
+			"skipFlagParsingAfterFirstArg": false,
+			"argsMin": 0,
+			"argsMax": 0,
+			"jsonErrors": false,
+			"timeout": 0,
+			"recover": false,
+			"panicExitCode": 0,
+			"crashFile": "",
+			"shellPrompt": "",
+			"shellHistoryFile": "",
+			"userAliases": null,
+			"categories": null,
+			"extraVersionInfo": null,
+			"helpWidth": 0,
+			"helpTopics": null,
+			"groupFlags": false,
+			"dotEnv": null,
+			"dotEnvRequired": false,
+			"valueDirs": null,
+			"debugResolution": false,
+			"promptForMissing": false,
+			"auditFile": "",
+			"exclusive": false,
+			"lockFile": "",
+			"cooldown": 0,
 			"readArgsFromStdin": false
 		  }
 		],
@@ -4551,6 +6189,13 @@ func TestJSONExportCommand(t *testing.T) {
 			  "TrimSpace": false
 			},
 			"onlyOnce": false,
+			"separator": "",
+			"disableSeparator": false,
+			"sensitive": false,
+			"placeholder": "",
+			"noEnvVarPrefix": false,
+			"filePathEnvVars": false,
+			"expandEnv": false,
 			"validateDefaults" : false
 		  },
 		  {
@@ -4572,6 +6217,13 @@ func TestJSONExportCommand(t *testing.T) {
 			  "TrimSpace": false
 			},
 			"onlyOnce": false,
+			"separator": "",
+			"disableSeparator": false,
+			"sensitive": false,
+			"placeholder": "",
+			"noEnvVarPrefix": false,
+			"filePathEnvVars": false,
+			"expandEnv": false,
 			"validateDefaults" : false
 		  },
 		  {
@@ -4592,6 +6244,13 @@ func TestJSONExportCommand(t *testing.T) {
 			  "Count": null
 			},
 			"onlyOnce": false,
+			"separator": "",
+			"disableSeparator": false,
+			"sensitive": false,
+			"placeholder": "",
+			"noEnvVarPrefix": false,
+			"filePathEnvVars": false,
+			"expandEnv": false,
 			"validateDefaults" : false
 		  },
 		  {
@@ -4610,6 +6269,13 @@ func TestJSONExportCommand(t *testing.T) {
 			  "Count": null
 			},
 			"onlyOnce": false,
+			"separator": "",
+			"disableSeparator": false,
+			"sensitive": false,
+			"placeholder": "",
+			"noEnvVarPrefix": false,
+			"filePathEnvVars": false,
+			"expandEnv": false,
 			"validateDefaults" : false
 		  }
 		],
@@ -4646,8 +6312,312 @@ func TestJSONExportCommand(t *testing.T) {
 			}
 		  }
 		],
+		"envVarPrefix": "",
+		"filePathEnvVars": false,
+		"unknownFlagPolicy": 0,
+		"unknownValueFlags": null,
+		"caseInsensitive": false,
+		"allowAbbreviations": false,
+		"suggestionsDisabled": false,
+		"suggestThreshold": 0,
+		"responseFiles": false,
+		"skipFlagParsingAfterFirstArg": false,
+		"argsMin": 0,
+		"argsMax": 0,
+		"jsonErrors": false,
+		"timeout": 0,
+		"recover": false,
+		"panicExitCode": 0,
+		"crashFile": "",
+		"shellPrompt": "",
+		"shellHistoryFile": "",
+		"userAliases": null,
+		"categories": null,
+		"extraVersionInfo": null,
+		"helpWidth": 0,
+		"helpTopics": null,
+		"groupFlags": false,
+		"dotEnv": null,
+		"dotEnvRequired": false,
+		"valueDirs": null,
+		"debugResolution": false,
+		"promptForMissing": false,
+		"auditFile": "",
+		"exclusive": false,
+		"lockFile": "",
+		"cooldown": 0,
 		"readArgsFromStdin": false
 	  }
 `
 	assert.JSONEq(t, expected, string(out))
 }
+
+func TestCommand_Load(t *testing.T) {
+	var loaded []string
+	lazy := func(name string, action ActionFunc) *Command {
+		return &Command{
+			Name:  name,
+			Usage: "do " + name,
+			Load: func() *Command {
+				loaded = append(loaded, name)
+				return &Command{
+					Flags:  []Flag{&StringFlag{Name: "target"}},
+					Action: action,
+				}
+			},
+		}
+	}
+
+	var target string
+	cmd := &Command{
+		Name:   "app",
+		Writer: io.Discard,
+		Commands: []*Command{
+			lazy("deploy", func(_ context.Context, cmd *Command) error {
+				target = cmd.String("target")
+				return nil
+			}),
+			lazy("build", nil),
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "deploy", "--target", "prod"}))
+	assert.Equal(t, []string{"deploy"}, loaded)
+	assert.Equal(t, "prod", target)
+	assert.Equal(t, "do deploy", cmd.Command("deploy").Usage)
+	assert.Nil(t, cmd.Command("deploy").Load)
+
+	cmd.LoadCommands()
+	assert.Equal(t, []string{"deploy", "build"}, loaded)
+	assert.Equal(t, "do build", cmd.Command("build").Usage)
+	assert.Nil(t, cmd.Command("build").Load)
+}
+
+func TestCommand_RunShell(t *testing.T) {
+	var calls []string
+	var out, errOut bytes.Buffer
+	historyFile := filepath.Join(t.TempDir(), "history")
+
+	cmd := &Command{
+		Name:             "app",
+		Reader:           strings.NewReader("greet --name 'Jane Doe'\n\ngreet\nfail\nbogus --nope\nexit\ngreet\n"),
+		Writer:           &out,
+		ErrWriter:        &errOut,
+		ShellHistoryFile: historyFile,
+		Commands: []*Command{
+			{
+				Name:  "greet",
+				Flags: []Flag{&StringFlag{Name: "name", Value: "world"}},
+				Action: func(_ context.Context, cmd *Command) error {
+					calls = append(calls, "hello "+cmd.String("name"))
+					return nil
+				},
+			},
+			{
+				Name: "fail",
+				Action: func(context.Context, *Command) error {
+					return Exit("failed", 3)
+				},
+			},
+		},
+	}
+
+	require.NoError(t, cmd.RunShell(buildTestContext(t)))
+	assert.Equal(t, []string{"hello Jane Doe", "hello world"}, calls)
+	assert.Contains(t, out.String(), "app> ")
+	assert.Contains(t, errOut.String(), "failed\n")
+	assert.Equal(t, []string{"greet --name 'Jane Doe'", "greet", "fail", "bogus --nope", "exit"}, cmd.ShellHistory())
+
+	data, err := os.ReadFile(historyFile)
+	require.NoError(t, err)
+	assert.Equal(t, "greet --name 'Jane Doe'\ngreet\nfail\nbogus --nope\nexit\n", string(data))
+
+	var prompts []string
+	cmd.ShellPrompt = "> "
+	cmd.ShellReadLine = func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return "", io.EOF
+	}
+	require.NoError(t, cmd.RunShell(buildTestContext(t)))
+	assert.Equal(t, []string{"> "}, prompts)
+	assert.Len(t, cmd.ShellHistory(), 5)
+}
+
+func TestCommand_CompleteLine(t *testing.T) {
+	cmd := &Command{
+		Name:  "app",
+		Flags: []Flag{&BoolFlag{Name: "verbose"}},
+		Commands: []*Command{
+			{
+				Name:    "deploy",
+				Aliases: []string{"d"},
+				Flags:   []Flag{&StringFlag{Name: "target"}},
+				Commands: []*Command{
+					{Name: "status"},
+					{Name: "start"},
+				},
+			},
+			{Name: "debug", Hidden: true},
+			{Name: "destroy"},
+		},
+	}
+
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{line: "", expected: []string{"d", "deploy", "destroy", "h", "help"}},
+		{line: "de", expected: []string{"deploy", "destroy"}},
+		{line: "deploy st", expected: []string{"start", "status"}},
+		{line: "deploy --t", expected: []string{"--target"}},
+		{line: "deploy --v", expected: []string{"--verbose"}},
+		{line: "deploy -- st", expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			assert.Equal(t, test.expected, cmd.CompleteLine(test.line))
+		})
+	}
+}
+
+// newManyFlagsCommand returns a command with n persistent flags and a
+// subcommand with n flags
+func newManyFlagsCommand(n int) *Command {
+	cmd := &Command{
+		Name:   "app",
+		Writer: io.Discard,
+		Commands: []*Command{
+			{
+				Name:   "sub",
+				Action: func(context.Context, *Command) error { return nil },
+			},
+		},
+	}
+	for i := 0; i < n; i++ {
+		cmd.Flags = append(cmd.Flags, &StringFlag{Name: fmt.Sprintf("root-flag-%d", i), Aliases: []string{fmt.Sprintf("r%d", i)}})
+		cmd.Commands[0].Flags = append(cmd.Commands[0].Flags, &IntFlag{Name: fmt.Sprintf("sub-flag-%d", i)})
+	}
+	return cmd
+}
+
+func BenchmarkCommand_RunManyFlags(b *testing.B) {
+	args := []string{"app", "--root-flag-1", "a", "--r399", "b", "sub", "--sub-flag-7", "7", "--root-flag-200", "c"}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		cmd := newManyFlagsCommand(400)
+		b.StartTimer()
+
+		if err := cmd.Run(context.Background(), args); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCommand_LookupManyFlags(b *testing.B) {
+	cmd := newManyFlagsCommand(400)
+	var sub *Command
+	cmd.Commands[0].Action = func(_ context.Context, cmd *Command) error {
+		sub = cmd
+		return nil
+	}
+	require.NoError(b, cmd.Run(context.Background(), []string{"app", "--r399", "b", "sub", "--sub-flag-7", "7"}))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if sub.String("root-flag-399") != "b" || sub.Int("sub-flag-7") != 7 || sub.IsSet("sub-flag-399") {
+			b.Fatal("unexpected value")
+		}
+	}
+}
+
+// TestCommand_RunConcurrent is meant to be run with the race detector
+func TestCommand_RunConcurrent(t *testing.T) {
+	newCommand := func(name string, stdout, stderr io.Writer, exitCode *int) *Command {
+		return &Command{
+			Name:      name,
+			Version:   "1.0.0",
+			Writer:    stdout,
+			ErrWriter: stderr,
+			OsExiter:  func(code int) { *exitCode = code },
+			HelpPrinter: func(w io.Writer, templ string, data any) {
+				fmt.Fprintf(w, "help of %s\n", data.(*Command).Name)
+			},
+			Flags: []Flag{
+				&StringFlag{Name: "greeting", Value: "hello", Sources: EnvVars("GREETING")},
+			},
+			Commands: []*Command{
+				{
+					Name: "greet",
+					Action: func(_ context.Context, cmd *Command) error {
+						fmt.Fprintf(cmd.Root().Writer, "%s from %s\n", cmd.String("greeting"), cmd.Root().Name)
+						return nil
+					},
+				},
+				{
+					Name: "fail",
+					Action: func(context.Context, *Command) error {
+						return Exit("failed in "+name, 3)
+					},
+				},
+			},
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		for _, args := range [][]string{{"--help"}, {"--version"}, {"greet"}, {"--greeting", "hi", "greet"}, {"fail"}} {
+			wg.Add(1)
+			go func(name string, args []string) {
+				defer wg.Done()
+
+				var stdout, stderr bytes.Buffer
+				exitCode := 0
+				cmd := newCommand(name, &stdout, &stderr, &exitCode)
+				err := cmd.Run(context.Background(), append([]string{name}, args...))
+
+				switch args[len(args)-1] {
+				case "--help":
+					assert.Equal(t, "help of "+name+"\n", stdout.String())
+				case "--version":
+					assert.Equal(t, name+" version 1.0.0\n", stdout.String())
+				case "greet":
+					assert.Contains(t, stdout.String(), "from "+name+"\n")
+				case "fail":
+					assert.Error(t, err)
+					assert.Equal(t, 3, exitCode)
+					assert.Equal(t, "failed in "+name+"\n", stderr.String())
+				}
+			}(fmt.Sprintf("app%d", i), args)
+		}
+	}
+	wg.Wait()
+}
+
+func TestCommand_flagByName(t *testing.T) {
+	cmd := &Command{
+		Name:   "app",
+		Flags:  []Flag{&StringFlag{Name: "a"}, &StringFlag{Name: "b"}},
+		Action: func(context.Context, *Command) error { return nil },
+	}
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app"}))
+	require.NotNil(t, cmd.flagIndex)
+	assert.Same(t, cmd.Flags[1], cmd.flagByName("b"))
+
+	// replaced by a slice of the same length
+	c := &StringFlag{Name: "c"}
+	cmd.Flags = []Flag{&StringFlag{Name: "a"}, c}
+	assert.Same(t, c, cmd.flagByName("c"))
+	assert.Nil(t, cmd.flagByName("b"))
+
+	// an element replaced in place
+	cmd.indexFlags()
+	c2 := &StringFlag{Name: "c"}
+	cmd.Flags[1] = c2
+	assert.Same(t, c2, cmd.flagByName("c"))
+	cmd.Flags[1] = &StringFlag{Name: "d"}
+	assert.Nil(t, cmd.flagByName("c"))
+}
@@ -0,0 +1,601 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConfigFormat decodes a config file into a map of flag names to values.
+// Values which are maps keyed by a subcommand name hold the values of the
+// flags of that subcommand.
+type ConfigFormat interface {
+	// Decode decodes the content of a config file
+	Decode(data []byte) (map[string]any, error)
+}
+
+// ConfigWriter is implemented by config formats config init can write
+// config files in, see ConfigCommands
+type ConfigWriter interface {
+	// WriteConfig writes the values of the node as a config file
+	WriteConfig(w io.Writer, node *ConfigNode) error
+}
+
+// ConfigNode holds the values of the flags of a command and its
+// subcommands written to a config file by a ConfigWriter
+type ConfigNode struct {
+	Name     string        // name of the command
+	Entries  []ConfigEntry // values of the flags of the command
+	Children []*ConfigNode // values of the flags of the subcommands
+}
+
+// ConfigEntry is the value of a flag in a ConfigNode
+type ConfigEntry struct {
+	Name  string // name of the flag
+	Usage string // usage of the flag, e.g. written as a comment
+	Value any    // value of the flag, a bool, int64, uint64, float64, string, []any or map[string]any
+}
+
+// configFormats are the formats of config files by their extension, see
+// RegisterConfigFormat
+var configFormats = map[string]ConfigFormat{
+	".json": JSON,
+}
+
+// configFormatNames are the formats of config files by their name, see
+// RegisterConfigFormat
+var configFormatNames = map[string]ConfigFormat{
+	"json": JSON,
+}
+
+// RegisterConfigFormat registers a config file format under the name, which
+// selects it with the --format flag of config init, and the extensions
+// config files in the format are detected by, like ".yaml". Formats which
+// are not part of this package, like YAML, TOML or INI, are registered by
+// the packages providing them, e.g. by importing
+// github.com/urfave/cli/v3/cliyaml. It is meant to be called from init
+// functions and is not safe to call while commands run.
+func RegisterConfigFormat(name string, format ConfigFormat, exts ...string) {
+	configFormatNames[name] = format
+	for _, ext := range exts {
+		configFormats[strings.ToLower(ext)] = format
+	}
+}
+
+// configFormatList returns the names of the registered config formats
+func configFormatList() []string {
+	names := make([]string, 0, len(configFormatNames))
+	for name := range configFormatNames {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ConfigFile configures the config file flag values are read from, see
+// Command.ConfigFile. Values given on the command line or in env vars take
+// precedence over values in the config file, which take precedence over the
+// default values of flags.
+type ConfigFile struct {
+	// Name of the flag giving the paths of config files, which take
+	// precedence over all other config files. A string slice flag of this
+	// name is added to the root command if it has no such flag.
+	Flag string
+	// Path of the config file with the lowest precedence, e.g. a system
+	// wide config file. It is not an error if no file exists at this path.
+	Path string
+	// Paths of further config files, e.g. a user config file, later files
+	// take precedence over earlier ones and over Path. It is not an error if
+	// no file exists at these paths.
+	Paths []string
+	// Format of the config file, e.g. JSON. The format of files with the
+	// extension of a registered format like .json, or .toml with
+	// github.com/urfave/cli/v3/clitoml imported, is detected from it, see
+	// RegisterConfigFormat.
+	Format ConfigFormat
+	// Name of the flag selecting the profile, the top level section of the
+	// config file the values are read from, like in AWS credentials files.
+	// A string flag of this name is added to the root command if it has no
+	// such flag.
+	ProfileFlag string
+	// Profile the values are read from if the profile flag is not set. It
+	// is not an error if the config file has no such profile.
+	Profile string
+	// Whether to discover config files with DiscoverConfigFiles for the
+	// name of the root command and the ProjectFiles. Values in discovered
+	// files take precedence over values in the files at Path and Paths.
+	Discover bool
+	// Names of project config files searched for in the working directory
+	// and its parents, e.g. .myapp.yaml
+	ProjectFiles []string
+	// How often Command.WatchConfig checks the config files for changes,
+	// defaults to 1 second
+	WatchInterval time.Duration
+}
+
+// configFileFlag returns the flag added to a command for its ConfigFile
+func (cf *ConfigFile) configFileFlag() Flag {
+	return &StringSliceFlag{
+		Name:      cf.Flag,
+		Usage:     "load flag values from config `FILE`, later files take precedence",
+		TakesFile: true,
+	}
+}
+
+// profileFlag returns the flag added to a command for its ConfigFile
+// profile
+func (cf *ConfigFile) profileFlag() Flag {
+	return &StringFlag{
+		Name:  cf.ProfileFlag,
+		Usage: "read flag values from config `PROFILE`",
+		Value: cf.Profile,
+	}
+}
+
+// configProfile returns the profile of the config file flag values are read
+// from, and whether it was given with the profile flag
+func (cmd *Command) configProfile(cf *ConfigFile) (string, bool) {
+	if cf.ProfileFlag != "" && cmd.lookupFlag(cf.ProfileFlag) != nil {
+		profile, _ := cmd.value(cf.ProfileFlag).(string)
+		return profile, cmd.IsSet(cf.ProfileFlag)
+	}
+	return cf.Profile, false
+}
+
+// configLayer holds the values read from one config file
+type configLayer struct {
+	path   string
+	values map[string]any
+}
+
+// configPaths returns the paths of the config files of the command by
+// precedence, the highest first, and the number of leading paths given with
+// the flag, which must exist
+func (cmd *Command) configPaths(cf *ConfigFile) ([]string, int) {
+	var paths []string
+	if cf.Flag != "" && cmd.lookupFlag(cf.Flag) != nil && cmd.IsSet(cf.Flag) {
+		switch v := cmd.value(cf.Flag).(type) {
+		case string:
+			paths = append(paths, v)
+		case []string:
+			for i := len(v) - 1; i >= 0; i-- {
+				paths = append(paths, v[i])
+			}
+		}
+	}
+	explicit := len(paths)
+
+	if cf.Discover {
+		paths = append(paths, DiscoverConfigFiles(cmd.Root().Name, cf.ProjectFiles...)...)
+	}
+	for i := len(cf.Paths) - 1; i >= 0; i-- {
+		paths = append(paths, cf.Paths[i])
+	}
+	if cf.Path != "" {
+		paths = append(paths, cf.Path)
+	}
+	return paths, explicit
+}
+
+// loadConfig returns the values of the config files of the command by
+// precedence, reading the files once
+func (cmd *Command) loadConfig() ([]configLayer, error) {
+	root := cmd.Root()
+	cf := root.ConfigFile
+	if cf == nil {
+		return nil, nil
+	}
+
+	paths, explicit := cmd.configPaths(cf)
+	key := strings.Join(paths, string(os.PathListSeparator))
+	if root.configLayers != nil && root.configKey == key {
+		return root.configLayers, nil
+	}
+
+	layers := []configLayer{}
+	for i, path := range paths {
+		tracef("reading config file %[1]q (cmd=%[2]q)", path, cmd.Name)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) && i >= explicit {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read config file: %w", err)
+		}
+
+		format := configFormatFor(path, cf.Format)
+		if format == nil {
+			return nil, fmt.Errorf("unknown format of config file %q", path)
+		}
+
+		values, err := format.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file %q: %w", path, err)
+		}
+		layers = append(layers, configLayer{path: path, values: values})
+	}
+
+	root.configLayers, root.configKey = layers, key
+	return layers, nil
+}
+
+// configFormatFor returns the format of the config file at path by its
+// extension, or the given format if it has no known extension or the given
+// format is of the same kind, e.g. a lenient JSONFormat for a .json file
+func configFormatFor(path string, format ConfigFormat) ConfigFormat {
+	byExt := configFormats[strings.ToLower(filepath.Ext(path))]
+	if byExt == nil || (format != nil && reflect.TypeOf(format) == reflect.TypeOf(byExt)) {
+		return format
+	}
+	return byExt
+}
+
+// ConfigFileUsed returns the path of the config file flag values are read
+// from, the one with the highest precedence if several were read, or an
+// empty string if no config file was read
+func (cmd *Command) ConfigFileUsed() string {
+	if mu := cmd.Root().valuesMu; mu != nil {
+		mu.RLock()
+		defer mu.RUnlock()
+	}
+
+	if layers := cmd.Root().configLayers; len(layers) > 0 {
+		return layers[0].path
+	}
+	return ""
+}
+
+// DiscoverConfigFiles returns the existing config files of the application
+// by precedence, the highest first: the first of the project files found in
+// the working directory or the nearest of its parents, ~/.<app>rc and the
+// first of $XDG_CONFIG_HOME/<app>/config.*, where XDG_CONFIG_HOME defaults
+// to ~/.config.
+func DiscoverConfigFiles(app string, projectFiles ...string) []string {
+	var paths []string
+
+	if dir, err := os.Getwd(); err == nil && len(projectFiles) > 0 {
+	walk:
+		for {
+			for _, name := range projectFiles {
+				path := filepath.Join(dir, name)
+				if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+					paths = append(paths, path)
+					break walk
+				}
+			}
+
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+
+	home, _ := os.UserHomeDir()
+	if home != "" {
+		path := filepath.Join(home, "."+app+"rc")
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+			paths = append(paths, path)
+		}
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" && home != "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	if configHome != "" {
+		matches, _ := filepath.Glob(filepath.Join(configHome, app, "config.*"))
+		sort.Strings(matches)
+		if len(matches) > 0 {
+			paths = append(paths, matches[0])
+		}
+	}
+
+	tracef("discovered config files %[1]q", paths)
+	return paths
+}
+
+// applyConfig sets the flags of the command and the persistent flags of its
+// parents which are not set on the command line or from env vars to the
+// values in the config files. The values of flags of a subcommand are looked
+// up under the names of the subcommand and its parents, e.g. serve.port.
+func (cmd *Command) applyConfig() error {
+	cmd.configSources = nil
+
+	layers, err := cmd.loadConfig()
+	if err != nil || len(layers) == 0 {
+		return err
+	}
+
+	layers, prefix, err := cmd.profileConfigLayers(layers)
+	if err != nil || len(layers) == 0 {
+		return err
+	}
+
+	for _, pCmd := range cmd.Lineage() {
+		keys := pCmd.configKeys()
+		scopes := scopeConfigLayers(layers, keys)
+		if len(scopes) == 0 {
+			continue
+		}
+		keys = append(slices.Clone(prefix), keys...)
+
+		for _, fl := range pCmd.Flags {
+			if pCmd != cmd {
+				if lf, ok := fl.(LocalFlag); !ok || lf.IsLocal() {
+					continue
+				}
+			}
+
+			if err := cmd.applyConfigValue(keys, fl, scopes); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// profileConfigLayers returns the values of the selected profile in the
+// config files and the key of the profile, or the config files unchanged if
+// no profile is selected. It returns no config files if none has the
+// profile, which is an error if the profile was given with the profile flag.
+func (cmd *Command) profileConfigLayers(layers []configLayer) ([]configLayer, []string, error) {
+	profile, explicit := cmd.configProfile(cmd.Root().ConfigFile)
+	if profile == "" {
+		return layers, nil, nil
+	}
+
+	scoped := scopeConfigLayers(layers, []string{profile})
+	if len(scoped) == 0 {
+		if explicit {
+			return nil, nil, fmt.Errorf("no profile %q in config file %q", profile, layers[0].path)
+		}
+		return nil, nil, nil
+	}
+
+	tracef("using config profile %[1]q (cmd=%[2]q)", profile, cmd.Name)
+	return scoped, []string{profile}, nil
+}
+
+// scopeConfigLayers returns the values nested under the keys in the config
+// files having them
+func scopeConfigLayers(layers []configLayer, keys []string) []configLayer {
+	var scopes []configLayer
+	for _, layer := range layers {
+		if values, ok := configScope(layer.values, keys); ok {
+			scopes = append(scopes, configLayer{path: layer.path, values: values})
+		}
+	}
+	return scopes
+}
+
+// applyConfigValue sets the flag to its value in the first of the config
+// files having one
+func (cmd *Command) applyConfigValue(keys []string, fl Flag, scopes []configLayer) error {
+	names := fl.Names()
+	if len(names) == 0 || cmd.flagSet.Lookup(names[0]) == nil {
+		return nil
+	}
+	for _, name := range names {
+		if cmd.IsSet(name) {
+			return nil
+		}
+	}
+
+	key, path, vals, err := findConfigValue(keys, names, scopes)
+	if err != nil || path == "" {
+		return err
+	}
+
+	tracef("setting flag %[1]q from config key %[2]q in %[3]q (cmd=%[4]q)", names[0], key, path, cmd.Name)
+	for _, v := range vals {
+		if err := cmd.flagSet.Set(names[0], v); err != nil {
+			return fmt.Errorf("invalid value %q for %q in config file %q: %w", v, key, path, err)
+		}
+	}
+
+	if cmd.configSources == nil {
+		cmd.configSources = map[string]string{}
+	}
+	cmd.configSources[names[0]] = configSourceDesc(key, path)
+	return nil
+}
+
+// findConfigValue returns the key, the path of the config file and the
+// strings a flag with the given names is set to from the first of the
+// config files having a value for it, or an empty path if none has one
+func findConfigValue(keys, names []string, scopes []configLayer) (string, string, []string, error) {
+	for _, scope := range scopes {
+		for _, name := range names {
+			value, ok := scope.values[name]
+			if !ok {
+				continue
+			}
+
+			key := strings.Join(append(slices.Clone(keys), name), ".")
+			vals, err := configValueStrings(value)
+			if err != nil {
+				return "", "", nil, fmt.Errorf("invalid value for %q in config file %q: %w", key, scope.path, err)
+			}
+			return key, scope.path, vals, nil
+		}
+	}
+
+	return "", "", nil, nil
+}
+
+func configSourceDesc(key, path string) string {
+	return fmt.Sprintf("key %q in config file %q", key, path)
+}
+
+// configKeys returns the names of the command and its parents below the
+// root command, which the values of its flags are nested under in config
+// files
+func (cmd *Command) configKeys() []string {
+	var keys []string
+	for pCmd := cmd; pCmd.parent != nil; pCmd = pCmd.parent {
+		keys = append([]string{pCmd.Name}, keys...)
+	}
+	return keys
+}
+
+func configScope(values map[string]any, keys []string) (map[string]any, bool) {
+	for _, key := range keys {
+		scope, ok := values[key].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		values = scope
+	}
+	return values, true
+}
+
+// configValueStrings returns the strings a flag is set to for a config value.
+// A list sets the flag once for each item, a map once for each key=value.
+func configValueStrings(value any) ([]string, error) {
+	switch v := value.(type) {
+	case []any:
+		vals := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := configScalarString(item)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, s)
+		}
+		return vals, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		vals := make([]string, 0, len(v))
+		for _, key := range keys {
+			s, err := configScalarString(v[key])
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, key+"="+s)
+		}
+		return vals, nil
+	}
+
+	s, err := configScalarString(value)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+func configScalarString(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case time.Time:
+		return v.Format(time.RFC3339), nil
+	case json.Number:
+		return v.String(), nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("unsupported value of type %T", value)
+}
+
+// JSON decodes config files in JSON format
+var JSON ConfigFormat = JSONFormat{}
+
+// JSONFormat decodes config files in JSON format. With Lenient set it allows
+// // and /* */ comments and trailing commas, like JSON5.
+type JSONFormat struct {
+	Lenient bool
+}
+
+func (f JSONFormat) Decode(data []byte) (map[string]any, error) {
+	if f.Lenient {
+		data = stripJSONExtensions(data)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	values := map[string]any{}
+	if err := dec.Decode(&values); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := 1 + bytes.Count(data[:min(int(syntaxErr.Offset), len(data))], []byte("\n"))
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		return nil, err
+	}
+	return values, nil
+}
+
+// stripJSONExtensions replaces comments and trailing commas outside of
+// strings with spaces, keeping newlines so errors refer to the right line
+func stripJSONExtensions(data []byte) []byte {
+	out := bytes.Clone(data)
+	inString := false
+	lastComma := -1
+
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			lastComma = -1
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(out) - i - 2
+			} else {
+				end += 2
+			}
+			for j := i; j < i+2+end && j < len(out); j++ {
+				if out[j] != '\n' {
+					out[j] = ' '
+				}
+			}
+			i += 1 + end
+		case c == ',':
+			lastComma = i
+		case c == '}' || c == ']':
+			if lastComma >= 0 {
+				out[lastComma] = ' '
+			}
+			lastComma = -1
+		case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			lastComma = -1
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,309 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigCommands returns a config command with the subcommands init, which
+// writes a config file with all flags of the root command and its
+// subcommands set to their default values, and show, which prints the
+// values flags are set to and where they are set from. Both are derived
+// from the flag definitions and the ConfigFile of the root command.
+func ConfigCommands() *Command {
+	return &Command{
+		Name:            "config",
+		Usage:           "Manage the config file",
+		isConfigCommand: true,
+		Commands: []*Command{
+			{
+				Name:  "init",
+				Usage: "Write a config file with the default values of all flags",
+				Flags: []Flag{
+					&StringFlag{
+						Name:  "format",
+						Usage: "`FORMAT` of the config file, one of " + strings.Join(configFormatList(), ", ") + ", detected from the output file by default",
+						Validator: func(s string) error {
+							if _, ok := configFormatNames[s]; !ok && s != "" {
+								return fmt.Errorf("unknown config format %q", s)
+							}
+							return nil
+						},
+					},
+					&StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "write the config file to `FILE` instead of stdout", TakesFile: true},
+					&BoolFlag{Name: "force", Usage: "overwrite an existing config file"},
+				},
+				Action: configInit,
+			},
+			{
+				Name:   "show",
+				Usage:  "Print the values of all flags and where they are set from",
+				Action: configShow,
+			},
+		},
+	}
+}
+
+func configInit(_ context.Context, cmd *Command) error {
+	root := cmd.Root()
+	output := cmd.String("output")
+
+	format := configFormatNames[cmd.String("format")]
+	if format == nil {
+		var rootFormat ConfigFormat
+		if root.ConfigFile != nil {
+			rootFormat = root.ConfigFile.Format
+		}
+		format = configFormatFor(output, rootFormat)
+	}
+	if format == nil {
+		format = JSON
+	}
+	// keep the options of the format of the root command, e.g. a lenient
+	// JSONFormat allowing comments
+	if root.ConfigFile != nil && root.ConfigFile.Format != nil && reflect.TypeOf(root.ConfigFile.Format) == reflect.TypeOf(format) {
+		format = root.ConfigFile.Format
+	}
+
+	node := configTree(root, func(_ *Command, fl Flag) any {
+		if df, ok := fl.(interface{ configDefault() any }); ok {
+			return configValueOf(df.configDefault())
+		}
+		return nil
+	})
+
+	w := root.Writer
+	if output != "" {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+		if cmd.Bool("force") {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		}
+		f, err := os.OpenFile(output, flags, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("config file %q already exists, use --force to overwrite it", output)
+		}
+		if err != nil {
+			return fmt.Errorf("could not write config file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	tracef("writing config file %[1]q in format %[2]T (cmd=%[3]q)", output, format, cmd.Name)
+	return writeConfig(w, format, node)
+}
+
+func configShow(_ context.Context, cmd *Command) error {
+	root := cmd.Root()
+
+	var layers []configLayer
+	var prefix []string
+	if root.ConfigFile != nil {
+		var err error
+		if layers, err = cmd.loadConfig(); err != nil {
+			return err
+		}
+		if layers, prefix, err = cmd.profileConfigLayers(layers); err != nil {
+			return err
+		}
+	}
+
+	type row struct{ key, value, source string }
+	var rows []row
+
+	configTree(root, func(c *Command, fl Flag) any {
+		name := fl.Names()[0]
+		keys := c.configKeys()
+
+		var value any
+		source := "default"
+		if c == root {
+			value, source = configValueOf(cmd.Value(name)), cmd.ValueSource(name)
+		} else {
+			if df, ok := fl.(interface{ configDefault() any }); ok {
+				value = configValueOf(df.configDefault())
+			}
+		scopes:
+			for _, scope := range scopeConfigLayers(layers, keys) {
+				for _, n := range fl.Names() {
+					if v, ok := scope.values[n]; ok {
+						key := strings.Join(append(append(prefix[:len(prefix):len(prefix)], keys...), n), ".")
+						value, source = v, fmt.Sprintf("key %q in config file %q", key, scope.path)
+						break scopes
+					}
+				}
+			}
+		}
+
+		text := "REDACTED"
+		if sf, ok := fl.(SensitiveFlag); !ok || !sf.IsSensitive() {
+			text = configLiteral(value)
+		}
+		rows = append(rows, row{strings.Join(append(keys, name), "."), text, source})
+		return nil
+	})
+
+	t := NewTable("KEY", "VALUE", "SOURCE")
+	for _, r := range rows {
+		t.Append(r.key, r.value, r.source)
+	}
+	return t.Write(root.Writer)
+}
+
+// configTree returns the flags of the command and its subcommands which can
+// be set in a config file, with the values returned by value. Hidden flags
+// and commands, the help and version flags and the flags of the ConfigFile
+// are left out.
+func configTree(cmd *Command, value func(*Command, Flag) any) *ConfigNode {
+	node := &ConfigNode{Name: cmd.Name}
+
+	var skip []string
+	if cf := cmd.ConfigFile; cf != nil && cmd.parent == nil {
+		skip = []string{cf.Flag, cf.ProfileFlag}
+	}
+
+	for _, fl := range cmd.Flags {
+		names := fl.Names()
+		if len(names) == 0 || fl == HelpFlag || fl == VersionFlag || fl == NoColorFlag || fl == ColorFlag || slices.Contains(skip, names[0]) {
+			continue
+		}
+		if vf, ok := fl.(VisibleFlag); ok && !vf.IsVisible() {
+			continue
+		}
+
+		entry := ConfigEntry{Name: names[0], Value: value(cmd, fl)}
+		if df, ok := fl.(DocGenerationFlag); ok {
+			_, entry.Usage = unquoteUsage(df.GetUsage())
+		}
+		node.Entries = append(node.Entries, entry)
+	}
+
+	for _, subCmd := range cmd.Commands {
+		if subCmd.Hidden || subCmd.Name == helpName || subCmd.isConfigCommand {
+			continue
+		}
+		subCmd.ensureLoaded()
+		if child := configTree(subCmd, value); len(child.Entries) > 0 || len(child.Children) > 0 {
+			node.Children = append(node.Children, child)
+		}
+	}
+
+	return node
+}
+
+// configValueOf returns the value of a flag as it is written to a config
+// file, a bool, int64, uint64, float64, string, []any or map[string]any
+func configValueOf(value any) any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice:
+		items := make([]any, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, configValueOf(rv.Index(i).Interface()))
+		}
+		return items
+	case reflect.Map:
+		m := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = configValueOf(iter.Value().Interface())
+		}
+		return m
+	}
+	return fmt.Sprint(value)
+}
+
+// configLiteral returns the value as JSON
+func configLiteral(value any) string {
+	if value == nil {
+		return `""`
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return strconv.Quote(fmt.Sprint(value))
+	}
+	return string(data)
+}
+
+// writeConfig writes the values of the node as a config file in the format
+func writeConfig(w io.Writer, format ConfigFormat, node *ConfigNode) error {
+	cw, ok := format.(ConfigWriter)
+	if !ok {
+		return fmt.Errorf("cannot write config files in format %T", format)
+	}
+	return cw.WriteConfig(w, node)
+}
+
+// WriteConfig writes the values of the node as JSON, with the usage of the
+// flags as comments if Lenient is set
+func (f JSONFormat) WriteConfig(w io.Writer, node *ConfigNode) error {
+	var sb strings.Builder
+	writeJSONConfig(&sb, node, "", f.Lenient)
+	sb.WriteString("\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeJSONConfig(sb *strings.Builder, node *ConfigNode, indent string, comments bool) {
+	sb.WriteString("{\n")
+	n := len(node.Entries) + len(node.Children)
+	i := 0
+	sep := func() {
+		if i++; i < n {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n")
+	}
+
+	for _, e := range node.Entries {
+		if comments && e.Usage != "" {
+			fmt.Fprintf(sb, "%s  // %s\n", indent, e.Usage)
+		}
+		fmt.Fprintf(sb, "%s  %q: %s", indent, e.Name, configLiteral(e.Value))
+		sep()
+	}
+	for _, child := range node.Children {
+		fmt.Fprintf(sb, "%s  %q: ", indent, child.Name)
+		writeJSONConfig(sb, child, indent+"  ", comments)
+		sep()
+	}
+	sb.WriteString(indent + "}")
+}
+
+func sortedConfigKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
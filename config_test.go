@@ -0,0 +1,757 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_ConfigFileYAML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+verbose: true
+name: from-config
+tags: [a, b]
+serve:
+  port: 8080
+  labels:
+    env: prod
+db:
+  migrate:
+    dry-run: true
+`), 0o644))
+
+	type result struct {
+		verbose bool
+		name    string
+		tags    []string
+		port    int64
+		labels  map[string]string
+		dryRun  bool
+	}
+
+	newCmd := func(res *result) *Command {
+		return &Command{
+			Name:       "app",
+			ConfigFile: &ConfigFile{Flag: "config", Path: filepath.Join(dir, "missing.yaml"), Format: testYAML},
+			Flags: []Flag{
+				&BoolFlag{Name: "verbose"},
+				&StringFlag{Name: "name", Value: "default", Sources: EnvVars("APP_NAME")},
+				&StringSliceFlag{Name: "tags"},
+			},
+			Action: func(_ context.Context, cmd *Command) error {
+				res.verbose = cmd.Bool("verbose")
+				res.name = cmd.String("name")
+				res.tags = cmd.StringSlice("tags")
+				return nil
+			},
+			Commands: []*Command{
+				{
+					Name: "serve",
+					Flags: []Flag{
+						&IntFlag{Name: "port", Value: 80, Required: true},
+						&StringMapFlag{Name: "labels"},
+					},
+					Action: func(_ context.Context, cmd *Command) error {
+						res.verbose = cmd.Bool("verbose")
+						res.port = cmd.Int("port")
+						res.labels = cmd.StringMap("labels")
+						return nil
+					},
+				},
+				{
+					Name: "db",
+					Commands: []*Command{
+						{
+							Name:  "migrate",
+							Flags: []Flag{&BoolFlag{Name: "dry-run"}},
+							Action: func(_ context.Context, cmd *Command) error {
+								res.dryRun = cmd.Bool("dry-run")
+								return nil
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("root flags", func(t *testing.T) {
+		var res result
+		require.NoError(t, newCmd(&res).Run(buildTestContext(t), []string{"app", "--config", path}))
+		assert.Equal(t, result{verbose: true, name: "from-config", tags: []string{"a", "b"}}, res)
+	})
+
+	t.Run("command line takes precedence", func(t *testing.T) {
+		var res result
+		require.NoError(t, newCmd(&res).Run(buildTestContext(t), []string{"app", "--config", path, "--name", "cli"}))
+		assert.Equal(t, "cli", res.name)
+	})
+
+	t.Run("env takes precedence", func(t *testing.T) {
+		t.Setenv("APP_NAME", "env")
+		var res result
+		require.NoError(t, newCmd(&res).Run(buildTestContext(t), []string{"app", "--config", path}))
+		assert.Equal(t, "env", res.name)
+	})
+
+	t.Run("subcommand flags", func(t *testing.T) {
+		var res result
+		require.NoError(t, newCmd(&res).Run(buildTestContext(t), []string{"app", "serve", "--config", path}))
+		assert.True(t, res.verbose)
+		assert.Equal(t, int64(8080), res.port)
+		assert.Equal(t, map[string]string{"env": "prod"}, res.labels)
+
+		require.NoError(t, newCmd(&res).Run(buildTestContext(t), []string{"app", "--config", path, "db", "migrate"}))
+		assert.True(t, res.dryRun)
+	})
+
+	t.Run("missing default path", func(t *testing.T) {
+		var res result
+		require.NoError(t, newCmd(&res).Run(buildTestContext(t), []string{"app"}))
+		assert.Equal(t, "default", res.name)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		var res result
+		err := newCmd(&res).Run(buildTestContext(t), []string{"app", "--config", filepath.Join(dir, "nope.yaml")})
+		assert.ErrorContains(t, err, "could not read config file")
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		bad := filepath.Join(dir, "bad.yaml")
+		require.NoError(t, os.WriteFile(bad, []byte("serve:\n  port: eighty\n"), 0o644))
+
+		var res result
+		err := newCmd(&res).Run(buildTestContext(t), []string{"app", "--config", bad, "serve"})
+		assert.ErrorContains(t, err, `invalid value "eighty" for "serve.port" in config file "`+bad+`"`)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		bad := filepath.Join(dir, "invalid.yaml")
+		require.NoError(t, os.WriteFile(bad, []byte("name: [a\n"), 0o644))
+
+		var res result
+		err := newCmd(&res).Run(buildTestContext(t), []string{"app", "--config", bad})
+		assert.ErrorContains(t, err, `invalid config file "`+bad+`"`)
+	})
+}
+
+func TestJSONDecode(t *testing.T) {
+	src := []byte(`{
+  // the port to listen on
+  "port": 8080,
+  "url": "http://example.com/*not a comment*/",
+  /* subcommand
+     flags */
+  "serve": {"hosts": ["a", "b",],},
+}`)
+
+	_, err := JSON.Decode(src)
+	assert.ErrorContains(t, err, "line 2: invalid character '/'")
+
+	values, err := JSONFormat{Lenient: true}.Decode(src)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"port":  json.Number("8080"),
+		"url":   "http://example.com/*not a comment*/",
+		"serve": map[string]any{"hosts": []any{"a", "b"}},
+	}, values)
+
+	_, err = JSONFormat{Lenient: true}.Decode([]byte("{\n  // comment\n  \"a\": 1\n  \"b\": 2\n}"))
+	assert.ErrorContains(t, err, "line 4: invalid character '\"' after object key:value pair")
+}
+
+func TestCommand_ConfigFileJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  // generated by the deploy tool
+  "size": 9007199254740993,
+  "ratio": 0.5,
+}`), 0o644))
+
+	var (
+		size  uint64
+		ratio float64
+	)
+	cmd := &Command{
+		Name:       "app",
+		ConfigFile: &ConfigFile{Path: path, Format: JSONFormat{Lenient: true}},
+		Flags: []Flag{
+			&UintFlag{Name: "size"},
+			&FloatFlag{Name: "ratio"},
+		},
+		Action: func(_ context.Context, cmd *Command) error {
+			size, ratio = cmd.Uint("size"), cmd.Float("ratio")
+			return nil
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app"}))
+	assert.Equal(t, uint64(9007199254740993), size)
+	assert.Equal(t, 0.5, ratio)
+}
+
+func TestCommand_ConfigFileProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	require.NoError(t, os.WriteFile(path, []byte(`
+default:
+  region: us-east-1
+prod:
+  region: eu-west-1
+  serve:
+    port: 443
+`), 0o644))
+
+	var (
+		region string
+		port   int64
+	)
+	newCmd := func() *Command {
+		return &Command{
+			Name: "app",
+			ConfigFile: &ConfigFile{
+				Path:        path,
+				Format:      testYAML,
+				ProfileFlag: "profile",
+				Profile:     "default",
+			},
+			Flags: []Flag{&StringFlag{Name: "region"}},
+			Commands: []*Command{
+				{
+					Name:  "serve",
+					Flags: []Flag{&IntFlag{Name: "port", Value: 80}},
+					Action: func(_ context.Context, cmd *Command) error {
+						region, port = cmd.String("region"), cmd.Int("port")
+						return nil
+					},
+				},
+			},
+		}
+	}
+
+	require.NoError(t, newCmd().Run(buildTestContext(t), []string{"app", "serve"}))
+	assert.Equal(t, "us-east-1", region)
+	assert.Equal(t, int64(80), port)
+
+	require.NoError(t, newCmd().Run(buildTestContext(t), []string{"app", "--profile", "prod", "serve"}))
+	assert.Equal(t, "eu-west-1", region)
+	assert.Equal(t, int64(443), port)
+
+	err := newCmd().Run(buildTestContext(t), []string{"app", "--profile", "staging", "serve"})
+	assert.ErrorContains(t, err, `no profile "staging" in config file "`+path+`"`)
+}
+
+func TestDiscoverConfigFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg"))
+
+	project := filepath.Join(home, "src", "project")
+	workDir := filepath.Join(project, "pkg", "sub")
+	require.NoError(t, os.MkdirAll(workDir, 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(home, "xdg", "app"), 0o755))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(workDir))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	assert.Empty(t, DiscoverConfigFiles("app", ".app.yaml"))
+
+	xdgFile := filepath.Join(home, "xdg", "app", "config.json")
+	rcFile := filepath.Join(home, ".apprc")
+	projectFile := filepath.Join(project, ".app.yaml")
+	require.NoError(t, os.WriteFile(xdgFile, []byte(`{"name": "xdg", "port": 1}`), 0o644))
+	require.NoError(t, os.WriteFile(rcFile, []byte("name: rc\nport: 2\nverbose: true\n"), 0o644))
+	require.NoError(t, os.WriteFile(projectFile, []byte("name: project\n"), 0o644))
+
+	expected := []string{projectFile, rcFile, xdgFile}
+	actual := DiscoverConfigFiles("app", ".app.yml", ".app.yaml")
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		// the working directory may be reported with symlinks resolved
+		assert.Equal(t, filepath.Base(expected[i]), filepath.Base(actual[i]))
+	}
+
+	var (
+		name, used string
+		port       int64
+		verbose    bool
+	)
+	cmd := &Command{
+		Name: "app",
+		ConfigFile: &ConfigFile{
+			Flag:         "config",
+			Format:       testYAML,
+			Discover:     true,
+			ProjectFiles: []string{".app.yaml"},
+		},
+		Flags: []Flag{
+			&StringFlag{Name: "name"},
+			&IntFlag{Name: "port"},
+			&BoolFlag{Name: "verbose"},
+		},
+		Action: func(_ context.Context, cmd *Command) error {
+			name, port, verbose, used = cmd.String("name"), cmd.Int("port"), cmd.Bool("verbose"), cmd.ConfigFileUsed()
+			return nil
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app"}))
+	assert.Equal(t, "project", name)
+	assert.Equal(t, int64(2), port)
+	assert.True(t, verbose)
+	assert.Equal(t, filepath.Base(projectFile), filepath.Base(used))
+
+	cmd.resetRunState()
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "--config", xdgFile}))
+	assert.Equal(t, "xdg", name)
+	assert.Equal(t, int64(1), port)
+	assert.True(t, verbose)
+	assert.Equal(t, xdgFile, used)
+}
+
+func TestCommand_ConfigFileLayers(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+	system := write("system.yaml", "name: system\nport: 1\nregion: eu\nverbose: true\n")
+	user := write("user.yml", "name: user\nport: 2\n")
+	first := write("first.json", `{"name": "first", "port": 3}`)
+	second := write("second.yaml", "name: second\n")
+
+	var name, region string
+	var port int64
+	var sources map[string]string
+	cmd := &Command{
+		Name: "app",
+		ConfigFile: &ConfigFile{
+			Flag:  "config",
+			Path:  system,
+			Paths: []string{user, filepath.Join(dir, "missing.yaml")},
+		},
+		Flags: []Flag{
+			&StringFlag{Name: "name"},
+			&IntFlag{Name: "port"},
+			&StringFlag{Name: "region", Sources: EnvVars("LAYERS_TEST_REGION")},
+			&BoolFlag{Name: "verbose"},
+			&BoolFlag{Name: "debug"},
+		},
+		Commands: []*Command{
+			{
+				Name: "serve",
+				Action: func(_ context.Context, cmd *Command) error {
+					name, port, region = cmd.String("name"), cmd.Int("port"), cmd.String("region")
+					sources = map[string]string{}
+					for _, flag := range []string{"name", "port", "region", "verbose", "debug", "config", "unknown"} {
+						sources[flag] = cmd.ValueSource(flag)
+					}
+					return nil
+				},
+			},
+		},
+	}
+
+	t.Setenv("LAYERS_TEST_REGION", "us")
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "--config", first, "--config", second, "serve", "--port", "4"}))
+	assert.Equal(t, "second", name)
+	assert.Equal(t, int64(4), port)
+	assert.Equal(t, "us", region)
+	assert.Equal(t, second, cmd.ConfigFileUsed())
+	assert.Equal(t, map[string]string{
+		"name":    `key "name" in config file "` + second + `"`,
+		"port":    "command line",
+		"region":  `environment variable "LAYERS_TEST_REGION"`,
+		"verbose": `key "verbose" in config file "` + system + `"`,
+		"debug":   "default",
+		"config":  "command line",
+		"unknown": "",
+	}, sources)
+
+	cmd.resetRunState()
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "serve"}))
+	assert.Equal(t, "user", name)
+	assert.Equal(t, int64(2), port)
+	assert.Equal(t, user, cmd.ConfigFileUsed())
+
+	cmd.resetRunState()
+	err := cmd.Run(buildTestContext(t), []string{"app", "--config", filepath.Join(dir, "missing.yaml"), "serve"})
+	assert.ErrorContains(t, err, "could not read config file")
+}
+
+func TestConfigCommands(t *testing.T) {
+	type result struct {
+		name    string
+		port    int64
+		tags    []string
+		wait    time.Duration
+		labels  map[string]string
+		dryRun  bool
+		verbose bool
+	}
+
+	newCmd := func(res *result) *Command {
+		return &Command{
+			Name:       "app",
+			ConfigFile: &ConfigFile{Flag: "config"},
+			Flags: []Flag{
+				&StringFlag{Name: "name", Value: "my app", Usage: "`NAME` of the app"},
+				&IntFlag{Name: "port", Value: 8080},
+				&StringSliceFlag{Name: "tags", Value: []string{"a", "b"}},
+				&DurationFlag{Name: "wait", Value: time.Minute},
+				&StringFlag{Name: "token", Sensitive: true},
+				&BoolFlag{Name: "secret", Hidden: true},
+			},
+			Commands: []*Command{
+				ConfigCommands(),
+				{
+					Name:  "serve",
+					Flags: []Flag{&StringMapFlag{Name: "labels", Value: map[string]string{"env": "dev"}}},
+					Action: func(_ context.Context, cmd *Command) error {
+						res.name, res.port, res.tags, res.wait = cmd.String("name"), cmd.Int("port"), cmd.StringSlice("tags"), cmd.Duration("wait")
+						res.labels = cmd.StringMap("labels")
+						return nil
+					},
+				},
+				{
+					Name: "db",
+					Commands: []*Command{
+						{
+							Name:  "migrate",
+							Flags: []Flag{&BoolFlag{Name: "dry-run", Usage: "only print the migrations"}, &BoolFlag{Name: "verbose", Value: true}},
+							Action: func(_ context.Context, cmd *Command) error {
+								res.dryRun, res.verbose = cmd.Bool("dry-run"), cmd.Bool("verbose")
+								return nil
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	var out bytes.Buffer
+	cmd := newCmd(&result{})
+	cmd.Writer = &out
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "config", "init"}))
+	assert.Equal(t, `{
+  "name": "my app",
+  "port": 8080,
+  "tags": ["a","b"],
+  "wait": "1m0s",
+  "token": "",
+  "serve": {
+    "labels": {"env":"dev"}
+  },
+  "db": {
+    "migrate": {
+      "dry-run": false,
+      "verbose": true
+    }
+  }
+}
+`, out.String())
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.json")
+	require.NoError(t, newCmd(&result{}).Run(buildTestContext(t), []string{"app", "config", "init", "--output", path}))
+
+	err := newCmd(&result{}).Run(buildTestContext(t), []string{"app", "config", "init", "--output", path})
+	assert.ErrorContains(t, err, "already exists")
+	require.NoError(t, newCmd(&result{}).Run(buildTestContext(t), []string{"app", "config", "init", "--output", path, "--force"}))
+
+	want := result{name: "my app", port: 8080, tags: []string{"a", "b"}, wait: time.Minute, labels: map[string]string{"env": "dev"}}
+	var res result
+	require.NoError(t, newCmd(&res).Run(buildTestContext(t), []string{"app", "--config", path, "serve"}))
+	assert.Equal(t, want, res)
+
+	res = result{}
+	require.NoError(t, newCmd(&res).Run(buildTestContext(t), []string{"app", "--config", path, "db", "migrate"}))
+	assert.True(t, res.verbose)
+	assert.False(t, res.dryRun)
+
+	path = filepath.Join(dir, "show.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("name: shown\ntoken: hunter2\ndb:\n  migrate:\n    dry-run: true\n"), 0o644))
+
+	out.Reset()
+	cmd = newCmd(&result{})
+	cmd.Writer = &out
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "--config", path, "--port", "9090", "config", "show"}))
+	assert.Equal(t, `KEY                 VALUE          SOURCE
+name                "shown"        key "name" in config file "`+path+`"
+port                9090           command line
+tags                ["a","b"]      default
+wait                "1m0s"         default
+token               REDACTED       key "token" in config file "`+path+`"
+serve.labels        {"env":"dev"}  default
+db.migrate.dry-run  true           key "db.migrate.dry-run" in config file "`+path+`"
+db.migrate.verbose  true           default
+`, out.String())
+
+	err = newCmd(&result{}).Run(buildTestContext(t), []string{"app", "config", "init", "--format", "xml"})
+	assert.ErrorContains(t, err, `unknown config format "xml"`)
+}
+
+func TestCommand_DebugResolution(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("region: eu\n"), 0o644))
+	t.Setenv("APP_TOKEN", "hunter2")
+
+	newCmd := func(errWriter io.Writer) *Command {
+		return &Command{
+			Name:       "my-app",
+			ErrWriter:  errWriter,
+			ConfigFile: &ConfigFile{Path: path},
+			Flags: []Flag{
+				&StringFlag{Name: "region"},
+				&StringFlag{Name: "token", Sensitive: true, Sources: EnvVars("APP_TOKEN")},
+			},
+			Commands: []*Command{
+				{
+					Name:   "serve",
+					Flags:  []Flag{&IntFlag{Name: "port", Value: 80}},
+					Action: func(context.Context, *Command) error { return nil },
+				},
+			},
+		}
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, newCmd(&out).Run(buildTestContext(t), []string{"my-app", "serve", "--port", "8080"}))
+	assert.Empty(t, out.String())
+
+	t.Setenv("MY_APP_CLI_DEBUG", "1")
+	require.NoError(t, newCmd(&out).Run(buildTestContext(t), []string{"my-app", "serve", "--port", "8080"}))
+	assert.Equal(t, `my-app: flag --region=eu from key "region" in config file "`+path+`"
+my-app: flag --token=REDACTED from environment variable "APP_TOKEN"
+my-app serve: flag --port=8080 from command line
+`, out.String())
+
+	t.Setenv("MY_APP_CLI_DEBUG", "")
+	out.Reset()
+	cmd := newCmd(&out)
+	cmd.DebugResolution = true
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"my-app", "serve"}))
+	assert.Contains(t, out.String(), "my-app serve: flag --port=80 from default\n")
+}
+
+func TestCommand_IsSetFrom(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("region: eu\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "user"), []byte("admin"), 0o644))
+	t.Setenv("APP_TOKEN", "hunter2")
+
+	var got map[string]FlagSource
+	cmd := &Command{
+		Name:       "app",
+		ConfigFile: &ConfigFile{Path: path},
+		ValueDirs:  []string{dir},
+		Flags: []Flag{
+			&StringFlag{Name: "region"},
+			&StringFlag{Name: "token", Sources: EnvVars("APP_TOKEN")},
+			&StringFlag{Name: "user"},
+			&IntFlag{Name: "port"},
+			&BoolFlag{Name: "verbose"},
+		},
+		Action: func(_ context.Context, cmd *Command) error {
+			got = map[string]FlagSource{}
+			for _, name := range []string{"region", "token", "user", "port", "verbose", "unknown"} {
+				got[name] = cmd.IsSetFrom(name)
+			}
+			return nil
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "--port", "80"}))
+	assert.Equal(t, map[string]FlagSource{
+		"region":  SourceConfigFile,
+		"token":   SourceEnvVar,
+		"user":    SourceOther,
+		"port":    SourceCommandLine,
+		"verbose": SourceDefault,
+		"unknown": SourceUnknown,
+	}, got)
+	assert.Equal(t, "env var", SourceEnvVar.String())
+}
+
+func TestCommand_WatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	write := func(content string, mtime time.Time) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	now := time.Now()
+	write("port: 80\nhost: a\ntags: [x]\n", now)
+
+	errs := make(chan string, 10)
+	changes := make(chan []string, 10)
+	values := make(chan []any, 10)
+	ctx, cancel := context.WithCancel(buildTestContext(t))
+	defer cancel()
+
+	cmd := &Command{
+		Name:       "app",
+		ErrWriter:  chanWriter(errs),
+		ConfigFile: &ConfigFile{Path: path, WatchInterval: 5 * time.Millisecond},
+		Flags: []Flag{
+			&IntFlag{Name: "port", Validator: func(v int64) error {
+				if v <= 0 {
+					return errors.New("port must be positive")
+				}
+				return nil
+			}},
+			&StringFlag{Name: "host", Value: "localhost"},
+			&StringSliceFlag{Name: "tags"},
+			&StringFlag{Name: "name"},
+		},
+		Commands: []*Command{
+			{
+				Name: "serve",
+				Action: func(ctx context.Context, cmd *Command) error {
+					return cmd.WatchConfig(ctx, func(changed []string) {
+						values <- []any{cmd.Int("port"), cmd.String("host"), cmd.StringSlice("tags"), cmd.String("name"), cmd.ValueSource("host")}
+						changes <- changed
+					})
+				},
+			},
+		},
+	}
+
+	require.NoError(t, cmd.Run(ctx, []string{"app", "--name", "cli", "serve"}))
+
+	receive := func() ([]string, []any) {
+		select {
+		case changed := <-changes:
+			return changed, <-values
+		case <-time.After(5 * time.Second):
+			t.Fatal("no config change received")
+			return nil, nil
+		}
+	}
+
+	write("port: 81\ntags: [x, y]\nname: config\n", now.Add(time.Second))
+	changed, vals := receive()
+	assert.ElementsMatch(t, []string{"port", "host", "tags"}, changed)
+	assert.Equal(t, []any{int64(81), "localhost", []string{"x", "y"}, "cli", "default"}, vals)
+
+	write("port: -1\n", now.Add(2*time.Second))
+	select {
+	case err := <-errs:
+		assert.Equal(t, `could not reload config: invalid value "-1" for "port" in config file "`+path+`": port must be positive`+"\n", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload error received")
+	}
+	write("port: 82\nhost: b\ntags: [x, y]\n", now.Add(3*time.Second))
+	changed, vals = receive()
+	assert.ElementsMatch(t, []string{"port", "host"}, changed)
+	assert.Equal(t, []any{int64(82), "b", []string{"x", "y"}, "cli", `key "host" in config file "` + path + `"`}, vals)
+
+	// values read with the methods of the command are synchronized with
+	// the reload
+	write("port: 83\nhost: b\ntags: [x, y]\n", now.Add(4*time.Second))
+	assert.Eventually(t, func() bool {
+		return cmd.Int("port") == 83 && cmd.ValueSource("port") == `key "port" in config file "`+path+`"`
+	}, 5*time.Second, time.Millisecond)
+	<-changes
+	<-values
+
+	assert.EqualError(t, (&Command{}).WatchConfig(ctx, func([]string) {}), "no config file to watch")
+}
+
+func TestCommand_WatchConfigConcurrentAccessors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	write := func(content string, mtime time.Time) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	now := time.Now()
+	write("port: 80\n", now)
+	t.Setenv("APP_HOST", "env")
+
+	changes := make(chan []string, 10)
+	ctx, cancel := context.WithCancel(buildTestContext(t))
+	defer cancel()
+
+	cmd := &Command{
+		Name:       "app",
+		ConfigFile: &ConfigFile{Path: path, WatchInterval: time.Millisecond},
+		Flags: []Flag{
+			&IntFlag{Name: "port"},
+			&StringFlag{Name: "host", Sources: EnvVars("APP_HOST")},
+			&StringFlag{Name: "name", DefaultFunc: func() (string, error) { return "computed", nil }},
+		},
+		Action: func(ctx context.Context, cmd *Command) error {
+			return cmd.WatchConfig(ctx, func(changed []string) { changes <- changed })
+		},
+	}
+	require.NoError(t, cmd.Run(ctx, []string{"app"}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 1000; j++ {
+			assert.NoError(t, cmd.Set("name", "computed"))
+		}
+	}()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				_ = cmd.IsSet("name")
+			}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				_ = cmd.Int("port")
+				assert.Equal(t, "env", cmd.String("host"))
+				assert.Equal(t, "computed", cmd.String("name"))
+				assert.True(t, cmd.IsSet("host"))
+				_ = cmd.IsSet("port")
+				_ = cmd.Value("port")
+				_ = cmd.ValueSource("port")
+			}
+		}()
+	}
+
+	write("port: 81\n", now.Add(time.Second))
+	wg.Wait()
+
+	select {
+	case changed := <-changes:
+		assert.Equal(t, []string{"port"}, changed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no config change received")
+	}
+	assert.Equal(t, int64(81), cmd.Int("port"))
+}
+
+// chanWriter sends everything written to it to the channel
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
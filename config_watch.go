@@ -0,0 +1,270 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultConfigWatchInterval is how often config files are checked for
+// changes if the ConfigFile has no WatchInterval
+const defaultConfigWatchInterval = time.Second
+
+// configReloadFlag is an interface for flags whose value can be set again
+// from a config file after the flags were parsed
+type configReloadFlag interface {
+	parseConfigValue(vals []string) (any, error)
+	setConfigValue(v any)
+}
+
+// reloadableMapSource is implemented by map sources which can read their
+// values again, like cliremote.RemoteSource
+type reloadableMapSource interface {
+	Load(ctx context.Context) error
+}
+
+// WatchConfig watches the config files of the root command, see
+// Command.ConfigFile, until the context is done. When a config file changes
+// the flags of the command and the persistent flags of its parents which
+// are not set on the command line or from another source are set to their
+// new values, or to their default values if removed from the config files,
+// and fn is called with the names of the flags whose value changed. Map
+// sources which can be read again, like cliremote.RemoteSource, are read
+// again too and the flags read from them set to their new values. The new
+// values are validated first, if any is invalid no flag is changed and the
+// error is written to ErrWriter. fn is called from another goroutine. The
+// flags are changed under a lock also taken when reading them with the
+// methods of Command, like Value or String, so these can be called from any
+// goroutine. Destination pointers of the flags are written without it and
+// must only be read in fn or synchronized with it.
+func (cmd *Command) WatchConfig(ctx context.Context, fn func(changed []string)) error {
+	root := cmd.Root()
+	cf := root.ConfigFile
+	if cf == nil {
+		return errors.New("no config file to watch")
+	}
+
+	interval := cf.WatchInterval
+	if interval <= 0 {
+		interval = defaultConfigWatchInterval
+	}
+
+	if root.valuesMu == nil {
+		root.valuesMu = &sync.RWMutex{}
+	}
+
+	state := cmd.configFilesState(cf)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if s := cmd.configFilesState(cf); s != state {
+				state = s
+				tracef("config files changed (cmd=%[1]q)", cmd.Name)
+				changed, err := cmd.reloadConfig(ctx)
+				if err != nil {
+					_, _ = fmt.Fprintf(root.ErrWriter, "could not reload config: %v\n", err)
+					continue
+				}
+				if len(changed) > 0 {
+					fn(changed)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// configFilesState returns the size and modification time of the config
+// files of the command, to tell when one changed
+func (cmd *Command) configFilesState(cf *ConfigFile) string {
+	paths, _ := cmd.configPaths(cf)
+
+	var sb strings.Builder
+	for _, path := range paths {
+		if fi, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&sb, "%s:%d:%d\n", path, fi.Size(), fi.ModTime().UnixNano())
+		} else {
+			fmt.Fprintf(&sb, "%s:-\n", path)
+		}
+	}
+	return sb.String()
+}
+
+// reloadConfig reads the config files and reloadable map sources again and
+// sets the flags read from them or not set at all to their new values,
+// returning the names of the flags whose value changed
+func (cmd *Command) reloadConfig(ctx context.Context) ([]string, error) {
+	root := cmd.Root()
+
+	reloaded := map[MapSource]bool{}
+	for _, pCmd := range cmd.Lineage() {
+		for _, fl := range pCmd.Flags {
+			for _, ms := range flagMapSources(fl) {
+				if rs, ok := ms.(reloadableMapSource); ok && !reloaded[ms] {
+					if err := rs.Load(ctx); err != nil {
+						return nil, err
+					}
+					reloaded[ms] = true
+				}
+			}
+		}
+	}
+
+	root.valuesMu.Lock()
+	defer root.valuesMu.Unlock()
+
+	root.configLayers, root.configKey = nil, ""
+
+	layers, err := cmd.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	layers, prefix, err := cmd.profileConfigLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]configUpdate{}
+	var changed []string
+
+	for _, pCmd := range cmd.Lineage() {
+		keys := pCmd.configKeys()
+		scopes := scopeConfigLayers(layers, keys)
+		keys = append(prefix[:len(prefix):len(prefix)], keys...)
+
+		for _, fl := range pCmd.Flags {
+			if pCmd != cmd {
+				if lf, ok := fl.(LocalFlag); !ok || lf.IsLocal() {
+					continue
+				}
+			}
+
+			names := fl.Names()
+			rf, ok := fl.(configReloadFlag)
+			if !ok || len(names) == 0 || cmd.flagSet.Lookup(names[0]) == nil {
+				continue
+			}
+			if _, ok := updates[names[0]]; ok {
+				continue
+			}
+			src, _ := cmd.flagSource(names[0])
+			if src == SourceCommandLine {
+				continue
+			}
+			u, ok, err := reloadedSourceValue(ctx, fl, rf, reloaded)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				updates[names[0]] = u
+				if !reflect.DeepEqual(u.value, cmd.value(names[0])) {
+					changed = append(changed, names[0])
+				}
+				continue
+			}
+			if src != SourceDefault && src != SourceConfigFile {
+				continue
+			}
+
+			key, path, vals, err := findConfigValue(keys, names, scopes)
+			if err != nil {
+				return nil, err
+			}
+			value, err := rf.parseConfigValue(vals)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for %q in config file %q: %w", strings.Join(vals, ","), key, path, err)
+			}
+			if value == nil {
+				continue
+			}
+
+			source := ""
+			if path != "" {
+				source = configSourceDesc(key, path)
+			}
+			updates[names[0]] = configUpdate{fl: rf, value: value, source: source}
+			if !reflect.DeepEqual(value, cmd.value(names[0])) {
+				changed = append(changed, names[0])
+			}
+		}
+	}
+
+	if cmd.configSources == nil {
+		cmd.configSources = map[string]string{}
+	}
+	for name, u := range updates {
+		u.fl.setConfigValue(u.value)
+		if !u.fromMapSource {
+			cmd.configSources[name] = u.source
+		}
+	}
+
+	tracef("reloaded config, changed flags %[1]q (cmd=%[2]q)", changed, cmd.Name)
+	return changed, nil
+}
+
+// configUpdate is the new value of a flag found by reloadConfig
+type configUpdate struct {
+	fl            configReloadFlag
+	value         any
+	source        string
+	fromMapSource bool
+}
+
+// sourceChainFlag is an interface for flags whose Sources can be looked up
+// again when their map sources are reloaded
+type sourceChainFlag interface {
+	sourceChain() *ValueSourceChain
+}
+
+// flagMapSources returns the map sources among the sources of the flag
+func flagMapSources(fl Flag) []MapSource {
+	sf, ok := fl.(sourceChainFlag)
+	if !ok {
+		return nil
+	}
+	var sources []MapSource
+	for _, src := range sf.sourceChain().Chain {
+		if mvs, ok := src.(*mapValueSource); ok {
+			sources = append(sources, mvs.ms)
+		}
+	}
+	return sources
+}
+
+// reloadedSourceValue looks up the value of a flag in its sources again if
+// one of them is a map source which has been read again
+func reloadedSourceValue(ctx context.Context, fl Flag, rf configReloadFlag, reloaded map[MapSource]bool) (configUpdate, bool, error) {
+	sf, ok := fl.(sourceChainFlag)
+	if !ok || !slices.ContainsFunc(flagMapSources(fl), func(ms MapSource) bool { return reloaded[ms] }) {
+		return configUpdate{}, false, nil
+	}
+
+	val, src, found, err := sf.sourceChain().lookupWithSourceErr(ctx)
+	if err != nil || !found {
+		return configUpdate{}, false, err
+	}
+	value, err := rf.parseConfigValue([]string{val})
+	if err != nil {
+		return configUpdate{}, false, fmt.Errorf("invalid value %q from %s: %w", val, src, err)
+	}
+	if value == nil {
+		return configUpdate{}, false, nil
+	}
+	return configUpdate{fl: rf, value: value, fromMapSource: true}, true, nil
+}
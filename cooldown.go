@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ensureForceFlag adds a --force flag to bypass the Cooldown unless the
+// command already defines a flag of that name, which then bypasses it
+func (cmd *Command) ensureForceFlag() {
+	if cmd.Cooldown <= 0 || cmd.forceFlag != nil {
+		return
+	}
+
+	for _, fl := range cmd.Flags {
+		if slices.Contains(fl.Names(), forceFlagName) {
+			tracef("not adding force flag as it is already defined (cmd=%[1]q)", cmd.Name)
+			return
+		}
+	}
+
+	tracef("appending force flag (cmd=%[1]q)", cmd.Name)
+	cmd.forceFlag = &BoolFlag{
+		Name:  forceFlagName,
+		Usage: "run even if the command ran recently",
+		Local: true,
+	}
+	cmd.appendFlag(cmd.forceFlag)
+}
+
+// cooldownPath returns the path of the file the time of the last successful
+// run of the command is stored in
+func (cmd *Command) cooldownPath() (string, error) {
+	dir, err := cmd.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	name := strings.Join(strings.Fields(cmd.FullName()), "-")
+	return filepath.Join(dir, "cooldown", name), nil
+}
+
+// inCooldown returns true if the command has a Cooldown and ran successfully
+// less than the Cooldown ago, unless --force is set
+func (cmd *Command) inCooldown() bool {
+	if cmd.Cooldown <= 0 || cmd.Bool(forceFlagName) {
+		return false
+	}
+
+	path, err := cmd.cooldownPath()
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	last, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+	if err != nil {
+		return false
+	}
+
+	if since := cmd.now().Sub(last); since >= 0 && since < cmd.Cooldown {
+		tracef("skipping action as it ran %[1]v ago, cooldown is %[2]v (cmd=%[3]q)", since, cmd.Cooldown, cmd.Name)
+		return true
+	}
+	return false
+}
+
+// markCooldown stores the time of a successful run of a command with a
+// Cooldown. Errors are ignored, the command then runs again the next time.
+func (cmd *Command) markCooldown() {
+	if cmd.Cooldown <= 0 {
+		return
+	}
+
+	path, err := cmd.cooldownPath()
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		tracef("could not store cooldown: %[1]v (cmd=%[2]q)", err, cmd.Name)
+		return
+	}
+	if err := os.WriteFile(path, []byte(cmd.now().UTC().Format(time.RFC3339Nano)+"\n"), 0o600); err != nil {
+		tracef("could not store cooldown: %[1]v (cmd=%[2]q)", err, cmd.Name)
+	}
+}
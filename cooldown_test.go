@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Cooldown(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("APP_CACHE_DIR", cacheDir)
+
+	runs := 0
+	var fail bool
+	newCmd := func() *Command {
+		return &Command{
+			Name:           "app",
+			ExitErrHandler: func(context.Context, *Command, error) {},
+			Commands: []*Command{
+				{
+					Name:     "check-updates",
+					Cooldown: time.Hour,
+					Action: func(context.Context, *Command) error {
+						runs++
+						if fail {
+							return Exit("offline", 1)
+						}
+						return nil
+					},
+				},
+			},
+		}
+	}
+	run := func(args ...string) error {
+		return newCmd().Run(buildTestContext(t), append([]string{"app", "check-updates"}, args...))
+	}
+
+	// failed runs do not start the cooldown
+	fail = true
+	assert.Error(t, run())
+	assert.NoFileExists(t, filepath.Join(cacheDir, "cooldown", "app-check-updates"))
+
+	fail = false
+	require.NoError(t, run())
+	require.NoError(t, run())
+	assert.Equal(t, 2, runs)
+	assert.FileExists(t, filepath.Join(cacheDir, "cooldown", "app-check-updates"))
+
+	require.NoError(t, run("--force"))
+	assert.Equal(t, 3, runs)
+
+	// expired
+	past := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339Nano)
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "cooldown", "app-check-updates"), []byte(past), 0o600))
+	require.NoError(t, run())
+	assert.Equal(t, 4, runs)
+}
@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrCredentialNotFound is returned by a CredentialStore for a key without a
+// secret
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// ErrNoKeychain is returned by the Credentials of a command without a
+// CredentialStore
+var ErrNoKeychain = errors.New("no keychain to store credentials in")
+
+// CredentialStore stores secrets like tokens by key, see Command.Credentials
+type CredentialStore interface {
+	// Get returns the secret of the key or ErrCredentialNotFound
+	Get(key string) (string, error)
+	// Set stores the secret of the key, replacing an existing one
+	Set(key, secret string) error
+	// Delete removes the secret of the key or returns ErrCredentialNotFound
+	Delete(key string) error
+}
+
+// FileCredentialStore returns a CredentialStore keeping secrets as JSON in
+// the file, which is only readable by the user. The secrets are not
+// encrypted, so it should only be used if there is no keychain, e.g. as the
+// CredentialStore of a command if clikeychain.Store returns nil.
+func FileCredentialStore(path string) CredentialStore {
+	return &fileCredentialStore{path: func() (string, error) { return path, nil }}
+}
+
+// Credentials returns the CredentialStore of the root command, e.g. the
+// keychain of the OS returned by clikeychain.Store. Without one, secrets
+// are not written to a file unless a FileCredentialStore is set as the
+// CredentialStore; the returned store fails with ErrNoKeychain instead.
+func (cmd *Command) Credentials() CredentialStore {
+	root := cmd.Root()
+	if root.CredentialStore != nil {
+		return root.CredentialStore
+	}
+	tracef("no keychain to store credentials in (cmd=%[1]q)", cmd.Name)
+	return noKeychainStore{}
+}
+
+// LoginFunc returns the secret to store on login, e.g. a token of an OAuth
+// flow, see LoginCommands
+type LoginFunc func(ctx context.Context, cmd *Command) (string, error)
+
+// LoginCommands returns login and logout commands which store the secret
+// returned by login in the Credentials under the key and delete it again. If
+// login is nil the secret is read with a hidden prompt or from the --token
+// flag of the login command.
+func LoginCommands(key string, login LoginFunc) []*Command {
+	var flags []Flag
+	if login == nil {
+		flags = []Flag{newTokenFlag()}
+		login = promptToken
+	}
+
+	return loginCommands(flags, func(*Command) (string, string, error) {
+		return key, "", nil
+	}, login, nil)
+}
+
+// newTokenFlag returns the --token flag of a login command prompting for
+// the token if it is not set
+func newTokenFlag() Flag {
+	return &StringFlag{Name: "token", Usage: "`TOKEN` to log in with instead of prompting for it", Sensitive: true}
+}
+
+// promptToken is the LoginFunc reading the secret with a hidden prompt or
+// from the --token flag
+func promptToken(_ context.Context, cmd *Command) (string, error) {
+	return cmd.PromptSecret("token")
+}
+
+// loginCommands returns login and logout commands storing the secret
+// returned by login under the key returned by key, which also returns what
+// is logged in to for the messages of the commands, e.g. context prod. after
+// is called once the secret is stored or deleted.
+func loginCommands(
+	flags []Flag,
+	key func(cmd *Command) (key, subject string, err error),
+	login LoginFunc,
+	after func(cmd *Command, loggedIn bool) error,
+) []*Command {
+	message := func(cmd *Command, msg, prep, subject string) {
+		if subject != "" {
+			msg += " " + prep + " " + subject
+		}
+		_, _ = fmt.Fprintln(cmd.Root().Writer, msg)
+	}
+
+	return []*Command{
+		{
+			Name:  "login",
+			Usage: "Log in and store the credentials",
+			Flags: flags,
+			Action: func(ctx context.Context, cmd *Command) error {
+				key, subject, err := key(cmd)
+				if err != nil {
+					return err
+				}
+				secret, err := login(ctx, cmd)
+				if err != nil {
+					return err
+				}
+				if secret == "" {
+					return errors.New("no credentials to store")
+				}
+				if err := cmd.Credentials().Set(key, secret); err != nil {
+					return fmt.Errorf("cannot store credentials: %w", err)
+				}
+				if after != nil {
+					if err := after(cmd, true); err != nil {
+						return err
+					}
+				}
+				message(cmd, "logged in", "to", subject)
+				return nil
+			},
+		},
+		{
+			Name:  "logout",
+			Usage: "Delete the stored credentials",
+			Action: func(_ context.Context, cmd *Command) error {
+				key, subject, err := key(cmd)
+				if err != nil {
+					return err
+				}
+				err = cmd.Credentials().Delete(key)
+				if errors.Is(err, ErrCredentialNotFound) {
+					message(cmd, "not logged in", "to", subject)
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("cannot delete credentials: %w", err)
+				}
+				if after != nil {
+					if err := after(cmd, false); err != nil {
+						return err
+					}
+				}
+				message(cmd, "logged out", "of", subject)
+				return nil
+			},
+		},
+	}
+}
+
+// noKeychainStore is the CredentialStore of a command without one
+type noKeychainStore struct{}
+
+func (noKeychainStore) Get(string) (string, error) { return "", ErrNoKeychain }
+
+func (noKeychainStore) Set(string, string) error { return ErrNoKeychain }
+
+func (noKeychainStore) Delete(string) error { return ErrNoKeychain }
+
+// fileCredentialStore is a CredentialStore writing all secrets to a JSON file
+type fileCredentialStore struct {
+	path func() (string, error)
+	mu   sync.Mutex
+}
+
+func (s *fileCredentialStore) Get(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, secrets, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[key]
+	if !ok {
+		return "", ErrCredentialNotFound
+	}
+	return secret, nil
+}
+
+func (s *fileCredentialStore) Set(key, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, secrets, err := s.read()
+	if err != nil {
+		return err
+	}
+	secrets[key] = secret
+	return s.write(path, secrets)
+}
+
+func (s *fileCredentialStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, secrets, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[key]; !ok {
+		return ErrCredentialNotFound
+	}
+	delete(secrets, key)
+	return s.write(path, secrets)
+}
+
+func (s *fileCredentialStore) read() (string, map[string]string, error) {
+	path, err := s.path()
+	if err != nil {
+		return "", nil, err
+	}
+
+	secrets := map[string]string{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return path, secrets, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return "", nil, err
+	}
+	return path, secrets, nil
+}
+
+func (s *fileCredentialStore) write(path string, secrets map[string]string) error {
+	data, err := json.MarshalIndent(secrets, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0o600)
+}
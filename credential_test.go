@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCredentialStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app", "credentials.json")
+	store := FileCredentialStore(path)
+
+	_, err := store.Get("token")
+	assert.ErrorIs(t, err, ErrCredentialNotFound)
+	assert.ErrorIs(t, store.Delete("token"), ErrCredentialNotFound)
+
+	require.NoError(t, store.Set("token", "secret"))
+	require.NoError(t, store.Set("other", "value"))
+	secret, err := store.Get("token")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", secret)
+
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+	}
+
+	require.NoError(t, store.Delete("token"))
+	_, err = store.Get("token")
+	assert.ErrorIs(t, err, ErrCredentialNotFound)
+	secret, err = store.Get("other")
+	require.NoError(t, err)
+	assert.Equal(t, "value", secret)
+}
+
+func TestLoginCommands(t *testing.T) {
+	store := FileCredentialStore(filepath.Join(t.TempDir(), "credentials.json"))
+
+	tests := []struct {
+		name    string
+		login   LoginFunc
+		args    []string
+		wantOut string
+		wantErr string
+		want    string
+	}{
+		{
+			name:    "token flag",
+			args:    []string{"app", "login", "--token", "abc"},
+			wantOut: "logged in\n",
+			want:    "abc",
+		},
+		{
+			name: "login func",
+			login: func(context.Context, *Command) (string, error) {
+				return "xyz", nil
+			},
+			args:    []string{"app", "login"},
+			wantOut: "logged in\n",
+			want:    "xyz",
+		},
+		{
+			name: "empty secret",
+			login: func(context.Context, *Command) (string, error) {
+				return "", nil
+			},
+			args:    []string{"app", "login"},
+			wantErr: "no credentials to store",
+			want:    "xyz",
+		},
+		{
+			name:    "logout",
+			args:    []string{"app", "logout"},
+			wantOut: "logged out\n",
+		},
+		{
+			name:    "logout again",
+			args:    []string{"app", "logout"},
+			wantOut: "not logged in\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := &Command{
+				Name:            "app",
+				Writer:          &out,
+				CredentialStore: store,
+				Commands:        LoginCommands("token", tt.login),
+			}
+
+			err := cmd.Run(buildTestContext(t), tt.args)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantOut, out.String())
+
+			secret, err := cmd.Credentials().Get("token")
+			if tt.want == "" {
+				assert.ErrorIs(t, err, ErrCredentialNotFound)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, secret)
+			}
+		})
+	}
+}
+
+func TestLoginCommands_noKeychain(t *testing.T) {
+	cmd := &Command{
+		Name:            "app",
+		CredentialStore: noKeychainStore{},
+		Commands:        LoginCommands("token", nil),
+	}
+
+	err := cmd.Run(buildTestContext(t), []string{"app", "login", "--token", "abc"})
+	require.ErrorIs(t, err, ErrNoKeychain)
+	assert.EqualError(t, err, "cannot store credentials: no keychain to store credentials in")
+}
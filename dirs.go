@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// appDir describes a per-app directory returned by Command.CacheDir,
+// Command.ConfigDir and Command.DataDir
+type appDir struct {
+	// name of the dir, the flag and env var overriding it are named after it,
+	// e.g. cache-dir and APP_CACHE_DIR
+	name string
+	// env var of the base dir and its default relative to the home dir
+	xdgEnv  string
+	xdgHome string
+	// env var of the base dir on Windows and the subdir of the app dir
+	windowsEnv    string
+	windowsSubdir string
+}
+
+var (
+	cacheDir  = appDir{name: "cache", xdgEnv: "XDG_CACHE_HOME", xdgHome: ".cache", windowsEnv: "LocalAppData", windowsSubdir: "cache"}
+	configDir = appDir{name: "config", xdgEnv: "XDG_CONFIG_HOME", xdgHome: ".config", windowsEnv: "AppData"}
+	dataDir   = appDir{name: "data", xdgEnv: "XDG_DATA_HOME", xdgHome: filepath.Join(".local", "share"), windowsEnv: "LocalAppData", windowsSubdir: "data"}
+)
+
+// CacheDir returns the directory of the root command for cached data which
+// can be recreated, $XDG_CACHE_HOME/<app> or ~/.cache/<app> and
+// %LocalAppData%\<app>\cache on Windows. It is overridden by a --cache-dir
+// flag of the command or its parents if it is set, or else by the
+// <APP>_CACHE_DIR env var. The directory is created if it does not exist.
+func (cmd *Command) CacheDir() (string, error) {
+	return cmd.appDir(cacheDir)
+}
+
+// ConfigDir returns the directory of the root command for config files,
+// $XDG_CONFIG_HOME/<app> or ~/.config/<app> and %AppData%\<app> on Windows,
+// like DiscoverConfigFiles. It is overridden by --config-dir or
+// <APP>_CONFIG_DIR like CacheDir.
+func (cmd *Command) ConfigDir() (string, error) {
+	return cmd.appDir(configDir)
+}
+
+// DataDir returns the directory of the root command for persistent data,
+// $XDG_DATA_HOME/<app> or ~/.local/share/<app> and %LocalAppData%\<app>\data
+// on Windows. It is overridden by --data-dir or <APP>_DATA_DIR like
+// CacheDir.
+func (cmd *Command) DataDir() (string, error) {
+	return cmd.appDir(dataDir)
+}
+
+// appDir returns the directory, which is created with permissions 0700 if it
+// does not exist. It is overridden by the flag named after it if the command
+// or its parents have it and it is set, e.g. --cache-dir, or else by the env
+// var named after it, e.g. APP_CACHE_DIR.
+func (cmd *Command) appDir(dir appDir) (string, error) {
+	path, err := cmd.appDirPath(dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(path, 0o700); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (cmd *Command) appDirPath(dir appDir) (string, error) {
+	flagName := dir.name + "-dir"
+	if cmd.lookupFlag(flagName) != nil && cmd.IsSet(flagName) {
+		if path := cmd.String(flagName); path != "" {
+			tracef("using %[1]s dir %[2]q from flag (cmd=%[3]q)", dir.name, path, cmd.Name)
+			return path, nil
+		}
+	}
+	if path := os.Getenv(cmd.appEnvVar(flagName)); path != "" {
+		tracef("using %[1]s dir %[2]q from env (cmd=%[3]q)", dir.name, path, cmd.Name)
+		return path, nil
+	}
+
+	app := cmd.Root().Name
+	if runtime.GOOS == "windows" {
+		base := os.Getenv(dir.windowsEnv)
+		if base == "" {
+			return "", errors.New("%" + dir.windowsEnv + "% is not set")
+		}
+		return filepath.Join(base, app, dir.windowsSubdir), nil
+	}
+
+	if base := os.Getenv(dir.xdgEnv); filepath.IsAbs(base) {
+		return filepath.Join(base, app), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, dir.xdgHome, app), nil
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_AppDirs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses XDG dirs")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, "xdg-cache"))
+	t.Setenv("XDG_CONFIG_HOME", "relative")
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("MY_APP_DATA_DIR", filepath.Join(home, "env-data"))
+
+	var dirs []string
+	cmd := &Command{
+		Name:  "my-app",
+		Flags: []Flag{&StringFlag{Name: "config-dir"}, &StringFlag{Name: "data-dir"}},
+		Commands: []*Command{
+			{
+				Name: "sub",
+				Action: func(_ context.Context, cmd *Command) error {
+					for _, dir := range []func() (string, error){cmd.CacheDir, cmd.ConfigDir, cmd.DataDir} {
+						path, err := dir()
+						if err != nil {
+							return err
+						}
+						dirs = append(dirs, path)
+					}
+					return nil
+				},
+			},
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"my-app", "sub"}))
+	assert.Equal(t, []string{
+		filepath.Join(home, "xdg-cache", "my-app"),
+		filepath.Join(home, ".config", "my-app"),
+		filepath.Join(home, "env-data"),
+	}, dirs)
+	for _, dir := range dirs {
+		fi, err := os.Stat(dir)
+		require.NoError(t, err)
+		assert.True(t, fi.IsDir())
+		assert.Equal(t, os.FileMode(0o700), fi.Mode().Perm())
+	}
+
+	dirs = nil
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"my-app", "--config-dir", filepath.Join(home, "flag-config"), "sub"}))
+	assert.Equal(t, filepath.Join(home, "flag-config"), dirs[1])
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// loadDotEnv sets env vars from the dotenv files of the command, see
+// Command.DotEnv. Values of later files take precedence over earlier ones,
+// env vars which are already set are kept.
+func (cmd *Command) loadDotEnv() error {
+	values := map[string]string{}
+	for _, path := range cmd.DotEnv {
+		fileValues, err := readDotEnv(path, values)
+		if errors.Is(err, os.ErrNotExist) && !cmd.DotEnvRequired {
+			tracef("skipping missing dotenv file %[1]q (cmd=%[2]q)", path, cmd.Name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		tracef("loaded %[1]d values from dotenv file %[2]q (cmd=%[3]q)", len(fileValues), path, cmd.Name)
+		for key, value := range fileValues {
+			values[key] = value
+		}
+	}
+
+	for key, value := range values {
+		if _, ok := os.LookupEnv(key); ok {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readDotEnv reads a dotenv file with one KEY=value per line. Lines may
+// start with "export", empty lines and lines starting with # are ignored.
+// Values may be quoted with ' to be taken literally or with " to expand
+// escape sequences like \n, $VAR and ${VAR} are expanded in values not
+// quoted with ', looking up values read before and then the environment.
+func readDotEnv(path string, loaded map[string]string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read dotenv file: %w", err)
+	}
+	defer f.Close()
+
+	values := map[string]string{}
+	lookup := func(key string) string {
+		if v, ok := values[key]; ok {
+			return v
+		}
+		if v, ok := loaded[key]; ok {
+			return v
+		}
+		return os.Getenv(key)
+	}
+
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%s:%d: invalid line %q", path, lineNum, line)
+		}
+
+		value, err := parseDotEnvValue(strings.TrimSpace(value), lookup)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read dotenv file: %w", err)
+	}
+
+	return values, nil
+}
+
+func parseDotEnvValue(value string, lookup func(string) string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	switch quote := value[0]; quote {
+	case '\'', '"':
+		end := strings.LastIndexByte(value, quote)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated quoted value %s", value)
+		}
+		if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected %q after quoted value", rest)
+		}
+		value = value[1:end]
+		if quote == '\'' {
+			return value, nil
+		}
+		value = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\r`, "\r", `\"`, `"`, `\\`, `\`).Replace(value)
+	default:
+		// a # preceded by whitespace starts a comment
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+	}
+
+	return os.Expand(value, lookup), nil
+}
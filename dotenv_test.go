@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_DotEnv(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte(`
+# defaults
+export DOTENV_TEST_HOST=localhost
+DOTENV_TEST_PORT=8080 # inline comment
+DOTENV_TEST_URL="http://${DOTENV_TEST_HOST}:$DOTENV_TEST_PORT"
+DOTENV_TEST_RAW='$DOTENV_TEST_HOST\n'
+DOTENV_TEST_LINES="a\nb"
+DOTENV_TEST_USER=from-file
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env.local"), []byte("DOTENV_TEST_PORT=9090\n"), 0o644))
+
+	for _, key := range []string{"DOTENV_TEST_HOST", "DOTENV_TEST_PORT", "DOTENV_TEST_URL", "DOTENV_TEST_RAW", "DOTENV_TEST_LINES"} {
+		key := key
+		t.Cleanup(func() { _ = os.Unsetenv(key) })
+	}
+	t.Setenv("DOTENV_TEST_USER", "from-env")
+
+	var (
+		port int64
+		user string
+	)
+	cmd := &Command{
+		Name:   "app",
+		DotEnv: []string{filepath.Join(dir, ".env"), filepath.Join(dir, ".env.local"), filepath.Join(dir, ".env.missing")},
+		Flags: []Flag{
+			&IntFlag{Name: "port", Sources: EnvVars("DOTENV_TEST_PORT")},
+			&StringFlag{Name: "user", Sources: EnvVars("DOTENV_TEST_USER")},
+		},
+		Action: func(_ context.Context, cmd *Command) error {
+			port, user = cmd.Int("port"), cmd.String("user")
+			return nil
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app"}))
+	assert.Equal(t, int64(9090), port)
+	assert.Equal(t, "from-env", user)
+	assert.Equal(t, "http://localhost:8080", os.Getenv("DOTENV_TEST_URL"))
+	assert.Equal(t, `$DOTENV_TEST_HOST\n`, os.Getenv("DOTENV_TEST_RAW"))
+	assert.Equal(t, "a\nb", os.Getenv("DOTENV_TEST_LINES"))
+
+	cmd = &Command{
+		Name:           "app",
+		DotEnv:         []string{filepath.Join(dir, ".env.missing")},
+		DotEnvRequired: true,
+	}
+	assert.ErrorContains(t, cmd.Run(buildTestContext(t), []string{"app"}), "could not read dotenv file")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env.bad"), []byte("VALID=1\nnot valid\n"), 0o644))
+	cmd = &Command{Name: "app", DotEnv: []string{filepath.Join(dir, ".env.bad")}}
+	assert.ErrorContains(t, cmd.Run(buildTestContext(t), []string{"app"}), `.env.bad:2: invalid line "not valid"`)
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -8,10 +9,16 @@ import (
 )
 
 // OsExiter is the function used when the app exits. If not set defaults to os.Exit.
+//
+// Deprecated: set Command.OsExiter instead, which is safe to use when
+// commands run concurrently. OsExiter is used if it is not set.
 var OsExiter = os.Exit
 
 // ErrWriter is used to write errors to the user. This can be anything
 // implementing the io.Writer interface and defaults to os.Stderr.
+//
+// Deprecated: set Command.ErrWriter instead, which is safe to use when
+// commands run concurrently. ErrWriter is used if it is not set.
 var ErrWriter io.Writer = os.Stderr
 
 // MultiError is an error that wraps multiple errors.
@@ -87,6 +94,39 @@ func (e *mutuallyExclusiveGroupRequiredFlag) Error() string {
 	return fmt.Sprintf("one of these flags needs to be provided: %s", strings.Join(missingFlags, ", "))
 }
 
+type errArgsCount struct {
+	command   string
+	argsUsage string
+	min       int
+	max       int
+	got       int
+}
+
+func (e *errArgsCount) Error() string {
+	var expected string
+	switch {
+	case e.min == e.max:
+		expected = fmt.Sprintf("exactly %s", pluralArgs(e.min))
+	case e.got < e.min:
+		expected = fmt.Sprintf("at least %s", pluralArgs(e.min))
+	default:
+		expected = fmt.Sprintf("at most %s", pluralArgs(e.max))
+	}
+
+	msg := fmt.Sprintf("%s expects %s but got %d", e.command, expected, e.got)
+	if e.argsUsage != "" {
+		msg += fmt.Sprintf(", usage: %s %s", e.command, e.argsUsage)
+	}
+	return msg
+}
+
+func pluralArgs(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
 // ErrorFormatter is the interface that will suitably format the error output
 type ErrorFormatter interface {
 	Format(s fmt.State, verb rune)
@@ -99,9 +139,27 @@ type ExitCoder interface {
 	ExitCode() int
 }
 
+// ExitCategorizer is implemented by errors which carry a machine readable
+// category in addition to their exit code, see [ExitWithCategory]
+type ExitCategorizer interface {
+	ExitCoder
+	Category() string
+}
+
+const (
+	// exit code and category of usage errors in JSON error output
+	usageErrorExitCode = 64
+	usageErrorCategory = "usage"
+
+	// exit code and category of errors caused by Command.Timeout
+	timeoutExitCode      = 124
+	timeoutErrorCategory = "timeout"
+)
+
 type exitError struct {
 	exitCode int
 	err      error
+	category string
 }
 
 // Exit wraps a message and exit code into an error, which by default is
@@ -129,6 +187,23 @@ func Exit(message interface{}, exitCode int) ExitCoder {
 	}
 }
 
+// ExitWithCategory is like [Exit] but additionally attaches a machine readable
+// category to the error, which is included in JSON error output, see
+// [Command.JSONErrors].
+func ExitWithCategory(message interface{}, exitCode int, category string) ExitCoder {
+	ee := Exit(message, exitCode).(*exitError)
+	ee.category = category
+	return ee
+}
+
+func newUsageError(err error) ExitCoder {
+	return &exitError{
+		err:      err,
+		exitCode: usageErrorExitCode,
+		category: usageErrorCategory,
+	}
+}
+
 func (ee *exitError) Error() string {
 	return ee.err.Error()
 }
@@ -137,6 +212,10 @@ func (ee *exitError) ExitCode() int {
 	return ee.exitCode
 }
 
+func (ee *exitError) Category() string {
+	return ee.category
+}
+
 // HandleExitCoder handles errors implementing ExitCoder by printing their
 // message and calling OsExiter with the given exit code.
 //
@@ -146,6 +225,11 @@ func (ee *exitError) ExitCode() int {
 //
 // This function is the default error-handling behavior for an App.
 func HandleExitCoder(err error) {
+	exitWithCode(err, ErrWriter, OsExiter)
+}
+
+// exitWithCode is HandleExitCoder writing to w and exiting with exit
+func exitWithCode(err error, w io.Writer, exit func(int)) {
 	if err == nil {
 		return
 	}
@@ -153,29 +237,29 @@ func HandleExitCoder(err error) {
 	if exitErr, ok := err.(ExitCoder); ok {
 		if err.Error() != "" {
 			if _, ok := exitErr.(ErrorFormatter); ok {
-				_, _ = fmt.Fprintf(ErrWriter, "%+v\n", err)
+				_, _ = fmt.Fprintf(w, "%+v\n", err)
 			} else {
-				_, _ = fmt.Fprintln(ErrWriter, err)
+				_, _ = fmt.Fprintln(w, err)
 			}
 		}
-		OsExiter(exitErr.ExitCode())
+		exit(exitErr.ExitCode())
 		return
 	}
 
 	if multiErr, ok := err.(MultiError); ok {
-		code := handleMultiError(multiErr)
-		OsExiter(code)
+		code := handleMultiError(multiErr, w)
+		exit(code)
 		return
 	}
 }
 
-func handleMultiError(multiErr MultiError) int {
+func handleMultiError(multiErr MultiError, w io.Writer) int {
 	code := 1
 	for _, merr := range multiErr.Errors() {
 		if multiErr2, ok := merr.(MultiError); ok {
-			code = handleMultiError(multiErr2)
+			code = handleMultiError(multiErr2, w)
 		} else if merr != nil {
-			fmt.Fprintln(ErrWriter, merr)
+			fmt.Fprintln(w, merr)
 			if exitErr, ok := merr.(ExitCoder); ok {
 				code = exitErr.ExitCode()
 			}
@@ -183,3 +267,40 @@ func handleMultiError(multiErr MultiError) int {
 	}
 	return code
 }
+
+type jsonError struct {
+	Error    string `json:"error"`
+	Code     int    `json:"code"`
+	Category string `json:"category,omitempty"`
+}
+
+// writeJSONError writes the error as a JSON object to w and returns its exit
+// code, which is determined like in HandleExitCoder
+func writeJSONError(w io.Writer, err error) int {
+	je := jsonError{Error: err.Error(), Code: exitCodeOf(err)}
+	if ec, ok := err.(ExitCategorizer); ok {
+		je.Category = ec.Category()
+	}
+
+	b, _ := json.Marshal(je)
+	_, _ = fmt.Fprintln(w, string(b))
+	return je.Code
+}
+
+func exitCodeOf(err error) int {
+	if exitErr, ok := err.(ExitCoder); ok {
+		return exitErr.ExitCode()
+	}
+
+	code := 1
+	if multiErr, ok := err.(MultiError); ok {
+		for _, merr := range multiErr.Errors() {
+			if _, ok := merr.(MultiError); ok {
+				code = exitCodeOf(merr)
+			} else if exitErr, ok := merr.(ExitCoder); ok {
+				code = exitErr.ExitCode()
+			}
+		}
+	}
+	return code
+}
@@ -232,3 +232,53 @@ func TestErrRequiredFlags_Error(t *testing.T) {
 	expectedMsg = "Required flag \"flag1\" not set"
 	assert.Equal(t, expectedMsg, err.Error())
 }
+
+func TestExitWithCategory(t *testing.T) {
+	err := ExitWithCategory("no such user", 3, "not_found")
+	assert.Equal(t, "no such user", err.Error())
+	assert.Equal(t, 3, err.ExitCode())
+
+	ec, ok := err.(ExitCategorizer)
+	assert.True(t, ok)
+	assert.Equal(t, "not_found", ec.Category())
+}
+
+func TestWriteJSONError(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		err      error
+		code     int
+		expected string
+	}{
+		{
+			name:     "plain error",
+			err:      errors.New("boom"),
+			code:     1,
+			expected: `{"error":"boom","code":1}`,
+		},
+		{
+			name:     "exit coder",
+			err:      Exit("boom", 9),
+			code:     9,
+			expected: `{"error":"boom","code":9}`,
+		},
+		{
+			name:     "categorized",
+			err:      ExitWithCategory(`"quoted"`, 3, "not_found"),
+			code:     3,
+			expected: `{"error":"\"quoted\"","code":3,"category":"not_found"}`,
+		},
+		{
+			name:     "multi error",
+			err:      newMultiError(errors.New("first"), Exit("second", 7)),
+			code:     7,
+			expected: `{"error":"first\nsecond","code":7}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.Equal(t, test.code, writeJSONError(&buf, test.err))
+			assert.Equal(t, test.expected+"\n", buf.String())
+		})
+	}
+}
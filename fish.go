@@ -11,6 +11,8 @@ import (
 // ToFishCompletion creates a fish completion string for the `*App`
 // The function errors if either parsing or writing of the string fails.
 func (cmd *Command) ToFishCompletion() (string, error) {
+	cmd.LoadCommands()
+
 	var w bytes.Buffer
 	if err := cmd.writeFishCompletionTemplate(&w); err != nil {
 		return "", err
@@ -26,7 +28,9 @@ type fishCommandCompletionTemplate struct {
 
 func (cmd *Command) writeFishCompletionTemplate(w io.Writer) error {
 	const name = "cli"
-	t, err := template.New(name).Parse(FishCompletionTemplate)
+	t, err := cachedTemplate(newTemplateKey("fish", FishCompletionTemplate), func() (*template.Template, error) {
+		return template.New(name).Parse(FishCompletionTemplate)
+	})
 	if err != nil {
 		return err
 	}
@@ -137,6 +141,11 @@ func (cmd *Command) prepareFishFlags(flags []Flag, previousCommands []string) []
 				completion.WriteString(" -r")
 			}
 
+			if vf, ok := f.(ValueCompletionFlag); ok && len(vf.ValueCompletions()) > 0 {
+				completion.WriteString(fmt.Sprintf(" -a '%s'",
+					escapeSingleQuotes(strings.Join(vf.ValueCompletions(), " "))))
+			}
+
 			if flag.GetUsage() != "" {
 				completion.WriteString(fmt.Sprintf(" -d '%s'",
 					escapeSingleQuotes(flag.GetUsage())))
@@ -159,6 +168,8 @@ func fishAddFileFlag(flag Flag, completion *strings.Builder) {
 		if f.TakesFile {
 			return
 		}
+	case *PathFlag:
+		return
 	}
 	completion.WriteString(" -f")
 }
@@ -37,3 +37,14 @@ func TestFishCompletion(t *testing.T) {
 	require.NoError(t, err)
 	expectFileContent(t, "testdata/expected-fish-full.fish", res)
 }
+
+func BenchmarkFishCompletion(b *testing.B) {
+	cmd := buildExtendedTestCommand()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := cmd.ToFishCompletion(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
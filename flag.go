@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"regexp"
 	"runtime"
 	"strings"
 	"time"
@@ -14,17 +13,11 @@ import (
 
 const defaultPlaceholder = "value"
 
-var (
-	defaultSliceFlagSeparator       = ","
-	defaultMapFlagKeyValueSeparator = "="
-	disableSliceFlagSeparator       = false
-)
+const defaultSliceFlagSeparator = ","
 
-var (
-	slPfx = fmt.Sprintf("sl:::%d:::", time.Now().UTC().UnixNano())
+var defaultMapFlagKeyValueSeparator = "="
 
-	commaWhitespace = regexp.MustCompile("[, ]+.*")
-)
+var slPfx = fmt.Sprintf("sl:::%d:::", time.Now().UTC().UnixNano())
 
 // GenerateShellCompletionFlag enables shell completion
 var GenerateShellCompletionFlag Flag = &BoolFlag{
@@ -148,6 +141,21 @@ type DocGenerationFlag interface {
 	TypeName() string
 }
 
+// PlaceholderFlag is an interface for flags which define an explicit
+// placeholder for their value, taking precedence over a back-quoted
+// name in the usage string
+type PlaceholderFlag interface {
+	// GetPlaceholder returns the placeholder, or an empty string
+	GetPlaceholder() string
+}
+
+// ValueCompletionFlag is an interface for flags which accept a fixed
+// set of values that can be offered as shell completions
+type ValueCompletionFlag interface {
+	// ValueCompletions returns the values to complete, or nil
+	ValueCompletions() []string
+}
+
 // DocGenerationMultiValueFlag extends DocGenerationFlag for slice/map based flags.
 type DocGenerationMultiValueFlag interface {
 	DocGenerationFlag
@@ -178,6 +186,69 @@ type CategorizableFlag interface {
 	SetCategory(string)
 }
 
+// SensitiveFlag is an interface to enable detection of flags whose values
+// are secrets and must not be displayed in help, docs or logs
+type SensitiveFlag interface {
+	IsSensitive() bool
+}
+
+// envSourceFlag is an interface for flags which can derive env vars and
+// further sources from the EnvVarPrefix, FilePathEnvVars, ValueDirs and
+// FlagSources settings of a command
+type envSourceFlag interface {
+	addPrefixedEnvVar(prefix string)
+	addFilePathEnvVars()
+	addMapSources(sources []MapSource)
+}
+
+// prefixedEnvVar returns the env var for a flag name with the given prefix,
+// e.g. MYAPP_LISTEN_ADDR for the prefix MYAPP and the flag listen-addr
+func prefixedEnvVar(prefix, name string) string {
+	name = strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+	if strings.HasSuffix(prefix, "_") {
+		return prefix + name
+	}
+	return prefix + "_" + name
+}
+
+// FlagSource is the kind of source the value of a flag was read from, see
+// Command.IsSetFrom
+type FlagSource int
+
+const (
+	// SourceUnknown is returned for a flag which does not exist
+	SourceUnknown FlagSource = iota
+	// SourceDefault is returned for a flag which is not set
+	SourceDefault
+	// SourceCommandLine is returned for a flag set on the command line
+	SourceCommandLine
+	// SourceEnvVar is returned for a flag read from an env var, including
+	// files named by env vars
+	SourceEnvVar
+	// SourceConfigFile is returned for a flag read from a config file, see
+	// Command.ConfigFile
+	SourceConfigFile
+	// SourceOther is returned for a flag read from another of its Sources,
+	// e.g. a file or a remote source
+	SourceOther
+)
+
+func (s FlagSource) String() string {
+	switch s {
+	case SourceDefault:
+		return "default"
+	case SourceCommandLine:
+		return "command line"
+	case SourceEnvVar:
+		return "env var"
+	case SourceConfigFile:
+		return "config file"
+	case SourceOther:
+		return "other"
+	}
+	return "unknown"
+}
+
 // LocalFlag is an interface to enable detection of flags which are local
 // to current command
 type LocalFlag interface {
@@ -188,6 +259,12 @@ func newFlagSet(name string, flags []Flag) (*flag.FlagSet, error) {
 	set := flag.NewFlagSet(name, flag.ContinueOnError)
 
 	for _, f := range flags {
+		if f == HelpFlag || f == VersionFlag {
+			if err := applyBuiltinFlag(set, f); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		if err := f.Apply(set); err != nil {
 			return nil, err
 		}
@@ -275,14 +352,21 @@ func withEnvHint(envVars []string, str string) string {
 }
 
 func FlagNames(name string, aliases []string) []string {
-	var ret []string
+	ret := make([]string, 0, len(aliases)+1)
 
-	for _, part := range append([]string{name}, aliases...) {
+	for i := -1; i < len(aliases); i++ {
+		part := name
+		if i >= 0 {
+			part = aliases[i]
+		}
 		// v1 -> v2 migration warning zone:
 		// Strip off anything after the first found comma or space, which
 		// *hopefully* makes it a tiny bit more obvious that unexpected behavior is
 		// caused by using the v1 form of stringly typed "Name".
-		ret = append(ret, commaWhitespace.ReplaceAllString(part, ""))
+		if i := strings.IndexAny(part, ", "); i >= 0 {
+			part = part[:i]
+		}
+		ret = append(ret, part)
 	}
 
 	return ret
@@ -307,6 +391,9 @@ func stringifyFlag(f Flag) string {
 		return ""
 	}
 	placeholder, usage := unquoteUsage(df.GetUsage())
+	if pf, ok := f.(PlaceholderFlag); ok && pf.GetPlaceholder() != "" {
+		placeholder = pf.GetPlaceholder()
+	}
 	needsPlaceholder := df.TakesValue()
 	// if needsPlaceholder is true, placeholder is empty
 	if needsPlaceholder && placeholder == "" {
@@ -339,6 +426,32 @@ func stringifyFlag(f Flag) string {
 	return withEnvHint(df.GetEnvVars(), fmt.Sprintf("%s\t%s", pn, usageWithDefault))
 }
 
+// applyBuiltinFlag applies the help or version flag without the names
+// which are already taken by the flags of the command, so e.g. -v can be
+// used as alias of a --verbose flag. A copy of the flag is applied so that
+// commands running concurrently do not share its value.
+func applyBuiltinFlag(set *flag.FlagSet, fl Flag) error {
+	if bf, ok := fl.(*BoolFlag); ok {
+		c := *bf
+		c.reset()
+		fl = &c
+	}
+
+	builtin := flag.NewFlagSet(set.Name(), flag.ContinueOnError)
+	if err := fl.Apply(builtin); err != nil {
+		return err
+	}
+
+	builtin.VisitAll(func(f *flag.Flag) {
+		if set.Lookup(f.Name) == nil {
+			set.Var(f.Value, f.Name, f.Usage)
+		} else {
+			tracef("not applying taken name %[1]q of builtin flag %[2]q", f.Name, fl.Names())
+		}
+	})
+	return nil
+}
+
 func hasFlag(flags []Flag, fl Flag) bool {
 	for _, existing := range flags {
 		if fl == existing {
@@ -349,10 +462,69 @@ func hasFlag(flags []Flag, fl Flag) bool {
 	return false
 }
 
-func flagSplitMultiValues(val string) []string {
-	if disableSliceFlagSeparator {
+// multiValueSeparator holds the per-flag separator settings of slice and
+// map flags. An empty sep falls back to the settings of the root command,
+// which are passed to the flags at parse time.
+type multiValueSeparator struct {
+	sep      string
+	disabled bool
+
+	cmdSep      string
+	cmdDisabled bool
+}
+
+// multiValueSeparatorSetter is implemented by values which split their
+// input into multiple values
+type multiValueSeparatorSetter interface {
+	setSeparator(multiValueSeparator)
+}
+
+// multiValueFlag is implemented by flags which take the separator settings
+// of the root command
+type multiValueFlag interface {
+	setCommandSeparator(sep string, disabled bool)
+}
+
+// split splits val on the separator. A separator preceded by a backslash
+// is kept as part of the value.
+func (mvs multiValueSeparator) split(val string) []string {
+	sep := mvs.sep
+	disabled := mvs.disabled
+
+	if sep == "" {
+		sep = mvs.cmdSep
+		disabled = disabled || mvs.cmdDisabled
+	}
+	if sep == "" {
+		sep = defaultSliceFlagSeparator
+	}
+
+	if disabled {
 		return []string{val}
 	}
 
-	return strings.Split(val, defaultSliceFlagSeparator)
+	var (
+		parts []string
+		cur   strings.Builder
+	)
+
+	for i := 0; i < len(val); {
+		if val[i] == '\\' && strings.HasPrefix(val[i+1:], sep) {
+			cur.WriteString(sep)
+			i += 1 + len(sep)
+			continue
+		}
+
+		if strings.HasPrefix(val[i:], sep) {
+			parts = append(parts, cur.String())
+			cur.Reset()
+			i += len(sep)
+			continue
+		}
+
+		cur.WriteByte(val[i])
+		i++
+	}
+
+	return append(parts, cur.String())
 }
@@ -25,6 +25,11 @@ type BoolWithInverseFlag struct {
 	posCount *int
 
 	negDest *bool
+
+	// the Destination and Count of the embedded bool flag before they were
+	// replaced by initialize
+	origDest  *bool
+	origCount *int
 }
 
 func (parent *BoolWithInverseFlag) Flags() []Flag {
@@ -35,6 +40,10 @@ func (parent *BoolWithInverseFlag) IsSet() bool {
 	return (*parent.posCount > 0) || (parent.positiveFlag.IsSet() || parent.negativeFlag.IsSet())
 }
 
+func (parent *BoolWithInverseFlag) isSetResolved() bool {
+	return (*parent.posCount > 0) || (parent.positiveFlag.isSetResolved() || parent.negativeFlag.isSetResolved())
+}
+
 func (parent *BoolWithInverseFlag) Value() bool {
 	return *parent.posDest
 }
@@ -55,6 +64,31 @@ func (parent *BoolWithInverseFlag) RunAction(ctx context.Context, cmd *Command)
 	return nil
 }
 
+// reset clears the state of both flags left by a previous run
+func (parent *BoolWithInverseFlag) reset() {
+	parent.BoolFlag.reset()
+	if parent.negativeFlag != nil {
+		parent.negativeFlag.reset()
+	}
+	if parent.posCount != nil {
+		*parent.posCount = 0
+	}
+}
+
+// clone returns a copy of the flag without the state of previous runs,
+// see Command.Clone
+func (parent *BoolWithInverseFlag) clone() Flag {
+	c := *parent
+	c.BoolFlag = parent.BoolFlag.clone().(*BoolFlag)
+	if parent.positiveFlag != nil {
+		c.BoolFlag.Destination = parent.origDest
+		c.BoolFlag.Config.Count = parent.origCount
+	}
+	c.positiveFlag, c.negativeFlag = nil, nil
+	c.posDest, c.posCount, c.negDest = nil, nil, nil
+	return &c
+}
+
 // Initialize creates a new BoolFlag that has an inverse flag
 //
 // consider a bool flag `--env`, there is no way to set it to false
@@ -62,6 +96,8 @@ func (parent *BoolWithInverseFlag) RunAction(ctx context.Context, cmd *Command)
 // it can be determined that BoolWithInverseFlag.IsSet().
 func (parent *BoolWithInverseFlag) initialize() {
 	child := parent.BoolFlag
+	parent.origDest = child.Destination
+	parent.origCount = child.Config.Count
 
 	parent.negDest = new(bool)
 	if child.Destination != nil {
@@ -149,6 +185,34 @@ func (parent *BoolWithInverseFlag) PostParse() error {
 	return nil
 }
 
+func (parent *BoolWithInverseFlag) deferSources(ctx context.Context, run int) error {
+	if parent.positiveFlag != nil {
+		if err := parent.positiveFlag.deferSources(ctx, run); err != nil {
+			return err
+		}
+	}
+	if parent.negativeFlag != nil {
+		if err := parent.negativeFlag.deferSources(ctx, run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (parent *BoolWithInverseFlag) resolveSources() error {
+	if parent.positiveFlag != nil {
+		if err := parent.positiveFlag.resolveSources(); err != nil {
+			return err
+		}
+	}
+	if parent.negativeFlag != nil {
+		if err := parent.negativeFlag.resolveSources(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (parent *BoolWithInverseFlag) Apply(set *flag.FlagSet) error {
 	if parent.positiveFlag == nil {
 		parent.initialize()
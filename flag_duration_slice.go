@@ -0,0 +1,22 @@
+package cli
+
+import "time"
+
+type (
+	DurationSlice     = SliceBase[time.Duration, NoConfig, durationValue]
+	DurationSliceFlag = FlagBase[[]time.Duration, NoConfig, DurationSlice]
+)
+
+var NewDurationSlice = NewSliceBase[time.Duration, NoConfig, durationValue]
+
+// DurationSlice looks up the value of a local DurationSliceFlag, returns
+// nil if not found
+func (cmd *Command) DurationSlice(name string) []time.Duration {
+	if v, ok := cmd.Value(name).([]time.Duration); ok {
+		tracef("duration slice available for flag name %[1]q with value=%[2]v (cmd=%[3]q)", name, v, cmd.Name)
+		return v
+	}
+
+	tracef("duration slice NOT available for flag name %[1]q (cmd=%[2]q)", name, cmd.Name)
+	return nil
+}
@@ -4,45 +4,52 @@ import (
 	"strconv"
 )
 
-type FloatFlag = FlagBase[float64, NoConfig, floatValue]
+type (
+	FloatFlag   = FlagBase[float64, NoConfig, floatValue[float64]]
+	Float32Flag = FlagBase[float32, NoConfig, floatValue[float32]]
+)
 
-// -- float64 Value
-type floatValue float64
+// -- floating point Value
+type floatValue[T float32 | float64] struct {
+	val *T
+}
 
 // Below functions are to satisfy the ValueCreator interface
 
-func (f floatValue) Create(val float64, p *float64, c NoConfig) Value {
+func (f floatValue[T]) Create(val T, p *T, c NoConfig) Value {
 	*p = val
-	return (*floatValue)(p)
+	return &floatValue[T]{val: p}
 }
 
-func (f floatValue) ToString(b float64) string {
-	return strconv.FormatFloat(b, 'g', -1, 64)
+func (f floatValue[T]) ToString(b T) string {
+	return strconv.FormatFloat(float64(b), 'g', -1, bitSizeOf[T]())
 }
 
 // Below functions are to satisfy the flag.Value interface
 
-func (f *floatValue) Set(s string) error {
-	v, err := strconv.ParseFloat(s, 64)
+func (f *floatValue[T]) Set(s string) error {
+	v, err := strconv.ParseFloat(s, bitSizeOf[T]())
 	if err != nil {
 		return err
 	}
-	*f = floatValue(v)
+	*f.val = T(v)
 	return err
 }
 
-func (f *floatValue) Get() any { return float64(*f) }
+func (f *floatValue[T]) Get() any { return *f.val }
 
-func (f *floatValue) String() string { return strconv.FormatFloat(float64(*f), 'g', -1, 64) }
+func (f *floatValue[T]) String() string {
+	return strconv.FormatFloat(float64(*f.val), 'g', -1, bitSizeOf[T]())
+}
 
 // Float looks up the value of a local FloatFlag, returns
 // 0 if not found
 func (cmd *Command) Float(name string) float64 {
-	if v, ok := cmd.Value(name).(float64); ok {
-		tracef("float available for flag name %[1]q with value=%[2]v (cmd=%[3]q)", name, v, cmd.Name)
-		return v
-	}
+	return flagValue[float64](cmd, name, "float")
+}
 
-	tracef("float NOT available for flag name %[1]q (cmd=%[2]q)", name, cmd.Name)
-	return 0
+// Float32 looks up the value of a local Float32Flag, returns
+// 0 if not found
+func (cmd *Command) Float32(name string) float32 {
+	return flagValue[float32](cmd, name, "float32")
 }
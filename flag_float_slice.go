@@ -1,11 +1,16 @@
 package cli
 
 type (
-	FloatSlice     = SliceBase[float64, NoConfig, floatValue]
-	FloatSliceFlag = FlagBase[[]float64, NoConfig, FloatSlice]
+	FloatSlice       = SliceBase[float64, NoConfig, floatValue[float64]]
+	FloatSliceFlag   = FlagBase[[]float64, NoConfig, FloatSlice]
+	Float32Slice     = SliceBase[float32, NoConfig, floatValue[float32]]
+	Float32SliceFlag = FlagBase[[]float32, NoConfig, Float32Slice]
 )
 
-var NewFloatSlice = NewSliceBase[float64, NoConfig, floatValue]
+var (
+	NewFloatSlice   = NewSliceBase[float64, NoConfig, floatValue[float64]]
+	NewFloat32Slice = NewSliceBase[float32, NoConfig, floatValue[float32]]
+)
 
 // FloatSlice looks up the value of a local FloatSliceFlag, returns
 // nil if not found
@@ -18,3 +23,15 @@ func (cmd *Command) FloatSlice(name string) []float64 {
 	tracef("float slice NOT available for flag name %[1]q (cmd=%[2]q)", name, cmd.Name)
 	return nil
 }
+
+// Float32Slice looks up the value of a local Float32SliceFlag, returns
+// nil if not found
+func (cmd *Command) Float32Slice(name string) []float32 {
+	if v, ok := cmd.Value(name).([]float32); ok {
+		tracef("float32 slice available for flag name %[1]q with value=%[2]v (cmd=%[3]q)", name, v, cmd.Name)
+		return v
+	}
+
+	tracef("float32 slice NOT available for flag name %[1]q (cmd=%[2]q)", name, cmd.Name)
+	return nil
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
 )
 
 // Value represents a value as used by cli.
@@ -20,17 +23,28 @@ type boolFlag interface {
 }
 
 type fnValue struct {
-	fn     func(string) error
-	isBool bool
-	v      Value
+	fn      func(string) error
+	isBool  bool
+	v       Value
+	resolve func() // reads the value from the sources of the flag if deferred
+	flag    Flag   // the flag the value belongs to, see lookupValue
+}
+
+func (f *fnValue) Get() any {
+	if f.resolve != nil {
+		f.resolve()
+	}
+	return f.v.Get()
 }
 
-func (f *fnValue) Get() any           { return f.v.Get() }
 func (f *fnValue) Set(s string) error { return f.fn(s) }
 func (f *fnValue) String() string {
 	if f.v == nil {
 		return ""
 	}
+	if f.resolve != nil {
+		f.resolve()
+	}
 	return f.v.String()
 }
 
@@ -67,12 +81,15 @@ type FlagBase[T any, C any, VC ValueCreator[T, C]] struct {
 	Category         string                                   `json:"category"`         // category of the flag, if any
 	DefaultText      string                                   `json:"defaultText"`      // default text of the flag for usage purposes
 	HideDefault      bool                                     `json:"hideDefault"`      // whether to hide the default value in output
+	Sensitive        bool                                     `json:"sensitive"`        // whether the flag value is a secret which must never be shown in help, docs or logs
 	Usage            string                                   `json:"usage"`            // usage string for help output
+	Placeholder      string                                   `json:"placeholder"`      // placeholder for the flag value in help output, e.g. FILE or HOST:PORT
 	Sources          ValueSourceChain                         `json:"-"`                // sources to load flag value from
 	Required         bool                                     `json:"required"`         // whether the flag is required or not
 	Hidden           bool                                     `json:"hidden"`           // whether to hide the flag in help output
 	Local            bool                                     `json:"local"`            // whether the flag needs to be applied to subcommands as well
 	Value            T                                        `json:"defaultValue"`     // default value for this flag if not set by from any source
+	DefaultFunc      func() (T, error)                        `json:"-"`                // function computing the default value when the flag is not set otherwise, overrides Value
 	Destination      *T                                       `json:"-"`                // destination pointer for value when set
 	Aliases          []string                                 `json:"aliases"`          // Aliases that are allowed for this flag
 	TakesFile        bool                                     `json:"takesFileArg"`     // whether this flag takes a file argument, mainly for shell completion purposes
@@ -81,13 +98,65 @@ type FlagBase[T any, C any, VC ValueCreator[T, C]] struct {
 	OnlyOnce         bool                                     `json:"onlyOnce"`         // whether this flag can be duplicated on the command line
 	Validator        func(T) error                            `json:"-"`                // custom function to validate this flag value
 	ValidateDefaults bool                                     `json:"validateDefaults"` // whether to validate defaults or not
+	Separator        string                                   `json:"separator"`        // separator for multiple values, overrides the command wide separator (slice and map flags only)
+	DisableSeparator bool                                     `json:"disableSeparator"` // whether to disable splitting of multiple values (slice and map flags only)
+	NoEnvVarPrefix   bool                                     `json:"noEnvVarPrefix"`   // whether to skip the env var derived from the command's EnvVarPrefix
+	FilePathEnvVars  bool                                     `json:"filePathEnvVars"`  // whether to also read the value from the file named by each env var suffixed with _FILE
+	ExpandEnv        bool                                     `json:"expandEnv"`        // whether to expand $VAR and ${VAR} references in values of this flag
+	ExpandLookup     func(string) (string, bool)              `json:"-"`                // function looking up variables for ExpandEnv, defaults to os.LookupEnv
 
 	// unexported fields for internal use
-	count      int   // number of times the flag has been set
-	hasBeenSet bool  // whether the flag has been set from env or file
-	applied    bool  // whether the flag has been applied to a flag set already
-	creator    VC    // value creator for this flag type
-	value      Value // value representing this flag's value
+	count       int             // number of times the flag has been set
+	hasBeenSet  bool            // whether the flag has been set from env or file
+	applied     bool            // whether the flag has been applied to a flag set already
+	creator     VC              // value creator for this flag type
+	value       Value           // value representing this flag's value
+	computed    bool            // whether DefaultFunc has been called already
+	computedVal T               // the value returned by DefaultFunc
+	source      ValueSource     // source the value was read from, if any
+	dest        *T              // pointer the value is stored at
+	sourcesRun  int             // run of the root command the sources were deferred in
+	sourcesCtx  context.Context // context of the run the sources are read with
+	sourcesOnce *sync.Once      // reads the sources once in this run, nil if they are not deferred
+	sourcesErr  error           // error reading the sources, returned again when resolved
+	cmdSep      string          // separator of the root command, see Command.SliceFlagSeparator
+	cmdNoSep    bool            // whether the root command disables the separator
+}
+
+// defaultValue returns the default value of the flag, calling
+// DefaultFunc once if it is set
+func (f *FlagBase[T, C, V]) defaultValue() (T, error) {
+	if f.DefaultFunc == nil {
+		return f.Value, nil
+	}
+
+	if !f.computed {
+		v, err := f.DefaultFunc()
+		if err != nil {
+			return f.Value, fmt.Errorf("could not compute default value for flag %s: %w", f.Name, err)
+		}
+		f.computedVal = v
+		f.computed = true
+	}
+
+	return f.computedVal, nil
+}
+
+// expand expands variable references in val if ExpandEnv is set
+func (f *FlagBase[T, C, V]) expand(val string) string {
+	if !f.ExpandEnv {
+		return val
+	}
+
+	lookup := f.ExpandLookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+
+	return os.Expand(val, func(key string) string {
+		v, _ := lookup(key)
+		return v
+	})
 }
 
 // GetValue returns the flags value as string representation and an empty
@@ -96,7 +165,8 @@ func (f *FlagBase[T, C, V]) GetValue() string {
 	if !f.TakesValue() {
 		return ""
 	}
-	return fmt.Sprintf("%v", f.Value)
+	val, _ := f.defaultValue()
+	return fmt.Sprintf("%v", val)
 }
 
 // TypeName returns the type of the flag.
@@ -139,26 +209,127 @@ func (f *FlagBase[T, C, V]) TypeName() string {
 func (f *FlagBase[T, C, V]) PostParse() error {
 	tracef("postparse (flag=%[1]q)", f.Name)
 
-	if !f.hasBeenSet {
-		if val, source, found := f.Sources.LookupWithSource(); found {
-			if val != "" || reflect.TypeOf(f.Value).Kind() == reflect.String {
-				if err := f.value.Set(val); err != nil {
-					return fmt.Errorf(
-						"could not parse %[1]q as %[2]T value from %[3]s for flag %[4]s: %[5]s",
-						val, f.Value, source, f.Name, err,
-					)
-				}
-			} else if val == "" && reflect.TypeOf(f.Value).Kind() == reflect.Bool {
-				_ = f.value.Set("false")
-			}
+	f.sourcesOnce = &sync.Once{}
+	f.sourcesErr = nil
+	return f.resolveSources()
+}
+
+// deferSources makes the flag read its sources only when its value is
+// first needed in the given run of the root command, with the context of
+// the run. Flags with a Destination are read right away since the value can
+// be read from there without going through the flag. Deferring the sources
+// again in the same run only replaces the context.
+func (f *FlagBase[T, C, V]) deferSources(ctx context.Context, run int) error {
+	f.sourcesCtx = ctx
+	if f.sourcesRun != run {
+		f.sourcesRun = run
+		f.sourcesOnce = &sync.Once{}
+		f.sourcesErr = nil
+	}
+
+	if f.Destination != nil {
+		return f.resolveSources()
+	}
+	return nil
+}
+
+// resolveSources sets the flag from the first of its sources that has a
+// value if they were deferred, unless it was set on the command line. The
+// sources are read at most once per run and any error is returned again
+// on later calls. It can be called from several goroutines at once, e.g.
+// by the accessors of Command while WatchConfig runs.
+func (f *FlagBase[T, C, V]) resolveSources() error {
+	once := f.sourcesOnce
+	if once == nil || f.value == nil {
+		return f.sourcesErr
+	}
+	once.Do(func() {
+		err := f.readSources()
+		if err == nil {
+			err = f.applyDefaultFunc()
+		}
+		f.sourcesErr = err
+	})
+	return f.sourcesErr
+}
 
-			f.hasBeenSet = true
+// applyDefaultFunc sets the flag to the value computed by DefaultFunc if it
+// was not set on the command line or from one of its sources, so that the
+// function is not called, and cannot fail, when the value is not needed
+func (f *FlagBase[T, C, V]) applyDefaultFunc() error {
+	if f.DefaultFunc == nil || f.hasBeenSet {
+		return nil
+	}
+
+	val, err := f.defaultValue()
+	if err != nil {
+		return err
+	}
+	if s, ok := any(val).(string); ok && f.ExpandEnv {
+		val = any(f.expand(s)).(T)
+	}
+
+	// the value of the flag keeps pointing to dest, which Create sets
+	_ = f.creator.Create(val, f.dest, f.Config)
+
+	if f.Validator != nil && f.ValidateDefaults {
+		return f.Validator(*f.dest)
+	}
+	return nil
+}
+
+func (f *FlagBase[T, C, V]) readSources() error {
+	if f.hasBeenSet {
+		return nil
+	}
+
+	ctx := f.sourcesCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	val, source, found, err := f.Sources.lookupWithSourceErr(ctx)
+	if err != nil {
+		return fmt.Errorf("could not read value for flag %[1]s from %[2]s: %[3]w", f.Name, source, err)
+	}
+	if found {
+		if val != "" || reflect.TypeOf(f.Value).Kind() == reflect.String {
+			if err := f.value.Set(f.expand(val)); err != nil {
+				return fmt.Errorf(
+					"could not parse %[1]q as %[2]T value from %[3]s for flag %[4]s: %[5]s",
+					val, f.Value, source, f.Name, err,
+				)
+			}
+		} else if val == "" && reflect.TypeOf(f.Value).Kind() == reflect.Bool {
+			_ = f.value.Set("false")
 		}
+
+		f.hasBeenSet = true
+		f.source = source
 	}
 
 	return nil
 }
 
+// valueSource returns the source the value of the flag was read from,
+// or nil if it was not read from one of its Sources
+func (f *FlagBase[T, C, V]) valueSource() ValueSource {
+	_ = f.resolveSources()
+	return f.source
+}
+
+// sourceChain returns the Sources of the flag
+func (f *FlagBase[T, C, V]) sourceChain() *ValueSourceChain {
+	return &f.Sources
+}
+
+// typedValue returns the value of the flag as T, without boxing it like
+// the Get method of its Value
+func (f *FlagBase[T, C, V]) typedValue() T {
+	_ = f.resolveSources()
+	return *f.dest
+}
+
 // Apply populates the flag given the flag set and environment
 func (f *FlagBase[T, C, V]) Apply(set *flag.FlagSet) error {
 	tracef("apply (flag=%[1]q)", f.Name)
@@ -170,12 +341,26 @@ func (f *FlagBase[T, C, V]) Apply(set *flag.FlagSet) error {
 	// flag can be applied to different flag sets multiple times while still
 	// keeping the env set.
 	if !f.applied || f.Local {
+		if f.FilePathEnvVars {
+			f.addFilePathEnvVars()
+		}
+
+		// the value computed by DefaultFunc is only set once it is known
+		// that the flag is not set otherwise, see applyDefaultFunc
 		newVal := f.Value
 
-		if f.Destination == nil {
-			f.value = f.creator.Create(newVal, new(T), f.Config)
-		} else {
-			f.value = f.creator.Create(newVal, f.Destination, f.Config)
+		if s, ok := any(newVal).(string); ok && f.ExpandEnv {
+			newVal = any(f.expand(s)).(T)
+		}
+
+		f.dest = f.Destination
+		if f.dest == nil {
+			f.dest = new(T)
+		}
+		f.value = f.creator.Create(newVal, f.dest, f.Config)
+
+		if sv, ok := f.value.(multiValueSeparatorSetter); ok {
+			sv.setSeparator(f.separator())
 		}
 
 		// Validate the given default or values set from external sources as well
@@ -198,7 +383,7 @@ func (f *FlagBase[T, C, V]) Apply(set *flag.FlagSet) error {
 					return fmt.Errorf("cant duplicate this flag")
 				}
 				f.count++
-				if err := f.value.Set(val); err != nil {
+				if err := f.value.Set(f.expand(val)); err != nil {
 					return err
 				}
 				f.hasBeenSet = true
@@ -209,8 +394,10 @@ func (f *FlagBase[T, C, V]) Apply(set *flag.FlagSet) error {
 				}
 				return nil
 			},
-			isBool: isBool,
-			v:      f.value,
+			isBool:  isBool,
+			v:       f.value,
+			resolve: func() { _ = f.resolveSources() },
+			flag:    f,
 		}, name, f.Usage)
 	}
 
@@ -218,9 +405,38 @@ func (f *FlagBase[T, C, V]) Apply(set *flag.FlagSet) error {
 	return nil
 }
 
+// reset clears the state of the flag left by a previous run, so it is
+// applied again from its default value and sources
+func (f *FlagBase[T, C, V]) reset() {
+	f.applied = false
+	f.hasBeenSet = false
+	f.count = 0
+	f.source = nil
+	f.sourcesOnce = nil
+	f.sourcesErr = nil
+}
+
+// clone returns a copy of the flag without the state of previous runs,
+// see Command.Clone
+func (f *FlagBase[T, C, V]) clone() Flag {
+	c := *f
+	c.reset()
+	c.Sources.Chain = slices.Clone(f.Sources.Chain)
+	c.value = nil
+	c.dest = nil
+	c.sourcesRun = 0
+	c.sourcesCtx = nil
+	return &c
+}
+
 // IsDefaultVisible returns true if the flag is not hidden, otherwise false
 func (f *FlagBase[T, C, V]) IsDefaultVisible() bool {
-	return !f.HideDefault
+	return !f.HideDefault && !f.Sensitive
+}
+
+// IsSensitive returns true if the flag value must not be displayed
+func (f *FlagBase[T, C, V]) IsSensitive() bool {
+	return f.Sensitive
 }
 
 // String returns a readable representation of this value (for usage defaults)
@@ -230,6 +446,11 @@ func (f *FlagBase[T, C, V]) String() string {
 
 // IsSet returns whether or not the flag has been set through env or file
 func (f *FlagBase[T, C, V]) IsSet() bool {
+	_ = f.resolveSources()
+	return f.hasBeenSet
+}
+
+func (f *FlagBase[T, C, V]) isSetResolved() bool {
 	return f.hasBeenSet
 }
 
@@ -262,6 +483,75 @@ func (f *FlagBase[T, C, V]) GetUsage() string {
 	return f.Usage
 }
 
+// GetPlaceholder returns the placeholder for the flag value
+func (f *FlagBase[T, C, V]) GetPlaceholder() string {
+	return f.Placeholder
+}
+
+// addPrefixedEnvVar appends the env var derived from the given prefix
+// and the flag name to the sources of the flag
+func (f *FlagBase[T, C, V]) addPrefixedEnvVar(prefix string) {
+	if f.NoEnvVarPrefix || prefix == "" {
+		return
+	}
+
+	key := prefixedEnvVar(prefix, f.Name)
+	if slices.Contains(f.Sources.EnvKeys(), key) {
+		return
+	}
+
+	tracef("adding env var %[1]q derived from prefix (flag=%[2]q)", key, f.Name)
+	f.Sources.Chain = append(f.Sources.Chain, EnvVar(key))
+}
+
+// addFilePathEnvVars adds a source reading from the file named by KEY_FILE
+// after each env var KEY of the flag
+func (f *FlagBase[T, C, V]) addFilePathEnvVars() {
+	chain := make([]ValueSource, 0, len(f.Sources.Chain))
+	for i, src := range f.Sources.Chain {
+		chain = append(chain, src)
+
+		ev, ok := src.(*envVarValueSource)
+		if !ok {
+			continue
+		}
+		if i+1 < len(f.Sources.Chain) {
+			if next, ok := f.Sources.Chain[i+1].(*envFileValueSource); ok && next.key == ev.key+envFileSuffix {
+				continue
+			}
+		}
+
+		tracef("adding file path env var %[1]q (flag=%[2]q)", ev.key+envFileSuffix, f.Name)
+		chain = append(chain, EnvFile(ev.key+envFileSuffix))
+	}
+	f.Sources.Chain = chain
+}
+
+// addMapSources appends a source looking up the name of the flag in each
+// of the map sources to the sources of the flag
+func (f *FlagBase[T, C, V]) addMapSources(sources []MapSource) {
+	for _, ms := range sources {
+		if slices.ContainsFunc(f.Sources.Chain, func(src ValueSource) bool {
+			mvs, ok := src.(*mapValueSource)
+			return ok && mvs.key == f.Name && (mvs.ms == ms || mvs.ms.GoString() == ms.GoString())
+		}) {
+			continue
+		}
+
+		tracef("adding map source %[1]s (flag=%[2]q)", ms, f.Name)
+		f.Sources.Chain = append(f.Sources.Chain, NewMapValueSource(f.Name, ms))
+	}
+}
+
+// ValueCompletions returns the values offered as shell completions for
+// the flag, if its value type only accepts a fixed set of them
+func (f *FlagBase[T, C, V]) ValueCompletions() []string {
+	if vc, ok := any(f.creator).(interface{ completions() []string }); ok {
+		return vc.completions()
+	}
+	return nil
+}
+
 // GetEnvVars returns the env vars for this flag
 func (f *FlagBase[T, C, V]) GetEnvVars() []string {
 	return f.Sources.EnvKeys()
@@ -279,7 +569,11 @@ func (f *FlagBase[T, C, V]) GetDefaultText() string {
 		return f.DefaultText
 	}
 	var v V
-	return v.ToString(f.Value)
+	val, err := f.defaultValue()
+	if err != nil {
+		return ""
+	}
+	return v.ToString(val)
 }
 
 // RunAction executes flag action if set
@@ -302,7 +596,76 @@ func (f *FlagBase[T, C, VC]) IsMultiValueFlag() bool {
 	return kind == reflect.Slice || kind == reflect.Map
 }
 
+// separator returns the separator settings passed to slice and map values
+func (f *FlagBase[T, C, VC]) separator() multiValueSeparator {
+	return multiValueSeparator{
+		sep:         f.Separator,
+		disabled:    f.DisableSeparator,
+		cmdSep:      f.cmdSep,
+		cmdDisabled: f.cmdNoSep,
+	}
+}
+
+// setCommandSeparator sets the separator settings of the root command the
+// flag is parsed by. Other flags are left alone, as builtin flags like
+// HelpFlag are shared by all commands.
+func (f *FlagBase[T, C, VC]) setCommandSeparator(sep string, disabled bool) {
+	if !f.IsMultiValueFlag() {
+		return
+	}
+	f.cmdSep, f.cmdNoSep = sep, disabled
+	if sv, ok := f.value.(multiValueSeparatorSetter); ok {
+		sv.setSeparator(f.separator())
+	}
+}
+
 // IsLocal returns false if flag needs to be persistent across subcommands
 func (f *FlagBase[T, C, VC]) IsLocal() bool {
 	return f.Local
 }
+
+// parseConfigValue returns the value of the flag set to its default value
+// and then to each of the strings, without changing the flag. It returns
+// nil for flags of interface types like GenericFlag, whose values cannot be
+// parsed without changing the flag.
+func (f *FlagBase[T, C, VC]) parseConfigValue(vals []string) (any, error) {
+	if reflect.TypeOf((*T)(nil)).Elem().Kind() == reflect.Interface {
+		return nil, nil
+	}
+
+	val, err := f.defaultValue()
+	if err != nil {
+		return nil, err
+	}
+
+	value := f.creator.Create(val, new(T), f.Config)
+	if sv, ok := value.(multiValueSeparatorSetter); ok {
+		sv.setSeparator(f.separator())
+	}
+	for _, s := range vals {
+		if err := value.Set(f.expand(s)); err != nil {
+			return nil, err
+		}
+	}
+
+	t := value.Get().(T)
+	if f.Validator != nil && len(vals) > 0 {
+		if err := f.Validator(t); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// setConfigValue sets the flag to a value returned by parseConfigValue
+func (f *FlagBase[T, C, VC]) setConfigValue(v any) {
+	if f.dest != nil {
+		*f.dest = v.(T)
+	}
+}
+
+// configDefault returns the default value of the flag for config files
+func (f *FlagBase[T, C, VC]) configDefault() any {
+	val, _ := f.defaultValue()
+	return val
+}
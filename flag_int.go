@@ -1,59 +1,88 @@
 package cli
 
-import (
-	"strconv"
-)
+import "strconv"
 
-type IntFlag = FlagBase[int64, IntegerConfig, intValue]
+type (
+	IntFlag   = FlagBase[int64, IntegerConfig, intValue[int64]]
+	Int8Flag  = FlagBase[int8, IntegerConfig, intValue[int8]]
+	Int16Flag = FlagBase[int16, IntegerConfig, intValue[int16]]
+	Int32Flag = FlagBase[int32, IntegerConfig, intValue[int32]]
+)
 
 // IntegerConfig is the configuration for all integer type flags
 type IntegerConfig struct {
 	Base int
 }
 
-// -- int64 Value
-type intValue struct {
-	val  *int64
+// -- signed integer Value
+type intValue[T int8 | int16 | int32 | int64] struct {
+	val  *T
 	base int
 }
 
 // Below functions are to satisfy the ValueCreator interface
 
-func (i intValue) Create(val int64, p *int64, c IntegerConfig) Value {
+func (i intValue[T]) Create(val T, p *T, c IntegerConfig) Value {
 	*p = val
-	return &intValue{
+	return &intValue[T]{
 		val:  p,
 		base: c.Base,
 	}
 }
 
-func (i intValue) ToString(b int64) string {
-	return strconv.FormatInt(b, 10)
+func (i intValue[T]) ToString(b T) string {
+	return strconv.FormatInt(int64(b), 10)
 }
 
 // Below functions are to satisfy the flag.Value interface
 
-func (i *intValue) Set(s string) error {
-	v, err := strconv.ParseInt(s, i.base, 64)
+func (i *intValue[T]) Set(s string) error {
+	v, err := strconv.ParseInt(s, i.base, bitSizeOf[T]())
 	if err != nil {
 		return err
 	}
-	*i.val = v
+	*i.val = T(v)
 	return err
 }
 
-func (i *intValue) Get() any { return int64(*i.val) }
+func (i *intValue[T]) Get() any { return *i.val }
 
-func (i *intValue) String() string { return strconv.FormatInt(int64(*i.val), 10) }
+func (i *intValue[T]) String() string { return strconv.FormatInt(int64(*i.val), 10) }
 
 // Int looks up the value of a local Int64Flag, returns
 // 0 if not found
 func (cmd *Command) Int(name string) int64 {
-	if v, ok := cmd.Value(name).(int64); ok {
-		tracef("int available for flag name %[1]q with value=%[2]v (cmd=%[3]q)", name, v, cmd.Name)
-		return v
-	}
+	return flagValue[int64](cmd, name, "int")
+}
+
+// Int8 looks up the value of a local Int8Flag, returns
+// 0 if not found
+func (cmd *Command) Int8(name string) int8 {
+	return flagValue[int8](cmd, name, "int8")
+}
 
-	tracef("int NOT available for flag name %[1]q (cmd=%[2]q)", name, cmd.Name)
-	return 0
+// Int16 looks up the value of a local Int16Flag, returns
+// 0 if not found
+func (cmd *Command) Int16(name string) int16 {
+	return flagValue[int16](cmd, name, "int16")
+}
+
+// Int32 looks up the value of a local Int32Flag, returns
+// 0 if not found
+func (cmd *Command) Int32(name string) int32 {
+	return flagValue[int32](cmd, name, "int32")
+}
+
+// bitSizeOf returns the size in bits of the numeric type T
+func bitSizeOf[T int8 | int16 | int32 | int64 | uint8 | uint16 | uint32 | uint64 | float32 | float64]() int {
+	switch any(*new(T)).(type) {
+	case int8, uint8:
+		return 8
+	case int16, uint16:
+		return 16
+	case int32, uint32, float32:
+		return 32
+	default:
+		return 64
+	}
 }
@@ -1,11 +1,16 @@
 package cli
 
 type (
-	IntSlice     = SliceBase[int64, IntegerConfig, intValue]
-	IntSliceFlag = FlagBase[[]int64, IntegerConfig, IntSlice]
+	IntSlice       = SliceBase[int64, IntegerConfig, intValue[int64]]
+	IntSliceFlag   = FlagBase[[]int64, IntegerConfig, IntSlice]
+	Int32Slice     = SliceBase[int32, IntegerConfig, intValue[int32]]
+	Int32SliceFlag = FlagBase[[]int32, IntegerConfig, Int32Slice]
 )
 
-var NewIntSlice = NewSliceBase[int64, IntegerConfig, intValue]
+var (
+	NewIntSlice   = NewSliceBase[int64, IntegerConfig, intValue[int64]]
+	NewInt32Slice = NewSliceBase[int32, IntegerConfig, intValue[int32]]
+)
 
 // IntSlice looks up the value of a local IntSliceFlag, returns
 // nil if not found
@@ -18,3 +23,15 @@ func (cmd *Command) IntSlice(name string) []int64 {
 	tracef("int slice NOT available for flag name %[1]q (cmd=%[2]q)", name, cmd.Name)
 	return nil
 }
+
+// Int32Slice looks up the value of a local Int32SliceFlag, returns
+// nil if not found
+func (cmd *Command) Int32Slice(name string) []int32 {
+	if v, ok := cmd.Value(name).([]int32); ok {
+		tracef("int32 slice available for flag name %[1]q with value=%[2]v (cmd=%[3]q)", name, v, cmd.Name)
+		return v
+	}
+
+	tracef("int32 slice NOT available for flag name %[1]q (cmd=%[2]q)", name, cmd.Name)
+	return nil
+}
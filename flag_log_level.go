@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+type LogLevelFlag = FlagBase[slog.Level, NoConfig, logLevelValue]
+
+// -- slog.Level Value
+type logLevelValue slog.Level
+
+// Below functions are to satisfy the ValueCreator interface
+
+func (l logLevelValue) Create(val slog.Level, p *slog.Level, c NoConfig) Value {
+	*p = val
+	return (*logLevelValue)(p)
+}
+
+func (l logLevelValue) ToString(val slog.Level) string {
+	return strings.ToLower(val.String())
+}
+
+// completions returns the level names offered as shell completions
+func (l logLevelValue) completions() []string {
+	return []string{"debug", "info", "warn", "error"}
+}
+
+// Below functions are to satisfy the flag.Value interface
+
+func (l *logLevelValue) Set(s string) error {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		*l = logLevelValue(n)
+		return nil
+	}
+
+	if strings.EqualFold(s, "warning") {
+		s = "warn"
+	}
+
+	var v slog.Level
+	if err := v.UnmarshalText([]byte(s)); err != nil {
+		return err
+	}
+	*l = logLevelValue(v)
+	return nil
+}
+
+func (l *logLevelValue) Get() any { return slog.Level(*l) }
+
+func (l *logLevelValue) String() string { return strings.ToLower(slog.Level(*l).String()) }
+
+// Level looks up the value of a local LogLevelFlag, returns
+// slog.LevelInfo if not found
+func (cmd *Command) Level(name string) slog.Level {
+	return flagValue[slog.Level](cmd, name, "level")
+}
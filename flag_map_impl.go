@@ -13,6 +13,7 @@ type MapBase[T any, C any, VC ValueCreator[T, C]] struct {
 	dict       *map[string]T
 	hasBeenSet bool
 	value      Value
+	separator  multiValueSeparator
 }
 
 func (i MapBase[T, C, VC]) Create(val map[string]T, p *map[string]T, c C) Value {
@@ -50,7 +51,7 @@ func (i *MapBase[T, C, VC]) Set(value string) error {
 		return nil
 	}
 
-	for _, item := range flagSplitMultiValues(value) {
+	for _, item := range i.separator.split(value) {
 		key, value, ok := strings.Cut(item, defaultMapFlagKeyValueSeparator)
 		if !ok {
 			return fmt.Errorf("item %q is missing separator %q", item, defaultMapFlagKeyValueSeparator)
@@ -64,6 +65,10 @@ func (i *MapBase[T, C, VC]) Set(value string) error {
 	return nil
 }
 
+func (i *MapBase[T, C, VC]) setSeparator(mvs multiValueSeparator) {
+	i.separator = mvs
+}
+
 // String returns a readable representation of this value (for usage defaults)
 func (i *MapBase[T, C, VC]) String() string {
 	v := i.Value()
@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type PathFlag = FlagBase[string, PathConfig, pathValue]
+
+// PathConfig defines the constraints checked for path flags
+type PathConfig struct {
+	// Whether the path must exist
+	MustExist bool
+	// Whether the path must be a directory, implies MustExist
+	MustBeDir bool
+	// Whether the path (or its parent directory, if it does not
+	// exist yet) must be writable
+	MustBeWritable bool
+	// Whether to expand a leading ~ to the user's home directory
+	ExpandTilde bool
+	// Whether to convert the path to an absolute path
+	MakeAbsolute bool
+}
+
+// -- path Value
+type pathValue struct {
+	destination *string
+	config      PathConfig
+}
+
+// Below functions are to satisfy the ValueCreator interface
+
+func (p pathValue) Create(val string, dest *string, c PathConfig) Value {
+	if val != "" {
+		// the default value is normalized but not validated, since
+		// the path it points to may only be created later on
+		if resolved, err := resolvePath(val, c); err == nil {
+			val = resolved
+		}
+	}
+	*dest = val
+	return &pathValue{
+		destination: dest,
+		config:      c,
+	}
+}
+
+func (p pathValue) ToString(val string) string {
+	if val == "" {
+		return val
+	}
+	return fmt.Sprintf("%q", val)
+}
+
+// Below functions are to satisfy the flag.Value interface
+
+func (p *pathValue) Set(val string) error {
+	resolved, err := resolvePath(val, p.config)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPath(resolved, p.config); err != nil {
+		return err
+	}
+
+	*p.destination = resolved
+	return nil
+}
+
+func (p *pathValue) Get() any { return *p.destination }
+
+func (p *pathValue) String() string {
+	if p.destination != nil {
+		return *p.destination
+	}
+	return ""
+}
+
+func resolvePath(path string, c PathConfig) (string, error) {
+	if c.ExpandTilde && (path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return path, fmt.Errorf("cannot expand %q: %w", path, err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	if c.MakeAbsolute {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return path, fmt.Errorf("cannot make %q absolute: %w", path, err)
+		}
+		path = abs
+	}
+
+	return path, nil
+}
+
+func checkPath(path string, c PathConfig) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if c.MustExist || c.MustBeDir {
+			return fmt.Errorf("path %q does not exist", path)
+		}
+		if c.MustBeWritable {
+			return checkWritableDir(filepath.Dir(path))
+		}
+		return nil
+	}
+
+	if c.MustBeDir && !info.IsDir() {
+		return fmt.Errorf("path %q is not a directory", path)
+	}
+
+	if !c.MustBeWritable {
+		return nil
+	}
+
+	if info.IsDir() {
+		return checkWritableDir(path)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("path %q is not writable", path)
+	}
+
+	return f.Close()
+}
+
+func checkWritableDir(dir string) error {
+	f, err := os.CreateTemp(dir, ".cli-write-check-*")
+	if err != nil {
+		return fmt.Errorf("directory %q is not writable", dir)
+	}
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
+
+// Path looks up the resolved value of a local PathFlag, returns
+// "" if not found
+func (cmd *Command) Path(name string) string {
+	return flagValue[string](cmd, name, "path")
+}
@@ -12,6 +12,7 @@ type SliceBase[T any, C any, VC ValueCreator[T, C]] struct {
 	slice      *[]T
 	hasBeenSet bool
 	value      Value
+	separator  multiValueSeparator
 }
 
 func (i SliceBase[T, C, VC]) Create(val []T, p *[]T, c C) Value {
@@ -47,7 +48,7 @@ func (i *SliceBase[T, C, VC]) Set(value string) error {
 		return nil
 	}
 
-	for _, s := range flagSplitMultiValues(value) {
+	for _, s := range i.separator.split(value) {
 		if err := i.value.Set(strings.TrimSpace(s)); err != nil {
 			return err
 		}
@@ -57,6 +58,10 @@ func (i *SliceBase[T, C, VC]) Set(value string) error {
 	return nil
 }
 
+func (i *SliceBase[T, C, VC]) setSeparator(mvs multiValueSeparator) {
+	i.separator = mvs
+}
+
 // String returns a readable representation of this value (for usage defaults)
 func (i *SliceBase[T, C, VC]) String() string {
 	v := i.Value()
@@ -0,0 +1,259 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const structTagName = "cli"
+
+// structFlagTag holds the options parsed from a `cli:"..."` struct tag
+type structFlagTag struct {
+	name     string
+	usage    string
+	category string
+	aliases  []string
+	envVars  []string
+	required bool
+	hidden   bool
+	local    bool
+}
+
+// structField is a struct field bound to a flag
+type structField struct {
+	tag   structFlagTag
+	value reflect.Value
+}
+
+// FlagsFromStruct generates flags for all fields of the struct pointed to by
+// v which are tagged with `cli:"..."`. The tag is a comma separated list of
+// options:
+//
+//	name=NAME       name of the flag, defaults to the kebab-cased field name
+//	usage=TEXT      usage text of the flag, must not contain commas
+//	alias=NAME      alias of the flag, may be repeated
+//	env=KEY         environment variable to read the value from, may be repeated
+//	category=NAME   category of the flag in help output
+//	required        whether the flag is required
+//	hidden          whether the flag is hidden from help output
+//	local           whether the flag is local to the command
+//
+// A tag of "-" skips the field, untagged embedded structs are descended into.
+// The current values of the fields are used as the defaults of the flags. Use
+// [Command.Bind] to populate the struct after the flags have been parsed.
+func FlagsFromStruct(v any) ([]Flag, error) {
+	fields, err := structFields(v)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := make([]Flag, 0, len(fields))
+	for _, sf := range fields {
+		fl, err := newStructFlag(sf)
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, fl)
+	}
+
+	return flags, nil
+}
+
+// Bind populates the tagged fields of the struct pointed to by v with the
+// values of the corresponding flags, see [FlagsFromStruct]. Fields whose
+// flag is not defined for the command are left untouched.
+func (cmd *Command) Bind(v any) error {
+	fields, err := structFields(v)
+	if err != nil {
+		return err
+	}
+
+	for _, sf := range fields {
+		if cmd.lookupFlag(sf.tag.name) == nil {
+			tracef("skipping binding of undefined flag %[1]q (cmd=%[2]q)", sf.tag.name, cmd.Name)
+			continue
+		}
+
+		val := reflect.ValueOf(cmd.Value(sf.tag.name))
+		if !val.IsValid() {
+			continue
+		}
+
+		if !val.Type().ConvertibleTo(sf.value.Type()) {
+			return fmt.Errorf("cannot bind %[1]T value of flag %[2]s to field of type %[3]s", val.Interface(), sf.tag.name, sf.value.Type())
+		}
+
+		tracef("binding flag %[1]q with value=%[2]v (cmd=%[3]q)", sf.tag.name, val.Interface(), cmd.Name)
+		sf.value.Set(val.Convert(sf.value.Type()))
+	}
+
+	return nil
+}
+
+func structFields(v any) ([]structField, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a non-nil pointer to a struct, got %T", v)
+	}
+
+	return collectStructFields(rv.Elem(), nil)
+}
+
+func collectStructFields(rv reflect.Value, fields []structField) ([]structField, error) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, ok := field.Tag.Lookup(structTagName)
+
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				var err error
+				if fields, err = collectStructFields(rv.Field(i), fields); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		if tag == "-" {
+			continue
+		}
+
+		if !field.IsExported() {
+			return nil, fmt.Errorf("cannot bind unexported field %s", field.Name)
+		}
+
+		parsed, err := parseStructFlagTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag on field %s: %w", field.Name, err)
+		}
+		if parsed.name == "" {
+			parsed.name = kebabCase(field.Name)
+		}
+
+		fields = append(fields, structField{tag: parsed, value: rv.Field(i)})
+	}
+
+	return fields, nil
+}
+
+func parseStructFlagTag(tag string) (structFlagTag, error) {
+	var t structFlagTag
+	for _, opt := range strings.Split(tag, ",") {
+		key, val, hasVal := strings.Cut(strings.TrimSpace(opt), "=")
+		switch {
+		case key == "":
+			continue
+		case key == "name" && hasVal:
+			t.name = val
+		case key == "usage" && hasVal:
+			t.usage = val
+		case key == "category" && hasVal:
+			t.category = val
+		case key == "alias" && hasVal:
+			t.aliases = append(t.aliases, val)
+		case key == "env" && hasVal:
+			t.envVars = append(t.envVars, val)
+		case key == "required" && !hasVal:
+			t.required = true
+		case key == "hidden" && !hasVal:
+			t.hidden = true
+		case key == "local" && !hasVal:
+			t.local = true
+		default:
+			return t, fmt.Errorf("unknown option %q", opt)
+		}
+	}
+	return t, nil
+}
+
+// kebabCase converts a Go identifier like ListenAddr or HTTPPort to
+// listen-addr or http-port
+func kebabCase(s string) string {
+	runes := []rune(s)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := !unicode.IsUpper(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || nextLower {
+				sb.WriteByte('-')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+func newStructFlag(sf structField) (Flag, error) {
+	switch sf.value.Type() {
+	case reflect.TypeOf(time.Duration(0)):
+		return structFlag[time.Duration, NoConfig, durationValue](sf), nil
+	case reflect.TypeOf(slog.Level(0)):
+		return structFlag[slog.Level, NoConfig, logLevelValue](sf), nil
+	case reflect.TypeOf([]string(nil)):
+		return structFlag[[]string, StringConfig, StringSlice](sf), nil
+	case reflect.TypeOf([]int64(nil)):
+		return structFlag[[]int64, IntegerConfig, IntSlice](sf), nil
+	case reflect.TypeOf([]uint64(nil)):
+		return structFlag[[]uint64, IntegerConfig, UintSlice](sf), nil
+	case reflect.TypeOf([]float64(nil)):
+		return structFlag[[]float64, NoConfig, FloatSlice](sf), nil
+	case reflect.TypeOf([]time.Duration(nil)):
+		return structFlag[[]time.Duration, NoConfig, DurationSlice](sf), nil
+	case reflect.TypeOf(map[string]string(nil)):
+		return structFlag[map[string]string, StringConfig, StringMap](sf), nil
+	}
+
+	switch sf.value.Kind() {
+	case reflect.String:
+		return structFlag[string, StringConfig, stringValue](sf), nil
+	case reflect.Bool:
+		return structFlag[bool, BoolConfig, boolValue](sf), nil
+	case reflect.Int, reflect.Int64:
+		return structFlag[int64, IntegerConfig, intValue[int64]](sf), nil
+	case reflect.Int8:
+		return structFlag[int8, IntegerConfig, intValue[int8]](sf), nil
+	case reflect.Int16:
+		return structFlag[int16, IntegerConfig, intValue[int16]](sf), nil
+	case reflect.Int32:
+		return structFlag[int32, IntegerConfig, intValue[int32]](sf), nil
+	case reflect.Uint, reflect.Uint64:
+		return structFlag[uint64, IntegerConfig, uintValue[uint64]](sf), nil
+	case reflect.Uint8:
+		return structFlag[uint8, IntegerConfig, uintValue[uint8]](sf), nil
+	case reflect.Uint16:
+		return structFlag[uint16, IntegerConfig, uintValue[uint16]](sf), nil
+	case reflect.Uint32:
+		return structFlag[uint32, IntegerConfig, uintValue[uint32]](sf), nil
+	case reflect.Float64:
+		return structFlag[float64, NoConfig, floatValue[float64]](sf), nil
+	case reflect.Float32:
+		return structFlag[float32, NoConfig, floatValue[float32]](sf), nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s for flag %s", sf.value.Type(), sf.tag.name)
+}
+
+func structFlag[T any, C any, VC ValueCreator[T, C]](sf structField) Flag {
+	var value T
+	if rv := reflect.ValueOf(&value).Elem(); sf.value.Type().ConvertibleTo(rv.Type()) {
+		rv.Set(sf.value.Convert(rv.Type()))
+	}
+
+	return &FlagBase[T, C, VC]{
+		Name:     sf.tag.name,
+		Usage:    sf.tag.usage,
+		Category: sf.tag.category,
+		Aliases:  sf.tag.aliases,
+		Sources:  EnvVars(sf.tag.envVars...),
+		Required: sf.tag.required,
+		Hidden:   sf.tag.hidden,
+		Local:    sf.tag.local,
+		Value:    value,
+	}
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testStructLogging struct {
+	Verbose bool `cli:"alias=v,usage=enable verbose output"`
+}
+
+type testStructConfig struct {
+	testStructLogging
+
+	ListenAddr string            `cli:"usage=address to listen on,env=TEST_LISTEN_ADDR"`
+	Port       int               `cli:"name=port,alias=p,required"`
+	Timeout    time.Duration     `cli:"category=network"`
+	Ratio      float32           `cli:""`
+	Tags       []string          `cli:"name=tag"`
+	Labels     map[string]string `cli:"hidden"`
+	Ignored    string            `cli:"-"`
+	Untagged   string
+}
+
+func TestFlagsFromStruct(t *testing.T) {
+	cfg := testStructConfig{ListenAddr: "localhost", Timeout: time.Second}
+	flags, err := FlagsFromStruct(&cfg)
+	require.NoError(t, err)
+	require.Len(t, flags, 7)
+
+	assert.Equal(t, &BoolFlag{Name: "verbose", Aliases: []string{"v"}, Usage: "enable verbose output", Sources: EnvVars()}, flags[0])
+	assert.Equal(t, &StringFlag{Name: "listen-addr", Usage: "address to listen on", Sources: EnvVars("TEST_LISTEN_ADDR"), Value: "localhost"}, flags[1])
+	assert.Equal(t, &IntFlag{Name: "port", Aliases: []string{"p"}, Required: true, Sources: EnvVars()}, flags[2])
+	assert.Equal(t, &DurationFlag{Name: "timeout", Category: "network", Sources: EnvVars(), Value: time.Second}, flags[3])
+	assert.Equal(t, &Float32Flag{Name: "ratio", Sources: EnvVars()}, flags[4])
+	assert.Equal(t, &StringSliceFlag{Name: "tag", Sources: EnvVars()}, flags[5])
+	assert.Equal(t, &StringMapFlag{Name: "labels", Hidden: true, Sources: EnvVars()}, flags[6])
+}
+
+func TestFlagsFromStructErrors(t *testing.T) {
+	_, err := FlagsFromStruct(testStructConfig{})
+	assert.ErrorContains(t, err, "expected a non-nil pointer to a struct")
+
+	_, err = FlagsFromStruct(&struct {
+		Foo string `cli:"bogus"`
+	}{})
+	assert.ErrorContains(t, err, `invalid tag on field Foo: unknown option "bogus"`)
+
+	_, err = FlagsFromStruct(&struct {
+		Foo chan int `cli:""`
+	}{})
+	assert.ErrorContains(t, err, "unsupported type chan int for flag foo")
+}
+
+func TestCommandBind(t *testing.T) {
+	defer resetEnv(os.Environ())
+	os.Clearenv()
+	_ = os.Setenv("TEST_LISTEN_ADDR", "0.0.0.0")
+
+	var cfg testStructConfig
+	flags, err := FlagsFromStruct(&cfg)
+	require.NoError(t, err)
+
+	cmd := &Command{
+		Flags: flags,
+		Action: func(_ context.Context, cmd *Command) error {
+			return cmd.Bind(&cfg)
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"run", "-v", "-p", "8080", "--timeout", "5s", "--ratio", "0.5", "--tag", "a", "--tag", "b", "--labels", "k=v"}))
+	assert.Equal(t, testStructConfig{
+		testStructLogging: testStructLogging{Verbose: true},
+		ListenAddr:        "0.0.0.0",
+		Port:              8080,
+		Timeout:           5 * time.Second,
+		Ratio:             0.5,
+		Tags:              []string{"a", "b"},
+		Labels:            map[string]string{"k": "v"},
+	}, cfg)
+
+	flags, err = FlagsFromStruct(&testStructConfig{})
+	require.NoError(t, err)
+
+	cmd = &Command{Flags: flags}
+	err = cmd.Run(buildTestContext(t), []string{"run"})
+	assert.ErrorContains(t, err, `Required flag "port" not set`)
+}
+
+func TestKebabCase(t *testing.T) {
+	for in, expected := range map[string]string{
+		"Port":       "port",
+		"ListenAddr": "listen-addr",
+		"HTTPPort":   "http-port",
+		"UseTLS":     "use-tls",
+		"MaxRetries": "max-retries",
+	} {
+		assert.Equal(t, expected, kebabCase(in), in)
+	}
+}
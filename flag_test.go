@@ -6,12 +6,15 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -57,7 +60,7 @@ func TestBoolFlagValueFromCommand(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.Bool("trueflag", true, "doc")
 	set.Bool("falseflag", false, "doc")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 	tf := &BoolFlag{Name: "trueflag"}
 	ff := &BoolFlag{Name: "falseflag"}
 
@@ -446,6 +449,21 @@ func TestFlagStringifying(t *testing.T) {
 			fl:       &DurationFlag{Name: "feels-about", DefaultText: "whimsically"},
 			expected: "--feels-about duration\t(default: whimsically)",
 		},
+		{
+			name:     "string-flag-sensitive",
+			fl:       &StringFlag{Name: "token", Value: "s3cr3t", Sensitive: true},
+			expected: "--token string\t",
+		},
+		{
+			name:     "string-flag-sensitive-with-default-text",
+			fl:       &StringFlag{Name: "password", Value: "hunter2", DefaultText: "hunter2", Sensitive: true},
+			expected: "--password string\t",
+		},
+		{
+			name:     "string-flag-hide-default",
+			fl:       &StringFlag{Name: "user", Value: "admin", HideDefault: true},
+			expected: "--user string\t",
+		},
 		{
 			name:     "float64-flag",
 			fl:       &FloatFlag{Name: "arduous"},
@@ -604,6 +622,17 @@ var stringFlagTests = []struct {
 	{"config", []string{"c"}, "Load configuration from `CONFIG`", "config.json", "--config CONFIG, -c CONFIG\tLoad configuration from CONFIG (default: \"config.json\")"},
 }
 
+func TestStringFlagPlaceholder(t *testing.T) {
+	fl := &StringFlag{Name: "listen", Usage: "address to listen on", Placeholder: "HOST:PORT"}
+	assert.Equal(t, "--listen HOST:PORT\taddress to listen on", fl.String())
+
+	fl = &StringFlag{Name: "config", Usage: "load configuration from `FILE`", Placeholder: "PATH"}
+	assert.Equal(t, "--config PATH\tload configuration from FILE", fl.String())
+
+	sfl := &StringSliceFlag{Name: "host", Placeholder: "HOST"}
+	assert.Equal(t, "--host HOST [ --host HOST ]\t", sfl.String())
+}
+
 func TestStringFlagHelpOutput(t *testing.T) {
 	for _, test := range stringFlagTests {
 		fl := &StringFlag{Name: test.name, Aliases: test.aliases, Usage: test.usage, Value: test.value}
@@ -620,6 +649,152 @@ func TestStringFlagDefaultText(t *testing.T) {
 	assert.Equal(t, expected, fl.String())
 }
 
+func TestFlagDefaultFunc(t *testing.T) {
+	calls := 0
+	fl := &StringFlag{
+		Name:  "user",
+		Usage: "user to connect as",
+		DefaultFunc: func() (string, error) {
+			calls++
+			return "gopher", nil
+		},
+	}
+	assert.Equal(t, "--user string\tuser to connect as (default: \"gopher\")", fl.String())
+
+	cmd := &Command{
+		Flags: []Flag{fl},
+		Action: func(_ context.Context, cmd *Command) error {
+			assert.Equal(t, "gopher", cmd.String("user"))
+			assert.False(t, cmd.IsSet("user"))
+			return nil
+		},
+	}
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"run"}))
+	assert.Equal(t, 1, calls, "DefaultFunc should only be called once")
+
+	fl = &StringFlag{
+		Name:        "user",
+		DefaultText: "current user",
+		DefaultFunc: func() (string, error) { return "gopher", nil },
+	}
+	assert.Equal(t, "--user string\t(default: current user)", fl.String())
+
+	ifl := &IntFlag{
+		Name:        "port",
+		DefaultFunc: func() (int64, error) { return 0, errors.New("no free port") },
+	}
+	cmd = &Command{
+		Flags: []Flag{ifl},
+		Action: func(_ context.Context, cmd *Command) error {
+			assert.Equal(t, int64(80), cmd.Int("port"))
+			return nil
+		},
+	}
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"run", "--port", "80"}))
+
+	cmd = cmd.Clone()
+	cmd.Flags[0].(*IntFlag).Sources = NewValueSourceChain(&staticValueSource{v: "8080"})
+	cmd.Action = func(_ context.Context, cmd *Command) error {
+		assert.Equal(t, int64(8080), cmd.Int("port"))
+		return nil
+	}
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"run"}))
+
+	ifl = &IntFlag{
+		Name:        "port",
+		DefaultFunc: func() (int64, error) { return 0, errors.New("no free port") },
+	}
+	cmd = &Command{
+		Flags:  []Flag{ifl},
+		Action: func(_ context.Context, cmd *Command) error { return nil },
+	}
+	err := cmd.Run(buildTestContext(t), []string{"run"})
+	assert.ErrorContains(t, err, "could not compute default value for flag port: no free port")
+}
+
+func TestLogLevelFlag(t *testing.T) {
+	tests := []struct {
+		arg      string
+		expected slog.Level
+		err      bool
+	}{
+		{arg: "debug", expected: slog.LevelDebug},
+		{arg: "INFO", expected: slog.LevelInfo},
+		{arg: "warn", expected: slog.LevelWarn},
+		{arg: "warning", expected: slog.LevelWarn},
+		{arg: "error", expected: slog.LevelError},
+		{arg: "info+2", expected: slog.LevelInfo + 2},
+		{arg: "-4", expected: slog.LevelDebug},
+		{arg: "12", expected: slog.Level(12)},
+		{arg: "verbose", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.arg, func(t *testing.T) {
+			cmd := &Command{
+				Flags: []Flag{&LogLevelFlag{Name: "log-level"}},
+				Action: func(_ context.Context, cmd *Command) error {
+					assert.Equal(t, test.expected, cmd.Level("log-level"))
+					return nil
+				},
+			}
+			err := cmd.Run(buildTestContext(t), []string{"run", "--log-level", test.arg})
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	fl := &LogLevelFlag{Name: "log-level", Usage: "minimum log level", Value: slog.LevelWarn}
+	assert.Equal(t, "--log-level level\tminimum log level (default: warn)", fl.String())
+	assert.Equal(t, []string{"debug", "info", "warn", "error"}, fl.ValueCompletions())
+	assert.Nil(t, (&StringFlag{Name: "foo"}).ValueCompletions())
+
+	cmd := &Command{}
+	assert.Equal(t, slog.LevelInfo, cmd.Level("log-level"))
+}
+
+func TestFlagExpandEnv(t *testing.T) {
+	t.Setenv("APP_HOME", "/home/gopher")
+	t.Setenv("XDG_CONFIG_HOME", "/home/gopher/.config")
+	t.Setenv("APP_CACHE", "${APP_HOME}/cache")
+
+	cmd := &Command{
+		Flags: []Flag{
+			&StringFlag{Name: "config", ExpandEnv: true, Value: "${XDG_CONFIG_HOME}/app.yaml"},
+			&StringFlag{Name: "data", ExpandEnv: true},
+			&StringFlag{Name: "cache", ExpandEnv: true, Sources: EnvVars("APP_CACHE")},
+			&StringFlag{Name: "price"},
+			&StringSliceFlag{Name: "path", ExpandEnv: true},
+			&StringFlag{
+				Name:      "custom",
+				ExpandEnv: true,
+				ExpandLookup: func(key string) (string, bool) {
+					return strings.ToLower(key), true
+				},
+			},
+		},
+		Action: func(_ context.Context, cmd *Command) error {
+			assert.Equal(t, "/home/gopher/.config/app.yaml", cmd.String("config"))
+			assert.Equal(t, "/home/gopher/data", cmd.String("data"))
+			assert.Equal(t, "/home/gopher/cache", cmd.String("cache"))
+			assert.Equal(t, "$5", cmd.String("price"))
+			assert.Equal(t, []string{"/home/gopher/bin", "/usr/bin"}, cmd.StringSlice("path"))
+			assert.Equal(t, "foo-bar", cmd.String("custom"))
+			return nil
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{
+		"run",
+		"--data", "$APP_HOME/data",
+		"--price", "$5",
+		"--path", "$APP_HOME/bin,/usr/bin",
+		"--custom", "${FOO}-${BAR}",
+	}))
+}
+
 func TestStringFlagWithEnvVarHelpOutput(t *testing.T) {
 	defer resetEnv(os.Environ())
 	os.Clearenv()
@@ -678,7 +853,7 @@ func TestStringFlagApply_SetsAllNames(t *testing.T) {
 func TestStringFlagValueFromCommand(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.String("myflag", "foobar", "doc")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 	f := &StringFlag{Name: "myflag"}
 	require.Equal(t, "foobar", cmd.String(f.Name))
 }
@@ -810,7 +985,7 @@ func TestStringSliceFlagApply_DefaultValueWithDestination(t *testing.T) {
 func TestStringSliceFlagValueFromCommand(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.Var(NewStringSlice("a", "b", "c"), "myflag", "doc")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 	f := &StringSliceFlag{Name: "myflag"}
 	require.Equal(t, []string{"a", "b", "c"}, cmd.StringSlice(f.Name))
 }
@@ -864,7 +1039,7 @@ func TestIntFlagApply_SetsAllNames(t *testing.T) {
 func TestIntFlagValueFromCommand(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.Int64("myflag", int64(42), "doc")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 	fl := &IntFlag{Name: "myflag"}
 	require.Equal(t, int64(42), cmd.Int(fl.Name))
 }
@@ -907,7 +1082,7 @@ func TestUintFlagWithEnvVarHelpOutput(t *testing.T) {
 func TestUintFlagValueFromCommand(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.Uint64("myflag", 42, "doc")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 	fl := &UintFlag{Name: "myflag"}
 	require.Equal(t, uint64(42), cmd.Uint(fl.Name))
 }
@@ -950,7 +1125,7 @@ func TestUint64FlagWithEnvVarHelpOutput(t *testing.T) {
 func TestUint64FlagValueFromCommand(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.Uint64("myflag", 42, "doc")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 	f := &UintFlag{Name: "myflag"}
 	require.Equal(t, uint64(42), cmd.Uint(f.Name))
 }
@@ -1004,7 +1179,7 @@ func TestDurationFlagApply_SetsAllNames(t *testing.T) {
 func TestDurationFlagValueFromCommand(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.Duration("myflag", 42*time.Second, "doc")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 	f := &DurationFlag{Name: "myflag"}
 	require.Equal(t, 42*time.Second, cmd.Duration(f.Name))
 }
@@ -1117,9 +1292,9 @@ func TestIntSliceFlag_SetFromParentCommand(t *testing.T) {
 	_ = fl.Apply(set)
 	cmd := &Command{
 		parent: &Command{
-			flagSet: set,
+			runState: runState{flagSet: set},
 		},
-		flagSet: flag.NewFlagSet("empty", 0),
+		runState: runState{flagSet: flag.NewFlagSet("empty", 0)},
 	}
 
 	require.Equalf(t, []int64{1, 2, 3, 4}, cmd.IntSlice("numbers"), "child context unable to view parent flag")
@@ -1128,7 +1303,7 @@ func TestIntSliceFlag_SetFromParentCommand(t *testing.T) {
 func TestIntSliceFlagValueFromCommand(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.Var(NewIntSlice(1, 2, 3), "myflag", "doc")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 	f := &IntSliceFlag{Name: "myflag"}
 	require.Equal(t, []int64{1, 2, 3}, cmd.IntSlice(f.Name))
 }
@@ -1251,9 +1426,9 @@ func TestUintSliceFlag_SetFromParentCommand(t *testing.T) {
 
 	cmd := &Command{
 		parent: &Command{
-			flagSet: set,
+			runState: runState{flagSet: set},
 		},
-		flagSet: flag.NewFlagSet("empty", 0),
+		runState: runState{flagSet: flag.NewFlagSet("empty", 0)},
 	}
 
 	r.Equalf(
@@ -1270,9 +1445,9 @@ func TestUintSliceFlag_ReturnNil(t *testing.T) {
 	r.NoError(fl.Apply(set))
 	cmd := &Command{
 		parent: &Command{
-			flagSet: set,
+			runState: runState{flagSet: set},
 		},
-		flagSet: flag.NewFlagSet("empty", 0),
+		runState: runState{flagSet: flag.NewFlagSet("empty", 0)},
 	}
 	r.Equalf(
 		[]uint64(nil),
@@ -1398,9 +1573,9 @@ func TestUint64SliceFlag_SetFromParentCommand(t *testing.T) {
 	r.NoError(fl.Apply(set))
 	cmd := &Command{
 		parent: &Command{
-			flagSet: set,
+			runState: runState{flagSet: set},
 		},
-		flagSet: flag.NewFlagSet("empty", 0),
+		runState: runState{flagSet: flag.NewFlagSet("empty", 0)},
 	}
 	r.Equalf(
 		[]uint64{1, 2, 3, 4}, cmd.UintSlice("numbers"),
@@ -1415,9 +1590,9 @@ func TestUint64SliceFlag_ReturnNil(t *testing.T) {
 	r.NoError(fl.Apply(set))
 	cmd := &Command{
 		parent: &Command{
-			flagSet: set,
+			runState: runState{flagSet: set},
 		},
-		flagSet: flag.NewFlagSet("empty", 0),
+		runState: runState{flagSet: flag.NewFlagSet("empty", 0)},
 	}
 	r.Equalf(
 		[]uint64(nil), cmd.UintSlice("numbers"),
@@ -1470,7 +1645,7 @@ func TestFloat64FlagApply_SetsAllNames(t *testing.T) {
 func TestFloat64FlagValueFromCommand(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.Float64("myflag", 1.23, "doc")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 	f := &FloatFlag{Name: "myflag"}
 	require.Equal(t, 1.23, cmd.Float(f.Name))
 }
@@ -1567,7 +1742,7 @@ func TestFloat64SliceFlagApply_DefaultValueWithDestination(t *testing.T) {
 func TestFloat64SliceFlagValueFromCommand(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.Var(NewFloatSlice(1.23, 4.56), "myflag", "doc")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 	f := &FloatSliceFlag{Name: "myflag"}
 	require.Equal(t, []float64{1.23, 4.56}, cmd.FloatSlice(f.Name))
 }
@@ -1589,6 +1764,81 @@ func TestFloat64SliceFlagApply_ParentCommand(t *testing.T) {
 	}).Run(buildTestContext(t), []string{"run", "child"})
 }
 
+func TestSizedNumericFlags(t *testing.T) {
+	r := require.New(t)
+
+	cmd := &Command{
+		Flags: []Flag{
+			&Int8Flag{Name: "i8"},
+			&Int16Flag{Name: "i16"},
+			&Int32Flag{Name: "i32"},
+			&Uint8Flag{Name: "u8"},
+			&Uint16Flag{Name: "u16"},
+			&Uint32Flag{Name: "u32"},
+			&Float32Flag{Name: "f32"},
+			&Int32SliceFlag{Name: "i32s"},
+			&Uint32SliceFlag{Name: "u32s"},
+			&Float32SliceFlag{Name: "f32s"},
+		},
+		Action: func(context.Context, *Command) error { return nil },
+	}
+
+	r.NoError(cmd.Run(buildTestContext(t), []string{
+		"run",
+		"--i8", "-128", "--i16", "32767", "--i32", "-7",
+		"--u8", "255", "--u16", "65535", "--u32", "4294967295",
+		"--f32", "1.5",
+		"--i32s", "1,-2", "--u32s", "3", "--u32s", "4", "--f32s", "0.25",
+	}))
+
+	r.Equal(int8(-128), cmd.Int8("i8"))
+	r.Equal(int16(32767), cmd.Int16("i16"))
+	r.Equal(int32(-7), cmd.Int32("i32"))
+	r.Equal(uint8(255), cmd.Uint8("u8"))
+	r.Equal(uint16(65535), cmd.Uint16("u16"))
+	r.Equal(uint32(4294967295), cmd.Uint32("u32"))
+	r.Equal(float32(1.5), cmd.Float32("f32"))
+	r.Equal([]int32{1, -2}, cmd.Int32Slice("i32s"))
+	r.Equal([]uint32{3, 4}, cmd.Uint32Slice("u32s"))
+	r.Equal([]float32{0.25}, cmd.Float32Slice("f32s"))
+
+	r.Equal(int8(0), cmd.Int8("i16"))
+	r.Nil(cmd.Int32Slice("u32s"))
+}
+
+func TestSizedNumericFlagsOverflow(t *testing.T) {
+	tests := []struct {
+		fl  Flag
+		arg string
+	}{
+		{&Int8Flag{Name: "n"}, "128"},
+		{&Int16Flag{Name: "n"}, "-32769"},
+		{&Int32Flag{Name: "n"}, "2147483648"},
+		{&Uint8Flag{Name: "n"}, "256"},
+		{&Uint16Flag{Name: "n"}, "65536"},
+		{&Uint32Flag{Name: "n"}, "4294967296"},
+		{&Float32Flag{Name: "n"}, "1e39"},
+		{&Int32SliceFlag{Name: "n"}, "1,2147483648"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%T", test.fl), func(t *testing.T) {
+			err := (&Command{
+				Flags:     []Flag{test.fl},
+				Writer:    io.Discard,
+				ErrWriter: io.Discard,
+			}).Run(buildTestContext(t), []string{"run", "--n", test.arg})
+			require.ErrorContains(t, err, "value out of range")
+		})
+	}
+}
+
+func TestSizedNumericFlagHelpOutput(t *testing.T) {
+	assert.Equal(t, "--port int\t(default: 8080)", (&Int16Flag{Name: "port", Value: 8080}).String())
+	assert.Equal(t, "--mask uint\t(default: 255)", (&Uint8Flag{Name: "mask", Value: 255}).String())
+	assert.Equal(t, "--ratio float\t(default: 0.1)", (&Float32Flag{Name: "ratio", Value: 0.1}).String())
+}
+
 var genericFlagTests = []struct {
 	name     string
 	value    Value
@@ -1820,6 +2070,32 @@ func TestParseMultiIntSliceWithDestinationAndEnv(t *testing.T) {
 	}).Run(buildTestContext(t), []string{"run", "-s", "10", "-s", "20"})
 }
 
+func TestParseMultiValueFlagsWithDestination(t *testing.T) {
+	t.Setenv("APP_LABELS", "env=prod,team=core")
+	t.Setenv("APP_TIMEOUTS", "1s,2s")
+
+	labels := map[string]string{}
+	timeouts := []time.Duration{}
+	ports := []uint32{}
+	tags := []string{}
+	cmd := &Command{
+		Flags: []Flag{
+			&StringMapFlag{Name: "label", Destination: &labels, Sources: EnvVars("APP_LABELS")},
+			&DurationSliceFlag{Name: "timeout", Destination: &timeouts, Sources: EnvVars("APP_TIMEOUTS")},
+			&Uint32SliceFlag{Name: "port", Destination: &ports},
+			&StringSliceFlag{Name: "tag", Destination: &tags, Value: []string{"default"}},
+		},
+		Action: func(context.Context, *Command) error {
+			assert.Equal(t, map[string]string{"env": "prod", "team": "core"}, labels)
+			assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, timeouts)
+			assert.Equal(t, []uint32{80, 443}, ports)
+			assert.Equal(t, []string{"default"}, tags)
+			return nil
+		},
+	}
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"run", "--port", "80", "--port", "443"}))
+}
+
 func TestParseMultiStringSliceWithDefaultsUnset(t *testing.T) {
 	_ = (&Command{
 		Flags: []Flag{
@@ -2662,7 +2938,7 @@ func TestTimestampFlagValueFromCommand(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	now := time.Now()
 	set.Var(newTimestamp(now), "myflag", "doc")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 	f := &TimestampFlag{Name: "myflag"}
 	require.Equal(t, now, cmd.Timestamp(f.Name))
 }
@@ -2953,6 +3229,204 @@ func TestTimestampFlagApply_WithDestination(t *testing.T) {
 	assert.Equal(t, expectedResult, destination)
 }
 
+var durationSliceFlagTests = []struct {
+	name     string
+	aliases  []string
+	value    []time.Duration
+	expected string
+}{
+	{"window", nil, []time.Duration{}, "--window duration [ --window duration ]\t"},
+	{"w", nil, []time.Duration{}, "-w duration [ -w duration ]\t"},
+	{
+		"window",
+		[]string{"w"},
+		[]time.Duration{5 * time.Minute, 30 * time.Minute},
+		"--window duration, -w duration [ --window duration, -w duration ]\t(default: 5m0s, 30m0s)",
+	},
+}
+
+func TestDurationSliceFlagHelpOutput(t *testing.T) {
+	for _, test := range durationSliceFlagTests {
+		fl := &DurationSliceFlag{Name: test.name, Aliases: test.aliases, Value: test.value}
+		assert.Equal(t, test.expected, fl.String())
+	}
+}
+
+func TestDurationSliceFlagApply_UsesEnvValues(t *testing.T) {
+	t.Setenv("MY_WINDOWS", "5m , 30m")
+
+	fl := &DurationSliceFlag{Name: "window", Sources: EnvVars("MY_WINDOWS"), Value: []time.Duration{time.Hour}}
+	set := flag.NewFlagSet("test", 0)
+
+	r := require.New(t)
+	r.NoError(fl.Apply(set))
+	r.NoError(set.Parse(nil))
+	r.NoError(fl.PostParse())
+	r.Equal([]time.Duration{5 * time.Minute, 30 * time.Minute}, set.Lookup("window").Value.(flag.Getter).Get())
+}
+
+func TestParseMultiDurationSlice(t *testing.T) {
+	var dest []time.Duration
+	r := require.New(t)
+
+	r.NoError((&Command{
+		Flags: []Flag{
+			&DurationSliceFlag{Name: "window", Aliases: []string{"w"}, Destination: &dest},
+		},
+		Action: func(_ context.Context, cmd *Command) error {
+			expected := []time.Duration{5 * time.Minute, 30 * time.Minute}
+			r.Equal(expected, cmd.DurationSlice("window"))
+			r.Equal(expected, cmd.DurationSlice("w"))
+			r.Equal(expected, dest)
+			return nil
+		},
+	}).Run(buildTestContext(t), []string{"run", "--window", "5m", "-w", "30m"}))
+}
+
+func TestParseMultiDurationSlice_Invalid(t *testing.T) {
+	err := (&Command{
+		Flags: []Flag{
+			&DurationSliceFlag{Name: "window"},
+		},
+		Action: func(context.Context, *Command) error { return nil },
+		Writer: io.Discard, ErrWriter: io.Discard,
+	}).Run(buildTestContext(t), []string{"run", "--window", "forever"})
+	require.ErrorContains(t, err, "invalid duration")
+}
+
+func TestParseMultiTimestampSlice(t *testing.T) {
+	first, _ := time.Parse(time.RFC3339, "2006-01-02T15:04:05Z")
+	second, _ := time.Parse(time.RFC3339, "2007-01-02T15:04:05Z")
+	r := require.New(t)
+
+	r.NoError((&Command{
+		Flags: []Flag{
+			&TimestampSliceFlag{Name: "at", Config: TimestampConfig{Layouts: []string{time.RFC3339}}},
+		},
+		Action: func(_ context.Context, cmd *Command) error {
+			r.Equal([]time.Time{first, second}, cmd.TimestampSlice("at"))
+			return nil
+		},
+	}).Run(buildTestContext(t), []string{"run", "--at", "2006-01-02T15:04:05Z", "--at", "2007-01-02T15:04:05Z"}))
+}
+
+func TestParseMultiTimestampSliceFromEnv(t *testing.T) {
+	t.Setenv("APP_AT", "2006-01-02T15:04:05Z,2007-01-02T15:04:05Z")
+	first, _ := time.Parse(time.RFC3339, "2006-01-02T15:04:05Z")
+	second, _ := time.Parse(time.RFC3339, "2007-01-02T15:04:05Z")
+	r := require.New(t)
+
+	r.NoError((&Command{
+		Flags: []Flag{
+			&TimestampSliceFlag{Name: "at", Sources: EnvVars("APP_AT"), Config: TimestampConfig{Layouts: []string{time.RFC3339}}},
+		},
+		Action: func(_ context.Context, cmd *Command) error {
+			r.Equal([]time.Time{first, second}, cmd.TimestampSlice("at"))
+			return nil
+		},
+	}).Run(buildTestContext(t), []string{"run"}))
+}
+
+func TestPathFlag(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("ok"), 0o644))
+
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		config      PathConfig
+		arg         string
+		expected    string
+		errContains string
+	}{
+		{
+			name:     "no constraints",
+			arg:      "does-not-exist",
+			expected: "does-not-exist",
+		},
+		{
+			name:     "make absolute",
+			config:   PathConfig{MakeAbsolute: true},
+			arg:      file,
+			expected: file,
+		},
+		{
+			name:     "expand tilde",
+			config:   PathConfig{ExpandTilde: true},
+			arg:      "~/foo",
+			expected: filepath.Join(home, "foo"),
+		},
+		{
+			name:        "must exist",
+			config:      PathConfig{MustExist: true},
+			arg:         filepath.Join(dir, "missing"),
+			errContains: "does not exist",
+		},
+		{
+			name:     "must be dir",
+			config:   PathConfig{MustBeDir: true},
+			arg:      dir,
+			expected: dir,
+		},
+		{
+			name:        "must be dir with file",
+			config:      PathConfig{MustBeDir: true},
+			arg:         file,
+			errContains: "is not a directory",
+		},
+		{
+			name:     "must be writable",
+			config:   PathConfig{MustBeWritable: true},
+			arg:      file,
+			expected: file,
+		},
+		{
+			name:     "must be writable with missing file in writable dir",
+			config:   PathConfig{MustBeWritable: true},
+			arg:      filepath.Join(dir, "new.txt"),
+			expected: filepath.Join(dir, "new.txt"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := require.New(t)
+			cmd := &Command{
+				Flags: []Flag{
+					&PathFlag{Name: "path", Config: test.config},
+				},
+				Writer:    io.Discard,
+				ErrWriter: io.Discard,
+			}
+
+			err := cmd.Run(buildTestContext(t), []string{"run", "--path", test.arg})
+			if test.errContains != "" {
+				r.ErrorContains(err, test.errContains)
+				return
+			}
+
+			r.NoError(err)
+			r.Equal(test.expected, cmd.Path("path"))
+		})
+	}
+}
+
+func TestPathFlagDefaultIsResolved(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	fl := &PathFlag{Name: "config", Value: "~/.apprc", Config: PathConfig{ExpandTilde: true, MustExist: true}}
+	set := flag.NewFlagSet("test", 0)
+
+	r := require.New(t)
+	r.NoError(fl.Apply(set))
+	r.NoError(set.Parse(nil))
+	r.Equal(filepath.Join(home, ".apprc"), set.Lookup("config").Value.(flag.Getter).Get())
+}
+
 // Test issue #1254
 // StringSlice() with UseShortOptionHandling causes duplicated entries, depending on the ordering of the flags
 func TestSliceShortOptionHandle(t *testing.T) {
@@ -2993,12 +3467,8 @@ func TestSliceShortOptionHandle(t *testing.T) {
 
 // Test issue #1541
 func TestCustomizedSliceFlagSeparator(t *testing.T) {
-	defaultSliceFlagSeparator = ";"
-	defer func() {
-		defaultSliceFlagSeparator = ","
-	}()
 	opts := []string{"opt1", "opt2", "opt3,op", "opt4"}
-	ret := flagSplitMultiValues(strings.Join(opts, ";"))
+	ret := multiValueSeparator{cmdSep: ";"}.split(strings.Join(opts, ";"))
 	require.Equal(t, 4, len(ret), "split slice flag failed")
 	for idx, r := range ret {
 		require.Equal(t, opts[idx], r, "get %dth failed", idx)
@@ -3006,17 +3476,89 @@ func TestCustomizedSliceFlagSeparator(t *testing.T) {
 }
 
 func TestFlagSplitMultiValues_Disabled(t *testing.T) {
-	disableSliceFlagSeparator = true
-	defer func() {
-		disableSliceFlagSeparator = false
-	}()
-
 	opts := []string{"opt1", "opt2", "opt3,op", "opt4"}
-	ret := flagSplitMultiValues(strings.Join(opts, defaultSliceFlagSeparator))
+	ret := multiValueSeparator{cmdDisabled: true}.split(strings.Join(opts, defaultSliceFlagSeparator))
 	require.Equal(t, 1, len(ret), "failed to disable split slice flag")
 	require.Equal(t, strings.Join(opts, defaultSliceFlagSeparator), ret[0])
 }
 
+func TestFlagSplitMultiValues_Escaped(t *testing.T) {
+	ret := multiValueSeparator{}.split(`CN=foo\,O=bar,CN=baz`)
+	require.Equal(t, []string{"CN=foo,O=bar", "CN=baz"}, ret)
+
+	ret = multiValueSeparator{}.split(`C:\dir,D:\other`)
+	require.Equal(t, []string{`C:\dir`, `D:\other`}, ret)
+}
+
+func TestSliceFlagSeparator_Concurrent(t *testing.T) {
+	newCmd := func(sep string, disabled bool) *Command {
+		return &Command{
+			Name:                      "foo",
+			SliceFlagSeparator:        sep,
+			DisableSliceFlagSeparator: disabled,
+			Flags:                     []Flag{&StringSliceFlag{Name: "opt"}},
+			Commands: []*Command{{
+				Name:   "bar",
+				Action: func(context.Context, *Command) error { return nil },
+			}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		sep      string
+		disabled bool
+		arg      string
+		expected []string
+	}{
+		{name: "default", arg: "a,b;c", expected: []string{"a", "b;c"}},
+		{name: "custom", sep: ";", arg: "a,b;c", expected: []string{"a,b", "c"}},
+		{name: "disabled", disabled: true, arg: "a,b;c", expected: []string{"a,b;c"}},
+	}
+
+	var wg sync.WaitGroup
+	for _, test := range tests {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cmd := newCmd(test.sep, test.disabled)
+				if err := cmd.Run(context.Background(), []string{"foo", "bar", "--opt", test.arg}); err != nil {
+					t.Errorf("%s: %v", test.name, err)
+					return
+				}
+				if got := cmd.StringSlice("opt"); !reflect.DeepEqual(got, test.expected) {
+					t.Errorf("%s: expected %q, got %q", test.name, test.expected, got)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func TestSliceFlagPerFlagSeparator(t *testing.T) {
+	t.Setenv("APP_SANS", `a.example.com;b.example.com\;c`)
+	t.Setenv("APP_DNS", "CN=foo,O=bar")
+	t.Setenv("APP_LABELS", "a=1|b=2")
+
+	r := require.New(t)
+	r.NoError((&Command{
+		Flags: []Flag{
+			&StringSliceFlag{Name: "san", Separator: ";", Sources: EnvVars("APP_SANS")},
+			&StringSliceFlag{Name: "dn", DisableSeparator: true, Sources: EnvVars("APP_DNS")},
+			&StringMapFlag{Name: "label", Separator: "|", Sources: EnvVars("APP_LABELS")},
+			&StringSliceFlag{Name: "other"},
+		},
+		Action: func(_ context.Context, cmd *Command) error {
+			r.Equal([]string{"a.example.com", "b.example.com;c"}, cmd.StringSlice("san"))
+			r.Equal([]string{"CN=foo,O=bar"}, cmd.StringSlice("dn"))
+			r.Equal(map[string]string{"a": "1", "b": "2"}, cmd.StringMap("label"))
+			r.Equal([]string{"x", "y"}, cmd.StringSlice("other"))
+			return nil
+		},
+	}).Run(buildTestContext(t), []string{"run", "--other", "x,y"}))
+}
+
 var stringMapFlagTests = []struct {
 	name     string
 	aliases  []string
@@ -3109,7 +3651,7 @@ func TestStringMapFlagApply_DefaultValueWithDestination(t *testing.T) {
 func TestStringMapFlagValueFromCommand(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.Var(NewStringMap(map[string]string{"a": "b", "c": ""}), "myflag", "doc")
-	cmd := &Command{flagSet: set}
+	cmd := &Command{runState: runState{flagSet: set}}
 	f := &StringMapFlag{Name: "myflag"}
 	require.Equal(t, map[string]string{"a": "b", "c": ""}, cmd.StringMap(f.Name))
 }
@@ -3131,7 +3673,7 @@ func TestZeroValueMutexFlag(t *testing.T) {
 func TestExtFlag(t *testing.T) {
 	fs := flag.NewFlagSet("foo", flag.ContinueOnError)
 
-	var iv intValue
+	var iv intValue[int64]
 	var ipv int64
 
 	f := &flag.Flag{
@@ -3204,12 +3746,12 @@ func TestFlagsByName(t *testing.T) {
 
 func TestNonStringMap(t *testing.T) {
 	type (
-		floatMap = MapBase[float64, NoConfig, floatValue]
+		floatMap = MapBase[float64, NoConfig, floatValue[float64]]
 	)
 
 	p := map[string]float64{}
 
-	var fv floatValue
+	var fv floatValue[float64]
 
 	f := &floatMap{
 		value: &fv,
@@ -3269,7 +3811,7 @@ func TestGenericValue_SatisfiesBoolInterface(t *testing.T) {
 
 	assert.False(t, f.IsBoolFlag())
 
-	fv := floatValue(0)
+	fv := floatValue[float64]{val: new(float64)}
 	f = &genericValue{
 		val: &fv,
 	}
@@ -3312,3 +3854,190 @@ func TestGenericValue(t *testing.T) {
 	assert.Nil(t, g.Get())
 	assert.Empty(t, g.String())
 }
+
+// countingValueSource counts how often it is looked up
+type countingValueSource struct {
+	staticValueSource
+	lookups int
+}
+
+func (cvs *countingValueSource) Lookup() (string, bool) {
+	cvs.lookups++
+	return cvs.staticValueSource.Lookup()
+}
+
+func TestFlagSourcesDeferred(t *testing.T) {
+	newCommand := func(src ValueSource, action ActionFunc) *Command {
+		return &Command{
+			Name: "app",
+			Flags: []Flag{
+				&StringFlag{Name: "region", Sources: NewValueSourceChain(src)},
+			},
+			Commands: []*Command{
+				{Name: "sub", Action: action},
+			},
+			Action: action,
+		}
+	}
+
+	t.Run("not read for help", func(t *testing.T) {
+		src := &countingValueSource{staticValueSource: staticValueSource{v: "eu"}}
+		cmd := newCommand(src, nil)
+		cmd.Writer = io.Discard
+
+		require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "--help"}))
+		assert.Zero(t, src.lookups)
+	})
+
+	t.Run("read once when needed", func(t *testing.T) {
+		src := &countingValueSource{staticValueSource: staticValueSource{v: "eu"}}
+		cmd := newCommand(src, func(_ context.Context, cmd *Command) error {
+			assert.True(t, cmd.IsSet("region"))
+			assert.Equal(t, "eu", cmd.String("region"))
+			assert.Equal(t, "eu", cmd.String("region"))
+			return nil
+		})
+
+		require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "sub"}))
+		assert.Equal(t, 1, src.lookups)
+	})
+
+	t.Run("not read when set on command line", func(t *testing.T) {
+		src := &countingValueSource{staticValueSource: staticValueSource{v: "eu"}}
+		cmd := newCommand(src, func(_ context.Context, cmd *Command) error {
+			assert.Equal(t, "us", cmd.String("region"))
+			return nil
+		})
+
+		require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "--region", "us", "sub"}))
+		assert.Zero(t, src.lookups)
+	})
+
+	t.Run("invalid value fails before action", func(t *testing.T) {
+		called := false
+		cmd := &Command{
+			Name: "app",
+			Flags: []Flag{
+				&IntFlag{Name: "port", Sources: NewValueSourceChain(&staticValueSource{v: "http"})},
+			},
+			Action: func(context.Context, *Command) error {
+				called = true
+				return nil
+			},
+		}
+
+		err := cmd.Run(buildTestContext(t), []string{"app"})
+		assert.ErrorContains(t, err, `could not parse "http"`)
+		assert.False(t, called)
+	})
+
+	t.Run("invalid value of parent fails before before", func(t *testing.T) {
+		called := false
+		cmd := &Command{
+			Name: "app",
+			Flags: []Flag{
+				&IntFlag{Name: "port", Sources: NewValueSourceChain(&staticValueSource{v: "http"})},
+			},
+			Commands: []*Command{
+				{
+					Name: "sub",
+					Before: func(ctx context.Context, _ *Command) (context.Context, error) {
+						called = true
+						return ctx, nil
+					},
+					Action: func(context.Context, *Command) error {
+						called = true
+						return nil
+					},
+				},
+			},
+		}
+
+		err := cmd.Run(buildTestContext(t), []string{"app", "sub"})
+		assert.ErrorContains(t, err, `could not parse "http"`)
+		assert.False(t, called)
+	})
+}
+
+// newAccessorsCommand returns a subcommand after running it with a flag of
+// each of the scalar types, the string flag inherited from the root
+func newAccessorsCommand(t testing.TB) *Command {
+	var sub *Command
+	cmd := &Command{
+		Name: "app",
+		Flags: []Flag{
+			&StringFlag{Name: "name"},
+		},
+		Commands: []*Command{
+			{
+				Name: "sub",
+				Flags: []Flag{
+					&IntFlag{Name: "count"},
+					&BoolFlag{Name: "force"},
+					&DurationFlag{Name: "timeout"},
+					&FloatFlag{Name: "ratio"},
+				},
+				Action: func(_ context.Context, cmd *Command) error {
+					sub = cmd
+					return nil
+				},
+			},
+		},
+	}
+
+	args := []string{"app", "--name", "gopher", "sub", "--count", "1000", "--force", "--timeout", "90s", "--ratio", "0.5"}
+	require.NoError(t, cmd.Run(context.Background(), args))
+	return sub
+}
+
+func TestCommand_AccessorsDoNotAllocate(t *testing.T) {
+	if isTracingOn {
+		t.Skip("tracing allocates")
+	}
+
+	cmd := newAccessorsCommand(t)
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = cmd.String("name")
+		_ = cmd.Int("count")
+		_ = cmd.Bool("force")
+		_ = cmd.Duration("timeout")
+		_ = cmd.Float("ratio")
+	})
+	assert.Zero(t, allocs)
+
+	assert.Equal(t, "gopher", cmd.String("name"))
+	assert.Equal(t, int64(1000), cmd.Int("count"))
+	assert.True(t, cmd.Bool("force"))
+	assert.Equal(t, 90*time.Second, cmd.Duration("timeout"))
+	assert.Equal(t, 0.5, cmd.Float("ratio"))
+	assert.Zero(t, cmd.Int("name"))
+}
+
+func BenchmarkCommand_Accessors(b *testing.B) {
+	cmd := newAccessorsCommand(b)
+
+	b.Run("String", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = cmd.String("name")
+		}
+	})
+	b.Run("Int", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = cmd.Int("count")
+		}
+	})
+	b.Run("Bool", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = cmd.Bool("force")
+		}
+	})
+	b.Run("Duration", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = cmd.Duration("timeout")
+		}
+	})
+}
@@ -0,0 +1,22 @@
+package cli
+
+import "time"
+
+type (
+	TimestampSlice     = SliceBase[time.Time, TimestampConfig, timestampValue]
+	TimestampSliceFlag = FlagBase[[]time.Time, TimestampConfig, TimestampSlice]
+)
+
+var NewTimestampSlice = NewSliceBase[time.Time, TimestampConfig, timestampValue]
+
+// TimestampSlice looks up the value of a local TimestampSliceFlag, returns
+// nil if not found
+func (cmd *Command) TimestampSlice(name string) []time.Time {
+	if v, ok := cmd.Value(name).([]time.Time); ok {
+		tracef("timestamp slice available for flag name %[1]q with value=%[2]v (cmd=%[3]q)", name, v, cmd.Name)
+		return v
+	}
+
+	tracef("timestamp slice NOT available for flag name %[1]q (cmd=%[2]q)", name, cmd.Name)
+	return nil
+}
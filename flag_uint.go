@@ -4,51 +4,68 @@ import (
 	"strconv"
 )
 
-type UintFlag = FlagBase[uint64, IntegerConfig, uintValue]
+type (
+	UintFlag   = FlagBase[uint64, IntegerConfig, uintValue[uint64]]
+	Uint8Flag  = FlagBase[uint8, IntegerConfig, uintValue[uint8]]
+	Uint16Flag = FlagBase[uint16, IntegerConfig, uintValue[uint16]]
+	Uint32Flag = FlagBase[uint32, IntegerConfig, uintValue[uint32]]
+)
 
-// -- uint64 Value
-type uintValue struct {
-	val  *uint64
+// -- unsigned integer Value
+type uintValue[T uint8 | uint16 | uint32 | uint64] struct {
+	val  *T
 	base int
 }
 
 // Below functions are to satisfy the ValueCreator interface
 
-func (i uintValue) Create(val uint64, p *uint64, c IntegerConfig) Value {
+func (i uintValue[T]) Create(val T, p *T, c IntegerConfig) Value {
 	*p = val
-	return &uintValue{
+	return &uintValue[T]{
 		val:  p,
 		base: c.Base,
 	}
 }
 
-func (i uintValue) ToString(b uint64) string {
-	return strconv.FormatUint(b, 10)
+func (i uintValue[T]) ToString(b T) string {
+	return strconv.FormatUint(uint64(b), 10)
 }
 
 // Below functions are to satisfy the flag.Value interface
 
-func (i *uintValue) Set(s string) error {
-	v, err := strconv.ParseUint(s, i.base, 64)
+func (i *uintValue[T]) Set(s string) error {
+	v, err := strconv.ParseUint(s, i.base, bitSizeOf[T]())
 	if err != nil {
 		return err
 	}
-	*i.val = v
+	*i.val = T(v)
 	return err
 }
 
-func (i *uintValue) Get() any { return uint64(*i.val) }
+func (i *uintValue[T]) Get() any { return *i.val }
 
-func (i *uintValue) String() string { return strconv.FormatUint(uint64(*i.val), 10) }
+func (i *uintValue[T]) String() string { return strconv.FormatUint(uint64(*i.val), 10) }
 
 // Uint looks up the value of a local Uint64Flag, returns
 // 0 if not found
 func (cmd *Command) Uint(name string) uint64 {
-	if v, ok := cmd.Value(name).(uint64); ok {
-		tracef("uint available for flag name %[1]q with value=%[2]v (cmd=%[3]q)", name, v, cmd.Name)
-		return v
-	}
+	return flagValue[uint64](cmd, name, "uint")
+}
+
+// Uint8 looks up the value of a local Uint8Flag, returns
+// 0 if not found
+func (cmd *Command) Uint8(name string) uint8 {
+	return flagValue[uint8](cmd, name, "uint8")
+}
 
-	tracef("uint NOT available for flag name %[1]q (cmd=%[2]q)", name, cmd.Name)
-	return 0
+// Uint16 looks up the value of a local Uint16Flag, returns
+// 0 if not found
+func (cmd *Command) Uint16(name string) uint16 {
+	return flagValue[uint16](cmd, name, "uint16")
+}
+
+// Uint32 looks up the value of a local Uint32Flag, returns
+// 0 if not found
+func (cmd *Command) Uint32(name string) uint32 {
+	return flagValue[uint32](cmd, name, "uint32")
 }
@@ -1,11 +1,16 @@
 package cli
 
 type (
-	UintSlice     = SliceBase[uint64, IntegerConfig, uintValue]
-	UintSliceFlag = FlagBase[[]uint64, IntegerConfig, UintSlice]
+	UintSlice       = SliceBase[uint64, IntegerConfig, uintValue[uint64]]
+	UintSliceFlag   = FlagBase[[]uint64, IntegerConfig, UintSlice]
+	Uint32Slice     = SliceBase[uint32, IntegerConfig, uintValue[uint32]]
+	Uint32SliceFlag = FlagBase[[]uint32, IntegerConfig, Uint32Slice]
 )
 
-var NewUintSlice = NewSliceBase[uint64, IntegerConfig, uintValue]
+var (
+	NewUintSlice   = NewSliceBase[uint64, IntegerConfig, uintValue[uint64]]
+	NewUint32Slice = NewSliceBase[uint32, IntegerConfig, uintValue[uint32]]
+)
 
 // UintSlice looks up the value of a local UintSliceFlag, returns
 // nil if not found
@@ -18,3 +23,15 @@ func (cmd *Command) UintSlice(name string) []uint64 {
 	tracef("uint slice NOT available for flag name %[1]q (cmd=%[2]q)", name, cmd.Name)
 	return nil
 }
+
+// Uint32Slice looks up the value of a local Uint32SliceFlag, returns
+// nil if not found
+func (cmd *Command) Uint32Slice(name string) []uint32 {
+	if v, ok := cmd.Value(name).([]uint32); ok {
+		tracef("uint32 slice available for flag name %[1]q with value=%[2]v (cmd=%[3]q)", name, v, cmd.Name)
+		return v
+	}
+
+	tracef("uint32 slice NOT available for flag name %[1]q (cmd=%[2]q)", name, cmd.Name)
+	return nil
+}
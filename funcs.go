@@ -17,6 +17,10 @@ type AfterFunc func(context.Context, *Command) error
 // ActionFunc is the action to execute when no subcommands are specified
 type ActionFunc func(context.Context, *Command) error
 
+// MiddlewareFunc wraps an ActionFunc, e.g. to time it or check permissions
+// before calling next.
+type MiddlewareFunc func(next ActionFunc) ActionFunc
+
 // CommandNotFoundFunc is executed if the proper command cannot be found
 type CommandNotFoundFunc func(context.Context, *Command, string)
 
@@ -33,6 +37,17 @@ type InvalidFlagAccessFunc func(context.Context, *Command, string)
 // returned by Actions and Before/After functions.
 type ExitErrHandlerFunc func(context.Context, *Command, error)
 
+// OnErrorFunc is executed for errors returned by Actions, Before/After and flag
+// action functions before they are handled as exit codes. It may return a
+// different error, e.g. to map it to an exit code or add a hint, or nil to
+// swallow the error.
+type OnErrorFunc func(context.Context, *Command, error) error
+
+// PanicHandlerFunc is executed for panics recovered with Command.Recover, with
+// the command which was running, the value passed to panic and the stack
+// trace. The returned error is returned by Run.
+type PanicHandlerFunc func(ctx context.Context, cmd *Command, value any, stack []byte) error
+
 // FlagStringFunc is used by the help generation to display a flag, which is
 // expected to be a single line.
 type FlagStringFunc func(Flag) string
@@ -1,11 +1,15 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"text/tabwriter"
 	"text/template"
 	"unicode/utf8"
@@ -29,6 +33,9 @@ type helpPrinterCustom func(w io.Writer, templ string, data interface{}, customF
 // overridden. If the ExtraInfo field is defined on an App, this function
 // should not be modified, as HelpPrinterCustom will be used directly in order
 // to capture the extra information.
+//
+// Deprecated: set Command.HelpPrinter instead, which is safe to use when
+// commands run concurrently. HelpPrinter is used if it is not set.
 var HelpPrinter helpPrinter = printHelp
 
 // HelpPrinterCustom is a function that writes the help output. It is used as
@@ -66,6 +73,10 @@ func helpCommandAction(ctx context.Context, cmd *Command) error {
 
 	tracef("doing help for cmd %[1]q with args %[2]q", cmd, args)
 
+	if cmd.helpAllFlag != nil && cmd.Bool(helpAllFlagName) {
+		cmd.Root().helpAll = true
+	}
+
 	// This action can be triggered by a "default" action of a command
 	// or via cmd.Run when cmd == helpCmd. So we have following possibilities
 	//
@@ -86,6 +97,8 @@ func helpCommandAction(ctx context.Context, cmd *Command) error {
 		cmd = cmd.parent
 	}
 
+	cmd.notify(ctx, EventHelpShown, nil)
+
 	// Case 4. $ app help foo
 	// foo is the command for which help needs to be shown
 	if firstArg != "" {
@@ -111,11 +124,11 @@ func helpCommandAction(ctx context.Context, cmd *Command) error {
 
 		tmpl := cmd.CustomHelpTemplate
 		if tmpl == "" {
-			tmpl = CommandHelpTemplate
+			tmpl = cmd.helpTemplate(func(t *HelpTemplates) string { return t.Command }, CommandHelpTemplate)
 		}
 
 		tracef("running HelpPrinter with command %[1]q", cmd.Name)
-		HelpPrinter(cmd.Root().Writer, tmpl, cmd)
+		cmd.helpPrinter()(cmd.Root().Writer, tmpl, cmd)
 
 		return nil
 	}
@@ -135,19 +148,21 @@ func ShowAppHelp(cmd *Command) error {
 	tmpl := cmd.CustomRootCommandHelpTemplate
 	if tmpl == "" {
 		tracef("using RootCommandHelpTemplate")
-		tmpl = RootCommandHelpTemplate
+		tmpl = cmd.helpTemplate(func(t *HelpTemplates) string { return t.Root }, RootCommandHelpTemplate)
 	}
 
-	if cmd.ExtraInfo == nil {
-		HelpPrinter(cmd.Root().Writer, tmpl, cmd.Root())
+	if cmd.ExtraInfo == nil || cmd.Root().HelpPrinter != nil {
+		cmd.helpPrinter()(cmd.Root().Writer, tmpl, cmd.Root())
 		return nil
 	}
 
 	tracef("setting ExtraInfo in customAppData")
 	customAppData := func() map[string]any {
-		return map[string]any{
-			"ExtraInfo": cmd.ExtraInfo,
+		funcs := map[string]any{"ExtraInfo": cmd.ExtraInfo}
+		for name, fn := range cmd.helpTemplateFuncs() {
+			funcs[name] = fn
 		}
+		return funcs
 	}
 	HelpPrinterCustom(cmd.Root().Writer, tmpl, cmd.Root(), customAppData())
 
@@ -161,7 +176,7 @@ func DefaultAppComplete(ctx context.Context, cmd *Command) {
 
 func printCommandSuggestions(commands []*Command, writer io.Writer) {
 	for _, command := range commands {
-		if command.Hidden {
+		if command.isHidden() {
 			continue
 		}
 		if strings.HasSuffix(os.Getenv("SHELL"), "zsh") {
@@ -224,6 +239,20 @@ func printFlagSuggestions(lastArg string, flags []Flag, writer io.Writer) {
 	}
 }
 
+// flagValueCompletions returns the value completions of the flag named
+// by arg, if any
+func flagValueCompletions(arg string, flags []Flag) []string {
+	name := strings.TrimLeft(arg, "-")
+	for _, fl := range flags {
+		vf, ok := fl.(ValueCompletionFlag)
+		if !ok || !slices.Contains(fl.Names(), name) {
+			continue
+		}
+		return vf.ValueCompletions()
+	}
+	return nil
+}
+
 func DefaultCompleteWithFlags(ctx context.Context, cmd *Command) {
 	args := os.Args
 	if cmd != nil && cmd.flagSet != nil && cmd.parent != nil {
@@ -248,6 +277,14 @@ func DefaultCompleteWithFlags(ctx context.Context, cmd *Command) {
 	}
 
 	if strings.HasPrefix(lastArg, "-") {
+		if values := flagValueCompletions(lastArg, cmd.Flags); len(values) > 0 {
+			tracef("printing value suggestions for flag[%v] on command %[2]q", lastArg, cmd.Name)
+			for _, v := range values {
+				_, _ = fmt.Fprintln(cmd.Root().Writer, v)
+			}
+			return
+		}
+
 		tracef("printing flag suggestion for flag[%v] on command %[1]q", lastArg, cmd.Name)
 		printFlagSuggestions(lastArg, cmd.Flags, cmd.Root().Writer)
 		return
@@ -273,32 +310,40 @@ func ShowCommandHelp(ctx context.Context, cmd *Command, commandName string) erro
 			continue
 		}
 
+		subCmd.ensureLoaded()
+
 		tmpl := subCmd.CustomHelpTemplate
 		if tmpl == "" {
 			if len(subCmd.Commands) == 0 {
 				tracef("using CommandHelpTemplate")
-				tmpl = CommandHelpTemplate
+				tmpl = subCmd.helpTemplate(func(t *HelpTemplates) string { return t.Command }, CommandHelpTemplate)
 			} else {
 				tracef("using SubcommandHelpTemplate")
-				tmpl = SubcommandHelpTemplate
+				tmpl = subCmd.helpTemplate(func(t *HelpTemplates) string { return t.Subcommand }, SubcommandHelpTemplate)
 			}
 		}
 
 		tracef("running HelpPrinter")
-		HelpPrinter(cmd.Root().Writer, tmpl, subCmd)
+		cmd.helpPrinter()(cmd.Root().Writer, tmpl, subCmd)
 
 		tracef("returning nil after printing help")
 		return nil
 	}
 
+	if topic := cmd.helpTopic(commandName); topic != nil {
+		tracef("printing help topic %[1]q", topic.Name)
+		cmd.helpPrinter()(cmd.Root().Writer, HelpTopicTemplate, topic)
+		return nil
+	}
+
 	tracef("no matching command found")
 
 	if cmd.CommandNotFound == nil {
 		errMsg := fmt.Sprintf("No help topic for '%v'", commandName)
 
-		if cmd.Suggest {
-			if suggestion := SuggestCommand(cmd.Commands, commandName); suggestion != "" {
-				errMsg += ". " + suggestion
+		if cmd.suggest() {
+			if suggestion := SuggestCommand(cmd.Commands, commandName); suggestion != "" && cmd.closeEnough(suggestion, commandName) {
+				errMsg += ". " + fmt.Sprintf(SuggestDidYouMeanTemplate, suggestion)
 			}
 		}
 
@@ -312,6 +357,30 @@ func ShowCommandHelp(ctx context.Context, cmd *Command, commandName string) erro
 	return nil
 }
 
+// HelpTopic is a help entry for a concept rather than a command, shown with
+// "help NAME", e.g. for the environment variables or the config file format
+type HelpTopic struct {
+	// Name the topic is shown with
+	Name string `json:"name"`
+	// Short description listed in the help of the command
+	Usage string `json:"usage"`
+	// Text shown for the topic
+	Text string `json:"text"`
+}
+
+// helpTopic returns the help topic of the command or its parents with the
+// given name, or nil if there is none
+func (cmd *Command) helpTopic(name string) *HelpTopic {
+	for _, pCmd := range cmd.Lineage() {
+		for i := range pCmd.HelpTopics {
+			if pCmd.HelpTopics[i].Name == name {
+				return &pCmd.HelpTopics[i]
+			}
+		}
+	}
+	return nil
+}
+
 // ShowSubcommandHelpAndExit - Prints help for the given subcommand and exits with exit code.
 func ShowSubcommandHelpAndExit(cmd *Command, exitCode int) {
 	_ = ShowSubcommandHelp(cmd)
@@ -320,10 +389,58 @@ func ShowSubcommandHelpAndExit(cmd *Command, exitCode int) {
 
 // ShowSubcommandHelp prints help for the given subcommand
 func ShowSubcommandHelp(cmd *Command) error {
-	HelpPrinter(cmd.Root().Writer, SubcommandHelpTemplate, cmd)
+	tmpl := cmd.CustomHelpTemplate
+	if tmpl == "" {
+		tmpl = cmd.helpTemplate(func(t *HelpTemplates) string { return t.Subcommand }, SubcommandHelpTemplate)
+	}
+	cmd.helpPrinter()(cmd.Root().Writer, tmpl, cmd)
 	return nil
 }
 
+// HelpTemplates are the help templates of a command and its subcommands,
+// see Command.HelpTemplates. Empty templates default to the ones of the
+// parent commands and then to the package level templates.
+type HelpTemplates struct {
+	// Template for the help of the root command, like RootCommandHelpTemplate
+	Root string
+	// Template for the help of commands without subcommands, like
+	// CommandHelpTemplate
+	Command string
+	// Template for the help of commands with subcommands, like
+	// SubcommandHelpTemplate
+	Subcommand string
+}
+
+// helpTemplate returns the template selected by get from the HelpTemplates
+// of the command or its closest parent which sets it, or fallback
+func (cmd *Command) helpTemplate(get func(*HelpTemplates) string, fallback string) string {
+	for _, pCmd := range cmd.Lineage() {
+		if pCmd.HelpTemplates == nil {
+			continue
+		}
+		if tmpl := get(pCmd.HelpTemplates); tmpl != "" {
+			return tmpl
+		}
+	}
+	return fallback
+}
+
+// helpTemplateFuncs returns the HelpTemplateFuncs of the command and its
+// parents, the funcs of the closest command win, or nil if there are none
+func (cmd *Command) helpTemplateFuncs() map[string]any {
+	var funcs map[string]any
+	lineage := cmd.Lineage()
+	for i := len(lineage) - 1; i >= 0; i-- {
+		for name, fn := range lineage[i].HelpTemplateFuncs {
+			if funcs == nil {
+				funcs = map[string]any{}
+			}
+			funcs[name] = fn
+		}
+	}
+	return funcs
+}
+
 // ShowVersion prints the version number of the App
 func ShowVersion(cmd *Command) {
 	tracef("showing version via VersionPrinter (cmd=%[1]q)", cmd.Name)
@@ -331,6 +448,10 @@ func ShowVersion(cmd *Command) {
 }
 
 func printVersion(cmd *Command) {
+	if versionJSONRequested(cmd) {
+		_ = writeVersionJSON(cmd, cmd.VersionInfo())
+		return
+	}
 	_, _ = fmt.Fprintf(cmd.Root().Writer, "%v version %v\n", cmd.Name, cmd.Version)
 }
 
@@ -365,88 +486,205 @@ func printHelpCustom(out io.Writer, templ string, data interface{}, customFuncs
 		"offsetCommands": offsetCommands,
 	}
 
+	for key, value := range customFuncs {
+		funcMap[key] = value
+	}
+
 	if wa, ok := customFuncs["wrapAt"]; ok {
 		if wrapAtFunc, ok := wa.(func() int); ok {
 			wrapAt := wrapAtFunc()
-			customFuncs["wrap"] = func(input string, offset int) string {
+			funcMap["wrap"] = func(input string, offset int) string {
 				return wrap(input, offset, wrapAt)
 			}
 		}
 	}
 
-	for key, value := range customFuncs {
-		funcMap[key] = value
+	w := tabwriter.NewWriter(out, 1, 8, 2, ' ', 0)
+	subtemplates := helpSubtemplates()
+	t, err := cachedTemplate(helpTemplateKey(templ, subtemplates, funcMap), func() (*template.Template, error) {
+		return parseHelpTemplate(templ, subtemplates, funcMap)
+	})
+	if t == nil {
+		panic(err)
 	}
+	t.Funcs(funcMap)
 
-	w := tabwriter.NewWriter(out, 1, 8, 2, ' ', 0)
-	t := template.Must(template.New("help").Funcs(funcMap).Parse(templ))
+	tracef("executing template")
+	handleTemplateError(t.Execute(w, data))
 
-	if _, err := t.New("helpNameTemplate").Parse(helpNameTemplate); err != nil {
-		handleTemplateError(err)
-	}
+	_ = w.Flush()
+}
+
+// maxParsedTemplates is the number of templates kept by cachedTemplate,
+// the one parsed first is dropped when another one is parsed
+const maxParsedTemplates = 32
 
-	if _, err := t.New("argsTemplate").Parse(argsTemplate); err != nil {
-		handleTemplateError(err)
+// templateKey identifies a template parsed by cachedTemplate by its name
+// and its texts and funcs, see newTemplateKey
+type templateKey struct {
+	name string
+	text string
+}
+
+// newTemplateKey returns the key of the template with the name parsed from
+// the texts
+func newTemplateKey(name string, texts ...string) templateKey {
+	return templateKey{name: name, text: strings.Join(texts, "\x00")}
+}
+
+// parsedTemplates holds the templates parsed by cachedTemplate by key, and
+// the keys in the order they were parsed in
+var parsedTemplates struct {
+	sync.Mutex
+	byKey map[templateKey]*template.Template
+	keys  []templateKey
+}
+
+// cachedTemplate returns a clone of the template parsed by parse for the
+// key, calling parse only until it succeeds for the key. A template parse
+// returns along with an error is returned as is. The clones can be
+// executed and given other funcs safely while other goroutines use the
+// same template. At most maxParsedTemplates templates are kept.
+func cachedTemplate(key templateKey, parse func() (*template.Template, error)) (*template.Template, error) {
+	parsedTemplates.Lock()
+	t, ok := parsedTemplates.byKey[key]
+	parsedTemplates.Unlock()
+	if ok {
+		return t.Clone()
 	}
 
-	if _, err := t.New("usageTemplate").Parse(usageTemplate); err != nil {
-		handleTemplateError(err)
+	t, err := parse()
+	if err != nil {
+		return t, err
 	}
 
-	if _, err := t.New("descriptionTemplate").Parse(descriptionTemplate); err != nil {
-		handleTemplateError(err)
+	parsedTemplates.Lock()
+	if _, ok := parsedTemplates.byKey[key]; !ok {
+		if parsedTemplates.byKey == nil {
+			parsedTemplates.byKey = map[templateKey]*template.Template{}
+		}
+		if len(parsedTemplates.keys) >= maxParsedTemplates {
+			delete(parsedTemplates.byKey, parsedTemplates.keys[0])
+			parsedTemplates.keys = parsedTemplates.keys[1:]
+		}
+		parsedTemplates.byKey[key] = t
+		parsedTemplates.keys = append(parsedTemplates.keys, key)
 	}
+	parsedTemplates.Unlock()
+	return t.Clone()
+}
 
-	if _, err := t.New("visibleCommandTemplate").Parse(visibleCommandTemplate); err != nil {
-		handleTemplateError(err)
+// helpSubtemplates returns the names and texts of the templates help
+// templates may use
+func helpSubtemplates() [][2]string {
+	return [][2]string{
+		{"helpNameTemplate", helpNameTemplate},
+		{"argsTemplate", argsTemplate},
+		{"usageTemplate", usageTemplate},
+		{"descriptionTemplate", descriptionTemplate},
+		{"helpTopicsTemplate", helpTopicsTemplate},
+		{"visibleCommandTemplate", visibleCommandTemplate},
+		{"copyrightTemplate", copyrightTemplate},
+		{"versionTemplate", versionTemplate},
+		{"visibleFlagCategoryTemplate", visibleFlagCategoryTemplate},
+		{"visibleFlagTemplate", visibleFlagTemplate},
+		{"visiblePersistentFlagTemplate", visiblePersistentFlagTemplate},
+		{"visibleGlobalFlagCategoryTemplate", strings.Replace(visibleFlagCategoryTemplate, "OPTIONS", "GLOBAL OPTIONS", -1)},
+		{"authorsTemplate", authorsTemplate},
+		{"visibleCommandCategoryTemplate", visibleCommandCategoryTemplate},
 	}
+}
 
-	if _, err := t.New("copyrightTemplate").Parse(copyrightTemplate); err != nil {
-		handleTemplateError(err)
+// helpTemplateKey returns the key of the help template parsed from templ
+// and the subtemplates with the funcs. It only depends on the names of the
+// funcs since the funcs themselves are replaced before each use.
+func helpTemplateKey(templ string, subtemplates [][2]string, funcMap template.FuncMap) templateKey {
+	names := make([]string, 0, len(funcMap))
+	for name := range funcMap {
+		names = append(names, name)
 	}
+	slices.Sort(names)
 
-	if _, err := t.New("versionTemplate").Parse(versionTemplate); err != nil {
-		handleTemplateError(err)
+	texts := make([]string, 0, len(subtemplates)+2)
+	texts = append(texts, strings.Join(names, ","), templ)
+	for _, sub := range subtemplates {
+		texts = append(texts, sub[1])
 	}
+	return newTemplateKey("help", texts...)
+}
 
-	if _, err := t.New("visibleFlagCategoryTemplate").Parse(visibleFlagCategoryTemplate); err != nil {
-		handleTemplateError(err)
+// parseHelpTemplate parses templ and the subtemplates with the funcs. The
+// template is returned along with the error if only subtemplates fail.
+func parseHelpTemplate(templ string, subtemplates [][2]string, funcMap template.FuncMap) (*template.Template, error) {
+	t, err := template.New("help").Funcs(funcMap).Parse(templ)
+	if err != nil {
+		return nil, err
 	}
 
-	if _, err := t.New("visibleFlagTemplate").Parse(visibleFlagTemplate); err != nil {
-		handleTemplateError(err)
+	var subErr error
+	for _, sub := range subtemplates {
+		if _, err := t.New(sub[0]).Parse(sub[1]); err != nil {
+			handleTemplateError(err)
+			subErr = err
+		}
 	}
 
-	if _, err := t.New("visiblePersistentFlagTemplate").Parse(visiblePersistentFlagTemplate); err != nil {
-		handleTemplateError(err)
+	return t, subErr
+}
+
+// helpPrinter returns the HelpPrinter of the root command, or else the
+// package level HelpPrinter
+func (cmd *Command) helpPrinter() helpPrinter {
+	if printer := cmd.Root().HelpPrinter; printer != nil {
+		return printer
 	}
+	return HelpPrinter
+}
 
-	if _, err := t.New("visibleGlobalFlagCategoryTemplate").Parse(strings.Replace(visibleFlagCategoryTemplate, "OPTIONS", "GLOBAL OPTIONS", -1)); err != nil {
-		handleTemplateError(err)
+func printHelp(out io.Writer, templ string, data interface{}) {
+	cmd, ok := data.(*Command)
+	if !ok {
+		HelpPrinterCustom(out, templ, data, nil)
+		return
 	}
 
-	if _, err := t.New("authorsTemplate").Parse(authorsTemplate); err != nil {
-		handleTemplateError(err)
+	if cmd.Root().helpFormat == helpFormatJSON {
+		_ = writeHelpJSON(out, cmd)
+		return
 	}
 
-	if _, err := t.New("visibleCommandCategoryTemplate").Parse(visibleCommandCategoryTemplate); err != nil {
-		handleTemplateError(err)
+	funcs := cmd.helpTemplateFuncs()
+	style := cmd.helpStyle(out)
+	paged := cmd.pagerEnabled(out)
+	width := cmd.helpWidth(out)
+	if style == nil && !paged && width <= 0 {
+		HelpPrinterCustom(out, templ, data, funcs)
+		return
 	}
 
-	tracef("executing template")
-	handleTemplateError(t.Execute(w, data))
+	var buf bytes.Buffer
+	HelpPrinterCustom(&buf, templ, data, funcs)
 
-	_ = w.Flush()
-}
+	help := buf.String()
+	if width > 0 {
+		help = wrapHelpColumns(help, width)
+	}
+	if style != nil {
+		help = style.apply(help)
+	}
+	output := []byte(help)
 
-func printHelp(out io.Writer, templ string, data interface{}) {
-	HelpPrinterCustom(out, templ, data, nil)
+	if paged {
+		cmd.page(out, output)
+	} else {
+		_, _ = out.Write(output)
+	}
 }
 
 func checkVersion(cmd *Command) bool {
 	found := false
 	for _, name := range VersionFlag.Names() {
-		if cmd.Bool(name) {
+		if cmd.lookupFlag(name) == VersionFlag && cmd.Bool(name) {
 			found = true
 		}
 	}
@@ -516,6 +754,64 @@ func nindent(spaces int, v string) string {
 	return "\n" + indent(spaces, v)
 }
 
+// helpWidth returns the width help written to out is wrapped at: HelpWidth
+// of the root command if set, else if out is a terminal the width in the
+// COLUMNS env var or the actual width of the terminal, else 0 for no
+// wrapping
+func (cmd *Command) helpWidth(out io.Writer) int {
+	if width := cmd.Root().HelpWidth; width != 0 {
+		return width
+	}
+
+	if !isTerminal(out) {
+		return 0
+	}
+
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	if width, _, ok := terminalSizeOf(out); ok {
+		return width
+	}
+	return 0
+}
+
+// wrapHelpColumns wraps the lines of rendered help longer than width. The
+// usage text of commands and flags is wrapped into an aligned second column,
+// other lines are wrapped at their indentation.
+func wrapHelpColumns(help string, width int) string {
+	lines := strings.Split(help, "\n")
+	section := ""
+
+	for i, line := range lines {
+		if helpHeadingRe.MatchString(line) {
+			section = strings.TrimSuffix(line, ":")
+			continue
+		}
+		if len(line) <= width {
+			continue
+		}
+
+		m := helpEntryRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		indent, name, rest := m[1], m[2], m[3]
+
+		isEntry := section == "COMMANDS" || strings.HasSuffix(section, "OPTIONS")
+		if text := strings.TrimLeft(rest, " "); isEntry && text != "" {
+			column := len(indent) + len(name) + len(rest) - len(text)
+			lines[i] = line[:column] + wrapLine(text, column, width, strings.Repeat(" ", column))
+			continue
+		}
+
+		text := strings.TrimSpace(line[len(indent):])
+		lines[i] = indent + wrapLine(text, len(indent), width, indent)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
 func wrap(input string, offset int, wrapAt int) string {
 	var ss []string
 
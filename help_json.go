@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+)
+
+// helpFormatJSON is the format selected with --help=json
+const helpFormatJSON = "json"
+
+// helpDocument is the machine-readable help of a command written for
+// --help=json
+type helpDocument struct {
+	Name        string        `json:"name"`
+	FullName    string        `json:"fullName"`
+	Aliases     []string      `json:"aliases,omitempty"`
+	Usage       string        `json:"usage,omitempty"`
+	UsageText   string        `json:"usageText,omitempty"`
+	ArgsUsage   string        `json:"argsUsage,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Category    string        `json:"category,omitempty"`
+	Version     string        `json:"version,omitempty"`
+	Flags       []helpFlag    `json:"flags"`
+	Commands    []helpCommand `json:"commands"`
+}
+
+type helpFlag struct {
+	Names      []string `json:"names"`
+	Usage      string   `json:"usage,omitempty"`
+	Type       string   `json:"type,omitempty"`
+	TakesValue bool     `json:"takesValue"`
+	Default    string   `json:"default,omitempty"`
+	Required   bool     `json:"required"`
+	EnvVars    []string `json:"envVars,omitempty"`
+	Category   string   `json:"category,omitempty"`
+	Global     bool     `json:"global"`
+}
+
+type helpCommand struct {
+	Name     string   `json:"name"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Usage    string   `json:"usage,omitempty"`
+	Category string   `json:"category,omitempty"`
+}
+
+// extractHelpFormat returns a copy of args with --help=FORMAT replaced by
+// --help for a supported format, and the format or "" if not given
+func extractHelpFormat(args []string) ([]string, string) {
+	if HelpFlag == nil {
+		return args, ""
+	}
+
+	for i, arg := range args {
+		if i == 0 {
+			continue
+		}
+		if arg == "--" {
+			break
+		}
+		for _, name := range HelpFlag.Names() {
+			for _, prefix := range []string{"-", "--"} {
+				if format, ok := strings.CutPrefix(arg, prefix+name+"="); ok && format == helpFormatJSON {
+					tracef("using help format %[1]q", format)
+					args = slices.Clone(args)
+					args[i] = prefix + name
+					return args, format
+				}
+			}
+		}
+	}
+
+	return args, ""
+}
+
+// writeHelpJSON writes the machine-readable help of the command to out
+func writeHelpJSON(out io.Writer, cmd *Command) error {
+	doc := helpDocument{
+		Name:        cmd.Name,
+		FullName:    cmd.FullName(),
+		Aliases:     cmd.Aliases,
+		Usage:       cmd.Usage,
+		UsageText:   cmd.UsageText,
+		ArgsUsage:   cmd.ArgsUsage,
+		Description: cmd.Description,
+		Category:    cmd.Category,
+		Flags:       []helpFlag{},
+		Commands:    []helpCommand{},
+	}
+	if cmd.parent == nil && !cmd.HideVersion {
+		doc.Version = cmd.Version
+	}
+
+	for _, fl := range cmd.VisibleFlags() {
+		doc.Flags = append(doc.Flags, newHelpFlag(fl, false))
+	}
+	if cmd.parent != nil {
+		for _, fl := range cmd.VisiblePersistentFlags() {
+			doc.Flags = append(doc.Flags, newHelpFlag(fl, true))
+		}
+	}
+
+	for _, subCmd := range cmd.VisibleCommands() {
+		doc.Commands = append(doc.Commands, helpCommand{
+			Name:     subCmd.Name,
+			Aliases:  subCmd.Aliases,
+			Usage:    subCmd.Usage,
+			Category: subCmd.Category,
+		})
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func newHelpFlag(fl Flag, global bool) helpFlag {
+	hf := helpFlag{
+		Names:  fl.Names(),
+		Global: global,
+	}
+
+	if df, ok := fl.(DocGenerationFlag); ok {
+		hf.Usage = df.GetUsage()
+		hf.Type = df.TypeName()
+		hf.TakesValue = df.TakesValue()
+		hf.EnvVars = df.GetEnvVars()
+		if df.IsDefaultVisible() {
+			hf.Default = df.GetDefaultText()
+		}
+	}
+	if rf, ok := fl.(RequiredFlag); ok && rf.IsRequired() {
+		hf.Required = true
+		hf.Default = ""
+	}
+	if cf, ok := fl.(CategorizableFlag); ok {
+		hf.Category = cf.GetCategory()
+	}
+
+	return hf
+}
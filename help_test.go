@@ -10,7 +10,9 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"text/template"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -94,6 +96,23 @@ GLOBAL OPTIONS:
 		"expected output to include usage text")
 }
 
+func Test_Help_SensitiveFlagsNoDefault(t *testing.T) {
+	t.Setenv("APP_TOKEN", "s3cr3t-from-env")
+	output := new(bytes.Buffer)
+
+	cmd := &Command{
+		Flags: []Flag{
+			&StringFlag{Name: "token", Value: os.Getenv("APP_TOKEN"), Sources: EnvVars("APP_TOKEN"), Sensitive: true},
+		},
+		Writer: output,
+	}
+
+	_ = cmd.Run(buildTestContext(t), []string{"test", "-h"})
+
+	assert.Contains(t, output.String(), "--token string   [$APP_TOKEN]")
+	assert.NotContains(t, output.String(), "s3cr3t-from-env")
+}
+
 func Test_Help_Custom_Flags(t *testing.T) {
 	oldFlag := HelpFlag
 	defer func() {
@@ -171,7 +190,7 @@ func Test_Version_Custom_Flags(t *testing.T) {
 
 func Test_helpCommand_Action_ErrorIfNoTopic(t *testing.T) {
 	cmd := &Command{
-		flagSet: flag.NewFlagSet("test", 0),
+		runState: runState{flagSet: flag.NewFlagSet("test", 0)},
 	}
 
 	_ = cmd.Run(context.Background(), []string{"foo", "bar"})
@@ -292,7 +311,7 @@ func Test_helpCommand_HideHelpFlag(t *testing.T) {
 
 func Test_helpSubcommand_Action_ErrorIfNoTopic(t *testing.T) {
 	cmd := &Command{
-		flagSet: flag.NewFlagSet("test", 0),
+		runState: runState{flagSet: flag.NewFlagSet("test", 0)},
 	}
 	_ = cmd.Run(context.Background(), []string{"foo", "bar"})
 
@@ -1233,6 +1252,21 @@ func TestDefaultCompleteWithFlags(t *testing.T) {
 			env:      map[string]string{"SHELL": "bash"},
 			expected: "",
 		},
+		{
+			name: "flag-value-suggestion",
+			cmd: &Command{
+				Flags: []Flag{
+					&LogLevelFlag{Name: "log-level"},
+					&StringFlag{Name: "hat-shape"},
+				},
+				parent: &Command{
+					Name: "cmd",
+				},
+			},
+			argv:     []string{"cmd", "--log-level", completionFlag},
+			env:      map[string]string{"SHELL": "bash"},
+			expected: "debug\ninfo\nwarn\nerror\n",
+		},
 		{
 			name: "typical-command-suggestion",
 			cmd: &Command{
@@ -1735,6 +1769,549 @@ GLOBAL OPTIONS:
 `, output.String())
 }
 
+func TestCategoryDefinitionsHelp(t *testing.T) {
+	output := new(bytes.Buffer)
+	cmd := &Command{
+		Name:     "cli.test",
+		Writer:   output,
+		HideHelp: true,
+		Categories: []CategoryDefinition{
+			{Name: "Getting started", Description: "Commands to set up a project", Order: 1},
+			{Name: "Advanced", Order: 2},
+		},
+		Commands: []*Command{
+			{Name: "tune", Usage: "tune things", Category: "Advanced"},
+			{Name: "init", Usage: "create a project", Category: "Getting started"},
+			{Name: "misc", Usage: "other things", Category: "Miscellaneous"},
+			{Name: "version", Usage: "show version"},
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"cli.test"}))
+	assert.Contains(t, output.String(), `COMMANDS:
+   version  show version
+
+   Miscellaneous:
+     misc  other things
+
+   Getting started:
+   Commands to set up a project
+
+     init  create a project
+
+   Advanced:
+     tune  tune things
+`)
+}
+
+func TestHiddenUnlessHelp(t *testing.T) {
+	newCmd := func(output io.Writer) *Command {
+		return &Command{
+			Name:   "cli.test",
+			Writer: output,
+			Commands: []*Command{
+				{Name: "stable", Usage: "a stable command"},
+				{
+					Name:  "lab",
+					Usage: "an experimental command",
+					HiddenUnless: func(cmd *Command) bool {
+						return cmd.HelpAll()
+					},
+				},
+			},
+		}
+	}
+
+	output := new(bytes.Buffer)
+	require.NoError(t, newCmd(output).Run(buildTestContext(t), []string{"cli.test", "--help"}))
+	assert.Contains(t, output.String(), "stable")
+	assert.NotContains(t, output.String(), "lab")
+	assert.Contains(t, output.String(), "--all")
+
+	for _, args := range [][]string{{"cli.test", "--help", "--all"}, {"cli.test", "help", "--all"}} {
+		output.Reset()
+		require.NoError(t, newCmd(output).Run(buildTestContext(t), args))
+		assert.Contains(t, output.String(), "stable", args)
+		assert.Contains(t, output.String(), "an experimental command", args)
+	}
+
+	// the flag is only added where commands are hidden with HiddenUnless
+	output.Reset()
+	require.NoError(t, newCmd(output).Run(buildTestContext(t), []string{"cli.test", "stable", "--help"}))
+	assert.NotContains(t, output.String(), "--all")
+
+	t.Setenv("SHELL", "bash")
+	output.Reset()
+	cmd := newCmd(output)
+	cmd.setupCommandGraph()
+	printCommandSuggestions(cmd.Commands, output)
+	assert.Equal(t, "stable\n", output.String())
+}
+
+func TestHelpPager(t *testing.T) {
+	defer func(old func(io.Writer) bool) { isTerminal = old }(isTerminal)
+	isTerminal = func(io.Writer) bool { return true }
+
+	t.Setenv("NO_PAGER", "")
+
+	newCmd := func(output io.Writer) *Command {
+		return &Command{
+			Name:   "cli.test",
+			Writer: output,
+			Pager: func(_ *Command, out io.Writer, output []byte) error {
+				_, err := io.WriteString(out, ">"+string(output))
+				return err
+			},
+			Commands: []*Command{{Name: "one"}, {Name: "two"}},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		lines string
+		paged bool
+	}{
+		{name: "long output", lines: "5", paged: true},
+		{name: "short output", lines: "200", paged: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("LINES", test.lines)
+
+			output := new(bytes.Buffer)
+			require.NoError(t, newCmd(output).Run(buildTestContext(t), []string{"cli.test", "--help"}))
+			assert.Equal(t, test.paged, strings.HasPrefix(output.String(), ">NAME:"), output.String())
+		})
+	}
+
+	t.Run("height of terminal", func(t *testing.T) {
+		defer func(old func(io.Writer) (int, int, bool)) { terminalSizeOf = old }(terminalSizeOf)
+		t.Setenv("LINES", "")
+
+		for _, height := range []int{5, 200} {
+			terminalSizeOf = func(io.Writer) (int, int, bool) { return 80, height, true }
+
+			output := new(bytes.Buffer)
+			require.NoError(t, newCmd(output).Run(buildTestContext(t), []string{"cli.test", "--help"}))
+			assert.Equal(t, height == 5, strings.HasPrefix(output.String(), ">NAME:"), output.String())
+		}
+	})
+
+	t.Run("disabled with NO_PAGER", func(t *testing.T) {
+		t.Setenv("LINES", "5")
+		t.Setenv("NO_PAGER", "1")
+
+		output := new(bytes.Buffer)
+		require.NoError(t, newCmd(output).Run(buildTestContext(t), []string{"cli.test", "--help"}))
+		assert.True(t, strings.HasPrefix(output.String(), "NAME:"), output.String())
+	})
+
+	t.Run("written directly if the pager fails", func(t *testing.T) {
+		t.Setenv("LINES", "5")
+
+		output := new(bytes.Buffer)
+		cmd := newCmd(output)
+		cmd.Pager = func(*Command, io.Writer, []byte) error { return errors.New("no pager") }
+		require.NoError(t, cmd.Run(buildTestContext(t), []string{"cli.test", "--help"}))
+		assert.True(t, strings.HasPrefix(output.String(), "NAME:"), output.String())
+	})
+}
+
+func TestHelpStyle(t *testing.T) {
+	defer func(old func(io.Writer) bool) { isTerminal = old }(isTerminal)
+	isTerminal = func(io.Writer) bool { return true }
+	t.Setenv("NO_COLOR", "")
+
+	tag := func(name string) func(string) string {
+		return func(s string) string { return "<" + name + ">" + s + "</" + name + ">" }
+	}
+	style := &HelpStyle{
+		Heading: tag("h"),
+		Command: tag("c"),
+		Flag:    tag("f"),
+		Default: tag("d"),
+	}
+
+	newCmd := func(output io.Writer) *Command {
+		return &Command{
+			Name:      "cli.test",
+			Writer:    output,
+			HelpStyle: style,
+			Flags: []Flag{
+				&StringFlag{Name: "name", Usage: "who to greet", Value: "world"},
+			},
+			Commands: []*Command{
+				{Name: "greet", Usage: "say hello"},
+				{Name: "deploy", Usage: "ship it", Category: "ops"},
+			},
+		}
+	}
+
+	output := new(bytes.Buffer)
+	require.NoError(t, newCmd(output).Run(buildTestContext(t), []string{"cli.test", "--help"}))
+	assert.Equal(t, `<h>NAME:</h>
+   cli.test - A new cli application
+
+<h>USAGE:</h>
+   cli.test [global options] [command [command options]]
+
+<h>COMMANDS:</h>
+   <c>greet</c>    say hello
+   <c>help, h</c>  Shows a list of commands or help for one command
+
+   <h>ops:</h>
+     <c>deploy</c>  ship it
+
+<h>GLOBAL OPTIONS:</h>
+   <f>--name string</f>  who to greet <d>(default: "world")</d>
+   <f>--help, -h</f>     show help
+   <f>--no-color</f>     disable colors in output
+`, output.String())
+
+	output.Reset()
+	require.NoError(t, newCmd(output).Run(buildTestContext(t), []string{"cli.test", "--help", "--no-color"}))
+	assert.NotContains(t, output.String(), "<h>")
+
+	t.Setenv("NO_COLOR", "1")
+	output.Reset()
+	require.NoError(t, newCmd(output).Run(buildTestContext(t), []string{"cli.test", "--help"}))
+	assert.NotContains(t, output.String(), "<h>")
+}
+
+func TestShouldColorize(t *testing.T) {
+	defer func(old func(io.Writer) bool) { isTerminal = old }(isTerminal)
+	isTerminal = func(io.Writer) bool { return true }
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected bool
+	}{
+		{name: "terminal", args: []string{"app"}, expected: true},
+		{name: "never", args: []string{"app", "--color=never"}, expected: false},
+		{name: "no color env", args: []string{"app"}, env: map[string]string{"NO_COLOR": "1"}, expected: false},
+		{name: "always overrides env", args: []string{"app", "--color=always"}, env: map[string]string{"NO_COLOR": "1"}, expected: true},
+		{name: "clicolor off", args: []string{"app"}, env: map[string]string{"CLICOLOR": "0"}, expected: false},
+		{name: "no-color flag", args: []string{"app", "--no-color"}, env: map[string]string{"CLICOLOR_FORCE": "1"}, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, key := range []string{"NO_COLOR", "CLICOLOR", "CLICOLOR_FORCE"} {
+				t.Setenv(key, test.env[key])
+			}
+
+			colorFlag := *ColorFlag.(*StringFlag)
+			noColorFlag := *NoColorFlag.(*BoolFlag)
+			colorFlag.reset()
+			noColorFlag.reset()
+
+			var colorize bool
+			cmd := &Command{
+				Name:  "app",
+				Flags: []Flag{&colorFlag, &noColorFlag},
+				Commands: []*Command{
+					{Name: "sub"},
+				},
+				Action: func(_ context.Context, cmd *Command) error {
+					colorize = ShouldColorize(os.Stdout, cmd)
+					return nil
+				},
+			}
+			require.NoError(t, cmd.Run(buildTestContext(t), test.args))
+			assert.Equal(t, test.expected, colorize)
+		})
+	}
+
+	isTerminal = func(io.Writer) bool { return false }
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("CLICOLOR_FORCE", "1")
+	assert.True(t, ShouldColorize(io.Discard, nil))
+	t.Setenv("CLICOLOR_FORCE", "")
+	assert.False(t, ShouldColorize(io.Discard, nil))
+
+	cmd := &Command{Name: "app", Flags: []Flag{ColorFlag}}
+	assert.ErrorContains(t, cmd.Run(buildTestContext(t), []string{"app", "--color=sometimes"}), `invalid color mode "sometimes"`)
+}
+
+func TestHelpWidth(t *testing.T) {
+	defer func(old func(io.Writer) bool) { isTerminal = old }(isTerminal)
+	isTerminal = func(io.Writer) bool { return true }
+
+	newCmd := func(output io.Writer, width int) *Command {
+		return &Command{
+			Name:        "cli.test",
+			Usage:       "test wrapping",
+			Description: "a description which is long enough to be wrapped at the width of the help",
+			Writer:      output,
+			HelpWidth:   width,
+			Flags: []Flag{
+				&StringFlag{Name: "name", Usage: "the name of the person to greet when running the command"},
+			},
+			Commands: []*Command{
+				{Name: "greet", Usage: "say hello to the person given with the name flag"},
+			},
+		}
+	}
+
+	output := new(bytes.Buffer)
+	require.NoError(t, newCmd(output, 40).Run(buildTestContext(t), []string{"cli.test", "--help"}))
+	assert.Equal(t, `NAME:
+   cli.test - test wrapping
+
+USAGE:
+   cli.test [global options] [command
+   [command options]]
+
+DESCRIPTION:
+   a description which is long enough to
+   be wrapped at the width of the help
+
+COMMANDS:
+   greet    say hello to the person
+            given with the name flag
+   help, h  Shows a list of commands or
+            help for one command
+
+GLOBAL OPTIONS:
+   --name string  the name of the person
+                  to greet when running
+                  the command
+   --help, -h     show help
+`, output.String())
+
+	t.Setenv("COLUMNS", "40")
+	output.Reset()
+	require.NoError(t, newCmd(output, 0).Run(buildTestContext(t), []string{"cli.test", "--help"}))
+	assert.Contains(t, output.String(), "   --name string  the name of the person\n")
+
+	defer func(old func(io.Writer) (int, int, bool)) { terminalSizeOf = old }(terminalSizeOf)
+	terminalSizeOf = func(io.Writer) (int, int, bool) { return 40, 24, true }
+	t.Setenv("COLUMNS", "")
+	output.Reset()
+	require.NoError(t, newCmd(output, 0).Run(buildTestContext(t), []string{"cli.test", "--help"}))
+	assert.Contains(t, output.String(), "   --name string  the name of the person\n")
+
+	output.Reset()
+	require.NoError(t, newCmd(output, -1).Run(buildTestContext(t), []string{"cli.test", "--help"}))
+	assert.Contains(t, output.String(), "   --name string  the name of the person to greet when running the command\n")
+}
+
+func TestHelpJSON(t *testing.T) {
+	newCmd := func(output io.Writer) *Command {
+		return &Command{
+			Name:    "cli.test",
+			Usage:   "test json help",
+			Version: "1.0.0",
+			Writer:  output,
+			Flags: []Flag{
+				&StringFlag{Name: "name", Aliases: []string{"n"}, Usage: "who to greet", Value: "world", Sources: EnvVars("NAME")},
+			},
+			Commands: []*Command{
+				{
+					Name:      "deploy",
+					Usage:     "ship it",
+					ArgsUsage: "TARGET",
+					Category:  "ops",
+					Flags: []Flag{
+						&BoolFlag{Name: "force", Required: true, Local: true},
+					},
+				},
+				{Name: "secret", Hidden: true},
+			},
+		}
+	}
+
+	output := new(bytes.Buffer)
+	require.NoError(t, newCmd(output).Run(buildTestContext(t), []string{"cli.test", "--help=json"}))
+	assert.JSONEq(t, `{
+		"name": "cli.test",
+		"fullName": "cli.test",
+		"usage": "test json help",
+		"version": "1.0.0",
+		"flags": [
+			{"names": ["name", "n"], "usage": "who to greet", "type": "string", "takesValue": true, "default": "\"world\"", "required": false, "envVars": ["NAME"], "global": false},
+			{"names": ["help", "h"], "usage": "show help", "type": "bool", "takesValue": false, "required": false, "global": false},
+			{"names": ["version", "v"], "usage": "print the version", "type": "bool", "takesValue": false, "required": false, "global": false}
+		],
+		"commands": [
+			{"name": "deploy", "usage": "ship it", "category": "ops"}
+		]
+	}`, output.String())
+
+	output.Reset()
+	require.NoError(t, newCmd(output).Run(buildTestContext(t), []string{"cli.test", "deploy", "-h=json"}))
+	assert.JSONEq(t, `{
+		"name": "deploy",
+		"fullName": "cli.test deploy",
+		"usage": "ship it",
+		"argsUsage": "TARGET",
+		"category": "ops",
+		"flags": [
+			{"names": ["force"], "type": "bool", "takesValue": false, "required": true, "global": false},
+			{"names": ["help", "h"], "usage": "show help", "type": "bool", "takesValue": false, "required": false, "global": false},
+			{"names": ["name", "n"], "usage": "who to greet", "type": "string", "takesValue": true, "default": "\"world\"", "required": false, "envVars": ["NAME"], "global": true}
+		],
+		"commands": []
+	}`, output.String())
+
+	output.Reset()
+	err := newCmd(output).Run(buildTestContext(t), []string{"cli.test", "--help=yaml"})
+	assert.Error(t, err)
+}
+
+func TestHelpTopics(t *testing.T) {
+	newCmd := func(output io.Writer) *Command {
+		return &Command{
+			Name:   "cli.test",
+			Writer: output,
+			HelpTopics: []HelpTopic{
+				{Name: "environment", Usage: "environment variables", Text: "CLI_TEST_HOME\n   where to keep data\n"},
+				{Name: "config-file", Text: "The config file is YAML."},
+			},
+			Commands: []*Command{
+				{
+					Name:     "deploy",
+					Commands: []*Command{{Name: "now"}},
+				},
+			},
+		}
+	}
+
+	output := new(bytes.Buffer)
+	require.NoError(t, newCmd(output).Run(buildTestContext(t), []string{"cli.test", "--help"}))
+	assert.Contains(t, output.String(), `ADDITIONAL TOPICS:
+   environment  environment variables
+   config-file  
+`)
+
+	output.Reset()
+	require.NoError(t, newCmd(output).Run(buildTestContext(t), []string{"cli.test", "help", "environment"}))
+	assert.Equal(t, "environment - environment variables\n\nCLI_TEST_HOME\n   where to keep data\n", output.String())
+
+	output.Reset()
+	require.NoError(t, newCmd(output).Run(buildTestContext(t), []string{"cli.test", "deploy", "help", "config-file"}))
+	assert.Equal(t, "config-file\n\nThe config file is YAML.\n", output.String())
+}
+
+func TestHelpTemplatesPerCommand(t *testing.T) {
+	newCmd := func(output io.Writer, name string) *Command {
+		return &Command{
+			Name:   name,
+			Writer: output,
+			HelpTemplates: &HelpTemplates{
+				Root:       "root {{shout .Name}}\n",
+				Command:    "command {{shout .FullName}}\n",
+				Subcommand: "subcommand {{.FullName}}\n",
+			},
+			HelpTemplateFuncs: map[string]any{
+				"shout": strings.ToUpper,
+			},
+			Commands: []*Command{
+				{
+					Name:     "deploy",
+					Commands: []*Command{{Name: "now"}},
+				},
+				{
+					Name:               "custom",
+					CustomHelpTemplate: "custom {{.Name}}\n",
+				},
+				{
+					Name:              "quiet",
+					HelpTemplateFuncs: map[string]any{"shout": strings.ToLower},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{name: "root", args: []string{"one", "--help"}, expected: "root ONE\n"},
+		{name: "command", args: []string{"two", "deploy", "now", "--help"}, expected: "command TWO DEPLOY NOW\n"},
+		{name: "subcommand", args: []string{"one", "help", "deploy"}, expected: "subcommand one deploy\n"},
+		{name: "custom template", args: []string{"one", "custom", "--help"}, expected: "custom custom\n"},
+		{name: "overridden func", args: []string{"one", "quiet", "--help"}, expected: "command one quiet\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output := new(bytes.Buffer)
+			require.NoError(t, newCmd(output, test.args[0]).Run(buildTestContext(t), test.args))
+			assert.Equal(t, test.expected, output.String())
+		})
+	}
+
+	assert.True(t, strings.HasPrefix(RootCommandHelpTemplate, "NAME:"))
+}
+
+func TestGroupFlagsHelp(t *testing.T) {
+	var output bytes.Buffer
+	cmd := &Command{
+		Name:       "app",
+		Writer:     &output,
+		GroupFlags: true,
+		Flags: []Flag{
+			&BoolFlag{Name: "verbose", Usage: "log more"},
+			&StringFlag{Name: "name", Usage: "the name", Required: true},
+			&LogLevelFlag{Name: "log-level", Usage: "minimum level", Category: "Logging"},
+		},
+		Commands: []*Command{
+			{
+				Name:  "sub",
+				Flags: []Flag{&StringFlag{Name: "x", Required: true}},
+			},
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "--help"}))
+	assert.Contains(t, output.String(), `GLOBAL OPTIONS:
+   --name string  the name (required)
+   --help, -h     show help
+   --verbose      log more (default: false)
+
+   Logging
+
+   --log-level level  minimum level (default: info) (one of: debug, info, warn, error)
+`)
+
+	output.Reset()
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "sub", "--help"}))
+	assert.Contains(t, output.String(), `OPTIONS:
+   -x string   (required)
+   --help, -h  show help
+`)
+}
+
+func TestSubcommandUsageErrorHelp(t *testing.T) {
+	var output, errOutput bytes.Buffer
+	cmd := &Command{
+		Name:      "myapp",
+		Writer:    &output,
+		ErrWriter: &errOutput,
+		Commands: []*Command{{
+			Name: "cluster",
+			Commands: []*Command{{
+				Name: "node",
+				Commands: []*Command{{
+					Name:  "add",
+					Usage: "add a node",
+				}},
+			}},
+		}},
+	}
+
+	err := cmd.Run(buildTestContext(t), []string{"myapp", "cluster", "node", "add", "--bogus"})
+	require.ErrorContains(t, err, "flag provided but not defined: -bogus")
+	assert.Equal(t, "Incorrect Usage: flag provided but not defined: -bogus\n\n", errOutput.String())
+	assert.Contains(t, output.String(), "myapp cluster node add - add a node")
+	assert.Contains(t, output.String(), "USAGE:\n   myapp cluster node add\n")
+}
+
 func Test_checkShellCompleteFlag(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -1850,6 +2427,29 @@ func TestTemplateError(t *testing.T) {
 	assert.Contains(t, buf.String(), err.Error())
 }
 
+func TestCachedTemplateKeepsAtMostMaxParsedTemplates(t *testing.T) {
+	parse := func(text string) func() (*template.Template, error) {
+		return func() (*template.Template, error) {
+			return template.New("t").Parse(text)
+		}
+	}
+
+	first := newTemplateKey("test", "first")
+	_, err := cachedTemplate(first, parse("first"))
+	require.NoError(t, err)
+	for i := 0; i < maxParsedTemplates; i++ {
+		text := fmt.Sprintf("text %d", i)
+		_, err := cachedTemplate(newTemplateKey("test", text), parse(text))
+		require.NoError(t, err)
+	}
+
+	parsedTemplates.Lock()
+	defer parsedTemplates.Unlock()
+	assert.Len(t, parsedTemplates.keys, maxParsedTemplates)
+	assert.Len(t, parsedTemplates.byKey, maxParsedTemplates)
+	assert.NotContains(t, parsedTemplates.byKey, first)
+}
+
 func TestCliArgContainsFlag(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1916,7 +2516,7 @@ func TestCommandHelpSuggest(t *testing.T) {
 	cmd.setupDefaults([]string{"foo"})
 
 	err := ShowCommandHelp(context.Background(), cmd, "put")
-	assert.ErrorContains(t, err, "No help topic for 'put'. putz")
+	assert.ErrorContains(t, err, "No help topic for 'put'. Did you mean \"putz\"?")
 }
 
 func TestWrapLine(t *testing.T) {
@@ -1963,3 +2563,65 @@ func TestPrintHelpCustomTemplateError(t *testing.T) {
 		*tmpl = oldtmpl
 	}
 }
+
+func TestCommandHelpSuggestThreshold(t *testing.T) {
+	cmd := &Command{
+		Suggest:          true,
+		SuggestThreshold: 0.8,
+		Commands: []*Command{
+			{
+				Name: "putz",
+			},
+		},
+	}
+
+	cmd.setupDefaults([]string{"foo"})
+
+	err := ShowCommandHelp(context.Background(), cmd, "delete")
+	assert.EqualError(t, err, "No help topic for 'delete'")
+}
+
+func TestPrintHelpCustomConcurrent(t *testing.T) {
+	cmd := buildExtendedTestCommand()
+	cmd.setupDefaults([]string{"greet"})
+
+	render := func(wrapAt int) string {
+		var buf bytes.Buffer
+		printHelpCustom(&buf, RootCommandHelpTemplate, cmd, map[string]any{
+			"wrapAt": func() int { return wrapAt },
+		})
+		return buf.String()
+	}
+	narrow, wide := render(40), render(120)
+	require.NotEqual(t, narrow, wide)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				assert.Equal(t, narrow, render(40))
+			} else {
+				assert.Equal(t, wide, render(120))
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestPrintHelpCustomKeepsFuncs(t *testing.T) {
+	funcs := map[string]any{"wrapAt": func() int { return 40 }}
+	printHelpCustom(io.Discard, RootCommandHelpTemplate, buildExtendedTestCommand(), funcs)
+	assert.Len(t, funcs, 1)
+}
+
+func BenchmarkPrintHelpCustom(b *testing.B) {
+	cmd := buildExtendedTestCommand()
+	cmd.setupDefaults([]string{"greet"})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		printHelpCustom(io.Discard, RootCommandHelpTemplate, cmd, nil)
+	}
+}
@@ -2,8 +2,27 @@ package cli
 
 import (
 	"os"
+
+	"gopkg.in/yaml.v3"
 )
 
 func init() {
 	_ = os.Setenv("CLI_TEMPLATE_REPANIC", "1")
+
+	// cliyaml imports this package, so the tests here read YAML config
+	// files with testYAML instead
+	RegisterConfigFormat("yaml", testYAML, ".yaml", ".yml")
+}
+
+// testYAML decodes config files in YAML format
+var testYAML ConfigFormat = testYAMLFormat{}
+
+type testYAMLFormat struct{}
+
+func (testYAMLFormat) Decode(data []byte) (map[string]any, error) {
+	values := map[string]any{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
 }
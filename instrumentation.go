@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+)
+
+// Instrumentation creates a span for each run of a command, see
+// Command.Instrumentation. The package does not depend on a tracing library,
+// an adapter implements the interface with one like OpenTelemetry.
+type Instrumentation interface {
+	// StartSpan starts a span with the name and attributes. The carrier holds
+	// the trace context of the parent span, e.g. of the CI job running the
+	// command, which can be extracted with a propagator. The returned context
+	// is passed to the Before funcs and the Action.
+	StartSpan(ctx context.Context, name string, carrier EnvCarrier, attrs map[string]any) (context.Context, Span)
+}
+
+// Span is a span started by an Instrumentation
+type Span interface {
+	// End ends the span with the error of the command, if any, and the
+	// attributes cli.exit_code and cli.duration_ms
+	End(err error, attrs map[string]any)
+}
+
+// envCarrierKeys are the keys of the trace context propagated in env vars
+var envCarrierKeys = []string{"traceparent", "tracestate", "baggage"}
+
+// EnvCarrier reads and writes the trace context propagated in the env vars
+// TRACEPARENT, TRACESTATE and BAGGAGE, e.g. by CI systems. It implements the
+// TextMapCarrier interface of OpenTelemetry.
+type EnvCarrier struct{}
+
+// Get returns the value of the env var of the key
+func (EnvCarrier) Get(key string) string {
+	return os.Getenv(envCarrierName(key))
+}
+
+// Set sets the env var of the key, so the trace context is propagated to
+// child processes
+func (EnvCarrier) Set(key, value string) {
+	_ = os.Setenv(envCarrierName(key), value)
+}
+
+// Keys returns the keys of the trace context env vars which are set
+func (EnvCarrier) Keys() []string {
+	var keys []string
+	for _, key := range envCarrierKeys {
+		if _, ok := os.LookupEnv(envCarrierName(key)); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func envCarrierName(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// startSpan starts a span for the command with the Instrumentation of the
+// root command, if any
+func (cmd *Command) startSpan(ctx context.Context) context.Context {
+	root := cmd.Root()
+	if root.Instrumentation == nil {
+		return ctx
+	}
+
+	tracef("starting span (cmd=%[1]q)", cmd.Name)
+	start := time.Now()
+	ctx, span := root.Instrumentation.StartSpan(ctx, cmd.FullName(), EnvCarrier{}, map[string]any{
+		"cli.command.name": cmd.Name,
+		"cli.command.path": cmd.FullName(),
+	})
+	root.spanEnd = func(err error) {
+		code := 0
+		if err != nil {
+			code = exitCodeOf(err)
+		}
+		span.End(err, map[string]any{
+			"cli.exit_code":   code,
+			"cli.duration_ms": time.Since(start).Milliseconds(),
+		})
+	}
+	return ctx
+}
+
+// endSpan ends the span started by startSpan, if it has not ended yet
+func (cmd *Command) endSpan(err error) {
+	root := cmd.Root()
+	if root.spanEnd == nil {
+		return
+	}
+
+	tracef("ending span with error %[1]v (cmd=%[2]q)", err, cmd.Name)
+	end := root.spanEnd
+	root.spanEnd = nil
+	end(err)
+}
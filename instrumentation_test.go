@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSpanKey struct{}
+
+type testSpan struct {
+	name     string
+	parent   string
+	attrs    map[string]any
+	ended    int
+	err      error
+	endAttrs map[string]any
+}
+
+func (s *testSpan) End(err error, attrs map[string]any) {
+	s.ended++
+	s.err, s.endAttrs = err, attrs
+}
+
+type testInstrumentation struct {
+	spans []*testSpan
+}
+
+func (i *testInstrumentation) StartSpan(ctx context.Context, name string, carrier EnvCarrier, attrs map[string]any) (context.Context, Span) {
+	span := &testSpan{name: name, parent: carrier.Get("traceparent"), attrs: attrs}
+	i.spans = append(i.spans, span)
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+func TestCommand_Instrumentation(t *testing.T) {
+	t.Setenv("TRACEPARENT", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	tests := []struct {
+		name     string
+		args     []string
+		err      error
+		wantCode int
+	}{
+		{name: "success", args: []string{"app", "deploy"}},
+		{name: "error", args: []string{"app", "deploy"}, err: errors.New("failed"), wantCode: 1},
+		{name: "exit code", args: []string{"app", "deploy"}, err: Exit("failed", 3), wantCode: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var inst testInstrumentation
+			var spanInAction any
+			cmd := &Command{
+				Name:            "app",
+				Instrumentation: &inst,
+				ExitErrHandler:  func(context.Context, *Command, error) {},
+				Commands: []*Command{
+					{
+						Name: "deploy",
+						Action: func(ctx context.Context, cmd *Command) error {
+							spanInAction = ctx.Value(testSpanKey{})
+							return test.err
+						},
+					},
+				},
+			}
+
+			err := cmd.Run(buildTestContext(t), test.args)
+			assert.Equal(t, test.err, err)
+
+			require.Len(t, inst.spans, 1)
+			span := inst.spans[0]
+			assert.Same(t, span, spanInAction)
+			assert.Equal(t, "app deploy", span.name)
+			assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", span.parent)
+			assert.Equal(t, map[string]any{"cli.command.name": "deploy", "cli.command.path": "app deploy"}, span.attrs)
+			assert.Equal(t, 1, span.ended)
+			assert.Equal(t, test.err, span.err)
+			assert.Equal(t, test.wantCode, span.endAttrs["cli.exit_code"])
+			assert.Contains(t, span.endAttrs, "cli.duration_ms")
+		})
+	}
+}
+
+func TestEnvCarrier(t *testing.T) {
+	t.Setenv("TRACEPARENT", "")
+	t.Setenv("BAGGAGE", "user=alice")
+	// restored after the test, like the other env vars
+	t.Setenv("TRACESTATE", "")
+	require.NoError(t, os.Unsetenv("TRACESTATE"))
+
+	var carrier EnvCarrier
+	assert.Equal(t, "user=alice", carrier.Get("baggage"))
+	assert.Equal(t, []string{"traceparent", "baggage"}, carrier.Keys())
+
+	carrier.Set("tracestate", "vendor=1")
+	assert.Equal(t, "vendor=1", carrier.Get("tracestate"))
+	assert.Equal(t, []string{"traceparent", "tracestate", "baggage"}, carrier.Keys())
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned by running a command which is Exclusive while
+// another instance holds its lock
+var ErrLocked = errors.New("another instance is running")
+
+// errLockHeld is returned by tryLock if another process holds the lock
+var errLockHeld = errors.New("lock is held")
+
+// lockPath returns the path of the lock file of the command, or "" if it is
+// not exclusive. The nearest of the command and its parents with a LockFile
+// or Exclusive set applies. Without a LockFile the lock file is named after
+// the command setting Exclusive, so unrelated exclusive commands do not
+// lock each other out, and is in the CacheDir of the user, so instances run
+// by other users are not locked out either.
+func (cmd *Command) lockPath() (string, error) {
+	for _, pCmd := range cmd.Lineage() {
+		if pCmd.LockFile != "" {
+			return pCmd.LockFile, nil
+		}
+		if pCmd.Exclusive {
+			dir, err := cmd.Root().CacheDir()
+			if err != nil {
+				return "", fmt.Errorf("could not find lock file: %w", err)
+			}
+			return filepath.Join(dir, "lock-"+strings.ReplaceAll(pCmd.FullName(), " ", "-")), nil
+		}
+	}
+	return "", nil
+}
+
+// lock takes the lock of the command if it is exclusive and returns a
+// function releasing it. The pid of the process is written to the lock file,
+// so it can be reported by other instances.
+func (cmd *Command) lock() (func(), error) {
+	path, err := cmd.lockPath()
+	if err != nil || path == "" {
+		return func() {}, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open lock file: %w", err)
+	}
+
+	if err := tryLock(f); err != nil {
+		_ = f.Close()
+		if !errors.Is(err, errLockHeld) {
+			return nil, fmt.Errorf("could not lock %s: %w", path, err)
+		}
+
+		data, _ := os.ReadFile(path)
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+			return nil, fmt.Errorf("%w (pid %d)", ErrLocked, pid)
+		}
+		return nil, ErrLocked
+	}
+
+	tracef("locked %[1]q (cmd=%[2]q)", path, cmd.Name)
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return func() {
+		tracef("unlocking %[1]q (cmd=%[2]q)", path, cmd.Name)
+		_ = f.Truncate(0)
+		_ = f.Close()
+	}, nil
+}
+
+// lockFile takes an exclusive lock of the file at path, waiting up to
+// timeout while another process holds it, and returns a function releasing
+// it. It guards files which several instances read and write, like the
+// queue of telemetry events.
+func lockFile(path string, timeout time.Duration) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := tryLock(f)
+		if err == nil {
+			return func() { _ = f.Close() }, nil
+		}
+		if !errors.Is(err, errLockHeld) || time.Now().After(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("could not lock %s: %w", path, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package cli
+
+import (
+	"errors"
+	"os"
+)
+
+// tryLock fails as files cannot be locked on this platform, so exclusive
+// commands cannot run rather than run concurrently
+func tryLock(*os.File) error {
+	return errors.ErrUnsupported
+}
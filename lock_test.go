@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Exclusive(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "app.lock")
+
+	if runtime.GOOS == "wasip1" {
+		cmd := &Command{Name: "app", LockFile: lockFile, Action: func(context.Context, *Command) error { return nil }}
+		assert.ErrorIs(t, cmd.Run(buildTestContext(t), []string{"app"}), errors.ErrUnsupported)
+		return
+	}
+
+	newCmd := func(action ActionFunc) *Command {
+		return &Command{
+			Name:           "app",
+			LockFile:       lockFile,
+			ExitErrHandler: func(context.Context, *Command, error) {},
+			Commands: []*Command{
+				{Name: "migrate", Action: action},
+			},
+		}
+	}
+
+	ran := false
+	var innerErr error
+	outer := newCmd(func(ctx context.Context, _ *Command) error {
+		data, err := os.ReadFile(lockFile)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%d\n", os.Getpid()), string(data))
+
+		innerErr = newCmd(func(context.Context, *Command) error {
+			ran = true
+			return nil
+		}).Run(ctx, []string{"app", "migrate"})
+		return nil
+	})
+	require.NoError(t, outer.Run(buildTestContext(t), []string{"app", "migrate"}))
+
+	assert.False(t, ran)
+	assert.ErrorIs(t, innerErr, ErrLocked)
+	assert.EqualError(t, innerErr, fmt.Sprintf("another instance is running (pid %d)", os.Getpid()))
+
+	// released after the action
+	require.NoError(t, newCmd(func(context.Context, *Command) error {
+		ran = true
+		return nil
+	}).Run(buildTestContext(t), []string{"app", "migrate"}))
+	assert.True(t, ran)
+}
+
+func TestCommand_ExclusiveCommands(t *testing.T) {
+	if runtime.GOOS == "wasip1" {
+		t.Skip("files cannot be locked on wasip1")
+	}
+	t.Setenv("APP_CACHE_DIR", t.TempDir())
+
+	newCmd := func(migrate, sync ActionFunc) *Command {
+		return &Command{
+			Name: "app",
+			Commands: []*Command{
+				{Name: "db", Commands: []*Command{{Name: "migrate", Exclusive: true, Action: migrate}}},
+				{Name: "sync", Exclusive: true, Action: sync},
+			},
+		}
+	}
+
+	synced := false
+	var syncErr, migrateErr error
+	outer := newCmd(func(context.Context, *Command) error {
+		// run as other instances, not as subcommands of this run
+		syncErr = newCmd(nil, func(context.Context, *Command) error {
+			synced = true
+			return nil
+		}).Run(buildTestContext(t), []string{"app", "sync"})
+		migrateErr = newCmd(func(context.Context, *Command) error { return nil }, nil).
+			Run(buildTestContext(t), []string{"app", "db", "migrate"})
+		return nil
+	}, nil)
+	require.NoError(t, outer.Run(buildTestContext(t), []string{"app", "db", "migrate"}))
+
+	require.NoError(t, syncErr)
+	assert.True(t, synced)
+	assert.ErrorIs(t, migrateErr, ErrLocked)
+}
+
+func TestCommand_lockPath(t *testing.T) {
+	sub := &Command{Name: "sub"}
+	root := &Command{Name: "app", Commands: []*Command{sub}}
+	sub.parent = root
+
+	path, err := sub.lockPath()
+	require.NoError(t, err)
+	assert.Empty(t, path)
+
+	cacheDir := t.TempDir()
+	t.Setenv("APP_CACHE_DIR", cacheDir)
+	root.Exclusive = true
+	path, err = sub.lockPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(cacheDir, "lock-app"), path)
+
+	root.Exclusive = false
+	sub.Exclusive = true
+	path, err = sub.lockPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(cacheDir, "lock-app-sub"), path)
+
+	sub.LockFile = "/var/lock/sub.lock"
+	path, err = sub.lockPath()
+	require.NoError(t, err)
+	assert.Equal(t, "/var/lock/sub.lock", path)
+}
+
+func TestLockFile(t *testing.T) {
+	if runtime.GOOS == "wasip1" {
+		t.Skip("files cannot be locked on wasip1")
+	}
+	path := filepath.Join(t.TempDir(), "queue.lock")
+
+	unlock, err := lockFile(path, time.Second)
+	require.NoError(t, err)
+
+	_, err = lockFile(path, 50*time.Millisecond)
+	require.ErrorIs(t, err, errLockHeld)
+
+	unlock()
+	unlock, err = lockFile(path, time.Second)
+	require.NoError(t, err)
+	unlock()
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// names of the flags returned by LoggingFlags
+const (
+	logLevelFlagName  = "log-level"
+	logFormatFlagName = "log-format"
+	verboseFlagName   = "verbose"
+)
+
+// LoggingFlags returns the flags configuring the logger of Command.Logger,
+// to be added to the root command:
+//
+//	--log-level LEVEL   minimum level: debug, info, warn or error
+//	--log-format FORMAT text or json
+//	--quiet, -q         only log errors, the QuietFlag
+//	--verbose, -v       lower the level, -vv logs debug messages
+//
+// The -v alias takes precedence over the alias of the VersionFlag, which
+// stays available as --version.
+func LoggingFlags() []Flag {
+	flags := []Flag{
+		&LogLevelFlag{
+			Name:  logLevelFlagName,
+			Usage: "minimum `LEVEL` of log messages: debug, info, warn or error",
+			Value: slog.LevelInfo,
+		},
+		&StringFlag{
+			Name:  logFormatFlagName,
+			Usage: "`FORMAT` of log messages: text or json",
+			Value: "text",
+			Validator: func(format string) error {
+				switch format {
+				case "text", "json":
+					return nil
+				}
+				return fmt.Errorf("invalid log format %q, must be text or json", format)
+			},
+		},
+		&BoolFlag{
+			Name:        verboseFlagName,
+			Aliases:     []string{"v"},
+			Usage:       "log more, repeat to log more",
+			HideDefault: true,
+		},
+	}
+	if QuietFlag != nil {
+		flags = append(flags, QuietFlag)
+	}
+	return flags
+}
+
+// Logger returns a logger writing to the ErrWriter of the root command,
+// configured by the LoggingFlags of the command or its parents. Every
+// --verbose lowers the level by one step from the --log-level, while --quiet
+// only logs errors. Without the flags it logs messages from level info as
+// text. The logger is created once per root command.
+func (cmd *Command) Logger() *slog.Logger {
+	root := cmd.Root()
+	if root.logger != nil {
+		return root.logger
+	}
+
+	level := slog.LevelInfo
+	if cmd.lookupFlag(logLevelFlagName) != nil {
+		level = cmd.Level(logLevelFlagName)
+	}
+	if cmd.lookupFlag(verboseFlagName) != nil {
+		level -= slog.Level(4 * cmd.Count(verboseFlagName))
+	}
+	if QuietFlag != nil {
+		for _, name := range QuietFlag.Names() {
+			if cmd.lookupFlag(name) != nil && cmd.Bool(name) {
+				level = max(level, slog.LevelError)
+			}
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(root.ErrWriter, opts)
+	if cmd.lookupFlag(logFormatFlagName) != nil && cmd.String(logFormatFlagName) == "json" {
+		handler = slog.NewJSONHandler(root.ErrWriter, opts)
+	}
+
+	tracef("creating logger with level %[1]v (cmd=%[2]q)", level, cmd.Name)
+	root.logger = slog.New(handler)
+	return root.logger
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Logger(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "default",
+			want:    []string{"level=INFO msg=info", "level=ERROR msg=error"},
+			notWant: []string{"msg=debug"},
+		},
+		{
+			name: "verbose",
+			args: []string{"-v"},
+			want: []string{"level=DEBUG msg=debug", "level=INFO msg=info"},
+		},
+		{
+			name:    "level",
+			args:    []string{"--log-level", "warn"},
+			want:    []string{"level=WARN msg=warn"},
+			notWant: []string{"msg=info"},
+		},
+		{
+			name:    "level and verbose",
+			args:    []string{"--log-level", "error", "--verbose"},
+			want:    []string{"level=WARN msg=warn"},
+			notWant: []string{"msg=info"},
+		},
+		{
+			name:    "quiet",
+			args:    []string{"-q", "-v"},
+			want:    []string{"level=ERROR msg=error"},
+			notWant: []string{"msg=warn"},
+		},
+		{
+			name:    "json",
+			args:    []string{"--log-format", "json"},
+			want:    []string{`"level":"INFO","msg":"info"`},
+			notWant: []string{"level=INFO"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quiet := QuietFlag.(*BoolFlag)
+			quiet.reset()
+			defer quiet.reset()
+
+			var errOut bytes.Buffer
+			cmd := &Command{
+				Name:      "app",
+				ErrWriter: &errOut,
+				Flags:     LoggingFlags(),
+				Commands: []*Command{
+					{
+						Name: "sub",
+						Action: func(_ context.Context, cmd *Command) error {
+							logger := cmd.Logger()
+							assert.Same(t, logger, cmd.Root().Logger())
+							logger.Debug("debug")
+							logger.Info("info")
+							logger.Warn("warn")
+							logger.Error("error")
+							return nil
+						},
+					},
+				},
+			}
+
+			require.NoError(t, cmd.Run(buildTestContext(t), append(append([]string{"app"}, test.args...), "sub")))
+			for _, want := range test.want {
+				assert.Contains(t, errOut.String(), want)
+			}
+			for _, notWant := range test.notWant {
+				assert.NotContains(t, errOut.String(), notWant)
+			}
+		})
+	}
+}
+
+func TestLoggingFlags_VersionFlag(t *testing.T) {
+	var out bytes.Buffer
+	verbose := -1
+	cmd := &Command{
+		Name:    "app",
+		Version: "1.2.3",
+		Writer:  &out,
+		Flags:   LoggingFlags(),
+		Action: func(_ context.Context, cmd *Command) error {
+			verbose = cmd.Count("verbose")
+			return nil
+		},
+	}
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "-v"}))
+	assert.Equal(t, 1, verbose)
+	assert.Empty(t, out.String())
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "--version"}))
+	assert.Equal(t, "app version 1.2.3\n", out.String())
+}
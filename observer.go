@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+)
+
+// EventType is the type of a lifecycle event passed to an [Observer]
+type EventType int
+
+const (
+	// EventCommandResolved is emitted once the command to run has been
+	// determined, before any Before funcs run
+	EventCommandResolved EventType = iota
+	// EventFlagsParsed is emitted for each command along the way after its
+	// flags have been parsed successfully
+	EventFlagsParsed
+	// EventHelpShown is emitted when help is shown because it was requested
+	// or the command has no Action
+	EventHelpShown
+	// EventActionStarted is emitted right before the Action is called
+	EventActionStarted
+	// EventActionFinished is emitted after the Action has returned, with the
+	// returned error if any
+	EventActionFinished
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventCommandResolved:
+		return "CommandResolved"
+	case EventFlagsParsed:
+		return "FlagsParsed"
+	case EventHelpShown:
+		return "HelpShown"
+	case EventActionStarted:
+		return "ActionStarted"
+	case EventActionFinished:
+		return "ActionFinished"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event is a lifecycle event of running a command
+type Event struct {
+	Type    EventType
+	Command *Command
+	Err     error
+}
+
+// Observer is notified of the lifecycle events of running a command, e.g.
+// for analytics or audit logging, see [Command.Observer]
+type Observer interface {
+	Observe(ctx context.Context, ev Event)
+}
+
+// ObserverFunc is an adapter to allow the use of ordinary functions as
+// an [Observer]
+type ObserverFunc func(ctx context.Context, ev Event)
+
+// Observe calls f(ctx, ev)
+func (f ObserverFunc) Observe(ctx context.Context, ev Event) {
+	f(ctx, ev)
+}
+
+// notify passes an event for the command to the Observer of the root command
+func (cmd *Command) notify(ctx context.Context, typ EventType, err error) {
+	observer := cmd.Root().Observer
+	if observer == nil {
+		return
+	}
+
+	tracef("notifying observer of %[1]v (cmd=%[2]q)", typ, cmd.Name)
+	observer.Observe(ctx, Event{Type: typ, Command: cmd, Err: err})
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// OutputFormatter writes v to w in an output format, see Command.Print
+type OutputFormatter func(w io.Writer, v any) error
+
+// OutputFormatters are the formats Command.Print can write, by the name
+// given with the OutputFlag. Formats can be added or replaced, e.g. yaml is
+// added by importing github.com/urfave/cli/v3/cliyaml.
+var OutputFormatters = map[string]OutputFormatter{
+	"json":  FormatJSON,
+	"table": FormatTable,
+}
+
+// defaultOutputFormat is used by Command.Print if the OutputFlag is not set
+const defaultOutputFormat = "table"
+
+// OutputFlag selects the format of Command.Print with --output or -o, one of
+// the OutputFormatters. It is not added to commands automatically.
+var OutputFlag Flag = &StringFlag{
+	Name:    "output",
+	Aliases: []string{"o"},
+	Usage:   "output `FORMAT`, e.g. table or json",
+	Value:   defaultOutputFormat,
+	Validator: func(format string) error {
+		if _, ok := OutputFormatters[format]; !ok {
+			return fmt.Errorf("invalid output format %q, must be one of %s", format, strings.Join(outputFormats(), ", "))
+		}
+		return nil
+	},
+}
+
+func outputFormats() []string {
+	formats := make([]string, 0, len(OutputFormatters))
+	for name := range OutputFormatters {
+		formats = append(formats, name)
+	}
+	slices.Sort(formats)
+	return formats
+}
+
+// Print writes v to the Writer of the root command in the format selected
+// with the OutputFlag of the command or its parents, or as a table if the
+// flag is not set.
+func (cmd *Command) Print(v any) error {
+	format := defaultOutputFormat
+	if OutputFlag != nil {
+		for _, name := range OutputFlag.Names() {
+			if cmd.lookupFlag(name) != nil {
+				format = cmd.String(name)
+				break
+			}
+		}
+	}
+
+	formatter, ok := OutputFormatters[format]
+	if !ok {
+		return fmt.Errorf("invalid output format %q, must be one of %s", format, strings.Join(outputFormats(), ", "))
+	}
+	return formatter(cmd.Root().Writer, v)
+}
+
+// FormatJSON writes v as indented JSON
+func FormatJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// FormatTable writes v as a table with aligned columns. A struct is written
+// as a table with one row and a slice of structs with a row per element.
+// The columns are the exported fields, with the header given in the table
+// tag of the field, or else its name in the json tag or the field name, in
+// upper case. Fields with the tag table:"-" are left out. Maps are written
+// with a KEY and a VALUE column sorted by key, other slices with a line per
+// element and other values as they are.
+func FormatTable(w io.Writer, v any) error {
+	rv := indirectValue(reflect.ValueOf(v))
+
+	switch {
+	case !rv.IsValid():
+		return nil
+	case rv.Kind() == reflect.Struct:
+		return structTable(rv.Type(), []reflect.Value{rv}).Write(w)
+	case (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem() != reflect.TypeOf(byte(0)):
+		elemType := rv.Type().Elem()
+		for elemType.Kind() == reflect.Pointer {
+			elemType = elemType.Elem()
+		}
+		rows := make([]reflect.Value, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			rows = append(rows, indirectValue(rv.Index(i)))
+		}
+		if elemType.Kind() == reflect.Struct {
+			return structTable(elemType, rows).Write(w)
+		}
+		t := NewTable()
+		for _, row := range rows {
+			t.Append(tableCell(row))
+		}
+		return t.Write(w)
+	case rv.Kind() == reflect.Map:
+		keys := rv.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+		})
+		t := NewTable("KEY", "VALUE")
+		for _, key := range keys {
+			t.Append(tableCell(key), tableCell(rv.MapIndex(key)))
+		}
+		return t.Write(w)
+	}
+
+	_, err := fmt.Fprintln(w, tableCell(rv))
+	return err
+}
+
+// structTable returns a Table of the rows of the struct type t
+func structTable(t reflect.Type, rows []reflect.Value) *Table {
+	var (
+		headers []string
+		fields  [][]int
+	)
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		header := field.Name
+		if tag, ok := field.Tag.Lookup("table"); ok {
+			if tag == "-" {
+				continue
+			}
+			header = tag
+		} else if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name == "-" {
+			continue
+		} else if name != "" {
+			header = name
+		}
+		headers = append(headers, strings.ToUpper(header))
+		fields = append(fields, field.Index)
+	}
+
+	table := NewTable(headers...)
+	for _, row := range rows {
+		cells := make([]string, len(fields))
+		for i, index := range fields {
+			if row.IsValid() {
+				if field, err := row.FieldByIndexErr(index); err == nil {
+					cells[i] = tableCell(field)
+				}
+			}
+		}
+		table.Append(cells...)
+	}
+	return table
+}
+
+// tableCell returns the text of a value in a table, empty for nil
+func tableCell(v reflect.Value) string {
+	v = indirectValue(v)
+	if !v.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// indirectValue follows pointers and interfaces to the value they point to,
+// it returns the zero Value for nil
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type outputTestBase struct {
+	ID int `json:"id"`
+}
+
+type outputTestCluster struct {
+	outputTestBase
+	Name    string   `json:"name"`
+	Region  string   `table:"LOCATION" json:"region"`
+	Nodes   *int     `json:"nodes,omitempty"`
+	Secret  string   `table:"-" json:"-"`
+	Comment string   `json:"-"`
+	Tags    []string `json:"tags"`
+}
+
+func TestCommand_Print(t *testing.T) {
+	three := 3
+	clusters := []*outputTestCluster{
+		{outputTestBase{1}, "dev", "eu-west-1", &three, "s", "c", []string{"a", "b"}},
+		{outputTestBase{2}, "production", "us-east-1", nil, "s", "c", nil},
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		v       any
+		want    string
+		wantErr string
+	}{
+		{
+			name: "table",
+			v:    clusters,
+			want: "ID  NAME        LOCATION   NODES  TAGS\n" +
+				"1   dev         eu-west-1  3      [a b]\n" +
+				"2   production  us-east-1         []\n",
+		},
+		{
+			name: "struct table",
+			v:    clusters[0],
+			want: "ID  NAME  LOCATION   NODES  TAGS\n" +
+				"1   dev   eu-west-1  3      [a b]\n",
+		},
+		{
+			name: "map table",
+			args: []string{"-o", "table"},
+			v:    map[string]int{"b": 2, "a": 1},
+			want: "KEY  VALUE\na    1\nb    2\n",
+		},
+		{
+			name: "list table",
+			v:    []string{"a", "b"},
+			want: "a\nb\n",
+		},
+		{
+			name: "json",
+			args: []string{"--output", "json"},
+			v:    clusters[1:],
+			want: `[
+  {
+    "id": 2,
+    "name": "production",
+    "region": "us-east-1",
+    "tags": null
+  }
+]
+`,
+		},
+		{
+			name:    "invalid",
+			args:    []string{"-o", "xml"},
+			wantErr: `invalid value "xml" for flag -o: invalid output format "xml", must be one of json, table`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output := *OutputFlag.(*StringFlag)
+			output.reset()
+
+			var out bytes.Buffer
+			cmd := &Command{
+				Name:   "app",
+				Writer: &out,
+				Flags:  []Flag{&output},
+				Action: func(_ context.Context, cmd *Command) error {
+					return cmd.Print(test.v)
+				},
+			}
+
+			err := cmd.Run(buildTestContext(t), append([]string{"app"}, test.args...))
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, out.String())
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+)
+
+// PagerFunc writes output which is longer than the terminal to out through
+// a pager, see Command.Pager and clipager.Pager
+type PagerFunc func(cmd *Command, out io.Writer, output []byte) error
+
+// defaultTerminalHeight is used when the height of the terminal is not known
+const defaultTerminalHeight = 24
+
+// terminalReporter is implemented by readers and writers which are not files
+// but report whether they stand for a terminal, e.g. in tests
+type terminalReporter interface {
+	IsTerminal() bool
+}
+
+var isTerminal = func(w io.Writer) bool {
+	if tr, ok := w.(terminalReporter); ok {
+		return tr.IsTerminal()
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalSizeOf returns the width and height of the terminal w writes to,
+// and false if w is not a terminal or its size is not known
+var terminalSizeOf = func(w io.Writer) (int, int, bool) {
+	f, ok := w.(*os.File)
+	if !ok {
+		return 0, 0, false
+	}
+	width, height, err := terminalSize(f)
+	return width, height, err == nil && width > 0 && height > 0
+}
+
+// terminalHeight returns the height of the terminal out writes to: the
+// height in the LINES env var if set, else the actual height of the
+// terminal, or defaultTerminalHeight if it is not known
+func terminalHeight(out io.Writer) int {
+	if lines, err := strconv.Atoi(os.Getenv("LINES")); err == nil && lines > 0 {
+		return lines
+	}
+	if _, height, ok := terminalSizeOf(out); ok {
+		return height
+	}
+	return defaultTerminalHeight
+}
+
+// pagerEnabled returns true if output of the command written to out should
+// be shown through the Pager, see Command.Pager
+func (cmd *Command) pagerEnabled(out io.Writer) bool {
+	return cmd.Root().Pager != nil && os.Getenv("NO_PAGER") == "" && isTerminal(out)
+}
+
+// page writes output to out through the Pager of the root command if it is
+// longer than the terminal height. The output is written to out directly if
+// it fits on the terminal or the pager fails.
+func (cmd *Command) page(out io.Writer, output []byte) {
+	if bytes.Count(output, []byte("\n")) < terminalHeight(out) {
+		_, _ = out.Write(output)
+		return
+	}
+
+	if err := cmd.Root().Pager(cmd, out, output); err != nil {
+		tracef("failed to run pager: %[1]v (cmd=%[2]q)", err, cmd.Name)
+		_, _ = out.Write(output)
+	}
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultPanicExitCode is the exit code used for recovered panics unless
+// PanicExitCode is set, it matches the one of an unrecovered panic
+const defaultPanicExitCode = 2
+
+// panicError is returned by Run for panics recovered with Command.Recover.
+// Formatted with %+v it prints a crash report with the command, its flag
+// values and the stack of the panic.
+type panicError struct {
+	value    any
+	command  string
+	flags    []string
+	stack    string
+	exitCode int
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+func (e *panicError) ExitCode() int {
+	return e.exitCode
+}
+
+func (e *panicError) Format(s fmt.State, verb rune) {
+	if verb != 'v' || !s.Flag('+') {
+		_, _ = fmt.Fprint(s, e.Error())
+		return
+	}
+	_, _ = fmt.Fprint(s, e.report())
+}
+
+func (e *panicError) report() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n\ncommand: %s\n", e.Error(), e.command)
+	if len(e.flags) > 0 {
+		fmt.Fprintf(&sb, "flags:\n")
+		for _, fl := range e.flags {
+			fmt.Fprintf(&sb, "   %s\n", fl)
+		}
+	}
+	fmt.Fprintf(&sb, "\n%s", e.stack)
+	return sb.String()
+}
+
+// handlePanic turns a recovered panic into an error, calling the
+// PanicHandler if set and writing the crash report to CrashFile
+func (cmd *Command) handlePanic(ctx context.Context, value any, stack []byte) error {
+	tracef("recovered panic %[1]v (cmd=%[2]q)", value, cmd.Name)
+
+	pCmd := cmd.runningCmd
+	if pCmd == nil {
+		pCmd = cmd
+	}
+
+	if cmd.PanicHandler != nil {
+		return cmd.handleExitCoder(ctx, cmd.PanicHandler(ctx, pCmd, value, stack))
+	}
+
+	exitCode := cmd.PanicExitCode
+	if exitCode == 0 {
+		exitCode = defaultPanicExitCode
+	}
+
+	err := &panicError{
+		value:    value,
+		command:  pCmd.FullName(),
+		flags:    pCmd.setFlagValues(),
+		stack:    trimPanicStack(stack),
+		exitCode: exitCode,
+	}
+
+	if cmd.CrashFile != "" {
+		if werr := os.WriteFile(cmd.CrashFile, []byte(err.report()), 0o600); werr != nil {
+			tracef("failed to write crash file %[1]q: %[2]v (cmd=%[3]q)", cmd.CrashFile, werr, cmd.Name)
+		} else {
+			err.stack += fmt.Sprintf("\ncrash report written to %s\n", cmd.CrashFile)
+		}
+	}
+
+	return cmd.handleExitCoder(ctx, err)
+}
+
+// setFlagValues returns name=value for all flags set for the command and
+// its parents, with the values of sensitive flags redacted
+func (cmd *Command) setFlagValues() []string {
+	var values []string
+	for _, pCmd := range cmd.Lineage() {
+		for _, fl := range pCmd.Flags {
+			if !fl.IsSet() || len(fl.Names()) == 0 {
+				continue
+			}
+
+			name := fl.Names()[0]
+			value := "REDACTED"
+			if sf, ok := fl.(SensitiveFlag); !ok || !sf.IsSensitive() {
+				value = fmt.Sprintf("%v", pCmd.Value(name))
+			}
+			values = append(values, fmt.Sprintf("--%s=%s", name, value))
+		}
+	}
+	return values
+}
+
+// trimPanicStack removes the frames of the recovery itself from a stack
+// captured by debug.Stack in a deferred function, so the stack starts at the
+// frame which panicked
+func trimPanicStack(stack []byte) string {
+	header, rest, ok := bytes.Cut(stack, []byte("\n"))
+	if !ok {
+		return string(stack)
+	}
+
+	idx := bytes.Index(rest, []byte("\npanic("))
+	if idx < 0 {
+		return string(stack)
+	}
+
+	// skip the panic call and the line with its location
+	frames := rest[idx+1:]
+	for i := 0; i < 2; i++ {
+		if _, after, ok := bytes.Cut(frames, []byte("\n")); ok {
+			frames = after
+		}
+	}
+
+	return string(header) + "\n" + string(frames)
+}
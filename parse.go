@@ -2,7 +2,10 @@ package cli
 
 import (
 	"flag"
+	"fmt"
+	"slices"
 	"strings"
+	"unicode/utf8"
 )
 
 type iterativeParser interface {
@@ -80,6 +83,52 @@ func parseIter(set *flag.FlagSet, ip iterativeParser, args []string, shellComple
 
 const providedButNotDefinedErrMsg = "flag provided but not defined: -"
 
+// UnknownFlagPolicy defines how a command handles flags which are not defined
+type UnknownFlagPolicy int
+
+const (
+	// UnknownFlagError fails parsing with an error, this is the default
+	UnknownFlagError UnknownFlagPolicy = iota
+	// UnknownFlagIgnore drops unknown flags and continues parsing
+	UnknownFlagIgnore
+	// UnknownFlagCollect continues parsing and collects unknown flags,
+	// which are then available via Command.UnknownArgs
+	UnknownFlagCollect
+)
+
+// skipUnknownFlag checks whether err is caused by an unknown flag which
+// the policy of the command allows to skip. If so, it returns the args
+// remaining after the unknown flag
+func (cmd *Command) skipUnknownFlag(err error, args []string) ([]string, bool) {
+	if cmd.UnknownFlagPolicy == UnknownFlagError {
+		return nil, false
+	}
+
+	name, nameErr := flagFromError(err)
+	if nameErr != nil || name == "help" || name == "h" {
+		return nil, false
+	}
+
+	remaining := cmd.flagSet.Args()
+	consumed := len(args) - len(remaining)
+	if consumed <= 0 {
+		return nil, false
+	}
+
+	tokens := args[consumed-1 : consumed]
+	if len(remaining) > 0 && !strings.Contains(tokens[0], "=") && slices.Contains(cmd.UnknownValueFlags, name) {
+		tokens = args[consumed-1 : consumed+1]
+		remaining = remaining[1:]
+	}
+	tracef("skipping unknown flag %[1]q with policy %[2]v (cmd=%[3]q)", tokens, cmd.UnknownFlagPolicy, cmd.Name)
+
+	if cmd.UnknownFlagPolicy == UnknownFlagCollect {
+		cmd.unknownArgs = append(cmd.unknownArgs, tokens...)
+	}
+
+	return remaining, true
+}
+
 // flagFromError tries to parse a provided flag from an error message. If the
 // parsing fails, it returns the input error and an empty string
 func flagFromError(err error) (string, error) {
@@ -91,30 +140,38 @@ func flagFromError(err error) (string, error) {
 	return trimmed, nil
 }
 
+// splitShortOptions splits a cluster of short options like -abc into its
+// single options -a -b -c. Like with getopt, the first option taking a value
+// ends the cluster and the remaining characters always become its value,
+// e.g. -vn5 is split into -v -n 5 and -nv into -n v, whether or not -v is
+// defined.
 func splitShortOptions(set *flag.FlagSet, arg string) []string {
-	shortFlagsExist := func(s string) bool {
-		for index, c := range s[1:] {
-			if index == (len(s[1:])-1) && c == '-' {
-				break
-			}
-			if f := set.Lookup(string(c)); f == nil {
-				return false
-			}
-		}
-		return true
-	}
-
-	if !isSplittable(arg) || !shortFlagsExist(arg) {
+	if !isSplittable(arg) {
 		return []string{arg}
 	}
 
 	separated := make([]string, 0, len(arg)-1)
-	for _, flagChar := range arg[1:] {
-		if flagChar != '-' {
-			separated = append(separated, "-"+string(flagChar))
-		} else {
+	for index, flagChar := range arg[1:] {
+		if flagChar == '-' && index == len(arg)-2 {
 			separated = append(separated, "-")
+			break
+		}
+
+		f := set.Lookup(string(flagChar))
+		if f == nil {
+			return []string{arg}
+		}
+
+		separated = append(separated, "-"+string(flagChar))
+
+		if bf, ok := f.Value.(boolFlag); ok && bf.IsBoolFlag() {
+			continue
 		}
+
+		if rest := arg[1+index+utf8.RuneLen(flagChar):]; rest != "" {
+			separated = append(separated, rest)
+		}
+		break
 	}
 
 	return separated
@@ -123,3 +180,109 @@ func splitShortOptions(set *flag.FlagSet, arg string) []string {
 func isSplittable(flagArg string) bool {
 	return strings.HasPrefix(flagArg, "-") && !strings.HasPrefix(flagArg, "--") && len(flagArg) > 2
 }
+
+// normalizeFlagNames rewrites flags in args which match a defined flag only
+// case-insensitively or by an unambiguous prefix to the name of the defined
+// flag, up to the first "--" or subcommand name
+func (cmd *Command) normalizeFlagNames(args []string) ([]string, error) {
+	caseInsensitive := cmd.caseInsensitive()
+	allowAbbreviations := cmd.allowAbbreviations()
+	shortOptionHandling := cmd.useShortOptionHandling()
+
+	names := []string{}
+	cmd.flagSet.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+
+	normalized := make([]string, len(args))
+	copy(normalized, args)
+
+	for i, arg := range normalized {
+		if arg == "--" || cmd.Command(arg) != nil {
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			if cmd.SkipFlagParsingAfterFirstArg {
+				break
+			}
+			continue
+		}
+
+		dashes := "-"
+		if strings.HasPrefix(arg, "--") {
+			dashes = "--"
+		}
+
+		name, value, hasValue := strings.Cut(arg[len(dashes):], "=")
+		if name == "" || cmd.flagSet.Lookup(name) != nil {
+			continue
+		}
+
+		canonical := ""
+		if caseInsensitive {
+			for _, n := range names {
+				if strings.EqualFold(n, name) {
+					canonical = n
+					break
+				}
+			}
+		}
+
+		if canonical == "" && allowAbbreviations && len(name) > 1 && (dashes == "--" || !shortOptionHandling) {
+			var err error
+			if canonical, err = cmd.expandFlagAbbreviation(name, names, caseInsensitive); err != nil {
+				return normalized, err
+			}
+		}
+
+		if canonical == "" {
+			continue
+		}
+
+		tracef("normalizing flag %[1]q to %[2]q (cmd=%[3]q)", name, canonical, cmd.Name)
+		normalized[i] = dashes + canonical
+		if hasValue {
+			normalized[i] += "=" + value
+		}
+	}
+
+	return normalized, nil
+}
+
+// expandFlagAbbreviation returns the name of the only flag starting with
+// abbrev, an empty string if there is none or an error if abbrev is
+// ambiguous. Aliases of the same flag do not make an abbreviation ambiguous
+func (cmd *Command) expandFlagAbbreviation(abbrev string, names []string, caseInsensitive bool) (string, error) {
+	var match string
+	seen := map[any]bool{}
+	candidates := []string{}
+	for _, n := range names {
+		if len(n) < 2 || !hasPrefix(n, abbrev, caseInsensitive) {
+			continue
+		}
+
+		var value any = cmd.flagSet.Lookup(n).Value
+		if fv, ok := value.(*fnValue); ok {
+			value = fv.v
+		}
+
+		if seen[value] {
+			continue
+		}
+
+		seen[value] = true
+		match = n
+		candidates = append(candidates, "--"+n)
+	}
+
+	if len(candidates) > 1 {
+		return "", fmt.Errorf("ambiguous flag --%[1]s, could be: %[2]s", abbrev, strings.Join(candidates, ", "))
+	}
+
+	if match != "" {
+		tracef("matched flag %[1]q by abbreviation %[2]q (cmd=%[3]q)", match, abbrev, cmd.Name)
+	}
+
+	return match, nil
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"errors"
+	"slices"
+)
+
+// Invocation is a command line resolved by ParseArgs
+type Invocation struct {
+	// Command resolved to run, its flags and arguments can be read like in
+	// its Action
+	Command *Command
+	// Names of the commands from the root to the resolved command
+	Path []string
+	// Values of the flags of the command and its parents set on the command
+	// line, by the name of the flag
+	Flags map[string]any
+	// Positional arguments of the command
+	Args []string
+	// Whether help or the version was requested instead of running the
+	// command
+	Help    bool
+	Version bool
+}
+
+// ParseArgs resolves the command and parses the flags and arguments of the
+// command line args, including the program name like for Run, without
+// running any Before, Action or After funcs. Flags are only read from the
+// command line, not from env vars, config files or other sources, and
+// required flags are not checked as they may be set there. Plugins are not
+// resolved and args are not read from stdin or response files. It is meant
+// for fuzz tests and tools analyzing command lines. A Clone of cmd is
+// parsed, so cmd is not changed and can be parsed again or run. The
+// Command of the Invocation belongs to that clone.
+func ParseArgs(cmd *Command, args []string) (*Invocation, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no arguments, the first must be the program name")
+	}
+
+	cmd = cmd.Clone()
+	cmd.parseOnly = true
+	cmd.setupDefaults(args)
+	args, cmd.helpFormat = extractHelpFormat(args)
+	cmd.setupCommandGraph()
+
+	inv := &Invocation{}
+	for {
+		rest, err := cmd.parseFlags(&stringSliceArgs{v: args})
+		if err != nil {
+			return nil, err
+		}
+
+		if cmd.checkHelp() {
+			inv.Help = true
+			break
+		}
+		if cmd.parent == nil && !cmd.HideVersion && checkVersion(cmd) {
+			inv.Version = true
+			break
+		}
+		for _, grp := range cmd.MutuallyExclusiveFlags {
+			if err := grp.check(cmd); err != nil {
+				return nil, err
+			}
+		}
+
+		if rest.Present() && cmd.parent == nil && len(cmd.UserAliases) > 0 {
+			expanded, err := cmd.expandUserAlias(rest.Slice())
+			if err != nil {
+				return nil, err
+			}
+			rest = &stringSliceArgs{v: expanded}
+			cmd.parsedArgs = rest
+		}
+
+		subCmd, _, err := cmd.resolveSubcommand(rest)
+		if err != nil {
+			return nil, err
+		}
+		if subCmd == nil {
+			break
+		}
+		subCmd.ensureLoaded()
+		subCmd.setupDefaults(nil)
+		args = cmd.Args().Slice()
+		cmd = subCmd
+	}
+
+	if !inv.Help && !inv.Version && cmd.Action != nil {
+		if err := cmd.checkArgsCount(); err != nil {
+			return nil, err
+		}
+		if len(cmd.Arguments) > 0 {
+			rargs := cmd.Args().Slice()
+			for _, arg := range cmd.Arguments {
+				var err error
+				if rargs, err = arg.Parse(rargs); err != nil {
+					return nil, err
+				}
+			}
+			cmd.parsedArgs = &stringSliceArgs{v: rargs}
+		}
+	}
+
+	inv.Command = cmd
+	inv.Args = cmd.Args().Slice()
+	inv.Flags = make(map[string]any)
+	for _, pCmd := range cmd.Lineage() {
+		inv.Path = append(inv.Path, pCmd.Name)
+		for _, fl := range pCmd.Flags {
+			if fl == HelpFlag || fl == VersionFlag || len(fl.Names()) == 0 {
+				continue
+			}
+			if name := fl.Names()[0]; cmd.IsSet(name) {
+				if _, ok := inv.Flags[name]; !ok {
+					inv.Flags[name] = cmd.Value(name)
+				}
+			}
+		}
+	}
+	slices.Reverse(inv.Path)
+	return inv, nil
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newParseArgsCommand() *Command {
+	return &Command{
+		Name:  "app",
+		Flags: []Flag{&BoolFlag{Name: "debug", Sources: EnvVars("APP_DEBUG")}},
+		Before: func(context.Context, *Command) (context.Context, error) {
+			panic("Before must not run")
+		},
+		UserAliases: map[string][]string{"dp": {"deploy", "--env", "prod"}},
+		Commands: []*Command{
+			{
+				Name:      "deploy",
+				Flags:     []Flag{&StringFlag{Name: "env", Required: true}, &IntFlag{Name: "replicas", Value: 1}},
+				ArgsUsage: "SERVICE",
+				Action: func(context.Context, *Command) error {
+					panic("Action must not run")
+				},
+			},
+		},
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	t.Setenv("APP_DEBUG", "true")
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    *Invocation
+		wantErr string
+	}{
+		{
+			name: "subcommand",
+			args: []string{"app", "--debug", "deploy", "--env", "prod", "--replicas=3", "web"},
+			want: &Invocation{
+				Path:  []string{"app", "deploy"},
+				Flags: map[string]any{"debug": true, "env": "prod", "replicas": int64(3)},
+				Args:  []string{"web"},
+			},
+		},
+		{
+			name: "env vars are not read",
+			args: []string{"app", "deploy", "web"},
+			want: &Invocation{
+				Path:  []string{"app", "deploy"},
+				Flags: map[string]any{},
+				Args:  []string{"web"},
+			},
+		},
+		{
+			name: "user alias",
+			args: []string{"app", "dp", "web"},
+			want: &Invocation{
+				Path:  []string{"app", "deploy"},
+				Flags: map[string]any{"env": "prod"},
+				Args:  []string{"web"},
+			},
+		},
+		{
+			name: "help",
+			args: []string{"app", "deploy", "--help"},
+			want: &Invocation{
+				Path:  []string{"app", "deploy"},
+				Flags: map[string]any{},
+				Args:  []string{},
+				Help:  true,
+			},
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"app", "deploy", "--unknown"},
+			wantErr: "flag provided but not defined: -unknown",
+		},
+		{
+			name:    "no args",
+			wantErr: "no arguments, the first must be the program name",
+		},
+	}
+
+	cmd := newParseArgsCommand()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, err := ParseArgs(cmd, tt.args)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want.Path[len(tt.want.Path)-1], inv.Command.Name)
+			inv.Command = nil
+			assert.Equal(t, tt.want, inv)
+		})
+	}
+}
+
+func TestParseArgs_KeepsCommand(t *testing.T) {
+	cmd := newParseArgsCommand()
+	cmd.Before = nil
+	var env string
+	cmd.Commands[0].Action = func(_ context.Context, cmd *Command) error {
+		env = cmd.String("env")
+		return nil
+	}
+
+	first, err := ParseArgs(cmd, []string{"app", "deploy", "--env", "prod", "web"})
+	require.NoError(t, err)
+	second, err := ParseArgs(cmd, []string{"app", "deploy", "--env", "dev"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "prod", first.Command.String("env"))
+	assert.Equal(t, "dev", second.Command.String("env"))
+	assert.False(t, cmd.parseOnly)
+
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "deploy", "--env", "staging", "web"}))
+	assert.Equal(t, "staging", env)
+}
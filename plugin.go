@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"context"
+)
+
+// PluginFunc runs the plugin implementing a subcommand of cmd with the
+// arguments following its name
+type PluginFunc func(ctx context.Context, cmd *Command, args []string) error
+
+// PluginResolverFunc returns the plugin implementing the unknown subcommand
+// name of cmd, and false if there is none, see cliplugin.PathResolver for
+// plugins which are executables.
+type PluginResolverFunc func(cmd *Command, name string) (PluginFunc, bool)
+
+// pluginResolver returns the PluginResolver of the command or its closest
+// parent which has one
+func (cmd *Command) pluginResolver() PluginResolverFunc {
+	if cmd.Root().parseOnly {
+		return nil
+	}
+	for _, pCmd := range cmd.Lineage() {
+		if pCmd.PluginResolver != nil {
+			return pCmd.PluginResolver
+		}
+	}
+
+	return nil
+}
+
+// isPlugin returns true if name is resolved to a plugin for the command
+func (cmd *Command) isPlugin(name string) bool {
+	resolver := cmd.pluginResolver()
+	if resolver == nil {
+		return false
+	}
+
+	run, ok := resolver(cmd, name)
+	return ok && run != nil
+}
@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuietFlag disables the output of Progress with --quiet or -q. It is not
+// added to commands automatically.
+var QuietFlag Flag = &BoolFlag{
+	Name:    "quiet",
+	Aliases: []string{"q"},
+	Usage:   "do not show progress",
+}
+
+// progressInterval is how often a Progress is redrawn on a terminal
+var progressInterval = 100 * time.Millisecond
+
+// progressBarWidth is the number of characters of the bar of a Task
+const progressBarWidth = 30
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Progress shows the progress of one or more tasks on the Writer of the root
+// command, created with Command.Progress. On a terminal each task is shown on
+// its own line with a spinner or a bar which is redrawn until Stop is called.
+// Otherwise only plain lines are written when tasks start, reach a quarter of
+// their total and end, so logs e.g. in CI stay readable. Nothing is written
+// if the QuietFlag of the command or its parents is set. All methods are
+// safe for concurrent use.
+type Progress struct {
+	w     io.Writer
+	tty   bool
+	quiet bool
+
+	mu      sync.Mutex
+	tasks   []*Task
+	frame   int
+	lines   int
+	stop    chan struct{}
+	done    chan struct{}
+	stopped bool
+}
+
+// Task is a task shown by a Progress, either with a spinner if its total is
+// not known or with a bar
+type Task struct {
+	p       *Progress
+	label   string
+	total   int64
+	current int64
+	logged  int64
+	ended   bool
+	err     error
+}
+
+// Progress returns a new Progress writing to the Writer of the root command.
+// Stop must be called when all tasks are done.
+func (cmd *Command) Progress() *Progress {
+	root := cmd.Root()
+	p := &Progress{w: root.Writer, tty: isTerminal(root.Writer)}
+
+	if QuietFlag != nil {
+		for _, name := range QuietFlag.Names() {
+			if cmd.lookupFlag(name) != nil && cmd.Bool(name) {
+				p.quiet = true
+			}
+		}
+	}
+
+	if p.tty && !p.quiet {
+		p.stop, p.done = make(chan struct{}), make(chan struct{})
+		go p.run()
+	}
+	return p
+}
+
+func (p *Progress) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.frame++
+			p.render()
+			p.mu.Unlock()
+		}
+	}
+}
+
+// Spinner starts a task whose total is not known
+func (p *Progress) Spinner(label string) *Task {
+	return p.start(label, 0)
+}
+
+// Bar starts a task with the total, e.g. the number of bytes to download
+func (p *Progress) Bar(label string, total int64) *Task {
+	return p.start(label, total)
+}
+
+func (p *Progress) start(label string, total int64) *Task {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t := &Task{p: p, label: label, total: total}
+	p.tasks = append(p.tasks, t)
+	p.log("%s...", label)
+	p.render()
+	return t
+}
+
+// Stop draws the final state of all tasks and stops redrawing. Tasks which
+// have not ended are shown as they are.
+func (p *Progress) Stop() {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+	p.render()
+	p.stopped = true
+	p.mu.Unlock()
+
+	if p.stop != nil {
+		close(p.stop)
+		<-p.done
+	}
+}
+
+// log writes a line if the output is not a terminal
+func (p *Progress) log(format string, a ...any) {
+	if p.quiet || p.tty {
+		return
+	}
+	_, _ = fmt.Fprintf(p.w, format+"\n", a...)
+}
+
+// render redraws the lines of all tasks if the output is a terminal
+func (p *Progress) render() {
+	if p.quiet || !p.tty || p.stopped {
+		return
+	}
+
+	var b strings.Builder
+	if p.lines > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", p.lines)
+	}
+	for _, t := range p.tasks {
+		b.WriteString("\r\x1b[K")
+		b.WriteString(t.line(p.frame))
+		b.WriteString("\n")
+	}
+	p.lines = len(p.tasks)
+	_, _ = io.WriteString(p.w, b.String())
+}
+
+// Add adds n to the progress of the task
+func (t *Task) Add(n int64) {
+	t.p.mu.Lock()
+	defer t.p.mu.Unlock()
+	t.set(t.current + n)
+}
+
+// Set sets the progress of the task, e.g. the number of bytes downloaded
+func (t *Task) Set(n int64) {
+	t.p.mu.Lock()
+	defer t.p.mu.Unlock()
+	t.set(n)
+}
+
+func (t *Task) set(n int64) {
+	if t.ended {
+		return
+	}
+	t.current = n
+	if t.total <= 0 {
+		return
+	}
+
+	// log every quarter without a terminal
+	if quarter := min(n*4/t.total, 4) * t.total / 4; quarter > t.logged && n < t.total {
+		t.logged = quarter
+		t.p.log("%s: %d%%", t.label, t.percent())
+	}
+}
+
+// Done ends the task successfully
+func (t *Task) Done() {
+	t.end(nil)
+}
+
+// Fail ends the task with the error
+func (t *Task) Fail(err error) {
+	t.end(err)
+}
+
+func (t *Task) end(err error) {
+	t.p.mu.Lock()
+	defer t.p.mu.Unlock()
+
+	if t.ended {
+		return
+	}
+	t.ended, t.err = true, err
+	if err == nil && t.total > 0 {
+		t.current = t.total
+	}
+
+	if err != nil {
+		t.p.log("%s: failed: %v", t.label, err)
+	} else {
+		t.p.log("%s: done", t.label)
+	}
+	t.p.render()
+}
+
+func (t *Task) percent() int64 {
+	if t.total <= 0 {
+		return 0
+	}
+	return min(t.current*100/t.total, 100)
+}
+
+// line returns the line of the task on a terminal
+func (t *Task) line(frame int) string {
+	switch {
+	case t.err != nil:
+		return fmt.Sprintf("✗ %s: %v", t.label, t.err)
+	case t.ended:
+		return "✓ " + t.label
+	case t.total <= 0:
+		return spinnerFrames[frame%len(spinnerFrames)] + " " + t.label
+	}
+
+	filled := int(t.percent() * progressBarWidth / 100)
+	return fmt.Sprintf("%s [%s%s] %3d%%", t.label,
+		strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled), t.percent())
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Progress(t *testing.T) {
+	defer func(old func(io.Writer) bool) { isTerminal = old }(isTerminal)
+	defer func(old time.Duration) { progressInterval = old }(progressInterval)
+	progressInterval = time.Hour
+
+	tests := []struct {
+		name     string
+		terminal bool
+		args     []string
+		want     string
+	}{
+		{
+			name: "log lines",
+			want: "download...\n" +
+				"unpack...\n" +
+				"download: 30%\n" +
+				"download: 60%\n" +
+				"download: done\n" +
+				"unpack: failed: disk full\n",
+		},
+		{
+			name:     "terminal",
+			terminal: true,
+			want: "\r\x1b[Kdownload [                              ]   0%\n" +
+				"\x1b[1A\r\x1b[Kdownload [                              ]   0%\n\r\x1b[K⠋ unpack\n" +
+				"\x1b[2A\r\x1b[K✓ download\n\r\x1b[K⠋ unpack\n" +
+				"\x1b[2A\r\x1b[K✓ download\n\r\x1b[K✗ unpack: disk full\n" +
+				"\x1b[2A\r\x1b[K✓ download\n\r\x1b[K✗ unpack: disk full\n",
+		},
+		{
+			name:     "quiet",
+			terminal: true,
+			args:     []string{"--quiet"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isTerminal = func(io.Writer) bool { return test.terminal }
+			quiet := *QuietFlag.(*BoolFlag)
+			quiet.reset()
+
+			var out bytes.Buffer
+			cmd := &Command{
+				Name:   "app",
+				Writer: &out,
+				Flags:  []Flag{&quiet},
+				Action: func(_ context.Context, cmd *Command) error {
+					p := cmd.Progress()
+					download := p.Bar("download", 100)
+					unpack := p.Spinner("unpack")
+					download.Add(10)
+					download.Add(20)
+					download.Set(60)
+					download.Done()
+					download.Add(10)
+					unpack.Fail(errors.New("disk full"))
+					p.Stop()
+					p.Stop()
+					return nil
+				},
+			}
+
+			require.NoError(t, cmd.Run(buildTestContext(t), append([]string{"app"}, test.args...)))
+			assert.Equal(t, test.want, out.String())
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxPromptAttempts is how often a missing flag or a confirmation is
+// prompted for if the input is invalid
+const maxPromptAttempts = 3
+
+// AssumeYesFlag answers all confirmations of Command.Confirm with yes. It is
+// not added to commands automatically.
+var AssumeYesFlag Flag = &BoolFlag{
+	Name:    "yes",
+	Aliases: []string{"y", "assume-yes"},
+	Usage:   "assume yes for all confirmations",
+}
+
+// ErrNonInteractive is returned by Command.Confirm if the input is not a
+// terminal and the NonInteractiveFail policy applies
+var ErrNonInteractive = errors.New("input is not a terminal")
+
+// NonInteractivePolicy is how Command.Confirm answers if the input is not a
+// terminal
+type NonInteractivePolicy int
+
+const (
+	// NonInteractiveFail returns ErrNonInteractive
+	NonInteractiveFail NonInteractivePolicy = iota
+	// NonInteractiveAssumeNo answers no
+	NonInteractiveAssumeNo
+)
+
+type confirmOptions struct {
+	defaultYes     bool
+	nonInteractive NonInteractivePolicy
+}
+
+// ConfirmOption is an option of Command.Confirm
+type ConfirmOption func(*confirmOptions)
+
+// ConfirmDefault sets the answer to an empty input, no by default
+func ConfirmDefault(yes bool) ConfirmOption {
+	return func(o *confirmOptions) { o.defaultYes = yes }
+}
+
+// ConfirmNonInteractive sets how to answer if the input is not a terminal,
+// NonInteractiveFail by default
+func ConfirmNonInteractive(policy NonInteractivePolicy) ConfirmOption {
+	return func(o *confirmOptions) { o.nonInteractive = policy }
+}
+
+var isInputTerminal = func(r io.Reader) bool {
+	if tr, ok := r.(terminalReporter); ok {
+		return tr.IsTerminal()
+	}
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// openTTY opens the terminal of the process for reading
+var openTTY = func() (io.ReadCloser, error) {
+	if ttyPath == "" {
+		return nil, ErrNonInteractive
+	}
+	return os.Open(ttyPath)
+}
+
+// readLine writes the prompt to ErrWriter and reads a line from the Reader
+// of the root command, with echo turned off if hidden is set and the Reader
+// is a terminal
+func (cmd *Command) readLine(prompt string, hidden bool) (string, error) {
+	root := cmd.Root()
+	_, _ = fmt.Fprint(root.ErrWriter, prompt)
+
+	if hidden {
+		restore, err := hideInput(root.Reader, root.ErrWriter)
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	if root.promptReader == nil || root.promptSource != root.Reader {
+		root.promptReader, root.promptSource = bufio.NewReader(root.Reader), root.Reader
+	}
+	return trimLine(root.promptReader.ReadString('\n'))
+}
+
+// hideInput turns off echoing of r if it is a terminal and returns a function
+// turning it on again, which ends the line of the prompt on w
+func hideInput(r io.Reader, w io.Writer) (func(), error) {
+	f, ok := r.(*os.File)
+	if !ok || !isInputTerminal(f) {
+		return func() {}, nil
+	}
+	restore, err := disableEcho(f)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		restore()
+		_, _ = fmt.Fprintln(w)
+	}, nil
+}
+
+func trimLine(line string, err error) (string, error) {
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// promptForMissingFlags prompts for the values of the required flags of the
+// command and its parents which are not set, if PromptForMissing is set on
+// the root command and its Reader is a terminal. Values are set like on the
+// command line, so they are parsed and validated by the flag. Flags whose
+// value is still invalid after a few attempts are left unset.
+func (cmd *Command) promptForMissingFlags() {
+	root := cmd.Root()
+	if !root.PromptForMissing || !isInputTerminal(root.Reader) {
+		return
+	}
+
+	for _, pCmd := range cmd.Lineage() {
+		for _, fl := range pCmd.appliedFlags {
+			if ok, name := pCmd.checkRequiredFlag(fl); !ok {
+				pCmd.promptForFlag(fl, name)
+			}
+		}
+	}
+}
+
+func (cmd *Command) promptForFlag(fl Flag, name string) {
+	prompt := name
+	if df, ok := fl.(DocGenerationFlag); ok {
+		if _, usage := unquoteUsage(df.GetUsage()); usage != "" {
+			prompt = fmt.Sprintf("%s (--%s)", usage, name)
+		}
+	}
+	sf, hidden := fl.(SensitiveFlag)
+	hidden = hidden && sf.IsSensitive()
+
+	for i := 0; i < maxPromptAttempts; i++ {
+		value, err := cmd.readLine(prompt+": ", hidden)
+		if err != nil {
+			tracef("could not read value of flag %[1]q: %[2]v (cmd=%[3]q)", name, err, cmd.Name)
+			return
+		}
+		if value == "" {
+			continue
+		}
+
+		if err := cmd.flagSet.Set(name, value); err != nil {
+			_, _ = fmt.Fprintf(cmd.Root().ErrWriter, "invalid value for --%s: %v\n", name, err)
+			continue
+		}
+		return
+	}
+}
+
+// Confirm asks the user to confirm the prompt with yes or no on the terminal,
+// e.g. before a destructive operation. It returns true without asking if the
+// AssumeYesFlag of the command or its parents is set. If the input is not a
+// terminal it fails with ErrNonInteractive or answers no, see
+// ConfirmNonInteractive.
+func (cmd *Command) Confirm(prompt string, opts ...ConfirmOption) (bool, error) {
+	var o confirmOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if AssumeYesFlag != nil {
+		for _, name := range AssumeYesFlag.Names() {
+			if cmd.lookupFlag(name) != nil && cmd.Bool(name) {
+				tracef("assuming yes for %[1]q (cmd=%[2]q)", prompt, cmd.Name)
+				return true, nil
+			}
+		}
+	}
+
+	if !isInputTerminal(cmd.Root().Reader) {
+		if o.nonInteractive == NonInteractiveAssumeNo {
+			return false, nil
+		}
+		hint := ""
+		if AssumeYesFlag != nil && cmd.lookupFlag(AssumeYesFlag.Names()[0]) != nil {
+			hint = fmt.Sprintf(", use --%s to confirm", AssumeYesFlag.Names()[0])
+		}
+		return false, fmt.Errorf("cannot confirm %q%s: %w", prompt, hint, ErrNonInteractive)
+	}
+
+	choices := "[y/N]"
+	if o.defaultYes {
+		choices = "[Y/n]"
+	}
+
+	for i := 0; i < maxPromptAttempts; i++ {
+		answer, err := cmd.readLine(fmt.Sprintf("%s %s ", prompt, choices), false)
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "":
+			return o.defaultYes, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+
+	return false, nil
+}
+
+// PromptSecret reads a secret like a password from the terminal with echo
+// turned off. If the command or its parents have a flag named label which is
+// set, on the command line or from one of its sources like an env var, its
+// value is returned instead. The terminal of the process is read even if the
+// Reader of the root command is not a terminal, e.g. if the input is piped,
+// and ErrNonInteractive is returned if there is no terminal.
+func (cmd *Command) PromptSecret(label string) (string, error) {
+	if cmd.lookupFlag(label) != nil && cmd.IsSet(label) {
+		tracef("using value of flag %[1]q as secret (cmd=%[2]q)", label, cmd.Name)
+		return fmt.Sprint(cmd.Value(label)), nil
+	}
+
+	root := cmd.Root()
+	if isInputTerminal(root.Reader) {
+		return cmd.readLine(label+": ", true)
+	}
+
+	tty, err := openTTY()
+	if err != nil {
+		tracef("could not open terminal: %[1]v (cmd=%[2]q)", err, cmd.Name)
+		return "", fmt.Errorf("cannot prompt for %s: %w", label, ErrNonInteractive)
+	}
+	defer tty.Close()
+
+	_, _ = fmt.Fprint(root.ErrWriter, label+": ")
+	restore, err := hideInput(tty, root.ErrWriter)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	return trimLine(bufio.NewReader(tty).ReadString('\n'))
+}
@@ -0,0 +1,220 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_PromptForMissing(t *testing.T) {
+	defer func(old func(io.Reader) bool) { isInputTerminal = old }(isInputTerminal)
+	isInputTerminal = func(io.Reader) bool { return true }
+
+	newCmd := func(input string, errOut io.Writer) (*Command, *[]any) {
+		var got []any
+		return &Command{
+			Name:             "app",
+			PromptForMissing: true,
+			Reader:           strings.NewReader(input),
+			ErrWriter:        errOut,
+			Flags: []Flag{
+				&StringFlag{Name: "token", Required: true, Sensitive: true},
+			},
+			Commands: []*Command{
+				{
+					Name: "serve",
+					Flags: []Flag{
+						&IntFlag{Name: "port", Required: true, Usage: "`PORT` to listen on"},
+						&StringFlag{Name: "host", Value: "localhost"},
+					},
+					Action: func(_ context.Context, cmd *Command) error {
+						got = []any{cmd.String("token"), cmd.Int("port")}
+						return nil
+					},
+				},
+			},
+		}, &got
+	}
+
+	var errOut bytes.Buffer
+	cmd, got := newCmd("\neighty\n8080\nsecret\n", &errOut)
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "serve"}))
+	assert.Equal(t, []any{"secret", int64(8080)}, *got)
+	assert.Equal(t, `PORT to listen on (--port): PORT to listen on (--port): invalid value for --port: strconv.ParseInt: parsing "eighty": invalid syntax
+PORT to listen on (--port): token: `, errOut.String())
+
+	errOut.Reset()
+	cmd, got = newCmd("", &errOut)
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "--token", "t", "serve", "--port", "80"}))
+	assert.Equal(t, []any{"t", int64(80)}, *got)
+	assert.Empty(t, errOut.String())
+
+	cmd, _ = newCmd("", io.Discard)
+	cmd.Writer = io.Discard
+	err := cmd.Run(buildTestContext(t), []string{"app", "serve"})
+	assert.ErrorContains(t, err, `Required flags "port, token" not set`)
+
+	isInputTerminal = func(io.Reader) bool { return false }
+	cmd, _ = newCmd("8080\nsecret\n", io.Discard)
+	cmd.Writer = io.Discard
+	err = cmd.Run(buildTestContext(t), []string{"app", "serve"})
+	assert.ErrorContains(t, err, `Required flags "port, token" not set`)
+}
+
+func TestCommand_Confirm(t *testing.T) {
+	defer func(old func(io.Reader) bool) { isInputTerminal = old }(isInputTerminal)
+
+	yes := *AssumeYesFlag.(*BoolFlag)
+	yes.reset()
+
+	tests := []struct {
+		name     string
+		args     []string
+		terminal bool
+		input    string
+		opts     []ConfirmOption
+		want     bool
+		wantErr  string
+		prompts  string
+	}{
+		{name: "yes", terminal: true, input: "y\n", want: true, prompts: "Delete? [y/N] "},
+		{name: "no", terminal: true, input: "no\n", prompts: "Delete? [y/N] "},
+		{name: "default no", terminal: true, input: "\n", prompts: "Delete? [y/N] "},
+		{name: "default yes", terminal: true, input: "\n", opts: []ConfirmOption{ConfirmDefault(true)}, want: true, prompts: "Delete? [Y/n] "},
+		{name: "asks again", terminal: true, input: "maybe\nYES\n", want: true, prompts: "Delete? [y/N] Delete? [y/N] "},
+		{name: "eof", terminal: true, prompts: "Delete? [y/N] "},
+		{name: "assume yes", args: []string{"--yes"}, want: true},
+		{name: "assume yes alias", args: []string{"-y"}, terminal: true, want: true},
+		{name: "not a terminal", wantErr: `cannot confirm "Delete?", use --yes to confirm: input is not a terminal`},
+		{name: "not a terminal assume no", opts: []ConfirmOption{ConfirmNonInteractive(NonInteractiveAssumeNo)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isInputTerminal = func(io.Reader) bool { return test.terminal }
+
+			var errOut bytes.Buffer
+			var got bool
+			var err error
+			cmd := &Command{
+				Name:      "app",
+				Reader:    strings.NewReader(test.input),
+				ErrWriter: &errOut,
+				Flags:     []Flag{&yes},
+				Commands: []*Command{
+					{
+						Name: "delete",
+						Action: func(_ context.Context, cmd *Command) error {
+							got, err = cmd.Confirm("Delete?", test.opts...)
+							return nil
+						},
+					},
+				},
+			}
+
+			require.NoError(t, cmd.Run(buildTestContext(t), append(append([]string{"app"}, test.args...), "delete")))
+			yes.reset()
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				assert.ErrorIs(t, err, ErrNonInteractive)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+			assert.Equal(t, test.prompts, errOut.String())
+		})
+	}
+}
+
+func TestCommand_PromptSecret(t *testing.T) {
+	defer func(old func(io.Reader) bool) { isInputTerminal = old }(isInputTerminal)
+	defer func(old func() (io.ReadCloser, error)) { openTTY = old }(openTTY)
+
+	tests := []struct {
+		name     string
+		terminal bool
+		tty      string
+		noTTY    bool
+		args     []string
+		env      string
+		want     string
+		prompts  string
+		wantErr  string
+	}{
+		{
+			name:     "terminal",
+			terminal: true,
+			want:     "from stdin",
+			prompts:  "password: ",
+		},
+		{
+			name:    "piped",
+			tty:     "from tty\r\n",
+			want:    "from tty",
+			prompts: "password: ",
+		},
+		{
+			name: "flag",
+			args: []string{"--password", "from flag"},
+			want: "from flag",
+		},
+		{
+			name: "env",
+			env:  "from env",
+			want: "from env",
+		},
+		{
+			name:    "no terminal",
+			noTTY:   true,
+			wantErr: "cannot prompt for password: input is not a terminal",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isInputTerminal = func(io.Reader) bool { return test.terminal }
+			openTTY = func() (io.ReadCloser, error) {
+				if test.noTTY {
+					return nil, ErrNonInteractive
+				}
+				return io.NopCloser(strings.NewReader(test.tty)), nil
+			}
+			if test.env != "" {
+				t.Setenv("APP_PASSWORD", test.env)
+			}
+
+			var (
+				errOut bytes.Buffer
+				got    string
+				err    error
+			)
+			cmd := &Command{
+				Name:      "app",
+				Reader:    strings.NewReader("from stdin\n"),
+				ErrWriter: &errOut,
+				Flags: []Flag{
+					&StringFlag{Name: "password", Sources: EnvVars("APP_PASSWORD")},
+				},
+				Action: func(_ context.Context, cmd *Command) error {
+					got, err = cmd.PromptSecret("password")
+					return nil
+				},
+			}
+
+			require.NoError(t, cmd.Run(buildTestContext(t), append([]string{"app"}, test.args...)))
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				assert.ErrorIs(t, err, ErrNonInteractive)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+			assert.Equal(t, test.prompts, errOut.String())
+		})
+	}
+}
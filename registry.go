@@ -0,0 +1,25 @@
+package cli
+
+import "fmt"
+
+// registrySource encapsulates a MapSource reading values from the Windows
+// registry
+type registrySource struct {
+	path string
+}
+
+// Registry returns a MapSource looking up values of the Windows registry key
+// at path, e.g. HKLM\SOFTWARE\Policies\MyApp for settings pushed with group
+// policies. The root key may be given as HKLM, HKCU, HKEY_LOCAL_MACHINE or
+// HKEY_CURRENT_USER. Dots in the lookup key select subkeys, so serve.port is
+// the value port of the subkey serve. String values are used as is, DWORD
+// and QWORD values as decimal numbers and multi string values joined with
+// commas. On other platforms no value is ever found.
+func Registry(path string) MapSource {
+	return &registrySource{path: path}
+}
+
+func (rs *registrySource) String() string { return fmt.Sprintf("registry key %[1]q", rs.path) }
+func (rs *registrySource) GoString() string {
+	return fmt.Sprintf("&registrySource{path:%[1]q}", rs.path)
+}
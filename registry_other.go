@@ -0,0 +1,7 @@
+//go:build !windows
+
+package cli
+
+func (rs *registrySource) Lookup(string) (any, bool) {
+	return nil, false
+}
@@ -0,0 +1,93 @@
+//go:build windows
+
+package cli
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf16"
+)
+
+var registryRoots = map[string]syscall.Handle{
+	"HKLM":               syscall.HKEY_LOCAL_MACHINE,
+	"HKEY_LOCAL_MACHINE": syscall.HKEY_LOCAL_MACHINE,
+	"HKCU":               syscall.HKEY_CURRENT_USER,
+	"HKEY_CURRENT_USER":  syscall.HKEY_CURRENT_USER,
+	"HKU":                syscall.HKEY_USERS,
+	"HKEY_USERS":         syscall.HKEY_USERS,
+}
+
+func (rs *registrySource) Lookup(name string) (any, bool) {
+	rootName, path, _ := strings.Cut(rs.path, `\`)
+	root, ok := registryRoots[strings.ToUpper(rootName)]
+	if !ok || name == "" {
+		return nil, false
+	}
+
+	keys := strings.Split(name, ".")
+	subkey := strings.Join(append([]string{path}, keys[:len(keys)-1]...), `\`)
+	subkeyPtr, err := syscall.UTF16PtrFromString(subkey)
+	if err != nil {
+		return nil, false
+	}
+	namePtr, err := syscall.UTF16PtrFromString(keys[len(keys)-1])
+	if err != nil {
+		return nil, false
+	}
+
+	var h syscall.Handle
+	if err := syscall.RegOpenKeyEx(root, subkeyPtr, 0, syscall.KEY_READ|syscall.KEY_WOW64_64KEY, &h); err != nil {
+		return nil, false
+	}
+	defer syscall.RegCloseKey(h)
+
+	var typ, n uint32
+	if err := syscall.RegQueryValueEx(h, namePtr, nil, &typ, nil, &n); err != nil {
+		return nil, false
+	}
+	buf := make([]byte, n+2)
+	if n > 0 {
+		if err := syscall.RegQueryValueEx(h, namePtr, nil, &typ, &buf[0], &n); err != nil {
+			return nil, false
+		}
+	}
+	buf = buf[:n]
+
+	switch typ {
+	case syscall.REG_SZ, syscall.REG_EXPAND_SZ:
+		return registryString(buf), true
+	case syscall.REG_MULTI_SZ:
+		var values []string
+		for _, v := range strings.Split(registryString(buf), "\x00") {
+			if v != "" {
+				values = append(values, v)
+			}
+		}
+		return strings.Join(values, ","), true
+	case syscall.REG_DWORD:
+		if len(buf) < 4 {
+			return nil, false
+		}
+		return strconv.FormatUint(uint64(binary.LittleEndian.Uint32(buf)), 10), true
+	case syscall.REG_QWORD:
+		if len(buf) < 8 {
+			return nil, false
+		}
+		return strconv.FormatUint(binary.LittleEndian.Uint64(buf), 10), true
+	}
+
+	tracef("unsupported type %[1]d of registry value %[2]q in %[3]q", typ, name, rs.path)
+	return nil, false
+}
+
+// registryString decodes an UTF-16 registry value, keeping NUL separators
+// of multi string values but dropping the terminating ones
+func registryString(buf []byte) string {
+	u := make([]uint16, len(buf)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(buf[2*i:])
+	}
+	return strings.TrimRight(string(utf16.Decode(u)), "\x00")
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+const responseFilePrefix = "@"
+
+// expandResponseFiles replaces every argument of the form @FILE with the
+// arguments read from FILE, see [Command.ResponseFiles]. The first argument
+// is the program name and is kept as is, as is everything after "--".
+func expandResponseFiles(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return args, nil
+	}
+
+	expanded, err := expandResponseFileArgs(args[1:], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{args[0]}, expanded...), nil
+}
+
+func expandResponseFileArgs(args []string, stack []string) ([]string, error) {
+	var out []string
+	for i, arg := range args {
+		if arg == "--" {
+			return append(out, args[i:]...), nil
+		}
+
+		if !strings.HasPrefix(arg, responseFilePrefix) || len(arg) == len(responseFilePrefix) {
+			out = append(out, arg)
+			continue
+		}
+
+		path := arg[len(responseFilePrefix):]
+		if strings.HasPrefix(path, responseFilePrefix) {
+			// @@arg is an escaped literal @arg
+			out = append(out, path)
+			continue
+		}
+
+		fileArgs, err := readResponseFile(path, stack)
+		if err != nil {
+			return nil, err
+		}
+
+		tracef("expanded response file %[1]q to %[2]q", path, fileArgs)
+		out = append(out, fileArgs...)
+	}
+
+	return out, nil
+}
+
+func readResponseFile(path string, stack []string) ([]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range stack {
+		if p == abs {
+			return nil, fmt.Errorf("response file %s includes itself", path)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response file: %w", err)
+	}
+
+	args, err := splitResponseFile(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid response file %s: %w", path, err)
+	}
+
+	return expandResponseFileArgs(args, append(stack, abs))
+}
+
+// splitResponseFile splits the contents of a response file into arguments.
+// Arguments are separated by whitespace, single and double quotes group
+// characters into one argument, and within double quotes a backslash
+// escapes a double quote or a backslash. An unquoted # at the start of an
+// argument begins a comment which runs until the end of the line.
+func splitResponseFile(s string) ([]string, error) {
+	var (
+		args    []string
+		sb      strings.Builder
+		inToken bool
+		quote   rune
+	)
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+
+		switch {
+		case quote == '"' && ch == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\'):
+			i++
+			sb.WriteRune(runes[i])
+		case quote != 0 && ch == quote:
+			quote = 0
+		case quote != 0:
+			sb.WriteRune(ch)
+		case ch == '"' || ch == '\'':
+			quote = ch
+			inToken = true
+		case ch == '#' && !inToken:
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case unicode.IsSpace(ch):
+			if inToken {
+				args = append(args, sb.String())
+				sb.Reset()
+				inToken = false
+			}
+		default:
+			sb.WriteRune(ch)
+			inToken = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+
+	if inToken {
+		args = append(args, sb.String())
+	}
+
+	return args, nil
+}
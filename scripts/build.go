@@ -153,7 +153,7 @@ func main() {
 			},
 			&cli.StringSliceFlag{
 				Name:  "packages",
-				Value: []string{"cli", "scripts"},
+				Value: []string{"cli", "scripts", "selfupdate", "clihttp", "cliremote", "cliyaml", "clitoml", "cliini", "cliplugin", "clipager", "clikeychain"},
 			},
 		},
 	}
@@ -0,0 +1,264 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// errInterrupted is returned by Command.Select and Command.MultiSelect if
+// the user presses Ctrl-C while choosing
+var errInterrupted = errors.New("interrupted")
+
+// Select asks the user to choose one of the options. On a terminal the
+// options are chosen with the arrow keys and enter, or by number if the
+// terminal does not support it. If the command or its parents have a flag
+// named label which is set, its value is returned instead if it is one of the
+// options. If the input is not a terminal and the flag is not set it fails
+// with ErrNonInteractive.
+func (cmd *Command) Select(label string, options []string) (string, error) {
+	if len(options) == 0 {
+		return "", fmt.Errorf("no options to choose %s from", label)
+	}
+
+	if cmd.lookupFlag(label) != nil && cmd.IsSet(label) {
+		choice := fmt.Sprint(cmd.Value(label))
+		if err := checkChoices(label, []string{choice}, options); err != nil {
+			return "", err
+		}
+		return choice, nil
+	}
+
+	chosen, err := cmd.choose(label, options, false)
+	if err != nil {
+		return "", err
+	}
+	return options[chosen[0]], nil
+}
+
+// MultiSelect asks the user to choose any number of the options, like
+// Select. On a terminal the options are toggled with space. The value of a
+// flag named label is split at commas unless it is a StringSliceFlag.
+func (cmd *Command) MultiSelect(label string, options []string) ([]string, error) {
+	if len(options) == 0 {
+		return nil, fmt.Errorf("no options to choose %s from", label)
+	}
+
+	if cmd.lookupFlag(label) != nil && cmd.IsSet(label) {
+		var choices []string
+		switch v := cmd.Value(label).(type) {
+		case []string:
+			choices = v
+		default:
+			for _, s := range strings.Split(fmt.Sprint(v), ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					choices = append(choices, s)
+				}
+			}
+		}
+		if err := checkChoices(label, choices, options); err != nil {
+			return nil, err
+		}
+		return choices, nil
+	}
+
+	chosen, err := cmd.choose(label, options, true)
+	if err != nil {
+		return nil, err
+	}
+	choices := make([]string, 0, len(chosen))
+	for _, i := range chosen {
+		choices = append(choices, options[i])
+	}
+	return choices, nil
+}
+
+func checkChoices(label string, choices, options []string) error {
+	for _, choice := range choices {
+		if !slices.Contains(options, choice) {
+			return fmt.Errorf("invalid value %q for --%s, must be one of %s", choice, label, strings.Join(options, ", "))
+		}
+	}
+	return nil
+}
+
+// choose returns the indexes of the options chosen on the terminal
+func (cmd *Command) choose(label string, options []string, multi bool) ([]int, error) {
+	root := cmd.Root()
+	if !isInputTerminal(root.Reader) {
+		hint := ""
+		if cmd.lookupFlag(label) != nil {
+			hint = fmt.Sprintf(", use --%s", label)
+		}
+		return nil, fmt.Errorf("cannot prompt for %s%s: %w", label, hint, ErrNonInteractive)
+	}
+
+	if f, ok := root.Reader.(*os.File); ok {
+		restore, err := makeRaw(f)
+		if err == nil {
+			defer restore()
+			if root.promptReader == nil || root.promptSource != root.Reader {
+				root.promptReader, root.promptSource = bufio.NewReader(root.Reader), root.Reader
+			}
+			return runSelect(root.promptReader, root.ErrWriter, label, options, multi)
+		}
+		tracef("could not use raw mode, choosing by number: %[1]v (cmd=%[2]q)", err, cmd.Name)
+	}
+
+	return cmd.chooseByNumber(label, options, multi)
+}
+
+// chooseByNumber lists the numbered options and reads the numbers or names
+// of the chosen ones
+func (cmd *Command) chooseByNumber(label string, options []string, multi bool) ([]int, error) {
+	w := cmd.Root().ErrWriter
+	_, _ = fmt.Fprintf(w, "%s:\n", label)
+	for i, option := range options {
+		_, _ = fmt.Fprintf(w, "  %d) %s\n", i+1, option)
+	}
+
+	prompt := fmt.Sprintf("Choose 1-%d: ", len(options))
+	if multi {
+		prompt = fmt.Sprintf("Choose 1-%d, separated by commas: ", len(options))
+	}
+
+	for i := 0; i < maxPromptAttempts; i++ {
+		answer, err := cmd.readLine(prompt, false)
+		if err != nil {
+			return nil, err
+		}
+
+		fields := []string{answer}
+		if multi {
+			fields = strings.Split(answer, ",")
+		}
+
+		var chosen []int
+		for _, field := range fields {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			n := indexOfChoice(field, options)
+			if n < 0 {
+				_, _ = fmt.Fprintf(w, "invalid choice %q\n", field)
+				chosen = nil
+				break
+			}
+			chosen = append(chosen, n)
+		}
+		if len(chosen) > 0 || (multi && strings.TrimSpace(answer) == "") {
+			return chosen, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no valid choice for %s", label)
+}
+
+// indexOfChoice returns the index of the option with the number or name, or
+// -1 if there is none
+func indexOfChoice(choice string, options []string) int {
+	if n, err := strconv.Atoi(choice); err == nil {
+		if n >= 1 && n <= len(options) {
+			return n - 1
+		}
+		return -1
+	}
+	return slices.Index(options, choice)
+}
+
+// runSelect draws the options to w and reads the keys pressed from r in raw
+// mode until enter is pressed: up/down or k/j move the cursor and space
+// toggles an option if multi is set
+func runSelect(r io.ByteReader, w io.Writer, label string, options []string, multi bool) ([]int, error) {
+	cursor := 0
+	toggled := make([]bool, len(options))
+
+	draw := func(redraw bool) {
+		if redraw {
+			_, _ = fmt.Fprintf(w, "\x1b[%dA", len(options))
+		}
+		for i, option := range options {
+			pointer := " "
+			if i == cursor {
+				pointer = ">"
+			}
+			box := ""
+			if multi {
+				box = "[ ] "
+				if toggled[i] {
+					box = "[x] "
+				}
+			}
+			_, _ = fmt.Fprintf(w, "\r\x1b[K%s %s%s\n", pointer, box, option)
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "%s:\n", label)
+	draw(false)
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch b {
+		case 3: // Ctrl-C
+			return nil, errInterrupted
+		case '\r', '\n':
+			var chosen []int
+			if multi {
+				for i, ok := range toggled {
+					if ok {
+						chosen = append(chosen, i)
+					}
+				}
+			} else {
+				chosen = []int{cursor}
+			}
+
+			choices := make([]string, 0, len(chosen))
+			for _, i := range chosen {
+				choices = append(choices, options[i])
+			}
+			_, _ = fmt.Fprintf(w, "\x1b[%dA\r\x1b[J%s: %s\n", len(options)+1, label, strings.Join(choices, ", "))
+			return chosen, nil
+		case ' ':
+			if multi {
+				toggled[cursor] = !toggled[cursor]
+			}
+		case 'k':
+			cursor = (cursor + len(options) - 1) % len(options)
+		case 'j':
+			cursor = (cursor + 1) % len(options)
+		case 0x1b:
+			// arrow keys are sent as ESC [ A or ESC O A
+			if b, err = r.ReadByte(); err != nil {
+				return nil, err
+			}
+			if b != '[' && b != 'O' {
+				continue
+			}
+			if b, err = r.ReadByte(); err != nil {
+				return nil, err
+			}
+			switch b {
+			case 'A':
+				cursor = (cursor + len(options) - 1) % len(options)
+			case 'B':
+				cursor = (cursor + 1) % len(options)
+			default:
+				continue
+			}
+		default:
+			continue
+		}
+		draw(true)
+	}
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Select(t *testing.T) {
+	defer func(old func(io.Reader) bool) { isInputTerminal = old }(isInputTerminal)
+
+	options := []string{"dev", "staging", "prod"}
+
+	tests := []struct {
+		name     string
+		terminal bool
+		input    string
+		args     []string
+		multi    bool
+		want     any
+		prompts  string
+		wantErr  string
+	}{
+		{
+			name:     "by number",
+			terminal: true,
+			input:    "4\n2\n",
+			want:     "staging",
+			prompts:  "cluster:\n  1) dev\n  2) staging\n  3) prod\nChoose 1-3: invalid choice \"4\"\nChoose 1-3: ",
+		},
+		{
+			name:     "by name",
+			terminal: true,
+			input:    "prod\n",
+			want:     "prod",
+			prompts:  "cluster:\n  1) dev\n  2) staging\n  3) prod\nChoose 1-3: ",
+		},
+		{
+			name:     "multi",
+			terminal: true,
+			input:    "3, dev\n",
+			multi:    true,
+			want:     []string{"prod", "dev"},
+			prompts:  "cluster:\n  1) dev\n  2) staging\n  3) prod\nChoose 1-3, separated by commas: ",
+		},
+		{
+			name: "flag",
+			args: []string{"--cluster", "staging"},
+			want: "staging",
+		},
+		{
+			name:  "multi flag",
+			args:  []string{"--cluster", "dev,prod"},
+			multi: true,
+			want:  []string{"dev", "prod"},
+		},
+		{
+			name:    "invalid flag",
+			args:    []string{"--cluster", "qa"},
+			wantErr: `invalid value "qa" for --cluster, must be one of dev, staging, prod`,
+		},
+		{
+			name:    "not a terminal",
+			wantErr: "cannot prompt for cluster, use --cluster: input is not a terminal",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isInputTerminal = func(io.Reader) bool { return test.terminal }
+
+			var (
+				errOut bytes.Buffer
+				got    any
+				err    error
+			)
+			cmd := &Command{
+				Name:      "app",
+				Reader:    strings.NewReader(test.input),
+				ErrWriter: &errOut,
+				Flags:     []Flag{&StringFlag{Name: "cluster"}},
+				Action: func(_ context.Context, cmd *Command) error {
+					if test.multi {
+						got, err = cmd.MultiSelect("cluster", options)
+					} else {
+						got, err = cmd.Select("cluster", options)
+					}
+					return nil
+				},
+			}
+
+			require.NoError(t, cmd.Run(buildTestContext(t), append([]string{"app"}, test.args...)))
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+			assert.Equal(t, test.prompts, errOut.String())
+		})
+	}
+}
+
+func TestRunSelect(t *testing.T) {
+	options := []string{"dev", "staging", "prod"}
+
+	tests := []struct {
+		name  string
+		keys  string
+		multi bool
+		want  []int
+	}{
+		{name: "first", keys: "\r", want: []int{0}},
+		{name: "arrows", keys: "\x1b[B\x1b[B\x1b[A\r", want: []int{1}},
+		{name: "wrap", keys: "k\r", want: []int{2}},
+		{name: "application mode", keys: "\x1bOBj\r", want: []int{2}},
+		{name: "multi", keys: " jj \r", multi: true, want: []int{0, 2}},
+		{name: "multi none", keys: "\r", multi: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := runSelect(strings.NewReader(test.keys), io.Discard, "cluster", options, test.multi)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+
+	var out bytes.Buffer
+	_, err := runSelect(strings.NewReader("j\r"), &out, "cluster", options, false)
+	require.NoError(t, err)
+	assert.Equal(t, "cluster:\n"+
+		"\r\x1b[K> dev\n\r\x1b[K  staging\n\r\x1b[K  prod\n"+
+		"\x1b[3A\r\x1b[K  dev\n\r\x1b[K> staging\n\r\x1b[K  prod\n"+
+		"\x1b[4A\r\x1b[Jcluster: staging\n", out.String())
+
+	_, err = runSelect(strings.NewReader("j\x03"), io.Discard, "cluster", options, false)
+	assert.ErrorIs(t, err, errInterrupted)
+
+	_, err = runSelect(strings.NewReader("j"), io.Discard, "cluster", options, false)
+	assert.ErrorIs(t, err, io.EOF)
+}
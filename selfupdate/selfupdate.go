@@ -0,0 +1,338 @@
+// Package selfupdate provides a command replacing the running executable
+// with the latest release of an application:
+//
+//	cmd := &cli.Command{
+//		Name:    "myapp",
+//		Version: version,
+//		Commands: []*cli.Command{
+//			selfupdate.Command(selfupdate.Config{
+//				ReleasesURL: "https://example.com/myapp/releases.json",
+//				PublicKey:   releaseKey,
+//			}),
+//		},
+//	}
+//
+// It is a separate package so that applications which do not update
+// themselves do not link the HTTP client and crypto packages it needs.
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	// defaultReleaseChannel is the channel updated from unless configured
+	// or given with --channel
+	defaultReleaseChannel = "stable"
+	// defaultMaxSize is the maximum size of a downloaded binary or release
+	// manifest unless configured
+	defaultMaxSize = 256 << 20
+)
+
+// Release is a release of an application available for self-update
+type Release struct {
+	// Version of the release, compared to the Version of the root command
+	Version string `json:"version"`
+	// Binaries of the release keyed by GOOS/GOARCH, e.g. linux/amd64
+	Assets map[string]Asset `json:"assets"`
+}
+
+// Asset is the binary of a release for one platform
+type Asset struct {
+	// URL to download the binary from
+	URL string `json:"url"`
+	// Hex encoded SHA-256 checksum of the binary
+	SHA256 string `json:"sha256"`
+	// Base64 encoded ed25519 signature of the message returned by
+	// SignedMessage for the version of the release, the platform of the
+	// binary and its checksum, required if the Config has a PublicKey
+	Signature string `json:"signature,omitempty"`
+}
+
+// SignedMessage returns the message signed by the Signature of an Asset,
+// binding the checksum of the binary to the version of the release and the
+// platform, e.g. linux/amd64, so that a signed binary cannot be passed off
+// as another version or for another platform
+func SignedMessage(version, platform, sha256 string) []byte {
+	return []byte("urfave-cli-selfupdate\x00" + version + "\x00" + platform + "\x00" + strings.ToLower(sha256))
+}
+
+// Config configures the command returned by Command
+type Config struct {
+	// URL of a JSON manifest mapping release channels to releases, e.g.
+	// {"stable": {"version": "1.2.0", "assets": {"linux/amd64": {...}}}}
+	ReleasesURL string
+	// Function fetching the latest release of a channel, e.g. from the
+	// GitHub releases API, used instead of ReleasesURL if set
+	FetchRelease func(ctx context.Context, channel string) (*Release, error)
+	// Key the signatures of the releases are verified with, before their
+	// versions are compared. Without it, binaries are only verified by the
+	// checksum in the unsigned manifest, so the manifest and binaries must
+	// then be downloaded over https.
+	PublicKey ed25519.PublicKey
+	// Channel updated from unless given with --channel, defaults to stable
+	Channel string
+	// Client used for downloads, defaults to http.DefaultClient
+	Client *http.Client
+	// Maximum size in bytes of a downloaded binary or manifest, defaults
+	// to 256MiB
+	MaxSize int64
+	// Path of the executable to replace, defaults to os.Executable
+	Executable string
+}
+
+// Command returns a self-update command replacing the running executable
+// with the latest release of a channel, after verifying the signature of
+// its version and checksum and the checksum of the binary. With --check-only it only reports whether an update is
+// available. Releases older than the running version, or releases whose
+// version cannot be compared with it, are only installed with --force.
+func Command(config Config) *cli.Command {
+	channel := config.Channel
+	if channel == "" {
+		channel = defaultReleaseChannel
+	}
+
+	return &cli.Command{
+		Name:  "self-update",
+		Usage: "Update to the latest release",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "check-only", Usage: "only check whether an update is available"},
+			&cli.StringFlag{Name: "channel", Usage: "release `CHANNEL` to update from", Value: channel},
+			&cli.BoolFlag{Name: "force", Usage: "install the latest release even if it is not newer"},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			return config.run(ctx, cmd)
+		},
+	}
+}
+
+func (config Config) run(ctx context.Context, cmd *cli.Command) error {
+	root := cmd.Root()
+	channel := cmd.String("channel")
+
+	release, err := config.fetchRelease(ctx, channel)
+	if err != nil {
+		return fmt.Errorf("could not check for updates: %w", err)
+	}
+
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	asset, ok := release.Assets[platform]
+	if !ok {
+		return fmt.Errorf("release %s has no binary for %s", release.Version, platform)
+	}
+
+	// the version is only trusted once its signature is verified, so that
+	// an older release cannot be passed off as a newer one
+	if err := config.verifySignature(release.Version, platform, asset); err != nil {
+		return fmt.Errorf("could not verify release %s: %w", release.Version, err)
+	}
+
+	order, ok := compareVersions(release.Version, root.Version)
+	switch {
+	case cmd.Bool("force"):
+	case !ok:
+		return fmt.Errorf("cannot compare version %s with the latest release %s, use --force to install it", root.Version, release.Version)
+	case order == 0:
+		_, _ = fmt.Fprintf(root.Writer, "%s is up to date (%s)\n", root.Name, root.Version)
+		return nil
+	case order < 0:
+		_, _ = fmt.Fprintf(root.Writer, "%s %s is newer than the latest release %s, use --force to downgrade\n", root.Name, root.Version, release.Version)
+		return nil
+	}
+
+	if cmd.Bool("check-only") {
+		_, _ = fmt.Fprintf(root.Writer, "update available: %s -> %s\n", root.Version, release.Version)
+		return nil
+	}
+
+	data, err := config.download(ctx, asset.URL)
+	if err != nil {
+		return fmt.Errorf("could not download release %s: %w", release.Version, err)
+	}
+
+	if err := verifyChecksum(asset, data); err != nil {
+		return fmt.Errorf("could not verify release %s: %w", release.Version, err)
+	}
+
+	exe := config.Executable
+	if exe == "" {
+		if exe, err = os.Executable(); err != nil {
+			return err
+		}
+	}
+
+	if err := replaceExecutable(exe, data); err != nil {
+		return fmt.Errorf("could not replace executable: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(root.Writer, "updated %s from %s to %s\n", root.Name, root.Version, release.Version)
+	return nil
+}
+
+func (config Config) fetchRelease(ctx context.Context, channel string) (*Release, error) {
+	if config.FetchRelease != nil {
+		return config.FetchRelease(ctx, channel)
+	}
+
+	data, err := config.download(ctx, config.ReleasesURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest map[string]*Release
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid release manifest: %w", err)
+	}
+
+	release, ok := manifest[channel]
+	if !ok || release == nil {
+		return nil, fmt.Errorf("no release in channel %q", channel)
+	}
+
+	return release, nil
+}
+
+func (config Config) download(ctx context.Context, rawURL string) ([]byte, error) {
+	if config.PublicKey == nil {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "https" {
+			return nil, fmt.Errorf("refusing to download %s without https, as no public key to verify it with is configured", rawURL)
+		}
+	}
+
+	client := config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+
+	maxSize := config.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxSize
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("GET %s: larger than %d bytes", rawURL, maxSize)
+	}
+	return data, nil
+}
+
+// verifySignature verifies the signature of the asset for the version and
+// platform if the config has a PublicKey
+func (config Config) verifySignature(version, platform string, asset Asset) error {
+	if config.PublicKey == nil {
+		return nil
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(asset.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	if !ed25519.Verify(config.PublicKey, SignedMessage(version, platform, asset.SHA256), sig) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// verifyChecksum verifies that the checksum of the data is the one of the
+// asset
+func verifyChecksum(asset Asset, data []byte) error {
+	checksum, err := hex.DecodeString(asset.SHA256)
+	if err != nil || len(checksum) != sha256.Size {
+		return fmt.Errorf("invalid checksum %q", asset.SHA256)
+	}
+
+	sum := sha256.Sum256(data)
+	if !bytes.Equal(sum[:], checksum) {
+		return errors.New("checksum mismatch")
+	}
+	return nil
+}
+
+// replaceExecutable atomically replaces the executable at path with data by
+// writing it next to it and renaming it over the original. The original is
+// moved aside first on Windows, which does not allow replacing a running
+// executable, and moved back if the new one cannot be put in its place.
+func replaceExecutable(path string, data []byte) error {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0o755)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".new-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+
+	if runtime.GOOS != "windows" {
+		return os.Rename(tmp.Name(), path)
+	}
+
+	old := path + ".old"
+	_ = os.Remove(old)
+	if err := os.Rename(path, old); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		if restoreErr := os.Rename(old, path); restoreErr != nil {
+			return fmt.Errorf("%w, and could not restore %s: %v", err, path, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestCommand(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	binary := []byte("new binary")
+	sum := sha256.Sum256(binary)
+	asset := Asset{SHA256: hex.EncodeToString(sum[:])}
+	asset.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, SignedMessage("v1.1.0", platform, asset.SHA256)))
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	asset.URL = server.URL + "/app"
+	badAsset := asset
+	badAsset.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, binary))
+
+	manifest := map[string]Release{
+		"stable": {Version: "v1.1.0", Assets: map[string]Asset{platform: asset}},
+		"beta":   {Version: "v1.2.0-beta", Assets: map[string]Asset{platform: badAsset}},
+		// the signed binary of v1.1.0 passed off as a newer version
+		"relabeled": {Version: "v2.0.0", Assets: map[string]Asset{platform: asset}},
+	}
+	mux.HandleFunc("/releases.json", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(manifest)
+	})
+	mux.HandleFunc("/app", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(binary)
+	})
+
+	exe := filepath.Join(t.TempDir(), "app")
+	newCmd := func(out *bytes.Buffer, version string) *cli.Command {
+		return &cli.Command{
+			Name:    "app",
+			Version: version,
+			Writer:  out,
+			Commands: []*cli.Command{
+				Command(Config{
+					ReleasesURL: server.URL + "/releases.json",
+					PublicKey:   pub,
+					Executable:  exe,
+				}),
+			},
+		}
+	}
+
+	require.NoError(t, os.WriteFile(exe, []byte("old binary"), 0o755))
+
+	var out bytes.Buffer
+	require.NoError(t, newCmd(&out, "v1.0.0").Run(context.Background(), []string{"app", "self-update", "--check-only"}))
+	assert.Equal(t, "update available: v1.0.0 -> v1.1.0\n", out.String())
+
+	out.Reset()
+	require.NoError(t, newCmd(&out, "1.1.0").Run(context.Background(), []string{"app", "self-update"}))
+	assert.Equal(t, "app is up to date (1.1.0)\n", out.String())
+
+	out.Reset()
+	require.NoError(t, newCmd(&out, "v1.10.0").Run(context.Background(), []string{"app", "self-update"}))
+	assert.Equal(t, "app v1.10.0 is newer than the latest release v1.1.0, use --force to downgrade\n", out.String())
+
+	err = newCmd(&out, "dev").Run(context.Background(), []string{"app", "self-update"})
+	assert.EqualError(t, err, "cannot compare version dev with the latest release v1.1.0, use --force to install it")
+
+	out.Reset()
+	err = newCmd(&out, "v1.0.0").Run(context.Background(), []string{"app", "self-update", "--channel", "beta"})
+	assert.ErrorContains(t, err, "could not verify release v1.2.0-beta: signature mismatch")
+
+	out.Reset()
+	err = newCmd(&out, "v1.10.0").Run(context.Background(), []string{"app", "self-update", "--channel", "relabeled", "--check-only"})
+	assert.EqualError(t, err, "could not verify release v2.0.0: signature mismatch")
+	assert.Empty(t, out.String())
+
+	err = newCmd(&out, "v1.0.0").Run(context.Background(), []string{"app", "self-update", "--channel", "nightly"})
+	assert.ErrorContains(t, err, `no release in channel "nightly"`)
+
+	data, err := os.ReadFile(exe)
+	require.NoError(t, err)
+	assert.Equal(t, "old binary", string(data))
+
+	out.Reset()
+	require.NoError(t, newCmd(&out, "v1.0.0").Run(context.Background(), []string{"app", "self-update"}))
+	assert.Equal(t, "updated app from v1.0.0 to v1.1.0\n", out.String())
+
+	data, err = os.ReadFile(exe)
+	require.NoError(t, err)
+	assert.Equal(t, "new binary", string(data))
+
+	if runtime.GOOS != "windows" && runtime.GOOS != "wasip1" {
+		fi, err := os.Stat(exe)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o755), fi.Mode().Perm())
+	}
+
+	require.NoError(t, os.WriteFile(exe, []byte("newer binary"), 0o755))
+	out.Reset()
+	require.NoError(t, newCmd(&out, "v1.10.0").Run(context.Background(), []string{"app", "self-update", "--force"}))
+	assert.Equal(t, "updated app from v1.10.0 to v1.1.0\n", out.String())
+
+	data, err = os.ReadFile(exe)
+	require.NoError(t, err)
+	assert.Equal(t, "new binary", string(data))
+}
+
+func TestConfig_download(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	_, err := Config{}.download(context.Background(), server.URL)
+	assert.EqualError(t, err, "refusing to download "+server.URL+" without https, as no public key to verify it with is configured")
+
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	data, err := Config{PublicKey: pub, MaxSize: 10}.download(context.Background(), server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	_, err = Config{PublicKey: pub, MaxSize: 9}.download(context.Background(), server.URL)
+	assert.EqualError(t, err, "GET "+server.URL+": larger than 9 bytes")
+
+	tlsServer := httptest.NewTLSServer(server.Config.Handler)
+	defer tlsServer.Close()
+
+	data, err = Config{Client: tlsServer.Client()}.download(context.Background(), tlsServer.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		order int
+		ok    bool
+	}{
+		{a: "v1.2.3", b: "1.2.3", order: 0, ok: true},
+		{a: "1.2.3", b: "1.10.0", order: -1, ok: true},
+		{a: "2", b: "1.9.9", order: 1, ok: true},
+		{a: "1.2.3+build.5", b: "1.2.3", order: 0, ok: true},
+		{a: "1.2.3-rc.1", b: "1.2.3", order: -1, ok: true},
+		{a: "1.2.3-rc.2", b: "1.2.3-rc.10", order: -1, ok: true},
+		{a: "1.2.3-beta", b: "1.2.3-alpha", order: 1, ok: true},
+		{a: "1.2.3-1", b: "1.2.3-alpha", order: -1, ok: true},
+		{a: "1.2.3-alpha", b: "1.2.3-alpha.1", order: -1, ok: true},
+		{a: "dev", b: "1.2.3"},
+		{a: "1.2.3", b: ""},
+		{a: "1.2.3.4", b: "1.2.3"},
+		{a: "1.2.3-", b: "1.2.3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			order, ok := compareVersions(test.a, test.b)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.order, order)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package selfupdate
+
+import (
+	"strconv"
+	"strings"
+)
+
+// compareVersions compares the semantic versions a and b, with or without a
+// leading v, and returns -1, 0 or 1 if a is older than, the same as or newer
+// than b. It returns false if either is not a semantic version, e.g. a
+// development build without a version.
+func compareVersions(a, b string) (int, bool) {
+	va, ok := parseVersion(a)
+	if !ok {
+		return 0, false
+	}
+	vb, ok := parseVersion(b)
+	if !ok {
+		return 0, false
+	}
+
+	for i := range va.core {
+		if c := compareInts(va.core[i], vb.core[i]); c != 0 {
+			return c, true
+		}
+	}
+	return comparePrerelease(va.pre, vb.pre), true
+}
+
+type version struct {
+	core [3]uint64
+	pre  []string
+}
+
+// parseVersion parses a version like v1.2.3-rc.1+build, where the minor and
+// patch version may be left out. Build metadata is ignored.
+func parseVersion(s string) (version, bool) {
+	var v version
+
+	s = strings.TrimPrefix(s, "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	if hasPre {
+		if pre == "" {
+			return v, false
+		}
+		v.pre = strings.Split(pre, ".")
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > len(v.core) {
+		return v, false
+	}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return v, false
+		}
+		v.core[i] = n
+	}
+
+	return v, true
+}
+
+// comparePrerelease compares pre-release versions by the rules of semantic
+// versioning, a version without one is newer than any with one
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, errA := strconv.ParseUint(a[i], 10, 64)
+		nb, errB := strconv.ParseUint(b[i], 10, 64)
+		switch {
+		case errA == nil && errB == nil:
+			if c := compareInts(na, nb); c != 0 {
+				return c
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return compareInts(uint64(len(a)), uint64(len(b)))
+}
+
+func compareInts(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
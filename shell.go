@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ShellReadLineFunc reads one line of input for [Command.RunShell] after
+// displaying the prompt. It returns io.EOF when there is no more input.
+type ShellReadLineFunc func(prompt string) (string, error)
+
+// shellExitCommands end the shell started by RunShell
+var shellExitCommands = []string{"exit", "quit"}
+
+// RunShell runs an interactive shell in which each line of input is split
+// into arguments like a response file and run as an invocation of the
+// command, until the input ends or "exit" or "quit" is entered. Errors of
+// an invocation are written to the ErrWriter and do not end the shell.
+//
+// Lines are read from the Reader unless ShellReadLine is set, which allows
+// using a line editor with CompleteLine providing the completions. The lines
+// entered are recorded in the history, see ShellHistory and
+// ShellHistoryFile.
+func (cmd *Command) RunShell(ctx context.Context) error {
+	cmd.setupDefaults(os.Args)
+
+	readLine := cmd.ShellReadLine
+	if readLine == nil {
+		scanner := bufio.NewScanner(cmd.Reader)
+		readLine = func(prompt string) (string, error) {
+			_, _ = fmt.Fprint(cmd.Writer, prompt)
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return "", err
+				}
+				return "", io.EOF
+			}
+			return scanner.Text(), nil
+		}
+	}
+
+	if err := cmd.loadShellHistory(); err != nil {
+		return err
+	}
+
+	prompt := cmd.ShellPrompt
+	if prompt == "" {
+		prompt = cmd.Name + "> "
+	}
+
+	cmd.inShell = true
+	defer func() { cmd.inShell = false }()
+
+	for {
+		line, err := readLine(prompt)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if err := cmd.addShellHistory(line); err != nil {
+			_, _ = fmt.Fprintln(cmd.ErrWriter, err)
+		}
+
+		args, err := splitResponseFile(line)
+		if err != nil {
+			_, _ = fmt.Fprintln(cmd.ErrWriter, err)
+			continue
+		}
+
+		if len(args) == 1 && slices.Contains(shellExitCommands, args[0]) {
+			return nil
+		}
+
+		tracef("running shell line %[1]q (cmd=%[2]q)", args, cmd.Name)
+		cmd.resetRunState()
+		if err := cmd.Run(ctx, append([]string{cmd.Name}, args...)); err != nil {
+			if msg := err.Error(); msg != "" {
+				_, _ = fmt.Fprintln(cmd.ErrWriter, msg)
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// ShellHistory returns the lines entered in the shell started by RunShell,
+// including those loaded from the ShellHistoryFile, oldest first
+func (cmd *Command) ShellHistory() []string {
+	return cmd.shellHistory
+}
+
+// CompleteLine returns the completions of the last word of a line entered
+// in the shell started by RunShell, i.e. the names of the subcommands or
+// flags of the command the line resolves to which start with the last word
+func (cmd *Command) CompleteLine(line string) []string {
+	cmd.setupDefaults(os.Args)
+	cmd.setupCommandGraph()
+
+	words := strings.Fields(line)
+	last := ""
+	if len(words) > 0 && !strings.HasSuffix(line, " ") {
+		last = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	current := cmd
+	for _, word := range words {
+		if word == "--" {
+			return nil
+		}
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		subCmd := current.Command(word)
+		if subCmd == nil {
+			continue
+		}
+		subCmd.ensureLoaded()
+		current = subCmd
+	}
+
+	var completions []string
+	if strings.HasPrefix(last, "-") {
+		for _, pCmd := range current.Lineage() {
+			for _, fl := range pCmd.Flags {
+				if pCmd != current {
+					if lf, ok := fl.(LocalFlag); ok && lf.IsLocal() {
+						continue
+					}
+				}
+				if vf, ok := fl.(VisibleFlag); ok && !vf.IsVisible() {
+					continue
+				}
+				for _, name := range fl.Names() {
+					prefixed := prefixFor(name) + name
+					if strings.HasPrefix(prefixed, last) {
+						completions = append(completions, prefixed)
+					}
+				}
+			}
+		}
+	} else {
+		for _, subCmd := range current.Commands {
+			if subCmd.isHidden() {
+				continue
+			}
+			for _, name := range subCmd.Names() {
+				if strings.HasPrefix(name, last) {
+					completions = append(completions, name)
+				}
+			}
+		}
+	}
+
+	sort.Strings(completions)
+	return completions
+}
+
+// resetRunState resets the state of the command graph left by the previous
+// Run, so the command can be run again with other arguments
+func (cmd *Command) resetRunState() {
+	cmd.appliedFlags = nil
+	cmd.parsedArgs = nil
+	cmd.unknownArgs = nil
+	cmd.beforeCtx = nil
+	cmd.isInError = false
+	cmd.helpAll = false
+	cmd.runningCmd = nil
+	cmd.shutdownFuncs = nil
+	cmd.configLayers = nil
+	cmd.configSources = nil
+
+	for _, fl := range cmd.Flags {
+		if rf, ok := fl.(interface{ reset() }); ok {
+			rf.reset()
+		}
+	}
+
+	for _, subCmd := range cmd.Commands {
+		subCmd.resetRunState()
+	}
+}
+
+func (cmd *Command) loadShellHistory() error {
+	cmd.shellHistory = nil
+	if cmd.ShellHistoryFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(cmd.ShellHistoryFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read shell history: %w", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			cmd.shellHistory = append(cmd.shellHistory, line)
+		}
+	}
+
+	return nil
+}
+
+func (cmd *Command) addShellHistory(line string) error {
+	cmd.shellHistory = append(cmd.shellHistory, line)
+	if cmd.ShellHistoryFile == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(cmd.ShellHistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not write shell history: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		return fmt.Errorf("could not write shell history: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+// interruptedExitCode is the exit code used when a second signal is received
+// while the command is still shutting down after the first one
+const interruptedExitCode = 130
+
+var (
+	signalNotify = signal.Notify
+	signalStop   = signal.Stop
+)
+
+// notifySignals returns a copy of ctx which is canceled when one of
+// HandleSignals is received, the cause of the cancellation is set to an error
+// naming the signal. A second signal exits the process immediately with exit
+// code 130. The returned func must be called to stop listening for signals.
+func (cmd *Command) notifySignals(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	sigCh := make(chan os.Signal, 2)
+	done := make(chan struct{})
+	signalNotify(sigCh, cmd.HandleSignals...)
+
+	go func() {
+		select {
+		case sig := <-sigCh:
+			tracef("received signal %[1]v, canceling context (cmd=%[2]q)", sig, cmd.Name)
+			cancel(fmt.Errorf("received signal %v", sig))
+		case <-done:
+			return
+		}
+
+		select {
+		case sig := <-sigCh:
+			tracef("received second signal %[1]v, exiting (cmd=%[2]q)", sig, cmd.Name)
+			cmd.exit(interruptedExitCode)
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signalStop(sigCh)
+		close(done)
+		cancel(nil)
+	}
+}
@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// stateFile is the file in the DataDir the default StateStore writes to
+	stateFile = "state.json"
+	// stateLockFile is locked while the state file is updated, as other
+	// instances may update it at the same time
+	stateLockFile = "state.lock"
+	// stateLockTimeout is how long an update waits for another instance to
+	// release the lock of the state file
+	stateLockTimeout = time.Second
+)
+
+// StateStore persists small values between runs of a command, like the last
+// selected project or a cached token, see Command.State
+type StateStore interface {
+	// Get returns the value of the key and whether it is set and has not
+	// expired
+	Get(key string) (string, bool, error)
+	// Set sets the value of the key, which expires after the ttl unless it
+	// is 0
+	Set(key, value string, ttl time.Duration) error
+	// Delete removes the key, it is not an error if it is not set
+	Delete(key string) error
+}
+
+// State returns the StateStore of the root command, or else a store keeping
+// the values as JSON in state.json in the DataDir. The file is only readable
+// by the user, its permissions are fixed if they are wider.
+func (cmd *Command) State() StateStore {
+	root := cmd.Root()
+	if root.StateStore != nil {
+		return root.StateStore
+	}
+	if root.state == nil {
+		root.state = &fileStateStore{now: cmd.now, path: func() (string, error) {
+			dir, err := cmd.DataDir()
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(dir, stateFile), nil
+		}}
+	}
+	return root.state
+}
+
+type stateEntry struct {
+	Value   string    `json:"value"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+func (e stateEntry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !now.Before(e.Expires)
+}
+
+// fileStateStore is a StateStore writing all values to a JSON file
+type fileStateStore struct {
+	path func() (string, error)
+	now  func() time.Time
+	mu   sync.Mutex
+}
+
+func (s *fileStateStore) Get(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, entries, err := s.read()
+	if err != nil {
+		return "", false, err
+	}
+	entry, ok := entries[key]
+	if !ok || entry.expired(s.now()) {
+		return "", false, nil
+	}
+	return entry.Value, true, nil
+}
+
+func (s *fileStateStore) Set(key, value string, ttl time.Duration) error {
+	return s.update(func(entries map[string]stateEntry) {
+		entry := stateEntry{Value: value}
+		if ttl > 0 {
+			entry.Expires = s.now().Add(ttl).UTC()
+		}
+		entries[key] = entry
+	})
+}
+
+func (s *fileStateStore) Delete(key string) error {
+	return s.update(func(entries map[string]stateEntry) {
+		delete(entries, key)
+	})
+}
+
+// update changes the entries and writes them back without expired ones. The
+// file is locked meanwhile, so updates of other instances are not lost.
+// Without file locks, like on wasip1, only updates of this process are
+// serialized.
+func (s *fileStateStore) update(f func(map[string]stateEntry)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.path()
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(filepath.Join(filepath.Dir(path), stateLockFile), stateLockTimeout)
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		tracef("not locking %[1]q: %[2]v", path, err)
+	case err != nil:
+		return err
+	default:
+		defer unlock()
+	}
+
+	_, entries, err := s.read()
+	if err != nil {
+		return err
+	}
+	f(entries)
+
+	now := s.now()
+	for key, entry := range entries {
+		if entry.expired(now) {
+			delete(entries, key)
+		}
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0o600)
+}
+
+func (s *fileStateStore) read() (string, map[string]stateEntry, error) {
+	path, err := s.path()
+	if err != nil {
+		return "", nil, err
+	}
+
+	entries := map[string]stateEntry{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return path, entries, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	// tokens may be stored in the file, so it must not be readable by others
+	if fi, err := os.Stat(path); err == nil && fi.Mode().Perm()&0o077 != 0 {
+		tracef("fixing permissions %[1]v of %[2]q", fi.Mode().Perm(), path)
+		if err := os.Chmod(path, 0o600); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return "", nil, err
+	}
+	return path, entries, nil
+}
+
+// writeFileAtomic writes the data to a temp file which replaces the file, so
+// it is never read half written
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_State(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APP_DATA_DIR", dir)
+
+	run := func(action ActionFunc) {
+		cmd := &Command{
+			Name:     "app",
+			Commands: []*Command{{Name: "sub", Action: action}},
+		}
+		require.NoError(t, cmd.Run(buildTestContext(t), []string{"app", "sub"}))
+	}
+
+	run(func(_ context.Context, cmd *Command) error {
+		require.NoError(t, cmd.State().Set("project", "cli", 0))
+		require.NoError(t, cmd.State().Set("token", "secret", time.Hour))
+		require.NoError(t, cmd.State().Set("expired", "x", time.Nanosecond))
+		require.NoError(t, cmd.State().Set("deleted", "x", 0))
+		return cmd.State().Delete("deleted")
+	})
+
+	path := filepath.Join(dir, stateFile)
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+		require.NoError(t, os.Chmod(path, 0o644))
+	}
+
+	run(func(_ context.Context, cmd *Command) error {
+		for key, want := range map[string]string{"project": "cli", "token": "secret"} {
+			value, ok, err := cmd.State().Get(key)
+			require.NoError(t, err)
+			assert.True(t, ok, key)
+			assert.Equal(t, want, value)
+		}
+		for _, key := range []string{"expired", "deleted", "unknown"} {
+			_, ok, err := cmd.State().Get(key)
+			require.NoError(t, err)
+			assert.False(t, ok, key)
+		}
+		return nil
+	})
+
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+	}
+}
+
+func TestCommand_StateStore(t *testing.T) {
+	store := &fileStateStore{now: time.Now, path: func() (string, error) {
+		return filepath.Join(t.TempDir(), "state.json"), nil
+	}}
+	cmd := &Command{
+		Name:       "app",
+		StateStore: store,
+		Action: func(_ context.Context, cmd *Command) error {
+			assert.Same(t, store, cmd.State())
+			return nil
+		},
+	}
+	require.NoError(t, cmd.Run(buildTestContext(t), []string{"app"}))
+}
+
+func TestFileStateStore_ConcurrentUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), stateFile)
+
+	// each store stands for another instance updating the same file
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store := &fileStateStore{now: time.Now, path: func() (string, error) { return path, nil }}
+			for j := 0; j < 10; j++ {
+				assert.NoError(t, store.Set(fmt.Sprintf("key-%d-%d", i, j), "x", 0))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	_, entries, err := (&fileStateStore{path: func() (string, error) { return path, nil }}).read()
+	require.NoError(t, err)
+	assert.Len(t, entries, 40)
+}
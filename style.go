@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// NoColorFlag disables the HelpStyle of the root command. It is added to the
+// root command if it has a HelpStyle. Set to nil to not add the flag.
+var NoColorFlag Flag = &BoolFlag{
+	Name:        "no-color",
+	Usage:       "disable colors in output",
+	HideDefault: true,
+}
+
+// ColorFlag selects whether to colorize output with --color=auto|always|never,
+// see ShouldColorize. It is not added to commands automatically.
+var ColorFlag Flag = &StringFlag{
+	Name:  "color",
+	Usage: "colorize output: `WHEN` is auto, always or never",
+	Value: "auto",
+	Validator: func(when string) error {
+		switch when {
+		case "auto", "always", "never":
+			return nil
+		}
+		return fmt.Errorf("invalid color mode %q, must be auto, always or never", when)
+	},
+}
+
+// ShouldColorize returns true if output written to w should be colorized,
+// e.g. with ANSI escape codes. It honors, in order, the ColorFlag and the
+// NoColorFlag of the command or its parents, the NO_COLOR, CLICOLOR_FORCE and
+// CLICOLOR env vars, and otherwise whether w is a terminal. The command may
+// be nil to only check the env vars and w.
+func ShouldColorize(w io.Writer, cmd *Command) bool {
+	if cmd != nil {
+		if ColorFlag != nil {
+			for _, name := range ColorFlag.Names() {
+				if cmd.lookupFlag(name) == nil {
+					continue
+				}
+				switch cmd.String(name) {
+				case "always":
+					return true
+				case "never":
+					return false
+				}
+			}
+		}
+
+		if NoColorFlag != nil {
+			for _, name := range NoColorFlag.Names() {
+				if cmd.lookupFlag(name) != nil && cmd.Bool(name) {
+					return false
+				}
+			}
+		}
+	}
+
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	}
+	if os.Getenv("CLICOLOR") == "0" {
+		return false
+	}
+
+	return isTerminal(w)
+}
+
+// HelpStyle styles parts of the help output, e.g. with ANSI escape codes, see
+// Command.HelpStyle. Parts with a nil func are not styled.
+type HelpStyle struct {
+	// Section headings like USAGE: and command category names
+	Heading func(string) string
+	// Names of subcommands
+	Command func(string) string
+	// Names and placeholders of flags
+	Flag func(string) string
+	// Default values of flags
+	Default func(string) string
+}
+
+// DefaultHelpStyle shows headings in bold, command names in cyan, flag names
+// in green and default values dimmed
+var DefaultHelpStyle = &HelpStyle{
+	Heading: ANSIStyle("1"),
+	Command: ANSIStyle("36"),
+	Flag:    ANSIStyle("32"),
+	Default: ANSIStyle("2"),
+}
+
+// ANSIStyle returns a func wrapping a string in the ANSI escape sequence with
+// the given SGR parameters, e.g. "1;31" for bold red, and a reset
+func ANSIStyle(params string) func(string) string {
+	return func(s string) string {
+		return "\x1b[" + params + "m" + s + "\x1b[0m"
+	}
+}
+
+var (
+	helpHeadingRe = regexp.MustCompile(`^[A-Z][A-Z0-9 ()]*:$`)
+	helpEntryRe   = regexp.MustCompile(`^( +)(\S.*?)( {2,}.*)?$`)
+	helpDefaultRe = regexp.MustCompile(`\(default: .*?\)`)
+)
+
+// helpStyle returns the HelpStyle of the root command, or nil if output to
+// out should not be colorized, see ShouldColorize
+func (cmd *Command) helpStyle(out io.Writer) *HelpStyle {
+	root := cmd.Root()
+	if root.HelpStyle == nil || !ShouldColorize(out, cmd) {
+		return nil
+	}
+
+	return root.HelpStyle
+}
+
+// apply styles rendered help text line by line, by the section the lines
+// are in. Only the text is wrapped, so the alignment of columns is kept.
+func (s *HelpStyle) apply(help string) string {
+	lines := strings.Split(help, "\n")
+	section := ""
+	categorized := false
+
+	for i, line := range lines {
+		if helpHeadingRe.MatchString(line) {
+			section = strings.TrimSuffix(line, ":")
+			categorized = false
+			lines[i] = styleWith(s.Heading, line)
+			continue
+		}
+
+		m := helpEntryRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		indent, name, rest := m[1], m[2], m[3]
+
+		switch {
+		case section == "COMMANDS" && len(indent) == 3 && rest == "" && strings.HasSuffix(name, ":"):
+			categorized = true
+			lines[i] = indent + styleWith(s.Heading, name)
+		case section == "COMMANDS" && (len(indent) == 5 || (len(indent) == 3 && !categorized)):
+			lines[i] = indent + styleWith(s.Command, name) + rest
+		case strings.HasSuffix(section, "OPTIONS") && len(indent) == 3 && strings.HasPrefix(name, "-"):
+			if s.Default != nil {
+				rest = helpDefaultRe.ReplaceAllStringFunc(rest, s.Default)
+			}
+			lines[i] = indent + styleWith(s.Flag, name) + rest
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func styleWith(fn func(string) string, s string) string {
+	if fn == nil {
+		return s
+	}
+	return fn(s)
+}
@@ -150,3 +150,32 @@ func TestSuggestCommand(t *testing.T) {
 		assert.Equal(t, testCase.expected, res)
 	}
 }
+
+func TestSuggestFlagFromErrorThreshold(t *testing.T) {
+	app := buildExtendedTestCommand()
+	app.SuggestThreshold = 0.8
+
+	res, err := app.suggestFlagFromError(errors.New(providedButNotDefinedErrMsg+"sockt"), "")
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(SuggestDidYouMeanTemplate+"\n\n", "--socket"), res)
+
+	_, err = app.suggestFlagFromError(errors.New(providedButNotDefinedErrMsg+"zzz"), "")
+	assert.Error(t, err)
+}
+
+func TestCommandSuggestLineage(t *testing.T) {
+	sub := &Command{Name: "sub"}
+	root := &Command{Suggest: true, Commands: []*Command{sub}}
+	sub.parent = root
+
+	assert.True(t, root.suggest())
+	assert.True(t, sub.suggest())
+
+	sub.SuggestionsDisabled = true
+	assert.True(t, root.suggest())
+	assert.False(t, sub.suggest())
+
+	root.SuggestThreshold = 0.9
+	assert.True(t, sub.closeEnough("deploy", "deplyo"))
+	assert.False(t, sub.closeEnough("deploy", "status"))
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Alignment is the alignment of the cells of a column of a Table
+type Alignment int
+
+const (
+	// AlignLeft pads cells on the right
+	AlignLeft Alignment = iota
+	// AlignRight pads cells on the left, e.g. for numbers
+	AlignRight
+	// AlignCenter pads cells on both sides
+	AlignCenter
+)
+
+// tableColumnGap separates the columns of a Table
+const tableColumnGap = "  "
+
+var ansiEscapeRe = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// Table writes rows of cells in aligned columns, e.g. for output to a
+// terminal. The width of a column is the width of its widest cell, ANSI
+// escape sequences in cells are not counted.
+type Table struct {
+	// Headers of the columns, no header row is written if empty
+	Headers []string
+	// Alignment of the columns, AlignLeft for columns without one
+	Align []Alignment
+	// Maximum width of the columns, longer cells are cut off with "…". The
+	// width is not limited if it is 0.
+	MaxWidth int
+	// Style of the header row, e.g. ANSIStyle("1") for bold headers. It is
+	// applied after the headers are aligned.
+	HeaderStyle func(string) string
+
+	rows [][]string
+}
+
+// NewTable returns a Table with the headers
+func NewTable(headers ...string) *Table {
+	return &Table{Headers: headers}
+}
+
+// Append adds a row with the cells
+func (t *Table) Append(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// Write writes the header row and the rows to w
+func (t *Table) Write(w io.Writer) error {
+	rows := t.rows
+	if len(t.Headers) > 0 {
+		rows = append([][]string{t.Headers}, rows...)
+	}
+
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], t.cellWidth(cell))
+		}
+	}
+
+	var b strings.Builder
+	for r, row := range rows {
+		var line strings.Builder
+		for i, cell := range row {
+			if i > 0 {
+				line.WriteString(tableColumnGap)
+			}
+			align := AlignLeft
+			if i < len(t.Align) {
+				align = t.Align[i]
+			}
+			// the last column is not padded on the right
+			last := i == len(row)-1
+			line.WriteString(alignCell(t.cut(cell), widths[i], align, last))
+		}
+
+		text := line.String()
+		if r == 0 && len(t.Headers) > 0 && t.HeaderStyle != nil {
+			text = t.HeaderStyle(text)
+		}
+		b.WriteString(text)
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (t *Table) cellWidth(cell string) int {
+	width := displayWidth(cell)
+	if t.MaxWidth > 0 {
+		return min(width, t.MaxWidth)
+	}
+	return width
+}
+
+// cut cuts off a cell wider than MaxWidth
+func (t *Table) cut(cell string) string {
+	if t.MaxWidth <= 0 || displayWidth(cell) <= t.MaxWidth {
+		return cell
+	}
+	runes := []rune(ansiEscapeRe.ReplaceAllString(cell, ""))
+	return string(runes[:t.MaxWidth-1]) + "…"
+}
+
+// displayWidth returns the number of characters of s shown on a terminal
+func displayWidth(s string) int {
+	return utf8.RuneCountInString(ansiEscapeRe.ReplaceAllString(s, ""))
+}
+
+// alignCell pads the cell to the width with the alignment, trailing spaces
+// are left out if last is set
+func alignCell(cell string, width int, align Alignment, last bool) string {
+	pad := width - displayWidth(cell)
+	if pad <= 0 {
+		return cell
+	}
+
+	var left, right int
+	switch align {
+	case AlignRight:
+		left = pad
+	case AlignCenter:
+		left = pad / 2
+		right = pad - left
+	default:
+		right = pad
+	}
+	if last {
+		right = 0
+	}
+	return strings.Repeat(" ", left) + cell + strings.Repeat(" ", right)
+}
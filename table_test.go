@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTable(t *testing.T) {
+	tests := []struct {
+		name  string
+		table *Table
+		rows  [][]string
+		want  string
+	}{
+		{
+			name:  "left",
+			table: NewTable("NAME", "SIZE"),
+			rows:  [][]string{{"a", "1"}, {"longer", "100"}},
+			want:  "NAME    SIZE\na       1\nlonger  100\n",
+		},
+		{
+			name:  "right and center",
+			table: &Table{Headers: []string{"NAME", "STATE", "SIZE"}, Align: []Alignment{AlignLeft, AlignCenter, AlignRight}},
+			rows:  [][]string{{"a", "ok", "1"}, {"b", "failed", "100"}},
+			want:  "NAME  STATE   SIZE\na       ok       1\nb     failed   100\n",
+		},
+		{
+			name:  "max width",
+			table: &Table{Headers: []string{"NAME", "DESCRIPTION"}, MaxWidth: 8},
+			rows:  [][]string{{"a", "a very long description"}, {"ünïcödé name", "short"}},
+			want:  "NAME      DESCRIP…\na         a very …\nünïcödé…  short\n",
+		},
+		{
+			name:  "ansi",
+			table: &Table{Headers: []string{"NAME", "STATE"}, HeaderStyle: ANSIStyle("1")},
+			rows:  [][]string{{"\x1b[32mok\x1b[0m", "x"}, {"failed", "y"}},
+			want:  "\x1b[1mNAME    STATE\x1b[0m\n\x1b[32mok\x1b[0m      x\nfailed  y\n",
+		},
+		{
+			name:  "no headers",
+			table: NewTable(),
+			rows:  [][]string{{"a", "b"}, {"cc"}},
+			want:  "a   b\ncc\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, row := range test.rows {
+				test.table.Append(row...)
+			}
+			var out bytes.Buffer
+			require.NoError(t, test.table.Write(&out))
+			assert.Equal(t, test.want, out.String())
+		})
+	}
+}
@@ -0,0 +1,591 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTelemetryFlushAt is the number of queued events which are sent
+	// at once if TelemetryConfig has no FlushAt
+	defaultTelemetryFlushAt = 10
+	// defaultTelemetryMaxQueued is the number of events kept while they
+	// cannot be sent if TelemetryConfig has no MaxQueued
+	defaultTelemetryMaxQueued = 100
+	// defaultTelemetryTimeout is the timeout of sending events if
+	// TelemetryConfig has no Timeout
+	defaultTelemetryTimeout = 2 * time.Second
+	// defaultTelemetryBackoff is how long sending is not tried again after
+	// it failed if TelemetryConfig has no Backoff
+	defaultTelemetryBackoff = time.Hour
+	// maxTelemetryBackoff is the longest time sending is not tried again
+	maxTelemetryBackoff = 24 * time.Hour
+	// telemetryLockTimeout is how long recording waits for another instance
+	// to release the lock of the queue
+	telemetryLockTimeout = time.Second
+	// telemetryExitWait is how long Run waits for events still being sent
+	// in the background when the command exits
+	telemetryExitWait = 200 * time.Millisecond
+
+	telemetryConsentFile = "consent"
+	telemetryQueueFile   = "queue.jsonl"
+	telemetryBackoffFile = "backoff.json"
+	telemetryLockFile    = "queue.lock"
+)
+
+// TelemetryEvent is an anonymous usage event of running a command. It holds
+// no arguments or flag values.
+type TelemetryEvent struct {
+	// Time the command was started at
+	Time time.Time `json:"time"`
+	// Full name of the command, e.g. app deploy
+	Command string `json:"command"`
+	// Names of the flags which were set, without their values
+	Flags []string `json:"flags,omitempty"`
+	// How long the command ran
+	Duration time.Duration `json:"duration"`
+	// Exit code of the command
+	ExitCode int `json:"exitCode"`
+	// Class of the error of the command, its category if it has one, see
+	// ExitWithCategory, or else the type of the innermost wrapped error
+	ErrorClass string `json:"errorClass,omitempty"`
+	// Version of the root command
+	Version string `json:"version,omitempty"`
+	// Platform the command ran on
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+}
+
+// TelemetryTransport sends telemetry events, e.g. to an analytics service
+type TelemetryTransport interface {
+	Send(ctx context.Context, events []TelemetryEvent) error
+}
+
+// TelemetryTransportFunc is an adapter to allow the use of ordinary
+// functions as a TelemetryTransport
+type TelemetryTransportFunc func(ctx context.Context, events []TelemetryEvent) error
+
+// Send calls f(ctx, events)
+func (f TelemetryTransportFunc) Send(ctx context.Context, events []TelemetryEvent) error {
+	return f(ctx, events)
+}
+
+// TelemetryConfig configures the anonymous usage telemetry of a command, see
+// Command.Telemetry. Events are only recorded after the user opted in with
+// the telemetry on command of TelemetryCommand, or with the env var. They
+// are queued in a file when a command exits and sent in batches in the
+// background while a later command runs, so exiting is delayed by sending
+// for no more than a short time. Events which were not sent before the
+// process exited stay queued.
+type TelemetryConfig struct {
+	// Transport the events are sent with
+	Transport TelemetryTransport
+	// Directory the consent and the queued events are stored in, defaults to
+	// telemetry in the DataDir of the root command
+	Dir string
+	// Env var turning telemetry on or off regardless of the consent, e.g.
+	// with 1 or 0, defaults to <APP>_TELEMETRY. Telemetry is always off if
+	// DO_NOT_TRACK is set.
+	EnvVar string
+	// Number of queued events which are sent at once, defaults to 10
+	FlushAt int
+	// Number of events kept while they cannot be sent, older events are
+	// dropped, defaults to 100
+	MaxQueued int
+	// Timeout of sending events, defaults to 2 seconds
+	Timeout time.Duration
+	// How long sending is not tried again after it failed, so that commands
+	// are not slowed down by the timeout while the transport is down. It is
+	// doubled after each further failure, up to a day. Defaults to 1 hour.
+	Backoff time.Duration
+}
+
+// telemetryBackoff is the state of sending after it failed
+type telemetryBackoff struct {
+	// Number of consecutive failures
+	Failures int `json:"failures"`
+	// Time until which sending is not tried again
+	Until time.Time `json:"until"`
+}
+
+func (tc *TelemetryConfig) dir(root *Command) (string, error) {
+	if tc.Dir != "" {
+		return tc.Dir, nil
+	}
+	dir, err := root.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "telemetry"), nil
+}
+
+func (tc *TelemetryConfig) envVar(root *Command) string {
+	if tc.EnvVar != "" {
+		return tc.EnvVar
+	}
+	return strings.ToUpper(strings.ReplaceAll(root.Name, "-", "_")) + "_TELEMETRY"
+}
+
+// enabled returns whether telemetry is turned on and what by
+func (tc *TelemetryConfig) enabled(root *Command) (bool, string) {
+	if v := os.Getenv("DO_NOT_TRACK"); v != "" && v != "0" {
+		return false, "DO_NOT_TRACK"
+	}
+
+	envVar := tc.envVar(root)
+	if v, ok := os.LookupEnv(envVar); ok && v != "" {
+		on, err := parseSwitch(v)
+		if err == nil {
+			return on, envVar
+		}
+		tracef("ignoring invalid value %[1]q of %[2]s: %[3]v", v, envVar, err)
+	}
+
+	dir, err := tc.dir(root)
+	if err != nil {
+		return false, ""
+	}
+	data, err := os.ReadFile(filepath.Join(dir, telemetryConsentFile))
+	if err != nil {
+		return false, ""
+	}
+	on, _ := parseSwitch(strings.TrimSpace(string(data)))
+	return on, "consent"
+}
+
+func parseSwitch(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "on", "true", "yes":
+		return true, nil
+	case "0", "off", "false", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid value %q, must be on or off", s)
+}
+
+// TelemetryCommand returns a telemetry command with the subcommands on, off
+// and status to manage the consent to the Telemetry of the root command
+func TelemetryCommand() *Command {
+	consent := func(on bool) ActionFunc {
+		return func(_ context.Context, cmd *Command) error {
+			root := cmd.Root()
+			tc := root.Telemetry
+			if tc == nil {
+				return fmt.Errorf("%s has no telemetry", root.Name)
+			}
+			dir, err := tc.dir(root)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				return err
+			}
+
+			if err := os.WriteFile(filepath.Join(dir, telemetryConsentFile), []byte(onOff(on)+"\n"), 0o600); err != nil {
+				return err
+			}
+			if !on {
+				// drop the events which were not sent yet
+				unlock, err := lockFile(filepath.Join(dir, telemetryLockFile), telemetryLockTimeout)
+				if err != nil {
+					return err
+				}
+				_ = os.Remove(filepath.Join(dir, telemetryQueueFile))
+				_ = os.Remove(filepath.Join(dir, telemetryBackoffFile))
+				unlock()
+			}
+
+			_, _ = fmt.Fprintf(root.Writer, "telemetry is %s\n", onOff(on))
+			if enabled, by := tc.enabled(root); enabled != on {
+				_, _ = fmt.Fprintf(root.Writer, "but it is turned %s by %s\n", onOff(enabled), by)
+			}
+			return nil
+		}
+	}
+
+	return &Command{
+		Name:               "telemetry",
+		Usage:              "Manage anonymous usage telemetry",
+		isTelemetryCommand: true,
+		Commands: []*Command{
+			{
+				Name:   "on",
+				Usage:  "Send anonymous usage data",
+				Action: consent(true),
+			},
+			{
+				Name:   "off",
+				Usage:  "Do not send anonymous usage data",
+				Action: consent(false),
+			},
+			{
+				Name:  "status",
+				Usage: "Print whether anonymous usage data is sent",
+				Action: func(_ context.Context, cmd *Command) error {
+					root := cmd.Root()
+					if root.Telemetry == nil {
+						return fmt.Errorf("%s has no telemetry", root.Name)
+					}
+					enabled, by := root.Telemetry.enabled(root)
+					if by == "" || by == "consent" {
+						_, _ = fmt.Fprintf(root.Writer, "telemetry is %s\n", onOff(enabled))
+					} else {
+						_, _ = fmt.Fprintf(root.Writer, "telemetry is %s by %s\n", onOff(enabled), by)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// telemetry returns the telemetry config of the root command if telemetry
+// is turned on and the command is not one of TelemetryCommand
+func (cmd *Command) telemetry() *TelemetryConfig {
+	root := cmd.Root()
+	tc := root.Telemetry
+	if tc == nil {
+		return nil
+	}
+
+	if enabled, _ := tc.enabled(root); !enabled {
+		return nil
+	}
+	for _, pCmd := range cmd.Lineage() {
+		if pCmd.isTelemetryCommand {
+			return nil
+		}
+	}
+	return tc
+}
+
+// flushTelemetry starts sending the queued events in the background if
+// there are at least FlushAt, unless sending failed before and is backed
+// off. The events are sent while the command runs, so that its exit is not
+// delayed. Errors are not reported to not get in the way of the command.
+func (cmd *Command) flushTelemetry() {
+	tc := cmd.telemetry()
+	if tc == nil || tc.Transport == nil {
+		return
+	}
+
+	root := cmd.Root()
+	events, err := tc.due(root)
+	if err != nil {
+		tracef("could not read telemetry queue: %[1]v (cmd=%[2]q)", err, cmd.Name)
+		return
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	// the command may be changed once Run returned, so nothing is read from
+	// it in the background
+	dir, err := tc.dir(root)
+	if err != nil {
+		return
+	}
+	now := root.now()
+	done := make(chan struct{})
+	root.telemetryDone = done
+	go func() {
+		defer close(done)
+		tc.send(dir, now, events)
+	}()
+}
+
+// waitTelemetry waits up to telemetryExitWait for the events being sent in
+// the background, events which were not sent by then stay queued
+func (cmd *Command) waitTelemetry() {
+	done := cmd.telemetryDone
+	if done == nil {
+		return
+	}
+	cmd.telemetryDone = nil
+
+	select {
+	case <-done:
+	case <-time.After(telemetryExitWait):
+		tracef("not waiting for telemetry events being sent (cmd=%[1]q)", cmd.Name)
+	}
+}
+
+// recordTelemetry queues the telemetry event of running the command if
+// telemetry is turned on. Errors are not reported to not get in the way of
+// the command.
+func (cmd *Command) recordTelemetry(start time.Time, err error) {
+	tc := cmd.telemetry()
+	if tc == nil {
+		return
+	}
+
+	root := cmd.Root()
+	event := TelemetryEvent{
+		Time:     start.UTC(),
+		Command:  cmd.FullName(),
+		Flags:    cmd.setFlagNames(),
+		Duration: cmd.now().Sub(start),
+		Version:  root.Version,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	if err != nil {
+		event.ExitCode, event.ErrorClass = exitCodeOf(err), errorClass(err)
+	}
+
+	if err := tc.record(root, event); err != nil {
+		tracef("could not record telemetry: %[1]v (cmd=%[2]q)", err, cmd.Name)
+	}
+}
+
+// setFlagNames returns the sorted names of the flags of the command and its
+// parents which were set on the command line or from sources which have
+// been read already. Sources are not read for the event.
+func (cmd *Command) setFlagNames() []string {
+	var names []string
+	for _, pCmd := range cmd.Lineage() {
+		for _, fl := range pCmd.Flags {
+			if fl == HelpFlag || fl == VersionFlag || len(fl.Names()) == 0 {
+				continue
+			}
+			if name := fl.Names()[0]; pCmd.isSetResolved(name) && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func errorClass(err error) string {
+	if ec, ok := err.(ExitCategorizer); ok && ec.Category() != "" {
+		return ec.Category()
+	}
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = inner
+	}
+}
+
+// lock takes the lock of the queue, as other instances may read and write
+// it at the same time
+func (tc *TelemetryConfig) lock(root *Command) (string, func(), error) {
+	dir, err := tc.dir(root)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", nil, err
+	}
+
+	unlock, err := lockFile(filepath.Join(dir, telemetryLockFile), telemetryLockTimeout)
+	if err != nil {
+		return "", nil, err
+	}
+	return dir, unlock, nil
+}
+
+// record appends the event to the queue, dropping the oldest events if
+// there are more than MaxQueued
+func (tc *TelemetryConfig) record(root *Command, event TelemetryEvent) error {
+	dir, unlock, err := tc.lock(root)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	path := filepath.Join(dir, telemetryQueueFile)
+	events, err := readTelemetryQueue(path)
+	if err != nil {
+		return err
+	}
+	events = append(events, event)
+
+	maxQueued := tc.MaxQueued
+	if maxQueued <= 0 {
+		maxQueued = defaultTelemetryMaxQueued
+	}
+	if len(events) > maxQueued {
+		events = events[len(events)-maxQueued:]
+	}
+	return writeTelemetryQueue(path, events)
+}
+
+// due returns the queued events if there are at least FlushAt and sending
+// is not backed off
+func (tc *TelemetryConfig) due(root *Command) ([]TelemetryEvent, error) {
+	dir, unlock, err := tc.lock(root)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	events, err := readTelemetryQueue(filepath.Join(dir, telemetryQueueFile))
+	if err != nil {
+		return nil, err
+	}
+
+	flushAt := tc.FlushAt
+	if flushAt <= 0 {
+		flushAt = defaultTelemetryFlushAt
+	}
+	backoff := readTelemetryBackoff(filepath.Join(dir, telemetryBackoffFile))
+	if len(events) < flushAt || root.now().Before(backoff.Until) {
+		return nil, nil
+	}
+	return events, nil
+}
+
+// send sends the events and then removes them from the queue in dir,
+// keeping the events queued meanwhile. If sending fails, it is backed off
+// from now.
+func (tc *TelemetryConfig) send(dir string, now time.Time, events []TelemetryEvent) {
+	timeout := tc.Timeout
+	if timeout <= 0 {
+		timeout = defaultTelemetryTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	sendErr := tc.Transport.Send(ctx, events)
+
+	unlock, err := lockFile(filepath.Join(dir, telemetryLockFile), telemetryLockTimeout)
+	if err != nil {
+		tracef("could not update telemetry queue: %[1]v", err)
+		return
+	}
+	defer unlock()
+
+	backoffPath := filepath.Join(dir, telemetryBackoffFile)
+	if sendErr != nil {
+		backoff := readTelemetryBackoff(backoffPath)
+		backoff.Failures++
+		backoff.Until = now.Add(tc.backoff(backoff.Failures))
+		tracef("could not send %[1]d telemetry events, not trying again until %[2]v: %[3]v", len(events), backoff.Until, sendErr)
+		if err := writeTelemetryBackoff(backoffPath, backoff); err != nil {
+			tracef("could not write telemetry backoff: %[1]v", err)
+		}
+		return
+	}
+
+	tracef("sent %[1]d telemetry events", len(events))
+	_ = os.Remove(backoffPath)
+	if err := removeTelemetryEvents(filepath.Join(dir, telemetryQueueFile), events); err != nil {
+		tracef("could not update telemetry queue: %[1]v", err)
+	}
+}
+
+// removeTelemetryEvents removes the sent events from the queue
+func removeTelemetryEvents(path string, sent []TelemetryEvent) error {
+	events, err := readTelemetryQueue(path)
+	if err != nil {
+		return err
+	}
+
+	// events are compared by their encoding, as they were read back from
+	// the queue by other instances
+	count := map[string]int{}
+	for _, event := range sent {
+		if data, err := json.Marshal(event); err == nil {
+			count[string(data)]++
+		}
+	}
+	events = slices.DeleteFunc(events, func(event TelemetryEvent) bool {
+		data, err := json.Marshal(event)
+		if err != nil || count[string(data)] == 0 {
+			return false
+		}
+		count[string(data)]--
+		return true
+	})
+
+	if len(events) == 0 {
+		if err := os.Remove(path); !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return writeTelemetryQueue(path, events)
+}
+
+// backoff returns how long sending is not tried again after the given
+// number of consecutive failures
+func (tc *TelemetryConfig) backoff(failures int) time.Duration {
+	backoff := tc.Backoff
+	if backoff <= 0 {
+		backoff = defaultTelemetryBackoff
+	}
+	for i := 1; i < failures && backoff < maxTelemetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxTelemetryBackoff)
+}
+
+func readTelemetryBackoff(path string) telemetryBackoff {
+	var backoff telemetryBackoff
+	if data, err := os.ReadFile(path); err == nil {
+		// start over if the file cannot be read
+		_ = json.Unmarshal(data, &backoff)
+	}
+	return backoff
+}
+
+func writeTelemetryBackoff(path string, backoff telemetryBackoff) error {
+	data, err := json.Marshal(backoff)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func readTelemetryQueue(path string) ([]TelemetryEvent, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var events []TelemetryEvent
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var event TelemetryEvent
+		// skip lines which cannot be read, e.g. from an older version
+		if err := json.Unmarshal(scanner.Bytes(), &event); err == nil {
+			events = append(events, event)
+		}
+	}
+	return events, scanner.Err()
+}
+
+func writeTelemetryQueue(path string, events []TelemetryEvent) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o600)
+}
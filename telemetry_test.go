@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Telemetry(t *testing.T) {
+	t.Setenv("DO_NOT_TRACK", "")
+	t.Setenv("APP_TELEMETRY", "")
+
+	dir := t.TempDir()
+	var (
+		sent    [][]TelemetryEvent
+		sendErr error
+		tries   int
+		out     bytes.Buffer
+		now     = time.Now()
+	)
+	tc := &TelemetryConfig{
+		Dir:     dir,
+		FlushAt: 2,
+		Transport: TelemetryTransportFunc(func(_ context.Context, events []TelemetryEvent) error {
+			tries++
+			if sendErr != nil {
+				return sendErr
+			}
+			sent = append(sent, events)
+			return nil
+		}),
+	}
+	newCmd := func() *Command {
+		return &Command{
+			Name:           "app",
+			Version:        "1.0.0",
+			Writer:         &out,
+			Clock:          func() time.Time { return now },
+			Telemetry:      tc,
+			ExitErrHandler: func(context.Context, *Command, error) {},
+			Flags: []Flag{
+				&StringFlag{Name: "token", Aliases: []string{"t"}},
+				&IntFlag{Name: "retries", Sources: EnvVars("APP_RETRIES")},
+			},
+			Commands: []*Command{
+				TelemetryCommand(),
+				{
+					Name: "deploy",
+					Flags: []Flag{
+						&BoolFlag{Name: "force"},
+						&StringFlag{Name: "region", Sources: EnvVars("APP_REGION")},
+					},
+					Action: func(_ context.Context, cmd *Command) error {
+						if cmd.Bool("force") {
+							return ExitWithCategory("forced", 2, "usage")
+						}
+						return nil
+					},
+				},
+			},
+		}
+	}
+	run := func(args ...string) error {
+		out.Reset()
+		return newCmd().Run(buildTestContext(t), append([]string{"app"}, args...))
+	}
+
+	// opt-in
+	require.NoError(t, run("deploy"))
+	assert.NoFileExists(t, filepath.Join(dir, "queue.jsonl"))
+	require.NoError(t, run("telemetry", "status"))
+	assert.Equal(t, "telemetry is off\n", out.String())
+
+	require.NoError(t, run("telemetry", "on"))
+	assert.Equal(t, "telemetry is on\n", out.String())
+
+	// queued until FlushAt events
+	require.NoError(t, run("--token", "secret", "deploy"))
+	assert.Empty(t, sent)
+	queued, err := readTelemetryQueue(filepath.Join(dir, "queue.jsonl"))
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+	assert.Equal(t, "app deploy", queued[0].Command)
+	assert.Equal(t, []string{"token"}, queued[0].Flags)
+
+	assert.Error(t, run("deploy", "--force"))
+	assert.Empty(t, sent)
+
+	// sent while the next command runs, which is queued
+	t.Setenv("APP_REGION", "eu")
+	require.NoError(t, run("deploy"))
+	require.Len(t, sent, 1)
+	require.Len(t, sent[0], 2)
+	event := sent[0][1]
+	assert.Equal(t, "app deploy", event.Command)
+	assert.Equal(t, []string{"force"}, event.Flags)
+	assert.Equal(t, 2, event.ExitCode)
+	assert.Equal(t, "usage", event.ErrorClass)
+	assert.Equal(t, "1.0.0", event.Version)
+
+	// flags set from sources are only reported once they were read, the
+	// region is not read as the command failed on the retries before
+	t.Setenv("APP_RETRIES", "many")
+	require.Error(t, run("deploy"))
+	require.NoError(t, os.Unsetenv("APP_RETRIES"))
+	require.NoError(t, os.Unsetenv("APP_REGION"))
+	queued, err = readTelemetryQueue(filepath.Join(dir, "queue.jsonl"))
+	require.NoError(t, err)
+	require.Len(t, queued, 2)
+	assert.Equal(t, []string{"region"}, queued[0].Flags)
+	assert.Empty(t, queued[1].Flags)
+	assert.Equal(t, 1, queued[1].ExitCode)
+
+	// kept while they cannot be sent
+	sendErr = errors.New("offline")
+	require.NoError(t, run("deploy"))
+	require.NoError(t, run("deploy"))
+	queued, err = readTelemetryQueue(filepath.Join(dir, "queue.jsonl"))
+	require.NoError(t, err)
+	assert.Len(t, queued, 4)
+	assert.Equal(t, 2, tries)
+
+	// not tried again until the backoff passed, then doubled
+	now = now.Add(time.Hour)
+	require.NoError(t, run("deploy"))
+	assert.Equal(t, 3, tries)
+	now = now.Add(time.Hour)
+	require.NoError(t, run("deploy"))
+	assert.Equal(t, 3, tries)
+	now = now.Add(time.Hour)
+	sendErr = nil
+	require.NoError(t, run("deploy"))
+	assert.Equal(t, 4, tries)
+	require.Len(t, sent, 2)
+	assert.Len(t, sent[1], 6)
+	assert.NoFileExists(t, filepath.Join(dir, "backoff.json"))
+	queued, err = readTelemetryQueue(filepath.Join(dir, "queue.jsonl"))
+	require.NoError(t, err)
+	assert.Len(t, queued, 1)
+
+	sendErr = errors.New("offline")
+	require.NoError(t, run("deploy"))
+	require.NoError(t, run("deploy"))
+	assert.FileExists(t, filepath.Join(dir, "backoff.json"))
+
+	// env overrides
+	t.Setenv("APP_TELEMETRY", "0")
+	require.NoError(t, run("telemetry", "status"))
+	assert.Equal(t, "telemetry is off by APP_TELEMETRY\n", out.String())
+	t.Setenv("APP_TELEMETRY", "1")
+	t.Setenv("DO_NOT_TRACK", "1")
+	require.NoError(t, run("telemetry", "status"))
+	assert.Equal(t, "telemetry is off by DO_NOT_TRACK\n", out.String())
+
+	// opt-out drops queued events
+	t.Setenv("DO_NOT_TRACK", "")
+	t.Setenv("APP_TELEMETRY", "")
+	require.NoError(t, run("telemetry", "off"))
+	assert.Equal(t, "telemetry is off\n", out.String())
+	assert.NoFileExists(t, filepath.Join(dir, "queue.jsonl"))
+	assert.NoFileExists(t, filepath.Join(dir, "backoff.json"))
+	require.NoError(t, run("deploy"))
+	_, err = os.Stat(filepath.Join(dir, "queue.jsonl"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCommand_TelemetryDoesNotBlockExit(t *testing.T) {
+	t.Setenv("DO_NOT_TRACK", "")
+	t.Setenv("APP_TELEMETRY", "1")
+
+	release := make(chan struct{})
+	sent := make(chan []TelemetryEvent, 1)
+	dir := t.TempDir()
+	tc := &TelemetryConfig{
+		Dir:     dir,
+		FlushAt: 1,
+		Transport: TelemetryTransportFunc(func(_ context.Context, events []TelemetryEvent) error {
+			<-release
+			sent <- events
+			return nil
+		}),
+	}
+	run := func() {
+		cmd := &Command{Name: "app", Telemetry: tc, Action: func(context.Context, *Command) error { return nil }}
+		start := time.Now()
+		require.NoError(t, cmd.Run(buildTestContext(t), []string{"app"}))
+		assert.Less(t, time.Since(start), telemetryExitWait+time.Second)
+	}
+
+	run()
+	run()
+	assert.Empty(t, sent, "exited before the events were sent")
+
+	close(release)
+	select {
+	case events := <-sent:
+		assert.Len(t, events, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("events were not sent")
+	}
+
+	// the sent event is removed from the queue in the background
+	assert.Eventually(t, func() bool {
+		queued, err := readTelemetryQueue(filepath.Join(dir, "queue.jsonl"))
+		return err == nil && len(queued) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
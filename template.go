@@ -2,7 +2,7 @@ package cli
 
 var (
 	helpNameTemplate    = `{{$v := offset .FullName 6}}{{wrap .FullName 3}}{{if .Usage}} - {{wrap .Usage $v}}{{end}}`
-	argsTemplate        = `{{if .Arguments}}{{range .Arguments}}{{.Usage}}{{end}}{{end}}`
+	argsTemplate        = `{{if .Arguments}}{{range $i, $arg := .Arguments}}{{if $i}} {{end}}{{$arg.Usage}}{{end}}{{end}}`
 	usageTemplate       = `{{if .UsageText}}{{wrap .UsageText 3}}{{else}}{{.FullName}}{{if .VisibleFlags}} [command [command options]]{{end}}{{if .ArgsUsage}} {{.ArgsUsage}}{{else}}{{if .Arguments}} {{template "argsTemplate" .}}{{end}}{{end}}{{end}}`
 	descriptionTemplate = `{{wrap .Description 3}}`
 	authorsTemplate     = `{{with $length := len .Authors}}{{if ne 1 $length}}S{{end}}{{end}}:
@@ -15,7 +15,9 @@ var visibleCommandTemplate = `{{ $cv := offsetCommands .VisibleCommands 5}}{{ran
 
 var visibleCommandCategoryTemplate = `{{range .VisibleCategories}}{{if .Name}}
 
-   {{.Name}}:{{range .VisibleCommands}}
+   {{.Name}}:{{if .Description}}
+   {{wrap .Description 3}}
+{{end}}{{range .VisibleCommands}}
      {{join .Names ", "}}{{"\t"}}{{.Usage}}{{end}}{{else}}{{template "visibleCommandTemplate" .}}{{end}}{{end}}`
 
 var visibleFlagCategoryTemplate = `{{range .VisibleFlagCategories}}
@@ -38,6 +40,16 @@ VERSION:
 
 var copyrightTemplate = `{{wrap .Copyright 3}}`
 
+var helpTopicsTemplate = `{{range .HelpTopics}}
+   {{.Name}}{{"\t"}}{{.Usage}}{{end}}`
+
+// HelpTopicTemplate is the text template for help topics shown with
+// "help TOPIC", see Command.HelpTopics.
+var HelpTopicTemplate = `{{.Name}}{{if .Usage}} - {{.Usage}}{{end}}
+
+{{trim .Text}}
+`
+
 // RootCommandHelpTemplate is the text template for the Default help topic.
 // cli.go uses text/template to render templates. You can
 // render custom help text by setting this variable.
@@ -56,7 +68,9 @@ DESCRIPTION:
 
 AUTHOR{{template "authorsTemplate" .}}{{end}}{{if .VisibleCommands}}
 
-COMMANDS:{{template "visibleCommandCategoryTemplate" .}}{{end}}{{if .VisibleFlagCategories}}
+COMMANDS:{{template "visibleCommandCategoryTemplate" .}}{{end}}{{if .HelpTopics}}
+
+ADDITIONAL TOPICS:{{template "helpTopicsTemplate" .}}{{end}}{{if .VisibleFlagCategories}}
 
 GLOBAL OPTIONS:{{template "visibleFlagCategoryTemplate" .}}{{else if .VisibleFlags}}
 
@@ -103,7 +117,9 @@ CATEGORY:
 DESCRIPTION:
    {{template "descriptionTemplate" .}}{{end}}{{if .VisibleCommands}}
 
-COMMANDS:{{template "visibleCommandTemplate" .}}{{end}}{{if .VisibleFlagCategories}}
+COMMANDS:{{template "visibleCommandTemplate" .}}{{end}}{{if .HelpTopics}}
+
+ADDITIONAL TOPICS:{{template "helpTopicsTemplate" .}}{{end}}{{if .VisibleFlagCategories}}
 
 OPTIONS:{{template "visibleFlagCategoryTemplate" .}}{{else if .VisibleFlags}}
 
@@ -0,0 +1,26 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package cli
+
+import (
+	"errors"
+	"os"
+)
+
+// ttyPath is empty as the terminal of the process is not known
+const ttyPath = ""
+
+// disableEcho is not supported on this platform
+func disableEcho(*os.File) (func(), error) {
+	return nil, errors.New("hiding input is not supported on this platform")
+}
+
+// makeRaw is not supported on this platform
+func makeRaw(*os.File) (func(), error) {
+	return nil, errors.New("raw mode is not supported on this platform")
+}
+
+// terminalSize is not supported on this platform
+func terminalSize(*os.File) (int, int, error) {
+	return 0, 0, errors.New("the terminal size is not known on this platform")
+}
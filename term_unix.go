@@ -0,0 +1,68 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package cli
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ttyPath is the path of the terminal of the process
+const ttyPath = "/dev/tty"
+
+// disableEcho turns off echoing of the input of the terminal f and returns
+// a function turning it on again
+func disableEcho(f *os.File) (func(), error) {
+	return setTermios(f, func(t *syscall.Termios) {
+		t.Lflag &^= syscall.ECHO
+		t.Lflag |= syscall.ICANON | syscall.ISIG
+		t.Iflag |= syscall.ICRNL
+	})
+}
+
+// makeRaw puts the terminal f into raw mode, so every key is read as it is
+// pressed without echo, and returns a function restoring the previous mode
+func makeRaw(f *os.File) (func(), error) {
+	return setTermios(f, func(t *syscall.Termios) {
+		t.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+		t.Iflag &^= syscall.ICRNL | syscall.IXON
+		t.Cc[syscall.VMIN] = 1
+		t.Cc[syscall.VTIME] = 0
+	})
+}
+
+// setTermios changes the attributes of the terminal f and returns a function
+// restoring them
+func setTermios(f *os.File, change func(*syscall.Termios)) (func(), error) {
+	fd := f.Fd()
+
+	var old syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlGetTermios, uintptr(unsafe.Pointer(&old))); errno != 0 {
+		return nil, errno
+	}
+
+	t := old
+	change(&t)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlSetTermios, uintptr(unsafe.Pointer(&t))); errno != 0 {
+		return nil, errno
+	}
+
+	return func() {
+		_, _, _ = syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlSetTermios, uintptr(unsafe.Pointer(&old)))
+	}, nil
+}
+
+// winsize is the size of a terminal as returned by TIOCGWINSZ
+type winsize struct {
+	rows, cols, xpixels, ypixels uint16
+}
+
+// terminalSize returns the width and height in characters of the terminal f
+func terminalSize(f *os.File) (int, int, error) {
+	var ws winsize
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); errno != 0 {
+		return 0, 0, errno
+	}
+	return int(ws.cols), int(ws.rows), nil
+}
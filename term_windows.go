@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ttyPath is the path of the console input of the process
+const ttyPath = "CONIN$"
+
+// console modes of the input
+const (
+	enableProcessedInput       = 0x1
+	enableLineInput            = 0x2
+	enableEchoInput            = 0x4
+	enableVirtualTerminalInput = 0x200
+)
+
+var (
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procSetConsoleMode             = kernel32.NewProc("SetConsoleMode")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+)
+
+// consoleScreenBufferInfo is the CONSOLE_SCREEN_BUFFER_INFO structure
+type consoleScreenBufferInfo struct {
+	size              [2]int16
+	cursorPosition    [2]int16
+	attributes        uint16
+	window            [4]int16 // left, top, right, bottom
+	maximumWindowSize [2]int16
+}
+
+// disableEcho turns off echoing of the input of the console f and returns a
+// function turning it on again
+func disableEcho(f *os.File) (func(), error) {
+	return setConsoleMode(f, func(mode uint32) uint32 {
+		return mode &^ enableEchoInput
+	})
+}
+
+// makeRaw puts the console f into raw mode, so every key is read as it is
+// pressed without echo and arrow keys as escape sequences, and returns a
+// function restoring the previous mode
+func makeRaw(f *os.File) (func(), error) {
+	return setConsoleMode(f, func(mode uint32) uint32 {
+		return mode&^(enableProcessedInput|enableLineInput|enableEchoInput) | enableVirtualTerminalInput
+	})
+}
+
+// setConsoleMode changes the mode of the console f and returns a function
+// restoring it
+func setConsoleMode(f *os.File, change func(uint32) uint32) (func(), error) {
+	h := syscall.Handle(f.Fd())
+
+	var mode uint32
+	if err := syscall.GetConsoleMode(h, &mode); err != nil {
+		return nil, err
+	}
+
+	setMode := func(mode uint32) error {
+		if r, _, err := procSetConsoleMode.Call(uintptr(h), uintptr(mode)); r == 0 {
+			return err
+		}
+		return nil
+	}
+	if err := setMode(change(mode)); err != nil {
+		return nil, err
+	}
+
+	return func() { _ = setMode(mode) }, nil
+}
+
+// terminalSize returns the width and height in characters of the visible
+// window of the console f
+func terminalSize(f *os.File) (int, int, error) {
+	var info consoleScreenBufferInfo
+	if r, _, err := procGetConsoleScreenBufferInfo.Call(f.Fd(), uintptr(unsafe.Pointer(&info))); r == 0 {
+		return 0, 0, err
+	}
+	return int(info.window[2]-info.window[0]) + 1, int(info.window[3]-info.window[1]) + 1, nil
+}
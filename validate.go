@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// Validate checks the command and its subcommands for mistakes in their
+// definition which would otherwise only show when they are run, and returns
+// all of them. It is meant to be called in a test of the root command:
+//
+//   - commands with the same name or alias as a sibling command
+//   - flags with the same name or alias in a command
+//   - flags shadowing a flag of a parent command which is not local
+//   - required flags with a default value, which are never missing
+//   - Categories defined for a category no subcommand is in, or twice
+//
+// Commands loaded lazily are not checked.
+func (cmd *Command) Validate() error {
+	var errs []error
+	cmd.validate(cmd.FullName(), nil, &errs)
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return newMultiError(errs...)
+}
+
+// validate checks the command at the path, inherited are the commands the
+// inherited flags are defined in by name
+func (cmd *Command) validate(path string, inherited map[string]string, errs *[]error) {
+	fail := func(format string, a ...any) {
+		*errs = append(*errs, fmt.Errorf("command %q: "+format, append([]any{path}, a...)...))
+	}
+
+	flags := make(map[string]bool)
+	for _, fl := range cmd.Flags {
+		for _, name := range fl.Names() {
+			if flags[name] {
+				fail("duplicate flag %q", name)
+			}
+			flags[name] = true
+			if parent, ok := inherited[name]; ok {
+				fail("flag %q shadows the flag of command %q", name, parent)
+			}
+		}
+
+		if rf, ok := fl.(RequiredFlag); ok && rf.IsRequired() {
+			if df, ok := fl.(interface{ configDefault() any }); ok {
+				if v := reflect.ValueOf(df.configDefault()); v.IsValid() && !v.IsZero() {
+					fail("required flag %q has a default value", fl.Names()[0])
+				}
+			}
+		}
+	}
+
+	commands := make(map[string]bool)
+	var categories []string
+	for _, sub := range cmd.Commands {
+		for _, name := range sub.Names() {
+			if commands[name] {
+				fail("duplicate command %q", name)
+			}
+			commands[name] = true
+		}
+		if sub.Category != "" {
+			categories = append(categories, sub.Category)
+		}
+	}
+
+	var defined []string
+	for _, def := range cmd.Categories {
+		if slices.Contains(defined, def.Name) {
+			fail("category %q is defined twice", def.Name)
+		}
+		defined = append(defined, def.Name)
+		if !slices.Contains(categories, def.Name) {
+			fail("category %q has no commands", def.Name)
+		}
+	}
+
+	// flags which are not local are inherited by the subcommands
+	subInherited := make(map[string]string, len(inherited))
+	for name, parent := range inherited {
+		subInherited[name] = parent
+	}
+	for _, fl := range cmd.Flags {
+		if lf, ok := fl.(LocalFlag); ok && lf.IsLocal() {
+			continue
+		}
+		for _, name := range fl.Names() {
+			subInherited[name] = path
+		}
+	}
+	for _, sub := range cmd.Commands {
+		sub.validate(path+" "+sub.Name, subInherited, errs)
+	}
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Validate(t *testing.T) {
+	valid := &Command{
+		Name:       "app",
+		Flags:      []Flag{&StringFlag{Name: "config", Local: true}, &BoolFlag{Name: "debug"}},
+		Categories: []CategoryDefinition{{Name: "admin"}},
+		Commands: []*Command{
+			{
+				Name:     "deploy",
+				Aliases:  []string{"d"},
+				Category: "admin",
+				Flags:    []Flag{&StringFlag{Name: "config"}, &StringFlag{Name: "env", Required: true}},
+			},
+			{Name: "status"},
+		},
+	}
+	require.NoError(t, valid.Validate())
+
+	invalid := &Command{
+		Name:       "app",
+		Flags:      []Flag{&BoolFlag{Name: "debug", Aliases: []string{"d"}}, &BoolFlag{Name: "dry-run", Aliases: []string{"d"}}},
+		Categories: []CategoryDefinition{{Name: "admin"}, {Name: "unused"}, {Name: "admin"}},
+		Commands: []*Command{
+			{
+				Name:     "deploy",
+				Category: "admin",
+				Flags:    []Flag{&StringFlag{Name: "env", Value: "prod", Required: true}},
+				Commands: []*Command{
+					{Name: "now", Flags: []Flag{&BoolFlag{Name: "debug"}}},
+				},
+			},
+			{Name: "status", Aliases: []string{"deploy"}},
+		},
+	}
+	err := invalid.Validate()
+	require.Error(t, err)
+	assert.Equal(t, `command "app": duplicate flag "d"
+command "app": duplicate command "deploy"
+command "app": category "unused" has no commands
+command "app": category "admin" is defined twice
+command "app deploy": required flag "env" has a default value
+command "app deploy now": flag "debug" shadows the flag of command "app"`, err.Error())
+}
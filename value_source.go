@@ -1,8 +1,10 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -17,6 +19,14 @@ type ValueSource interface {
 	Lookup() (string, bool)
 }
 
+// ErrValueSource is implemented by value sources whose lookup can fail,
+// e.g. a file named by an env var. An error fails the command
+// instead of the value being looked up in the next source.
+type ErrValueSource interface {
+	// LookupErr is like Lookup but also returns the error of the lookup
+	LookupErr() (string, bool, error)
+}
+
 // EnvValueSource is to specifically detect env sources when
 // printing help text
 type EnvValueSource interface {
@@ -37,6 +47,14 @@ type MapSource interface {
 	Lookup(string) (any, bool)
 }
 
+// ErrMapSource is implemented by map sources whose lookup can fail, e.g. a
+// cliremote.RemoteSource. An error fails the command instead of
+// the value being looked up in the next source.
+type ErrMapSource interface {
+	// LookupErr is like Lookup but also returns the error of the lookup
+	LookupErr(key string) (any, bool, error)
+}
+
 // ValueSourceChain contains an ordered series of ValueSource that
 // allows for lookup where the first ValueSource to resolve is
 // returned
@@ -92,13 +110,49 @@ func (vsc *ValueSourceChain) Lookup() (string, bool) {
 }
 
 func (vsc *ValueSourceChain) LookupWithSource() (string, ValueSource, bool) {
+	value, src, found, _ := vsc.lookupWithSourceErr(context.Background())
+	return value, src, found
+}
+
+// contextValueSource is implemented by sources whose lookup can be canceled
+// with a context, like a map source reading from a remote backend
+type contextValueSource interface {
+	lookupContext(ctx context.Context) (string, bool, error)
+}
+
+// lookupWithSourceErr is like LookupWithSource but stops at the first
+// source failing with an error. Sources reading from a remote backend do so
+// with the context.
+func (vsc *ValueSourceChain) lookupWithSourceErr(ctx context.Context) (string, ValueSource, bool, error) {
 	for _, src := range vsc.Chain {
+		if cs, ok := src.(contextValueSource); ok {
+			value, found, err := cs.lookupContext(ctx)
+			if err != nil {
+				return "", src, false, err
+			}
+			if found {
+				return value, src, true, nil
+			}
+			continue
+		}
+
+		if es, ok := src.(ErrValueSource); ok {
+			value, found, err := es.LookupErr()
+			if err != nil {
+				return "", src, false, err
+			}
+			if found {
+				return value, src, true, nil
+			}
+			continue
+		}
+
 		if value, found := src.Lookup(); found {
-			return value, src, true
+			return value, src, true, nil
 		}
 	}
 
-	return "", nil, false
+	return "", nil, false, nil
 }
 
 // envVarValueSource encapsulates a ValueSource from an environment variable
@@ -141,6 +195,62 @@ func EnvVars(keys ...string) ValueSourceChain {
 	return vsc
 }
 
+// envFileSuffix is appended to env vars naming a file to read
+// the value from
+const envFileSuffix = "_FILE"
+
+// envFileValueSource encapsulates a ValueSource from a file whose path
+// is given by an environment variable
+type envFileValueSource struct {
+	key string
+}
+
+func (e *envFileValueSource) Lookup() (string, bool) {
+	v, ok, _ := e.LookupErr()
+	return v, ok
+}
+
+// LookupErr returns an error if the env var is set but the file it names
+// cannot be read, so a secret which is meant to be read from the file does
+// not silently fall back to another source or the default value
+func (e *envFileValueSource) LookupErr() (string, bool, error) {
+	path, ok := os.LookupEnv(strings.TrimSpace(e.key))
+	if !ok || path == "" {
+		return "", false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+func (e *envFileValueSource) IsFromEnv() bool {
+	return true
+}
+
+func (e *envFileValueSource) Key() string {
+	return e.key
+}
+
+func (e *envFileValueSource) String() string {
+	return fmt.Sprintf("file from environment variable %[1]q", e.key)
+}
+
+func (e *envFileValueSource) GoString() string {
+	return fmt.Sprintf("&envFileValueSource{Key:%[1]q}", e.key)
+}
+
+// EnvFile returns a ValueSource reading the contents of the file whose
+// path is given by the env var key, with trailing newlines removed
+func EnvFile(key string) ValueSource {
+	return &envFileValueSource{
+		key: key,
+	}
+}
+
 // fileValueSource encapsulates a ValueSource from a file
 type fileValueSource struct {
 	Path string
@@ -172,6 +282,67 @@ func Files(paths ...string) ValueSourceChain {
 	return vsc
 }
 
+// dirSource encapsulates a MapSource reading files from a directory
+type dirSource struct {
+	dir string
+}
+
+// Dir returns a MapSource looking up the contents of the file named by the
+// key in the directory, with trailing newlines removed, e.g. for secrets
+// and config maps mounted by Kubernetes
+func Dir(dir string) MapSource {
+	return &dirSource{dir: dir}
+}
+
+func (ds *dirSource) String() string { return fmt.Sprintf("directory %[1]q", ds.dir) }
+func (ds *dirSource) GoString() string {
+	return fmt.Sprintf("&dirSource{dir:%[1]q}", ds.dir)
+}
+
+func (ds *dirSource) Lookup(name string) (any, bool) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(filepath.Join(ds.dir, name))
+	if err != nil {
+		return nil, false
+	}
+	return strings.TrimRight(string(data), "\r\n"), true
+}
+
+// funcSource encapsulates a MapSource looking up values with a function
+type funcSource struct {
+	name string
+	fn   func(key string) (string, bool, error)
+}
+
+// NewFuncSource returns a MapSource looking up values with fn, e.g. to read
+// flag values from a secret store like Vault, AWS SSM or the 1Password CLI.
+// An error returned by fn fails the command, fn should return false to look
+// up the value in the next source instead.
+func NewFuncSource(name string, fn func(key string) (string, bool, error)) MapSource {
+	return &funcSource{name: name, fn: fn}
+}
+
+func (fs *funcSource) String() string { return fs.name }
+func (fs *funcSource) GoString() string {
+	return fmt.Sprintf("&funcSource{name:%[1]q}", fs.name)
+}
+
+func (fs *funcSource) Lookup(key string) (any, bool) {
+	v, ok, _ := fs.LookupErr(key)
+	return v, ok
+}
+
+func (fs *funcSource) LookupErr(key string) (any, bool, error) {
+	v, ok, err := fs.fn(key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
 type mapSource struct {
 	name string
 	m    map[any]any
@@ -248,6 +419,37 @@ func (mvs *mapValueSource) GoString() string {
 	return fmt.Sprintf("&mapValueSource{key:%[1]q, src:%[2]s}", mvs.key, mvs.ms.GoString())
 }
 
+func (mvs *mapValueSource) LookupErr() (string, bool, error) {
+	es, ok := mvs.ms.(ErrMapSource)
+	if !ok {
+		v, found := mvs.Lookup()
+		return v, found, nil
+	}
+
+	v, found, err := es.LookupErr(mvs.key)
+	if err != nil || !found {
+		return "", false, err
+	}
+	return fmt.Sprintf("%+v", v), true, nil
+}
+
+// lookupContext is like LookupErr but passes the context to map sources
+// with a LookupContext method
+func (mvs *mapValueSource) lookupContext(ctx context.Context) (string, bool, error) {
+	cs, ok := mvs.ms.(interface {
+		LookupContext(ctx context.Context, key string) (any, bool, error)
+	})
+	if !ok {
+		return mvs.LookupErr()
+	}
+
+	v, found, err := cs.LookupContext(ctx, mvs.key)
+	if err != nil || !found {
+		return "", false, err
+	}
+	return fmt.Sprintf("%+v", v), true, nil
+}
+
 func (mvs *mapValueSource) Lookup() (string, bool) {
 	if v, ok := mvs.ms.Lookup(mvs.key); !ok {
 		return "", false
@@ -1,10 +1,13 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -115,6 +118,52 @@ func TestFileValueSource(t *testing.T) {
 	})
 }
 
+func TestEnvFileValueSource(t *testing.T) {
+	r := require.New(t)
+
+	fileName := filepath.Join(t.TempDir(), "token")
+	r.NoError(os.WriteFile(fileName, []byte("s3cr3t\n"), 0o600))
+
+	src := EnvFile("APP_TOKEN_FILE")
+	r.Implements((*EnvValueSource)(nil), src)
+	r.Equal("file from environment variable \"APP_TOKEN_FILE\"", src.String())
+	r.Equal("&envFileValueSource{Key:\"APP_TOKEN_FILE\"}", src.GoString())
+
+	t.Run("not set", func(t *testing.T) {
+		_, ok := src.Lookup()
+		require.False(t, ok)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("APP_TOKEN_FILE", filepath.Join(t.TempDir(), "nope"))
+		_, ok := src.Lookup()
+		require.False(t, ok)
+
+		_, ok, err := src.(ErrValueSource).LookupErr()
+		require.False(t, ok)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+
+		cmd := &Command{
+			Flags: []Flag{
+				&StringFlag{Name: "token", Value: "default", Sources: NewValueSourceChain(src)},
+			},
+			Action: func(_ context.Context, cmd *Command) error {
+				_ = cmd.String("token")
+				return nil
+			},
+		}
+		err = cmd.Run(buildTestContext(t), []string{"app"})
+		require.ErrorContains(t, err, `could not read value for flag token from file from environment variable "APP_TOKEN_FILE"`)
+	})
+
+	t.Run("found", func(t *testing.T) {
+		t.Setenv("APP_TOKEN_FILE", fileName)
+		str, ok := src.Lookup()
+		require.True(t, ok)
+		require.Equal(t, "s3cr3t", str)
+	})
+}
+
 func TestFilePaths(t *testing.T) {
 	r := require.New(t)
 
@@ -346,3 +395,37 @@ func TestMapValueSourceStringer(t *testing.T) {
 	assert.Equal(t, `&mapValueSource{key:"bar", src:&mapSource{name:"test"}}`, mvs.GoString())
 	assert.Equal(t, `key "bar" from map source "test"`, mvs.String())
 }
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "token"), []byte("secret\r\n"), 0o600))
+
+	src := Dir(dir)
+	v, ok := src.Lookup("token")
+	assert.True(t, ok)
+	assert.Equal(t, "secret", v)
+
+	_, ok = src.Lookup("missing")
+	assert.False(t, ok)
+	_, ok = src.Lookup("../token")
+	assert.False(t, ok)
+
+	assert.Equal(t, `directory "`+dir+`"`, src.String())
+	assert.Equal(t, `&dirSource{dir:"`+dir+`"}`, src.GoString())
+}
+
+func TestRegistry(t *testing.T) {
+	src := Registry(`HKLM\SOFTWARE\Microsoft\Windows NT\CurrentVersion`)
+	assert.Equal(t, `registry key "HKLM\\SOFTWARE\\Microsoft\\Windows NT\\CurrentVersion"`, src.String())
+
+	_, ok := src.Lookup("urfave-cli-missing")
+	assert.False(t, ok)
+	_, ok = Registry(`HKXX\SOFTWARE`).Lookup("ProductName")
+	assert.False(t, ok)
+
+	v, ok := src.Lookup("ProductName")
+	assert.Equal(t, runtime.GOOS == "windows", ok)
+	if ok {
+		assert.NotEmpty(t, v)
+	}
+}
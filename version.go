@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"sort"
+)
+
+// versionJSONFlagName is the name of the flag which prints the version as
+// JSON, both with --version and the command returned by VersionCommand
+const versionJSONFlagName = "json"
+
+var readBuildInfo = debug.ReadBuildInfo
+
+// VersionInfo is the version of the root command together with the build
+// metadata embedded into the binary by the go toolchain
+type VersionInfo struct {
+	Name      string            `json:"name"`
+	Version   string            `json:"version"`
+	Revision  string            `json:"revision,omitempty"`
+	Dirty     bool              `json:"dirty,omitempty"`
+	Time      string            `json:"time,omitempty"`
+	GoVersion string            `json:"goVersion,omitempty"`
+	Extra     map[string]string `json:"extra,omitempty"`
+}
+
+// VersionInfo returns the version of the root command, the build metadata
+// read with debug.ReadBuildInfo and the ExtraVersionInfo of the root command.
+// The version of the main module is used if the root command has no Version.
+func (cmd *Command) VersionInfo() VersionInfo {
+	root := cmd.Root()
+	info := VersionInfo{
+		Name:    root.Name,
+		Version: root.Version,
+		Extra:   root.ExtraVersionInfo,
+	}
+
+	bi, ok := readBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.GoVersion = bi.GoVersion
+	if info.Version == "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.modified":
+			info.Dirty = s.Value == "true"
+		case "vcs.time":
+			info.Time = s.Value
+		}
+	}
+
+	return info
+}
+
+// VersionCommand returns a version command printing the VersionInfo of the
+// root command, with the flags --short to print only the version and --json
+// to print it as JSON
+func VersionCommand() *Command {
+	return &Command{
+		Name:  "version",
+		Usage: "Print the version",
+		Flags: []Flag{
+			&BoolFlag{Name: "short", Usage: "print only the version"},
+			&BoolFlag{Name: versionJSONFlagName, Usage: "print the version as JSON"},
+		},
+		Action: func(_ context.Context, cmd *Command) error {
+			w := cmd.Root().Writer
+			info := cmd.VersionInfo()
+
+			switch {
+			case cmd.Bool(versionJSONFlagName):
+				return writeVersionJSON(cmd, info)
+			case cmd.Bool("short"):
+				_, _ = fmt.Fprintln(w, info.Version)
+			default:
+				_, _ = fmt.Fprintf(w, "%s version %s\n", info.Name, info.Version)
+				if info.Revision != "" {
+					dirty := ""
+					if info.Dirty {
+						dirty = " (dirty)"
+					}
+					_, _ = fmt.Fprintf(w, "revision: %s%s\n", info.Revision, dirty)
+				}
+				if info.Time != "" {
+					_, _ = fmt.Fprintf(w, "built: %s\n", info.Time)
+				}
+				if info.GoVersion != "" {
+					_, _ = fmt.Fprintf(w, "go: %s\n", info.GoVersion)
+				}
+				keys := make([]string, 0, len(info.Extra))
+				for k := range info.Extra {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					_, _ = fmt.Fprintf(w, "%s: %s\n", k, info.Extra[k])
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+// versionJSONRequested returns true if the root command has a json flag
+// which is set, e.g. when run with --version --json
+func versionJSONRequested(cmd *Command) bool {
+	root := cmd.Root()
+	if _, ok := root.lookupFlag(versionJSONFlagName).(*BoolFlag); !ok {
+		return false
+	}
+	return root.Bool(versionJSONFlagName)
+}
+
+func writeVersionJSON(cmd *Command, info VersionInfo) error {
+	enc := json.NewEncoder(cmd.Root().Writer)
+	enc.SetIndent("", "  ")
+	return enc.Encode(info)
+}